@@ -1,7 +1,11 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,46 +21,225 @@ var llmCmd = man.Docs.GetCommand("llm")
 
 var llmChatCmd = man.Docs.GetCommand("llm/chat", man.WithRun(func(cmd *cobra.Command, args []string) {
 	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
 	
 	if len(args) == 0 {
 		c.ExitWithError("Model path is required", nil)
 	}
 	
-	modelPath := args[0]
+	modelPath := resolveModelArg(c, args[0])
 	
 	// Get flag values
 	stream := c.Flags.GetOptionalBool("stream")
 	contextSize := int(c.Flags.GetOptionalInt32("context-size"))
-	temperatureFlag, _ := cmd.Flags().GetFloat64("temperature")
-	temperature := temperatureFlag
-	systemPrompt := c.Flags.GetOptionalString("system-prompt")
+	systemPrompt, err := systemPromptFromFlags(cmd)
+	if err != nil {
+		c.ExitWithError("Failed to read system prompt", err)
+	}
 	enableRAG := c.Flags.GetOptionalBool("rag")
-	indexPath := c.Flags.GetOptionalString("index-path")
+	indexPaths, _ := cmd.Flags().GetStringSlice("index-path")
+	embeddingModelPath := c.Flags.GetOptionalString("embedding-model")
+	titleBoost, _ := cmd.Flags().GetFloat32("rag-title-boost")
+	maxPerSource := int(c.Flags.GetOptionalInt32("rag-max-per-source"))
+	maxResults := int(c.Flags.GetOptionalInt32("rag-max-results"))
+	expandNeighbors := int(c.Flags.GetOptionalInt32("rag-expand-neighbors"))
+	queryTurns := int(c.Flags.GetOptionalInt32("rag-query-turns"))
+	noContextNote := c.Flags.GetOptionalBool("rag-no-context-note")
+	minConfidence, _ := cmd.Flags().GetFloat32("rag-min-confidence")
+	contextWarning, _ := cmd.Flags().GetFloat64("context-warning")
+	sampling := samplingOptionsFromFlags(cmd)
+	if sampling.StopOnRepeat < 0 {
+		c.ExitWithError("--stop-on-repeat must not be negative", nil)
+	}
+	if c.Flags.GetOptionalBool("cache") && !sampling.Deterministic() {
+		c.ExitWithError("--cache requires --greedy or --seed, since sampled responses differ from run to run", nil)
+	}
+	embeddingOpts := embeddingOptionsFromFlags(c, cmd)
+	modelParams, err := modelParamsFromFlags(cmd)
+	if err != nil {
+		c.ExitWithError("Invalid llama settings", err)
+	}
+	prompt := c.Flags.GetOptionalString("prompt")
+	outputPath := c.Flags.GetOptionalString("output")
+	appendOutput := c.Flags.GetOptionalBool("append")
+	exportPath := c.Flags.GetOptionalString("export")
+	plain := c.Flags.GetOptionalBool("plain")
+	out := newChatPrinter(c, plain)
+	
+	jsonLines := c.Flags.GetOptionalBool("json-lines")
+	inputPath := c.Flags.GetOptionalString("input")
+	if jsonLines && prompt != "" {
+		c.ExitWithError("--json-lines and --prompt can't be used together", nil)
+	}
+	if inputPath != "" && !jsonLines {
+		c.ExitWithError("--input requires --json-lines", nil)
+	}
+	if outputPath != "" && prompt == "" && !jsonLines {
+		c.ExitWithError("--output requires --prompt or --json-lines", nil)
+	}
+	var conversation []llm.ChatMessage
+	if jsonLines {
+		conversation, err = loadConversation(inputPath, systemPrompt)
+		if err != nil {
+			c.ExitWithError("Failed to read conversation", err)
+		}
+	}
+	if appendOutput && outputPath == "" {
+		c.ExitWithError("--append requires --output", nil)
+	}
+	if prompt != "" && llm.IsBlank(prompt) {
+		c.ExitWithError("--prompt must not be blank", nil)
+	}
+	historyLimit, _ := cmd.Flags().GetInt("history-limit")
+	if historyLimit < 0 {
+		c.ExitWithError("--history-limit must not be negative", nil)
+	}
+	if maxResults < 0 {
+		c.ExitWithError("--rag-max-results must not be negative", nil)
+	}
+	if queryTurns < 0 {
+		c.ExitWithError("--rag-query-turns must not be negative", nil)
+	}
+	if contextWarning < 0 || contextWarning > 1 {
+		c.ExitWithError("--context-warning must be between 0 and 1", nil)
+	}
+	renderMode, err := resolveRenderMode(c.Flags.GetOptionalString("render"), os.Stdout)
+	if err != nil {
+		c.ExitWithError("Invalid --render value", err)
+	}
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	markdown, err := newMarkdownRenderer(renderMode, os.Stdout, colorEnabled(noColor))
+	if err != nil {
+		c.ExitWithError("Failed to set up output rendering", err)
+	}
+	wrapWidth := int(c.Flags.GetOptionalInt32("width"))
+	if wrapWidth < 0 {
+		c.ExitWithError("--width must not be negative", nil)
+	}
+	if wrapWidth == 0 && c.Flags.GetOptionalBool("wrap") {
+		wrapWidth = terminalWidth(os.Stdout)
+	}
 	
 	// Initialize simple chat engine to avoid goroutine issues
 	simpleEngine := llm.NewSimpleChatEngine(modelPath)
+	simpleEngine.SetSamplingOptions(sampling)
+	simpleEngine.SetModelParams(modelParams)
+	simpleEngine.SetResponseCache(responseCache(cmd, sampling))
+	simpleEngine.SetLogger(llmLogger(cmd))
+	simpleEngine.SetContextWarning(contextWarning, os.Stderr)
+	if showPrompt(cmd) {
+		simpleEngine.SetPromptWriter(os.Stderr)
+	}
+	ragOptions := llm.RAGContextOptions{MaxPerSource: maxPerSource, MaxResults: maxResults, MinConfidence: minConfidence, ExpandNeighbors: expandNeighbors, QueryTurns: queryTurns, BareContext: c.Flags.GetOptionalBool("rag-bare-context")}
+	if noContextNote {
+		ragOptions.NoContextNote = llm.DefaultNoContextNote
+	}
+	simpleEngine.SetRAGOptions(ragOptions)
+	promptBudget := llm.PromptBudget{Total: int(c.Flags.GetOptionalInt32("prompt-budget"))}
+	promptBudget.ContextShare, _ = cmd.Flags().GetFloat64("rag-context-share")
+	if err := promptBudget.Validate(); err != nil {
+		c.ExitWithError("Invalid --prompt-budget or --rag-context-share", err)
+	}
+	simpleEngine.SetPromptBudget(promptBudget)
+	if c.Flags.GetOptionalBool("rerank") {
+		simpleEngine.EnableRerank(nil)
+	}
+	simpleEngine.SetContextSize(contextSize)
+	simpleEngine.SetBatchSize(int(c.Flags.GetOptionalInt32("batch-size")))
 	
-	// Set defaults for RAG if enabled
-	if enableRAG {
-		if indexPath == "" {
-			homeDir, _ := os.UserHomeDir()
-			indexPath = filepath.Join(homeDir, ".otdfctl", "simple_rag_index.json")
+	if titleBoost < 0 || titleBoost > 1 {
+		c.ExitWithError("--rag-title-boost must be between 0 and 1", nil)
+	}
+	
+	// An embedding model selects vector RAG; otherwise fall back to the keyword store
+	// searchAlso holds the keyword indexes after the first, which /ingest doesn't add to
+	var searchAlso []llm.KeywordStore
+	if enableRAG && embeddingModelPath != "" {
+		embeddingModelPath = resolveModelArg(c, embeddingModelPath)
+		
+		out.Printf("🔧 Initializing RAG support...\n")
+		
+		embeddingEngine, err := llm.NewEmbeddingEnginePool(embeddingModelPath, embeddingOpts)
+		if err != nil {
+			c.ExitWithError("Failed to initialize embedding engine", err)
+		}
+		defer embeddingEngine.Close()
+		embeddingEngine.SetLogger(llmLogger(cmd))
+		
+		// Load the vector indexes; with several they are searched as one store
+		vectorStores := newVectorStores(c, cmd, indexPaths)
+		vectorStore := vectorStores[0]
+		if len(vectorStores) > 1 {
+			vectorStore = llm.NewMultiStore(vectorStores...)
 		}
+		vectorStore.SetTitleBoost(titleBoost)
+		if err := vectorStore.LoadIndex(); err != nil {
+			c.ExitWithError("Failed to load vector index", err)
+		}
+		defer vectorStore.Close()
 		
-		c.Printf("🔧 Initializing Simple RAG support...\n")
+		if vectorStore.GetDocumentCount() == 0 {
+			out.Printf("⚠️  Warning: No documents found in vector index. Run 'otdfctl llm ingest' first.\n")
+		} else {
+			simpleEngine.EnableRAG(vectorStore, embeddingEngine)
+			out.Printf("✅ RAG enabled with %d documents\n", vectorStore.GetDocumentCount())
+		}
+	} else if enableRAG {
+		out.Printf("🔧 Initializing Simple RAG support...\n")
 		
-		// Load simple RAG store
-		simpleStore := llm.NewSimpleRAGStore(indexPath)
+		// Load the keyword indexes; with several they are searched as one, and /ingest adds to the first
+		keywordStores := newKeywordStores(c, cmd, indexPaths)
+		simpleStore := keywordStores[0]
+		if len(keywordStores) > 1 {
+			simpleStore = llm.NewMultiKeywordStore(keywordStores...)
+		}
+		searchAlso = keywordStores[1:]
 		if err := simpleStore.LoadIndex(); err != nil {
 			c.ExitWithError("Failed to load simple RAG index", err)
 		}
+		defer simpleStore.Close()
 		
 		if simpleStore.GetDocumentCount() == 0 {
-			c.Printf("⚠️  Warning: No documents found in simple RAG index. Run 'otdfctl llm ingest-simple' first.\n")
+			out.Printf("⚠️  Warning: No documents found in simple RAG index. Run 'otdfctl llm ingest-simple' first.\n")
 		} else {
 			// Enable simple RAG on the chat engine
 			simpleEngine.EnableSimpleRAG(simpleStore)
-			c.Printf("✅ Simple RAG enabled with %d documents\n", simpleStore.GetDocumentCount())
+			out.Printf("✅ Simple RAG enabled with %d documents\n", simpleStore.GetDocumentCount())
+		}
+	}
+	
+	if examplesFile := c.Flags.GetOptionalString("examples-file"); examplesFile != "" {
+		examples, err := llm.LoadExamples(examplesFile)
+		if err != nil {
+			c.ExitWithError("Failed to load few-shot examples", err)
+		}
+		simpleEngine.SetExamples(examples)
+		out.Printf("📚 Loaded %d few-shot examples\n", len(examples)/2)
+	}
+	
+	if contextFiles, _ := cmd.Flags().GetStringArray("context-file"); len(contextFiles) > 0 {
+		files, err := llm.LoadContextFiles(contextFiles)
+		if err != nil {
+			c.ExitWithError("Failed to read context file", err)
+		}
+		contextFileTokens, _ := cmd.Flags().GetInt("context-file-tokens")
+		if contextFileTokens < 1 {
+			c.ExitWithError("--context-file-tokens must be at least 1", nil)
+		}
+		fileContext := llm.BuildFileContext(files, contextFileTokens)
+		for _, path := range fileContext.Truncated {
+			out.Printf("⚠️  Warning: %s was cut short to fit --context-file-tokens\n", path)
+		}
+		simpleEngine.SetFileContext(fileContext.Text)
+		out.Printf("📎 Added %d context files\n", len(files))
+	}
+	
+	// /ingest grows the first keyword index; it isn't available when chat retrieves from a vector index
+	ingestIndexPath := ""
+	if !enableRAG || embeddingModelPath == "" {
+		ingestIndexPath = firstPath(indexPaths)
+		if ingestIndexPath == "" {
+			ingestIndexPath = defaultKeywordIndexPath(c.Flags.GetOptionalString("store"))
 		}
 	}
 	
@@ -66,14 +249,64 @@ var llmChatCmd = man.Docs.GetCommand("llm/chat", man.WithRun(func(cmd *cobra.Com
 	}
 	defer simpleEngine.Stop()
 	
+	// Answer a single prompt, or the next turn of a replayed conversation, and exit instead of
+	// starting the interactive session
+	if prompt != "" || conversation != nil {
+		jsonFlag, _ := cmd.Flags().GetBool("json")
+		var w io.Writer = os.Stdout
+		if jsonFlag {
+			w = io.Discard
+		} else if plain {
+			w = plainWriter{w: w}
+		}
+		opts := promptOptions{stream: stream && !jsonFlag, outputPath: outputPath, appendFile: appendOutput, exportPath: exportPath}
+		echoSystem := c.Flags.GetOptionalBool("echo-system-prompt")
+		if !jsonFlag {
+			opts.markdown = markdown
+			opts.wrapWidth = wrapWidth
+			opts.echo = c.Flags.GetOptionalBool("echo")
+			opts.echoSystem = echoSystem
+		}
+		var response llm.SimpleResponse
+		if conversation != nil {
+			// The message being answered stands in for the prompt in JSON output
+			prompt = conversation[len(conversation)-1].Content
+			response, err = runConversation(context.Background(), simpleEngine, w, conversation, opts)
+		} else {
+			response, err = runPrompt(context.Background(), simpleEngine, w, systemPrompt, prompt, opts)
+		}
+		if err != nil {
+			c.ExitWithError("Failed to generate response", err)
+		}
+		result := newPromptResult(prompt, response, outputPath)
+		if echoSystem {
+			result.SystemPrompt = systemPrompt
+			if conversation != nil {
+				result.SystemPrompt = conversation[0].Content
+			} else if systemPrompt == "" {
+				result.SystemPrompt = getDefaultSystemPrompt()
+			}
+		}
+		c.ExitWithJSON(result)
+		if outputPath != "" {
+			out.Printf("💾 Saved response to %s\n", outputPath)
+		}
+		if exportPath != "" {
+			out.Printf("📝 Saved transcript to %s\n", exportPath)
+		}
+		return
+	}
+	
 	// Check if JSON output is requested
 	if jsonFlag, _ := cmd.Flags().GetBool("json"); jsonFlag {
 		session := map[string]interface{}{
 			"model_path": modelPath,
 			"config": map[string]interface{}{
 				"stream":       stream,
-				"context_size": contextSize,
-				"temperature":  temperature,
+				"context_size": simpleEngine.ContextSize(),
+				"temperature":  sampling.Temperature,
+				"seed":         sampling.Seed,
+				"greedy":       sampling.Greedy,
 			},
 			"rag_enabled": enableRAG,
 			"status":      "initialized",
@@ -83,7 +316,21 @@ var llmChatCmd = man.Docs.GetCommand("llm/chat", man.WithRun(func(cmd *cobra.Com
 	}
 	
 	// Start interactive chat session
-	if err := startSimpleInteractiveChat(c, simpleEngine, systemPrompt, stream); err != nil {
+	sessionOpts := interactiveChatOptions{
+		systemPrompt:    systemPrompt,
+		stream:          stream,
+		ingestIndexPath: ingestIndexPath,
+		ingestStore:     c.Flags.GetOptionalString("store"),
+		markdown:        markdown,
+		wrapWidth:       wrapWidth,
+		exportPath:      exportPath,
+		stats:           c.Flags.GetOptionalBool("stats"),
+		plain:           plain,
+		summarize:       c.Flags.GetOptionalBool("summarize-history"),
+		historyLimit:    historyLimit,
+		searchAlso:      searchAlso,
+	}
+	if err := startSimpleInteractiveChat(out, simpleEngine, sessionOpts); err != nil {
 		c.ExitWithError("Failed to start chat session", err)
 	}
 }))
@@ -92,12 +339,51 @@ func init() {
 	// TODO: Fix flag documentation parsing and use proper doc-driven flags
 	// For POC, hardcode flags temporarily
 	llmChatCmd.Flags().Bool("stream", true, "Enable streaming responses")
-	llmChatCmd.Flags().Int32("context-size", 4096, "Maximum context window size")
-	llmChatCmd.Flags().Float64("temperature", 0.7, "Sampling temperature (0.0-1.0)")
-	llmChatCmd.Flags().String("system-prompt", "", "Custom system prompt")
+	llmChatCmd.Flags().Int32("context-size", 0, "Maximum context window size (0 uses the model's trained context size, up to 8192)")
+	llmChatCmd.Flags().Int32("prompt-budget", 0, "Estimated tokens each prompt may use, split between RAG context and conversation history by --rag-context-share (0 sends the whole history and up to 800 tokens of RAG context)")
+	llmChatCmd.Flags().Float64("rag-context-share", llm.DefaultContextShare, "With --prompt-budget, the fraction (0-1) of it RAG context may use; the history gets the rest")
+	llmChatCmd.Flags().Float64("context-warning", llm.DefaultContextWarning, "Warn on stderr when a prompt fills more than this fraction (0-1) of the context window (0 disables)")
+	llmChatCmd.Flags().Int32("batch-size", 512, "Prompt tokens decoded per pass; longer prompts take several passes")
+	addSamplingFlags(&llmChatCmd.Command)
+	addContextFlags(&llmChatCmd.Command)
+	llmChatCmd.Flags().StringArray("system-prompt", nil, "Custom system prompt; repeat to layer instructions, which are joined in order")
+	llmChatCmd.Flags().StringArray("system-prompt-file", nil, "File holding part of the system prompt, e.g. a reusable persona; repeatable, and placed before any --system-prompt values")
+	llmChatCmd.Flags().String("examples-file", "", "YAML or JSON file of user/assistant example pairs placed after the system prompt in every prompt to steer the answer format")
+	llmChatCmd.Flags().StringArray("context-file", nil, "File added to the system prompt for the whole session, e.g. a config or policy to ask about, without ingesting it; repeatable")
+	llmChatCmd.Flags().Int("context-file-tokens", llm.DefaultContextFileTokens, "Estimated token budget shared by the --context-file files; files past it are cut short")
 	llmChatCmd.Flags().Bool("rag", false, "Enable RAG (Retrieval-Augmented Generation)")
-	llmChatCmd.Flags().String("index-path", "", "Path to RAG vector index (default: ~/.otdfctl/rag_index.json)")
-	llmChatCmd.Flags().String("embedding-model", "", "Path to embedding model for RAG (default: same as chat model)")
+	llmChatCmd.Flags().StringSlice("index-path", nil, "Path to RAG index, repeatable or comma-separated to search several indexes together (default: ~/.otdfctl/rag_index.json with --embedding-model, or rag_index.db with --store sqlite, otherwise ~/.otdfctl/simple_rag_index.json)")
+	llmChatCmd.Flags().String("embedding-model", "", "Path to embedding model for vector RAG (default: keyword RAG)")
+	addStoreFlag(&llmChatCmd.Command)
+	llmChatCmd.Flags().Int32("rag-max-per-source", 0, "Maximum chunks from the same source document in RAG context (0 for no limit)")
+	llmChatCmd.Flags().Int32("rag-max-results", 0, "Maximum documents in RAG context, however much of the token budget is left (0 for no limit)")
+	llmChatCmd.Flags().Bool("rerank", false, "With --rag, have the model rate the top 20 retrieval candidates for relevance and keep the best (one short generation per candidate)")
+	llmChatCmd.Flags().Int32("rag-expand-neighbors", 0, "Add up to this many neighboring chunks on each side of each vector RAG match, from the same document, while they fit the context budget (0 disables)")
+	llmChatCmd.Flags().Int32("rag-query-turns", 1, "Search with the last this many user messages joined together, so follow-up questions keep the earlier topic")
+	llmChatCmd.Flags().Bool("rag-bare-context", false, "Give the model only the retrieved documents' text, without their titles, sources and relevance scores")
+	llmChatCmd.Flags().Bool("rag-no-context-note", false, "Tell the model when RAG finds no relevant documents so it says it is answering from general knowledge")
+	llmChatCmd.Flags().Float32("rag-min-confidence", llm.DefaultMinConfidence, "Warn that an answer may not be grounded when the best RAG match scores below this (0 disables)")
+	llmChatCmd.Flags().Float32("rag-title-boost", 0, "Weight (0-1) of document title similarity in vector RAG scores")
+	llmChatCmd.Flags().String("render", renderPlain, "Format responses: markdown renders headings, lists and code blocks once a response completes; plain prints raw text (markdown falls back to plain when output is not a terminal)")
+	llmChatCmd.Flags().Bool("wrap", false, "Word-wrap plain responses to the terminal width, leaving code blocks as they are")
+	llmChatCmd.Flags().Int32("width", 0, "Word-wrap plain responses to this many columns (implies --wrap; 0 uses the terminal width)")
+	llmChatCmd.Flags().Bool("no-color", false, "Disable syntax highlighting and colors in --render markdown output (also set by the NO_COLOR environment variable)")
+	llmChatCmd.Flags().String("prompt", "", "Answer this prompt and exit instead of starting an interactive session")
+	llmChatCmd.Flags().Bool("json-lines", false, "Replay a conversation of JSON Lines chat messages from --input and print the next assistant turn, then exit")
+	llmChatCmd.Flags().String("input", "", "With --json-lines, the conversation file to replay (default: stdin)")
+	llmChatCmd.Flags().String("output", "", "With --prompt or --json-lines, also save the response to this file (parent directories are created)")
+	llmChatCmd.Flags().Bool("echo", false, "With --prompt or --json-lines, print the question before its answer")
+	llmChatCmd.Flags().Bool("echo-system-prompt", false, "Like --echo, and print the system prompt before the question; with --json, adds it to the output")
+	llmChatCmd.Flags().Bool("append", false, "Append to the --output file instead of overwriting it")
+	llmChatCmd.Flags().String("export", "", "Save the conversation as a Markdown transcript to this file when the session ends")
+	llmChatCmd.Flags().Bool("plain", false, "Print plain text without emoji or the startup banner, for piping and logs")
+	llmChatCmd.Flags().Bool("cache", false, "Cache responses in ~/.otdfctl/llm_cache; requires --greedy or --seed, which turn caching on by default")
+	llmChatCmd.Flags().Bool("no-cache", false, "Neither read nor write the response cache")
+	llmChatCmd.Flags().Int("history-limit", 0, "Keep at most this many earlier turns, dropping the oldest first (0 keeps every turn that fits in the context)")
+	llmChatCmd.Flags().Bool("summarize-history", false, "When the conversation outgrows the context window, have the model summarize the oldest turns instead of letting them fall out of the prompt")
+	llmChatCmd.Flags().Bool("stats", false, "Show the number of tokens generated and tokens per second after each response")
+	llmChatCmd.Flags().Bool("show-prompt", false, "Print the full prompt sent to the model, including any RAG context, to stderr")
+	llmChatCmd.Flags().Bool("verbose", false, "Same as --show-prompt")
 	llmChatCmd.Flags().Bool("json", false, "Output in JSON format")
 	
 	// Add chat command to llm parent
@@ -107,56 +393,301 @@ func init() {
 	RootCmd.AddCommand(&llmCmd.Command)
 }
 
+// llmLogger returns the logger the llm package should report its internal diagnostics to.
+// In JSON mode diagnostics are discarded so stdout only contains the JSON document.
+func llmLogger(cmd *cobra.Command) llm.Logger {
+	if jsonFlag, _ := cmd.Flags().GetBool("json"); jsonFlag {
+		return llm.NopLogger()
+	}
+	return log.Default()
+}
+
+// showPrompt reports whether --show-prompt or its --verbose alias was set
+func showPrompt(cmd *cobra.Command) bool {
+	show, _ := cmd.Flags().GetBool("show-prompt")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	return show || verbose
+}
+
+// resolveModelArg resolves a model file path or Ollama model name, exiting if a model name is unknown
+func resolveModelArg(c *cli.Cli, modelRef string) string {
+	modelPath, err := llm.ResolveModelPath(modelRef)
+	if err != nil {
+		c.ExitWithError("Model not found", err)
+	}
+	return modelPath
+}
+
+// addSamplingFlags registers the flags that control token sampling, and --model-params for
+// the advanced llama settings
+func addSamplingFlags(cmd *cobra.Command) {
+	defaults := llm.DefaultSamplingOptions()
+	cmd.Flags().Float64("temperature", float64(defaults.Temperature), "Sampling temperature (0.0-1.0)")
+	cmd.Flags().Uint32("seed", defaults.Seed, "Sampler seed for reproducible output (0 uses a time-based seed)")
+	cmd.Flags().Bool("greedy", defaults.Greedy, "Use deterministic greedy decoding (temperature 0, top-k 1)")
+	cmd.Flags().Float32("repeat-penalty", defaults.RepeatPenalty, "Penalty applied to repeated tokens (1.0 disables)")
+	cmd.Flags().Float32("frequency-penalty", defaults.FrequencyPenalty, "Penalty proportional to how often a token has appeared (0.0 disables)")
+	cmd.Flags().Float32("presence-penalty", defaults.PresencePenalty, "Penalty for any token that has already appeared (0.0 disables)")
+	cmd.Flags().Int("repeat-last-n", defaults.RepeatLastN, "Number of recent tokens considered for repetition penalties")
+	cmd.Flags().Int("stop-on-repeat", defaults.StopOnRepeat, "Stop a response once its last N tokens repeat the same phrase over and over (0 disables)")
+	cmd.Flags().String("model-params", "", `JSON object of advanced llama settings, e.g. '{"use_mmap":false,"n_threads":8}'`)
+}
+
+// responseCache returns the cache chat answers repeated prompts from, or nil when caching is
+// off. Only deterministic sampling is cached, since a sampled response is just one of many the
+// prompt could get; --no-cache turns caching off.
+func responseCache(cmd *cobra.Command, sampling llm.SamplingOptions) *llm.ResponseCache {
+	disabled, _ := cmd.Flags().GetBool("no-cache")
+	if disabled || !sampling.Deterministic() {
+		return nil
+	}
+	homeDir, _ := os.UserHomeDir()
+	return llm.NewResponseCache(filepath.Join(homeDir, ".otdfctl", "llm_cache"))
+}
+
+// systemPromptFromFlags layers the --system-prompt-file contents and then the --system-prompt
+// values, each in the order given, into a single system prompt. It returns "" when neither
+// flag is set.
+func systemPromptFromFlags(cmd *cobra.Command) (string, error) {
+	files, _ := cmd.Flags().GetStringArray("system-prompt-file")
+	prompts, _ := cmd.Flags().GetStringArray("system-prompt")
+
+	var parts []string
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(data))
+	}
+	return llm.JoinSystemPrompts(append(parts, prompts...)...), nil
+}
+
+// samplingOptionsFromFlags reads the sampling flags registered by addSamplingFlags
+func samplingOptionsFromFlags(cmd *cobra.Command) llm.SamplingOptions {
+	opts := llm.DefaultSamplingOptions()
+
+	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	opts.Temperature = float32(temperature)
+	opts.Seed, _ = cmd.Flags().GetUint32("seed")
+	opts.Greedy, _ = cmd.Flags().GetBool("greedy")
+	opts.RepeatPenalty, _ = cmd.Flags().GetFloat32("repeat-penalty")
+	opts.FrequencyPenalty, _ = cmd.Flags().GetFloat32("frequency-penalty")
+	opts.PresencePenalty, _ = cmd.Flags().GetFloat32("presence-penalty")
+	opts.RepeatLastN, _ = cmd.Flags().GetInt("repeat-last-n")
+	opts.StopOnRepeat, _ = cmd.Flags().GetInt("stop-on-repeat")
+
+	return opts
+}
+
+// modelParamsFromFlags reads --model-params over the default llama settings. An explicit
+// --threads, --flash-attention or --kv-cache-type takes precedence over the matching setting.
+func modelParamsFromFlags(cmd *cobra.Command) (llm.ModelParams, error) {
+	data, _ := cmd.Flags().GetString("model-params")
+	params, err := llm.ParseModelParams(data)
+	if err != nil {
+		return params, err
+	}
+	if cmd.Flags().Changed("threads") {
+		params.NumThreads, _ = cmd.Flags().GetInt("threads")
+	}
+	if cmd.Flags().Changed("flash-attention") {
+		params.FlashAttention, _ = cmd.Flags().GetBool("flash-attention")
+	}
+	if cmd.Flags().Changed("kv-cache-type") {
+		params.KVCacheType, _ = cmd.Flags().GetString("kv-cache-type")
+	}
+	return params, params.Validate()
+}
+
+// addContextFlags registers the flags that configure how model contexts run
+func addContextFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("threads", llm.DefaultThreads(), "Number of CPU threads each model context runs on (default: one per CPU, up to 16)")
+	cmd.Flags().Bool("flash-attention", false, "Use flash attention where the build supports it, which can speed up long contexts and save memory")
+	cmd.Flags().String("kv-cache-type", "", "Store the KV cache as "+strings.Join(llm.KVCacheTypes, ", ")+"; the quantized types need less memory for large contexts but need --flash-attention (default: f16)")
+}
+
+// embeddingOptionsFromFlags reads the flags registered by addContextFlags for an embedding engine
+// with a single context
+func embeddingOptionsFromFlags(c *cli.Cli, cmd *cobra.Command) llm.EmbeddingOptions {
+	flashAttention, _ := cmd.Flags().GetBool("flash-attention")
+	kvCacheType, _ := cmd.Flags().GetString("kv-cache-type")
+	if err := llm.CheckKVCacheType(kvCacheType, flashAttention); err != nil {
+		c.ExitWithError("Invalid --kv-cache-type", err)
+	}
+	return llm.EmbeddingOptions{Threads: threadsFromFlags(c, cmd), FlashAttention: flashAttention, KVCacheType: kvCacheType}
+}
+
+// threadsFromFlags returns the --threads value, exiting if it isn't positive
+func threadsFromFlags(c *cli.Cli, cmd *cobra.Command) int {
+	threads, _ := cmd.Flags().GetInt("threads")
+	if threads < 1 {
+		c.ExitWithError("--threads must be at least 1", nil)
+	}
+	return threads
+}
+
+// interactiveChatOptions configures startSimpleInteractiveChat
+type interactiveChatOptions struct {
+	systemPrompt string
+	stream       bool
+	// ingestIndexPath is the keyword index /ingest adds to; empty disables /ingest
+	ingestIndexPath string
+	// ingestStore is the backend of the keyword index, llm.StoreJSON or llm.StoreSQLite
+	ingestStore string
+	// searchAlso are further keyword indexes searched alongside the one /ingest adds to
+	searchAlso []llm.KeywordStore
+	// markdown, when non-nil, buffers each response and prints it rendered once complete
+	markdown *markdownRenderer
+	// wrapWidth word-wraps plain responses to this many columns; 0 leaves them unwrapped
+	wrapWidth int
+	// exportPath receives a Markdown transcript of the conversation when the session ends
+	exportPath string
+	// stats adds the token count and generation rate to each response's timing line
+	stats bool
+	// plain leaves out the startup banner; the printer strips emoji itself
+	plain bool
+	// summarize compresses the oldest turns into a summary once the history outgrows the context
+	summarize bool
+	// historyLimit is how many earlier turns are kept; 0 keeps them all
+	historyLimit int
+	// input replaces standard input, and interrupts the Ctrl-C signal, for tests
+	input      io.Reader
+	interrupts chan os.Signal
+}
+
 // startSimpleInteractiveChat handles the interactive chat session with the simple engine
-func startSimpleInteractiveChat(c *cli.Cli, engine *llm.SimpleChatEngine, systemPrompt string, stream bool) error {
+func startSimpleInteractiveChat(out chatPrinter, engine *llm.SimpleChatEngine, opts interactiveChatOptions) error {
+	systemPrompt := opts.systemPrompt
+	stream := opts.stream
+	ingestIndexPath := opts.ingestIndexPath
+	
 	// Initialize conversation with system message
-	messages := []llm.ChatMessage{}
-	if systemPrompt != "" {
-		messages = append(messages, llm.ChatMessage{
-			Role:    "system",
-			Content: systemPrompt,
-		})
-	} else {
-		messages = append(messages, llm.ChatMessage{
-			Role:    "system",
-			Content: getDefaultSystemPrompt(),
-		})
+	if systemPrompt == "" {
+		systemPrompt = getDefaultSystemPrompt()
 	}
+	messages := llm.SetSystemMessage(nil, systemPrompt)
+	// noRAG answers every message without retrieval until /norag turns it back on
+	noRAG := false
 	
-	c.Printf("🤖 OpenTDF LLM Chat started! Type 'exit' to quit, 'clear' to clear history.\n")
-	c.Printf("   Use '/stream' to toggle streaming mode, '/help' for commands.\n")
-	c.Printf("   Simple engine mode (no complex goroutines)\n\n")
+	if !opts.plain {
+		out.Printf("🤖 OpenTDF LLM Chat started! Type 'exit' to quit, 'clear' to clear history.\n")
+		out.Printf("   Use '/stream' to toggle streaming mode, '/help' for commands.\n")
+		out.Printf("   Simple engine mode (no complex goroutines)\n\n")
+	}
 	
-	scanner := bufio.NewScanner(os.Stdin)
+	// Ctrl-C stops the answer being generated; at the prompt it ends the session
+	var r io.Reader = os.Stdin
+	if opts.input != nil {
+		r = opts.input
+	}
+	in := newChatInput(r, opts.interrupts)
+	defer in.Close()
 	
 	for {
-		c.Printf("> ")
+		out.Printf("> ")
 		
-		if !scanner.Scan() {
+		line, err := in.Next()
+		if errors.Is(err, errChatInterrupted) {
+			out.Println("")
 			break
 		}
+		if err != nil {
+			break
+		}
+		
+		input := strings.TrimSpace(line)
+		
+		// Nothing to answer, e.g. only whitespace or zero-width characters
+		if llm.IsBlank(input) {
+			continue
+		}
 		
-		input := strings.TrimSpace(scanner.Text())
+		// Replace the system prompt without clearing the conversation
+		if strings.HasPrefix(input, "/system ") {
+			messages = llm.SetSystemMessage(messages, strings.TrimSpace(strings.TrimPrefix(input, "/system ")))
+			out.Println("System prompt updated.")
+			continue
+		}
 		
-		if input == "" {
+		// Add documents to the keyword index and start retrieving from them right away
+		if strings.HasPrefix(input, "/ingest ") {
+			if ingestIndexPath == "" {
+				out.Println("/ingest only adds to the keyword index; run 'otdfctl llm ingest' to update a vector index.")
+				continue
+			}
+			path := strings.TrimSpace(strings.TrimPrefix(input, "/ingest "))
+			store, added, err := ingestIntoChat(out, engine, opts.ingestStore, ingestIndexPath, path, opts.searchAlso...)
+			if err != nil {
+				out.Printf("Ingestion failed: %v\n", err)
+				continue
+			}
+			if added == 0 {
+				out.Printf("No markdown documents found in %s\n", path)
+				continue
+			}
+			out.Printf("📚 Added %d documents; the index now has %d\n", added, store.GetDocumentCount())
 			continue
 		}
 		
+		// Save the conversation so far as a Markdown transcript
+		if strings.HasPrefix(input, "/export ") {
+			path := strings.TrimSpace(strings.TrimPrefix(input, "/export "))
+			if err := exportTranscript(path, messages); err != nil {
+				out.Printf("Export failed: %v\n", err)
+				continue
+			}
+			out.Printf("📝 Saved transcript to %s\n", path)
+			continue
+		}
+		
+		// Load another model, keeping the conversation so far
+		if strings.HasPrefix(input, "/model ") {
+			switchModel(out, engine, strings.TrimSpace(strings.TrimPrefix(input, "/model ")))
+			continue
+		}
+		
+		// Answer just this message without retrieval
+		skipRAG := noRAG
+		if rest, ok := noRAGPrefix(input); ok {
+			input, skipRAG = rest, true
+		}
+		
 		// Handle commands
 		switch input {
 		case "exit", "quit":
-			c.Println("Goodbye! 👋")
-			return nil
+			out.Println("Goodbye! 👋")
+			return exportOnExit(out, opts.exportPath, messages)
 		case "clear":
 			messages = messages[:1] // Keep system message
-			c.Println("Chat history cleared.")
+			out.Println("Chat history cleared.")
 			continue
 		case "/stream":
 			stream = !stream
-			c.Printf("Streaming mode: %v\n", stream)
+			out.Printf("Streaming mode: %v\n", stream)
+			continue
+		case "/norag":
+			noRAG = !noRAG
+			out.Printf("RAG mode: %v\n", !noRAG)
+			continue
+		case "/system":
+			out.Println("Usage: /system <prompt>")
+			continue
+		case "/ingest":
+			out.Println("Usage: /ingest <file-or-dir>")
+			continue
+		case "/export":
+			out.Println("Usage: /export <file>")
+			continue
+		case "/model":
+			out.Printf("Model: %s\nUsage: /model <path-or-name>\n", engine.ModelPath())
+			continue
+		case "/system-reset":
+			messages = llm.SetSystemMessage(messages, systemPrompt)
+			out.Println("System prompt reset.")
 			continue
 		case "/help":
-			printHelp(c)
+			printHelp(out)
 			continue
 		}
 		
@@ -164,50 +695,168 @@ func startSimpleInteractiveChat(c *cli.Cli, engine *llm.SimpleChatEngine, system
 		messages = append(messages, llm.ChatMessage{
 			Role:    "user",
 			Content: input,
+			Time:    time.Now(),
+			NoRAG:   skipRAG,
 		})
 		
-		// Get response
-		c.Printf("🤖 ")
+		messages = llm.LimitHistory(messages, opts.historyLimit)
+		if opts.summarize {
+			messages = summarizeHistory(out, messages, engine.HistoryTokens(replyTokens), engine.Summarize)
+		}
 		
-		start := time.Now()
-		var fullResponse strings.Builder
+		// Get response
+		reply := answerTurn(out, engine, in, messages, stream, opts)
 		
-		if stream {
-			// Use streaming inference
-			response := engine.ChatStream(messages, func(token string) {
-				c.Printf("%s", token)
-				os.Stdout.Sync() // Force flush for real-time streaming
+		// Add assistant response to history
+		if reply != "" {
+			messages = append(messages, llm.ChatMessage{
+				Role:    "assistant",
+				Content: reply,
+				Time:    time.Now(),
+			})
+		}
+	}
+	
+	return exportOnExit(out, opts.exportPath, messages)
+}
+
+// turnEngine is the part of the engine that answers a chat turn
+type turnEngine interface {
+	ChatContext(ctx context.Context, messages []llm.ChatMessage) llm.SimpleResponse
+	ChatStream(ctx context.Context, messages []llm.ChatMessage, callback llm.StreamingCallback) llm.SimpleResponse
+}
+
+// answerTurn prints the engine's answer to messages, token by token when streaming, and
+// returns it for the history. Ctrl-C stops the answer and returns to the prompt; the part
+// already streamed is kept. opts decides how the answer is rendered or wrapped.
+func answerTurn(out chatPrinter, engine turnEngine, in *chatInput, messages []llm.ChatMessage, stream bool, opts interactiveChatOptions) string {
+	markdown := opts.markdown
+	out.Printf("🤖 ")
+	
+	start := time.Now()
+	var fullResponse strings.Builder
+	var response llm.SimpleResponse
+	
+	if stream {
+		// Use streaming inference, wrapping a line at a time
+		wrapper := newTextWrapper(opts.wrapWidth)
+		interrupted := in.Interruptible(func(ctx context.Context) {
+			response = engine.ChatStream(ctx, messages, func(token string) {
 				fullResponse.WriteString(token)
+				if markdown.enabled() {
+					return // Rendered once the response is complete
+				}
+				out.Printf("%s", wrapper.Add(token))
+				os.Stdout.Sync() // Force flush for real-time streaming
 			})
-			
-			if response.Error != nil {
-				c.Printf("\nError: %v\n", response.Error)
-				continue
-			}
-			
-			c.Printf("\n\n⏱️  Response time: %v\n", time.Since(start))
-		} else {
-			// Use non-streaming inference
-			response := engine.Chat(messages)
-			
-			if response.Error != nil {
-				c.Printf("\nError: %v\n", response.Error)
-				continue
+		})
+		if !markdown.enabled() {
+			out.Printf("%s", wrapper.Flush())
+		}
+		
+		if interrupted && errors.Is(response.Error, context.Canceled) {
+			if markdown.enabled() {
+				out.Printf("\n%s", markdown.Render(fullResponse.String()))
 			}
-			
-			c.Printf("%s\n\n⏱️  Response time: %v\n", response.Content, time.Since(start))
-			fullResponse.WriteString(response.Content)
+			out.Printf("\n\n⏹️  Stopped after %d tokens\n", response.TokenCount)
+			return fullResponse.String()
+		}
+		if response.Error != nil {
+			out.Printf("\nError: %v\n", response.Error)
+			return ""
 		}
 		
-		// Add assistant response to history
-		if fullResponse.Len() > 0 {
-			messages = append(messages, llm.ChatMessage{
-				Role:    "assistant",
-				Content: fullResponse.String(),
-			})
+		if markdown.enabled() {
+			out.Printf("\n%s", markdown.Render(fullResponse.String()))
 		}
+		out.Printf("\n\n%s\n", turnSummary(time.Since(start), response.TokenCount, opts.stats))
+		return fullResponse.String()
 	}
 	
+	// Use non-streaming inference
+	interrupted := in.Interruptible(func(ctx context.Context) {
+		response = engine.ChatContext(ctx, messages)
+	})
+	
+	if interrupted && errors.Is(response.Error, context.Canceled) {
+		out.Printf("\n⏹️  Stopped\n")
+		return ""
+	}
+	if response.Error != nil {
+		out.Printf("\nError: %v\n", response.Error)
+		return ""
+	}
+	
+	out.Printf("%s\n\n%s\n", renderResponse(response.Content, markdown, opts.wrapWidth), turnSummary(time.Since(start), response.TokenCount, opts.stats))
+	return response.Content
+}
+
+// switchModel loads the model file or Ollama model name modelRef into engine. If the model
+// can't be loaded the current one stays active.
+func switchModel(out chatPrinter, engine *llm.SimpleChatEngine, modelRef string) {
+	modelPath, err := llm.ResolveModelPath(modelRef)
+	if err == nil {
+		out.Printf("Loading %s...\n", modelPath)
+		err = engine.SwitchModel(modelPath)
+	}
+	if err != nil {
+		out.Printf("Model switch failed, still using %s: %v\n", engine.ModelPath(), err)
+		return
+	}
+	out.Printf("🔄 Switched to %s; conversation history kept\n", modelPath)
+}
+
+// noRAGPrefix reports whether input is a message prefixed with /norag, returning the message
+// without the prefix
+func noRAGPrefix(input string) (string, bool) {
+	if !strings.HasPrefix(input, "/norag ") {
+		return input, false
+	}
+	message := strings.TrimSpace(strings.TrimPrefix(input, "/norag "))
+	if llm.IsBlank(message) {
+		return input, false
+	}
+	return message, true
+}
+
+// summarizeHistory compresses the oldest turns of messages into a summary once they no longer
+// fit in maxTokens. If summarizing fails the history is kept whole and a warning is printed.
+func summarizeHistory(out chatPrinter, messages []llm.ChatMessage, maxTokens int, summarize llm.Summarizer) []llm.ChatMessage {
+	summarized, err := llm.SummarizeHistory(messages, maxTokens, summarize)
+	if err != nil {
+		out.Printf("⚠️  Failed to summarize earlier messages: %v\n", err)
+		return messages
+	}
+	if len(summarized) < len(messages) {
+		// The summary replaces the compressed turns, so one more than the difference went into it
+		out.Printf("📝 Summarized %d earlier messages to fit the context window\n", len(messages)-len(summarized)+1)
+	}
+	return summarized
+}
+
+// turnSummary describes how long a response took and, with stats, how many tokens it had
+// and how fast they were generated
+func turnSummary(elapsed time.Duration, tokens int, stats bool) string {
+	summary := fmt.Sprintf("⏱️  Response time: %v", elapsed)
+	if !stats {
+		return summary
+	}
+	summary += fmt.Sprintf(" | %d tokens", tokens)
+	if elapsed > 0 {
+		summary += fmt.Sprintf(" | %.1f tokens/s", float64(tokens)/elapsed.Seconds())
+	}
+	return summary
+}
+
+// exportOnExit saves the session transcript to exportPath, if one was requested
+func exportOnExit(out chatPrinter, exportPath string, messages []llm.ChatMessage) error {
+	if exportPath == "" {
+		return nil
+	}
+	if err := exportTranscript(exportPath, messages); err != nil {
+		return err
+	}
+	out.Printf("📝 Saved transcript to %s\n", exportPath)
 	return nil
 }
 
@@ -227,10 +876,18 @@ You help users understand OpenTDF concepts, debug issues, write policies, and im
 }
 
 // printHelp displays available commands
-func printHelp(c *cli.Cli) {
-	c.Println("\nAvailable commands:")
-	c.Println("  exit, quit  - Exit the chat")
-	c.Println("  clear       - Clear chat history")
-	c.Println("  /stream     - Toggle streaming mode")
-	c.Println("  /help       - Show this help")
+func printHelp(out chatPrinter) {
+	out.Println("\nAvailable commands:")
+	out.Println("  exit, quit  - Exit the chat")
+	out.Println("  clear       - Clear chat history")
+	out.Println("  /stream     - Toggle streaming mode")
+	out.Println("  /norag      - Toggle answering without retrieved documentation")
+	out.Println("  /norag <message> - Answer one message without retrieved documentation")
+	out.Println("  /system <prompt> - Replace the system prompt, keeping history")
+	out.Println("  /system-reset    - Restore the startup system prompt")
+	out.Println("  /ingest <path>   - Add a markdown file or directory to the keyword RAG index")
+	out.Println("  /export <file>   - Save the conversation as a Markdown transcript")
+	out.Println("  /model <path-or-name> - Switch to another model, keeping history (the last two stay loaded)")
+	out.Println("  /help       - Show this help")
+	out.Println("  Ctrl-C      - Stop the answer being generated; at the prompt, exit")
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmBenchCmd = man.Docs.GetCommand("llm/bench", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	if len(args) == 0 {
+		c.ExitWithError("Model path is required", nil)
+	}
+
+	modelPath := resolveModelArg(c, args[0])
+	runs, _ := cmd.Flags().GetInt("runs")
+	prompt := c.Flags.GetOptionalString("prompt")
+	if prompt == "" {
+		prompt = llm.DefaultBenchmarkPrompt
+	}
+
+	engine := llm.NewSimpleChatEngine(modelPath)
+	engine.SetLogger(llmLogger(cmd))
+
+	// Greedy decoding keeps the generated tokens identical across runs
+	sampling := llm.DefaultSamplingOptions()
+	sampling.Greedy = true
+	engine.SetSamplingOptions(sampling)
+
+	if err := engine.Start(); err != nil {
+		c.ExitWithError("Failed to start simple chat engine", err)
+	}
+	defer engine.Stop()
+
+	c.Printf("⏱️  Benchmarking %s (%d runs)...\n", modelPath, runs)
+
+	report, err := llm.RunBenchmark(engine, prompt, runs)
+	if err != nil {
+		c.ExitWithError("Benchmark failed", err)
+	}
+
+	if jsonFlag, _ := cmd.Flags().GetBool("json"); jsonFlag {
+		c.ExitWithJSON(report)
+		return
+	}
+
+	c.Printf("\n📊 Benchmark results\n")
+	c.Printf("   Prompt tokens:       %d\n", report.PromptTokens)
+	c.Printf("   Generated tokens:    %.1f\n", report.AvgGeneratedTokens)
+	c.Printf("   Prompt eval:         %.1f ms\n", report.AvgPromptEvalMs)
+	c.Printf("   Time to first token: %.1f ms\n", report.AvgTimeToFirstTokenMs)
+	c.Printf("   Total:               %.1f ms\n", report.AvgTotalMs)
+	c.Printf("   Generation rate:     %.2f tokens/sec\n", report.AvgTokensPerSecond)
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmBenchCmd.Flags().Int("runs", 3, "Number of runs to average over")
+	llmBenchCmd.Flags().String("prompt", "", "Override the fixed benchmark prompt")
+	llmBenchCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add bench command to llm parent
+	llmCmd.AddCommand(&llmBenchCmd.Command)
+}
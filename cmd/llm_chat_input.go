@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// errChatInterrupted is returned by chatInput.Next when Ctrl-C is pressed at the prompt
+var errChatInterrupted = errors.New("interrupted")
+
+// chatInput feeds the interactive chat its input lines and catches Ctrl-C, so an interrupt
+// stops the answer being generated instead of the program
+type chatInput struct {
+	lines      chan string
+	done       chan struct{}
+	interrupts chan os.Signal
+	stop       func()
+}
+
+// newChatInput reads lines from r. Interrupts are taken from interrupts when it is given, as
+// tests do; otherwise Ctrl-C is caught until Close.
+func newChatInput(r io.Reader, interrupts chan os.Signal) *chatInput {
+	in := &chatInput{
+		lines:      make(chan string),
+		done:       make(chan struct{}),
+		interrupts: interrupts,
+		stop:       func() {},
+	}
+	if in.interrupts == nil {
+		in.interrupts = make(chan os.Signal, 1)
+		signal.Notify(in.interrupts, os.Interrupt)
+		in.stop = func() { signal.Stop(in.interrupts) }
+	}
+
+	// Lines are read in the background so Next can wait for a line and an interrupt together
+	go func() {
+		defer close(in.lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case in.lines <- scanner.Text():
+			case <-in.done:
+				return
+			}
+		}
+	}()
+	return in
+}
+
+// Next waits for the next input line. It returns io.EOF at the end of the input and
+// errChatInterrupted when Ctrl-C is pressed at the prompt; either ends the session.
+func (in *chatInput) Next() (string, error) {
+	select {
+	case line, ok := <-in.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	case <-in.interrupts:
+		return "", errChatInterrupted
+	}
+}
+
+// Interruptible runs generate with a context that is cancelled if Ctrl-C is pressed before
+// generate returns, and reports whether it was
+func (in *chatInput) Interruptible(generate func(ctx context.Context)) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	finished := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-in.interrupts:
+			cancel()
+			interrupted <- true
+		case <-finished:
+			interrupted <- false
+		}
+	}()
+	generate(ctx)
+	close(finished)
+	return <-interrupted
+}
+
+// Close stops catching Ctrl-C and reading input
+func (in *chatInput) Close() {
+	close(in.done)
+	in.stop()
+}
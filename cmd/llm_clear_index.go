@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmClearIndexCmd = man.Docs.GetCommand("llm/clear-index", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	deleteFile := c.Flags.GetOptionalBool("delete-file")
+	force := c.Flags.GetOptionalBool("yes")
+
+	index, indexPath := loadDocumentIndex(c, cmd)
+	cleared := index.GetDocumentCount()
+
+	if !force {
+		cli.ConfirmTextInput(cli.ActionDelete, "index", "index path", indexPath)
+	}
+
+	if deleteFile {
+		if err := os.Remove(indexPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.ExitWithError("Failed to delete index file", err)
+		}
+	} else {
+		index.Clear()
+		if err := index.SaveIndex(); err != nil {
+			c.ExitWithError("Failed to save index", err)
+		}
+	}
+
+	c.ExitWithJSON(map[string]interface{}{
+		"cleared":      cleared,
+		"index_path":   indexPath,
+		"file_deleted": deleteFile,
+	})
+
+	if deleteFile {
+		c.Printf("🗑️  Deleted %s (%d documents)\n", indexPath, cleared)
+		return
+	}
+	c.Printf("🗑️  Cleared %d documents from %s\n", cleared, indexPath)
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	addDocumentIndexFlags(&llmClearIndexCmd.Command)
+	llmClearIndexCmd.Flags().Bool("delete-file", false, "Delete the index file instead of rewriting it empty")
+	llmClearIndexCmd.Flags().Bool("yes", false, "Skip the typed confirmation")
+	llmClearIndexCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add clear-index command to llm parent
+	llmCmd.AddCommand(&llmClearIndexCmd.Command)
+}
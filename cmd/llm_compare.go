@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+// compareColumnGap is the space between the answers printed side by side
+const compareColumnGap = 3
+
+var llmCompareCmd = man.Docs.GetCommand("llm/compare", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	modelA := c.Flags.GetOptionalString("model-a")
+	modelB := c.Flags.GetOptionalString("model-b")
+	if modelA == "" || modelB == "" {
+		c.ExitWithError("--model-a and --model-b are required", nil)
+	}
+	prompt := c.Flags.GetOptionalString("prompt")
+	if llm.IsBlank(prompt) {
+		c.ExitWithError("--prompt is required", nil)
+	}
+	modelPaths := []string{resolveModelArg(c, modelA), resolveModelArg(c, modelB)}
+
+	systemPrompt, err := systemPromptFromFlags(cmd)
+	if err != nil {
+		c.ExitWithError("Failed to read system prompt", err)
+	}
+	if systemPrompt == "" {
+		systemPrompt = getDefaultSystemPrompt()
+	}
+	sampling := samplingOptionsFromFlags(cmd)
+	if sampling.StopOnRepeat < 0 {
+		c.ExitWithError("--stop-on-repeat must not be negative", nil)
+	}
+	modelParams, err := modelParamsFromFlags(cmd)
+	if err != nil {
+		c.ExitWithError("Invalid llama settings", err)
+	}
+	contextSize := int(c.Flags.GetOptionalInt32("context-size"))
+
+	// Both models retrieve from the same index, so they are given the same RAG context
+	var keywordStore llm.KeywordStore
+	var vectorStore llm.Store
+	var embedder llm.Embedder
+	if c.Flags.GetOptionalBool("rag") {
+		indexPaths, _ := cmd.Flags().GetStringSlice("index-path")
+		if embeddingModelPath := c.Flags.GetOptionalString("embedding-model"); embeddingModelPath != "" {
+			embeddingEngine, err := llm.NewEmbeddingEnginePool(resolveModelArg(c, embeddingModelPath), embeddingOptionsFromFlags(c, cmd))
+			if err != nil {
+				c.ExitWithError("Failed to initialize embedding engine", err)
+			}
+			defer embeddingEngine.Close()
+			embedder = embeddingEngine
+
+			vectorStores := newVectorStores(c, cmd, indexPaths)
+			vectorStore = vectorStores[0]
+			if len(vectorStores) > 1 {
+				vectorStore = llm.NewMultiStore(vectorStores...)
+			}
+			if err := vectorStore.LoadIndex(); err != nil {
+				c.ExitWithError("Failed to load vector index", err)
+			}
+			defer vectorStore.Close()
+		} else {
+			keywordStores := newKeywordStores(c, cmd, indexPaths)
+			keywordStore = keywordStores[0]
+			if len(keywordStores) > 1 {
+				keywordStore = llm.NewMultiKeywordStore(keywordStores...)
+			}
+			if err := keywordStore.LoadIndex(); err != nil {
+				c.ExitWithError("Failed to load simple RAG index", err)
+			}
+			defer keywordStore.Close()
+		}
+	}
+
+	start := func(modelPath string) (llm.ChatGenerator, func(), error) {
+		engine := llm.NewSimpleChatEngine(modelPath)
+		engine.SetLogger(llmLogger(cmd))
+		engine.SetSamplingOptions(sampling)
+		engine.SetModelParams(modelParams)
+		engine.SetContextSize(contextSize)
+		if vectorStore != nil {
+			engine.EnableRAG(vectorStore, embedder)
+		} else if keywordStore != nil {
+			engine.EnableSimpleRAG(keywordStore)
+		}
+		if err := engine.Start(); err != nil {
+			return nil, nil, err
+		}
+		return engine, engine.Stop, nil
+	}
+
+	messages := llm.SetSystemMessage(nil, systemPrompt)
+	messages = append(messages, llm.ChatMessage{Role: "user", Content: prompt, Time: time.Now()})
+	answers := compareModels(modelPaths, messages, start)
+
+	c.ExitWithJSON(answers)
+
+	printComparison(os.Stdout, answers, terminalWidth(os.Stdout))
+	for _, answer := range answers {
+		if answer.Error != "" {
+			c.ExitWithError(fmt.Sprintf("%s failed to answer", answer.Model), nil)
+		}
+	}
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmCompareCmd.Flags().String("model-a", "", "First model file or Ollama model name to compare (required)")
+	llmCompareCmd.Flags().String("model-b", "", "Second model file or Ollama model name to compare (required)")
+	llmCompareCmd.Flags().String("prompt", "", "Prompt both models answer (required)")
+	llmCompareCmd.Flags().StringArray("system-prompt", nil, "Custom system prompt; repeat to layer instructions, which are joined in order")
+	llmCompareCmd.Flags().StringArray("system-prompt-file", nil, "File holding part of the system prompt; repeatable, and placed before any --system-prompt values")
+	llmCompareCmd.Flags().Int32("context-size", 0, "Maximum context window size (0 uses each model's trained context size, up to 8192)")
+	addSamplingFlags(&llmCompareCmd.Command)
+	addContextFlags(&llmCompareCmd.Command)
+	llmCompareCmd.Flags().Bool("rag", false, "Enable RAG, giving both models the same retrieved documentation")
+	llmCompareCmd.Flags().StringSlice("index-path", nil, "Path to RAG index, repeatable or comma-separated to search several indexes together (default: ~/.otdfctl/rag_index.json with --embedding-model, otherwise ~/.otdfctl/simple_rag_index.json)")
+	llmCompareCmd.Flags().String("embedding-model", "", "Path to embedding model for vector RAG (default: keyword RAG)")
+	addStoreFlag(&llmCompareCmd.Command)
+	llmCompareCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add compare command to llm parent
+	llmCmd.AddCommand(&llmCompareCmd.Command)
+}
+
+// comparedAnswer is one model's answer in `llm compare`
+type comparedAnswer struct {
+	Model     string  `json:"model"`
+	Response  string  `json:"response"`
+	Error     string  `json:"error,omitempty"`
+	Tokens    int     `json:"tokens"`
+	ElapsedMs float64 `json:"elapsed_ms"`
+}
+
+// compareModels answers messages with each model in turn. Each model is started only for its
+// own answer, so one model is in memory at a time and neither slows the other's timing. A
+// model that fails to start or answer is reported in its answer without stopping the others.
+func compareModels(modelPaths []string, messages []llm.ChatMessage, start func(modelPath string) (llm.ChatGenerator, func(), error)) []comparedAnswer {
+	answers := make([]comparedAnswer, len(modelPaths))
+	for i, modelPath := range modelPaths {
+		answers[i] = compareModel(modelPath, messages, start)
+	}
+	return answers
+}
+
+// compareModel answers messages with a single model for compareModels
+func compareModel(modelPath string, messages []llm.ChatMessage, start func(modelPath string) (llm.ChatGenerator, func(), error)) comparedAnswer {
+	answer := comparedAnswer{Model: modelPath}
+	engine, stop, err := start(modelPath)
+	if err != nil {
+		answer.Error = err.Error()
+		return answer
+	}
+	defer stop()
+
+	started := time.Now()
+	response := engine.Chat(messages)
+	answer.ElapsedMs = float64(time.Since(started).Microseconds()) / 1000
+	if response.Error != nil {
+		answer.Error = response.Error.Error()
+		return answer
+	}
+	answer.Response = response.Content
+	answer.Tokens = response.TokenCount
+	return answer
+}
+
+// printComparison prints the answers side by side in columns filling width, each headed by
+// its model and timing
+func printComparison(w io.Writer, answers []comparedAnswer, width int) {
+	if len(answers) == 0 {
+		return
+	}
+	columnWidth := max((width-compareColumnGap*(len(answers)-1))/len(answers), 20)
+
+	columns := make([]string, len(answers))
+	for i, answer := range answers {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s\n", filepath.Base(answer.Model))
+		fmt.Fprintf(&b, "⏱️  %.0f ms | %d tokens\n", answer.ElapsedMs, answer.Tokens)
+		fmt.Fprintf(&b, "%s\n", strings.Repeat("─", columnWidth))
+		if answer.Error != "" {
+			fmt.Fprintf(&b, "Error: %s", answer.Error)
+		} else {
+			b.WriteString(strings.TrimSpace(answer.Response))
+		}
+
+		style := lipgloss.NewStyle().Width(columnWidth)
+		if i < len(answers)-1 {
+			style = style.MarginRight(compareColumnGap)
+		}
+		columns[i] = style.Render(b.String())
+	}
+	fmt.Fprintln(w, lipgloss.JoinHorizontal(lipgloss.Top, columns...))
+}
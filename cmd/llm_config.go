@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// loadLLMDefaults fills in the llm command's flags that weren't passed on the command line
+// from the global config and the nearest .otdfctl-llm.yaml project config
+func loadLLMDefaults(c *cli.Cli, cmd *cobra.Command) {
+	cfg, err := llmConfig(OtdfctlCfg.LLM)
+	if err != nil {
+		c.ExitWithError("Failed to load "+config.LLMProjectFile, err)
+	}
+	if err := applyLLMDefaults(cmd, cfg); err != nil {
+		c.ExitWithError("Invalid setting in "+config.LLMProjectFile, err)
+	}
+}
+
+// llmConfig overlays the project config found from the working directory, if any, onto the
+// global LLM settings
+func llmConfig(global config.LLM) (config.LLM, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return global, err
+	}
+	path, err := config.FindLLMProjectConfig(cwd)
+	if err != nil || path == "" {
+		return global, err
+	}
+	return config.LoadLLMProjectConfig(path, global)
+}
+
+// applyLLMDefaults sets each of cmd's RAG flags that the user didn't pass from cfg, so
+// explicit flags always win. Flags the command doesn't have are skipped.
+func applyLLMDefaults(cmd *cobra.Command, cfg config.LLM) error {
+	// The embedding model decides which index chat uses, so it is set first
+	if err := setFlagDefault(cmd, "embedding-model", cfg.EmbeddingModel); err != nil {
+		return err
+	}
+
+	indexPath := cfg.IndexPath
+	if usesKeywordIndex(cmd) {
+		indexPath = cfg.SimpleIndexPath
+	}
+	if err := setFlagDefault(cmd, "index-path", indexPath); err != nil {
+		return err
+	}
+	if err := setFlagDefault(cmd, "store", cfg.Store); err != nil {
+		return err
+	}
+	// Commands that look at both indexes take the keyword index separately
+	if err := setFlagDefault(cmd, "simple-index-path", cfg.SimpleIndexPath); err != nil {
+		return err
+	}
+	if err := setFlagDefault(cmd, "system-prompt", cfg.SystemPrompt); err != nil {
+		return err
+	}
+	if cfg.ChunkSize > 0 {
+		return setFlagDefault(cmd, "chunk-size", strconv.Itoa(cfg.ChunkSize))
+	}
+	return nil
+}
+
+// usesKeywordIndex reports whether cmd reads or writes the keyword index rather than the
+// vector index
+func usesKeywordIndex(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "ingest-simple":
+		return true
+	case "chat":
+		// Chat retrieves from the vector index only when given an embedding model
+		embeddingModel, _ := cmd.Flags().GetString("embedding-model")
+		return embeddingModel == ""
+	default:
+		simple, _ := cmd.Flags().GetBool("simple")
+		return simple
+	}
+}
+
+// setFlagDefault sets a flag to value unless it was passed explicitly, value is empty, or
+// the command has no such flag
+func setFlagDefault(cmd *cobra.Command, name, value string) error {
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil || flag.Changed || value == "" {
+		return nil
+	}
+	return flag.Value.Set(value)
+}
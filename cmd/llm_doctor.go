@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/config"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// doctorSettings are the resolved defaults `llm doctor` checks
+type doctorSettings struct {
+	Model           string `json:"model"`
+	EmbeddingModel  string `json:"embedding_model"`
+	Store           string `json:"store"`
+	IndexPath       string `json:"index_path"`
+	SimpleIndexPath string `json:"simple_index_path"`
+	ProjectConfig   string `json:"project_config,omitempty"`
+}
+
+// doctorCheck is one line of the `llm doctor` checklist
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// doctorReport is the result of `llm doctor`, printed as-is with --json
+type doctorReport struct {
+	Defaults doctorSettings `json:"defaults"`
+	Checks   []doctorCheck  `json:"checks"`
+	// Healthy is false when any check failed; warnings don't count
+	Healthy bool `json:"healthy"`
+}
+
+// add records a check, marking the report unhealthy when it failed
+func (r *doctorReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	if status == doctorFail {
+		r.Healthy = false
+	}
+}
+
+var llmDoctorCmd = man.Docs.GetCommand("llm/doctor", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	report := doctorReport{Healthy: true}
+
+	// A broken project config is a finding, not a reason to stop checking
+	cfg, err := llmConfig(OtdfctlCfg.LLM)
+	if err != nil {
+		report.add("project config", doctorFail, "%v", err)
+	} else if err := applyLLMDefaults(cmd, cfg); err != nil {
+		report.add("project config", doctorFail, "invalid setting in %s: %v", config.LLMProjectFile, err)
+	}
+
+	settings := doctorSettings{
+		Model:           cfg.DefaultModelPath,
+		EmbeddingModel:  c.Flags.GetOptionalString("embedding-model"),
+		Store:           c.Flags.GetOptionalString("store"),
+		IndexPath:       c.Flags.GetOptionalString("index-path"),
+		SimpleIndexPath: c.Flags.GetOptionalString("simple-index-path"),
+	}
+	if len(args) > 0 {
+		settings.Model = args[0]
+	}
+	if settings.EmbeddingModel == "" {
+		settings.EmbeddingModel = llm.DefaultEmbeddingModel
+	}
+	if settings.IndexPath == "" {
+		settings.IndexPath = defaultVectorIndexPath(settings.Store)
+	}
+	if settings.SimpleIndexPath == "" {
+		settings.SimpleIndexPath = defaultKeywordIndexPath(settings.Store)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		settings.ProjectConfig, _ = config.FindLLMProjectConfig(cwd)
+	}
+
+	report = runDoctor(settings, report)
+
+	c.PrintIfJSON(report)
+	printDoctorReport(c, report)
+	if !report.Healthy {
+		c.ExitWithMessage("\nSome checks failed; fix them before running llm chat or llm ingest", 1)
+	}
+}))
+
+// runDoctor checks the models and indexes named in settings, appending its checks to report
+func runDoctor(settings doctorSettings, report doctorReport) doctorReport {
+	report.Defaults = settings
+
+	if settings.Model == "" {
+		report.add("chat model", doctorWarn, "no default model configured; pass one to llm chat or set llm.default_model_path")
+	} else if path, info, err := inspectModel(settings.Model); err != nil {
+		report.add("chat model", doctorFail, "%v", err)
+	} else {
+		report.add("chat model", doctorPass, "%s (%s, %d token context)", path, info.Architecture, info.ContextLength)
+	}
+
+	embeddingDim := 0
+	if path, info, err := inspectModel(settings.EmbeddingModel); err != nil {
+		report.add("embedding model", doctorFail, "%v", err)
+	} else {
+		embeddingDim = info.EmbeddingLength
+		report.add("embedding model", doctorPass, "%s (%d-dimension embeddings)", path, embeddingDim)
+	}
+
+	indexDim := 0
+	if vectorStore, err := llm.NewStore(settings.Store, settings.IndexPath); err != nil {
+		report.add("vector index", doctorFail, "%v", err)
+	} else {
+		if status, detail := inspectIndex(settings.IndexPath, vectorStore, "llm ingest"); status != doctorPass {
+			report.add("vector index", status, "%s", detail)
+		} else {
+			indexDim = vectorStore.EmbeddingDim()
+			report.add("vector index", doctorPass, "%s, %d-dimension embeddings", detail, indexDim)
+		}
+		vectorStore.Close()
+	}
+
+	switch {
+	case indexDim == 0 || embeddingDim == 0:
+		report.add("embedding dimensions", doctorSkip, "needs a readable embedding model and a non-empty vector index")
+	case indexDim != embeddingDim:
+		report.add("embedding dimensions", doctorFail,
+			"index holds %d-dimension embeddings but %s produces %d; pass the --embedding-model the index was built with, or re-ingest",
+			indexDim, settings.EmbeddingModel, embeddingDim)
+	default:
+		report.add("embedding dimensions", doctorPass, "index and embedding model both use %d dimensions", indexDim)
+	}
+
+	if keywordStore, err := llm.NewKeywordStore(settings.Store, settings.SimpleIndexPath); err != nil {
+		report.add("keyword index", doctorFail, "%v", err)
+	} else {
+		status, detail := inspectIndex(settings.SimpleIndexPath, keywordStore, "llm ingest-simple")
+		report.add("keyword index", status, "%s", detail)
+		keywordStore.Close()
+	}
+
+	return report
+}
+
+// inspectModel resolves a model path or Ollama model name and reads its GGUF metadata
+func inspectModel(modelRef string) (string, llm.ModelInfo, error) {
+	path, err := llm.ResolveModelPath(modelRef)
+	if err != nil {
+		return "", llm.ModelInfo{}, err
+	}
+	info, err := llm.ReadModelInfo(path)
+	return path, info, err
+}
+
+// inspectIndex loads the index at path into index, returning a check status and detail.
+// A missing or empty index is a warning naming the command that builds it.
+func inspectIndex(path string, index documentIndex, ingestCmd string) (string, string) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return doctorWarn, fmt.Sprintf("%s not found; run %s to build it", path, ingestCmd)
+	}
+	if err := index.LoadIndex(); err != nil {
+		return doctorFail, fmt.Sprintf("%s: %v", path, err)
+	}
+	if index.GetDocumentCount() == 0 {
+		return doctorWarn, fmt.Sprintf("%s has no documents; run %s to add some", path, ingestCmd)
+	}
+	return doctorPass, fmt.Sprintf("%s (%d documents)", path, index.GetDocumentCount())
+}
+
+// printDoctorReport prints the resolved defaults and the checklist
+func printDoctorReport(c *cli.Cli, report doctorReport) {
+	c.Printf("🩺 LLM setup\n")
+	model := report.Defaults.Model
+	if model == "" {
+		model = "(none)"
+	}
+	c.Printf("   Model: %s\n", model)
+	c.Printf("   Embedding model: %s\n", report.Defaults.EmbeddingModel)
+	c.Printf("   Vector index: %s (%s)\n", report.Defaults.IndexPath, report.Defaults.Store)
+	c.Printf("   Keyword index: %s\n", report.Defaults.SimpleIndexPath)
+	if report.Defaults.ProjectConfig != "" {
+		c.Printf("   Project config: %s\n", report.Defaults.ProjectConfig)
+	}
+	c.Printf("\n")
+
+	icons := map[string]string{doctorPass: "✅", doctorWarn: "⚠️ ", doctorFail: "❌", doctorSkip: "➖"}
+	for _, check := range report.Checks {
+		c.Printf("%s %s: %s\n", icons[check.Status], check.Name, check.Detail)
+	}
+}
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmDoctorCmd.Flags().String("embedding-model", "", "Embedding model file or Ollama model name to check (default: llama3.2:1b)")
+	llmDoctorCmd.Flags().String("index-path", "", "Vector index to check (default: ~/.otdfctl/rag_index.json, or ~/.otdfctl/rag_index.db with --store sqlite)")
+	addStoreFlag(&llmDoctorCmd.Command)
+	llmDoctorCmd.Flags().String("simple-index-path", "", "Keyword index to check (default: ~/.otdfctl/simple_rag_index.json, or ~/.otdfctl/simple_rag_index.db with --store sqlite)")
+	llmDoctorCmd.Flags().Bool("json", false, "Output the checklist in JSON format")
+
+	// Add doctor command to llm parent
+	llmCmd.AddCommand(&llmDoctorCmd.Command)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+// embedResult is the output of `llm embed`
+type embedResult struct {
+	Model     string    `json:"model"`
+	Dimension int       `json:"dimension"`
+	Embedding []float32 `json:"embedding"`
+}
+
+var llmEmbedCmd = man.Docs.GetCommand("llm/embed", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	modelPath := c.Flags.GetRequiredString("model")
+	text := readLLMTextInput(args, c.Flags.GetOptionalString("file"))
+	if strings.TrimSpace(text) == "" {
+		c.ExitWithError("Text to embed is required (pass it as an argument, with --file, or on stdin)", nil)
+	}
+
+	embeddingEngine, err := llm.NewEmbeddingEngine(resolveModelArg(c, modelPath))
+	if err != nil {
+		c.ExitWithError("Failed to initialize embedding engine", err)
+	}
+	defer embeddingEngine.Close()
+
+	result, err := runEmbed(embeddingEngine, modelPath, text)
+	if err != nil {
+		c.ExitWithError("Failed to generate embedding", err)
+	}
+
+	c.ExitWithJSON(result)
+
+	c.Printf("%s\n", formatVector(result.Embedding))
+	c.Printf("\n📐 Dimension: %d\n", result.Dimension)
+}))
+
+// readLLMTextInput returns the text given as arguments, read from a file, or piped on stdin
+func readLLMTextInput(args []string, filePath string) string {
+	if filePath != "" {
+		return string(cli.ReadFromFile(filePath))
+	}
+	if len(args) > 0 {
+		return strings.Join(args, " ")
+	}
+	return string(cli.ReadFromPipe(os.Stdin))
+}
+
+// runEmbed embeds text and describes the resulting vector
+func runEmbed(embedder llm.Embedder, model string, text string) (embedResult, error) {
+	embedding, err := embedder.GenerateEmbedding(text)
+	if err != nil {
+		return embedResult{}, err
+	}
+
+	return embedResult{
+		Model:     model,
+		Dimension: len(embedding),
+		Embedding: embedding,
+	}, nil
+}
+
+// formatVector renders a vector as a bracketed, comma-separated list
+func formatVector(v []float32) string {
+	values := make([]string, len(v))
+	for i, f := range v {
+		values[i] = fmt.Sprintf("%g", f)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmEmbedCmd.Flags().String("model", "", "Path to embedding model (required)")
+	llmEmbedCmd.Flags().String("file", "", "Read the text to embed from a file")
+	llmEmbedCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add embed command to llm parent
+	llmCmd.AddCommand(&llmEmbedCmd.Command)
+}
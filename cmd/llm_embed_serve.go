@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmEmbedServeCmd = man.Docs.GetCommand("llm/embed-serve", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	modelRef := llm.DefaultEmbeddingModel
+	if len(args) > 0 {
+		modelRef = args[0]
+	}
+	modelPath := resolveModelArg(c, modelRef)
+	addr := c.Flags.GetOptionalString("addr")
+	opts := embeddingOptionsFromFlags(c, cmd)
+	opts.Contexts, _ = cmd.Flags().GetInt("contexts")
+
+	c.Printf("📥 Loading embedding model %s...\n", modelPath)
+	engine, err := llm.NewEmbeddingEnginePool(modelPath, opts)
+	if err != nil {
+		c.ExitWithError("Failed to initialize embedding engine", err)
+	}
+	defer engine.Close()
+
+	server := llm.NewEmbedServer(engine)
+	server.SetLogger(llmLogger(cmd))
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	c.Printf("🚀 Serving embeddings on http://%s/embed\n", addr)
+	c.Printf("   Ingest with: otdfctl llm ingest --embed-endpoint http://%s\n", addr)
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		c.ExitWithError("Server failed", err)
+	}
+	c.Println("Server stopped.")
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmEmbedServeCmd.Flags().String("addr", "127.0.0.1:8182", "Address to listen on")
+	llmEmbedServeCmd.Flags().Int("contexts", 1, "Number of embedding contexts, and so requests embedded at once, sharing the loaded model")
+	addContextFlags(&llmEmbedServeCmd.Command)
+
+	// Add embed-serve command to llm parent
+	llmCmd.AddCommand(&llmEmbedServeCmd.Command)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmEvalCmd = man.Docs.GetCommand("llm/eval", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	queriesPath := c.Flags.GetOptionalString("queries")
+	embeddingModelPath := c.Flags.GetOptionalString("embedding-model")
+	simple := c.Flags.GetOptionalBool("simple")
+	topK := int(c.Flags.GetOptionalInt32("top-k"))
+	titleBoost, _ := cmd.Flags().GetFloat32("title-boost")
+
+	if queriesPath == "" {
+		c.ExitWithError("--queries is required", nil)
+	}
+	if !simple && embeddingModelPath == "" {
+		c.ExitWithError("--embedding-model is required to evaluate a vector index (use --simple for a keyword index)", nil)
+	}
+	if topK < 1 {
+		c.ExitWithError("--top-k must be at least 1", nil)
+	}
+	if titleBoost < 0 || titleBoost > 1 {
+		c.ExitWithError("--title-boost must be between 0 and 1", nil)
+	}
+
+	queriesFile, err := os.Open(queriesPath)
+	if err != nil {
+		c.ExitWithError("Failed to open queries file", err)
+	}
+	queries, err := llm.ReadEvalQueries(queriesFile)
+	queriesFile.Close()
+	if err != nil {
+		c.ExitWithError("Failed to read queries file", err)
+	}
+
+	index, indexPath := loadDocumentIndex(c, cmd)
+	var embedder llm.Embedder
+	if vectorStore, ok := index.(llm.Store); ok {
+		vectorStore.SetTitleBoost(titleBoost)
+
+		embeddingEngine, err := llm.NewEmbeddingEngine(resolveModelArg(c, embeddingModelPath))
+		if err != nil {
+			c.ExitWithError("Failed to initialize embedding engine", err)
+		}
+		defer embeddingEngine.Close()
+		embedder = embeddingEngine
+	}
+
+	report, err := llm.EvaluateRetrieval(queries, topK, indexRetriever(index, embedder))
+	if err != nil {
+		c.ExitWithError("Evaluation failed", err)
+	}
+
+	c.ExitWithJSON(report)
+
+	c.Printf("📏 %s: %d queries, top %d\n", indexPath, report.Queries, report.K)
+	for _, result := range report.Results {
+		mark := "✅"
+		if result.FirstRelevantRank == 0 {
+			mark = "❌"
+		}
+		c.Printf("   %s recall %.2f, first relevant at %s: %s\n", mark, result.Recall, formatRank(result.FirstRelevantRank), previewLine(result.Query, 80))
+	}
+	c.Printf("\n   Recall@%d: %.4f\n", report.K, report.RecallAtK)
+	c.Printf("   MRR:       %.4f\n", report.MRR)
+}))
+
+// indexRetriever searches index the way `llm search` does, returning only the IDs of the hits
+func indexRetriever(index documentIndex, embedder llm.Embedder) llm.Retriever {
+	return func(query string, topK int) ([]string, error) {
+		hits, err := runSearch(index, embedder, query, topK, false)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids, nil
+	}
+}
+
+// formatRank renders a 1-based rank, or "-" for a document that wasn't retrieved
+func formatRank(rank int) string {
+	if rank == 0 {
+		return "-"
+	}
+	return "#" + strconv.Itoa(rank)
+}
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmEvalCmd.Flags().String("queries", "", "Path to a JSON Lines file of queries and the IDs of their relevant documents (required)")
+	addDocumentIndexFlags(&llmEvalCmd.Command)
+	llmEvalCmd.Flags().String("embedding-model", "", "Path to the embedding model used to build the vector index (required unless --simple)")
+	llmEvalCmd.Flags().Int32("top-k", 5, "Number of documents retrieved per query (the k of recall@k)")
+	llmEvalCmd.Flags().Float32("title-boost", 0, "Weight (0-1) of document title similarity in vector scores")
+	llmEvalCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add eval command to llm parent
+	llmCmd.AddCommand(&llmEvalCmd.Command)
+}
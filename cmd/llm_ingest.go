@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/opentdf/otdfctl/pkg/cli"
 	"github.com/opentdf/otdfctl/pkg/llm"
@@ -12,84 +18,292 @@ import (
 
 var llmIngestCmd = man.Docs.GetCommand("llm/ingest", man.WithRun(func(cmd *cobra.Command, args []string) {
 	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
 
 	embeddingModelPath := c.Flags.GetOptionalString("embedding-model")
+	embedEndpoint := c.Flags.GetOptionalString("embed-endpoint")
 	indexPath := c.Flags.GetOptionalString("index-path")
 	sourceType := c.Flags.GetOptionalString("source")
 	sourcePath := c.Flags.GetOptionalString("path")
 	cacheDir := c.Flags.GetOptionalString("cache-dir")
+	dryRun := c.Flags.GetOptionalBool("dry-run")
+	lang := c.Flags.GetOptionalString("lang")
+	include, _ := cmd.Flags().GetStringArray("include")
+	exclude, _ := cmd.Flags().GetStringArray("exclude")
+	maxFileSize, _ := cmd.Flags().GetInt64("max-file-size")
+	chunkSize := c.Flags.GetOptionalInt32("chunk-size")
+	codeChunkSize := c.Flags.GetOptionalInt32("code-chunk-size")
+	minChunkSize := c.Flags.GetOptionalInt32("min-chunk-size")
+	keepCode := c.Flags.GetOptionalBool("keep-code")
+	keepLinks := c.Flags.GetOptionalBool("keep-links")
+	embedTitles := c.Flags.GetOptionalBool("embed-titles")
+	progress, logger := ingestOutput(c, llmLogger(cmd))
 
-	// Set defaults
-	if embeddingModelPath == "" {
-		embeddingModelPath = "/Users/ryan/.ollama/models/blobs/sha256-74701a8c35f6c8d9a4b91f3f3497643001d63e0c7a84e085bed452548fa88d45"
+	if chunkSize < 1 {
+		c.ExitWithError("--chunk-size must be at least 1", nil)
 	}
-	if indexPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		indexPath = filepath.Join(homeDir, ".otdfctl", "rag_index.json")
+	if codeChunkSize < 0 {
+		c.ExitWithError("--code-chunk-size must not be negative", nil)
+	}
+	if minChunkSize < 0 {
+		c.ExitWithError("--min-chunk-size must not be negative", nil)
 	}
+	boosts := sourceBoostsFromFlags(c, cmd)
+	since := sinceFromFlags(c, cmd, sourceType)
+
+	// Set defaults
 	if cacheDir == "" {
 		homeDir, _ := os.UserHomeDir()
 		cacheDir = filepath.Join(homeDir, ".otdfctl", "doc_cache")
 	}
 
-	c.Printf("🔧 Initializing RAG document ingestion...\n")
-	c.Printf("   Embedding model: %s\n", embeddingModelPath)
-	c.Printf("   Index path: %s\n", indexPath)
-	c.Printf("   Cache directory: %s\n", cacheDir)
+	// Ctrl-C stops ingestion after the current chunk so the work done so far can be saved
+	ctx, stop := signal.NotifyContext(c.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Initialize embedding engine
-	c.Printf("\n📥 Loading embedding model...\n")
-	embeddingEngine, err := llm.NewEmbeddingEngine(embeddingModelPath)
-	if err != nil {
-		c.ExitWithError("Failed to initialize embedding engine", err)
+	if dryRun {
+		// Chunk the source without loading the embedding model or touching the index
+		ingester := llm.NewDocumentIngester(nil, nil, cacheDir)
+		ingester.SetLogger(logger)
+		ingester.SetLanguage(lang)
+		ingester.SetMaxFileSize(maxFileSize)
+		ingester.SetChunkSize(int(chunkSize))
+		ingester.SetCodeChunkSize(int(codeChunkSize))
+		ingester.SetMinChunkSize(int(minChunkSize))
+		ingester.SetModifiedSince(since)
+		ingester.SetKeepCode(keepCode)
+	ingester.SetKeepLinks(keepLinks)
+		if err := ingester.SetPathFilter(include, exclude); err != nil {
+			c.ExitWithError("Invalid --include or --exclude pattern", err)
+		}
+
+		var plan *llm.IngestPlan
+		var err error
+		switch sourceType {
+		case "github":
+			plan, err = ingester.PlanFromGitHub(ctx)
+		case "local":
+			if sourcePath == "" {
+				c.ExitWithError("--path is required when --source=local", nil)
+			}
+			plan, err = ingester.PlanFromLocalDirectory(ctx, sourcePath)
+		default:
+			c.ExitWithError("Invalid source type. Use 'github' or 'local'", nil)
+		}
+		if err != nil {
+			c.ExitWithError("Failed to plan ingestion from "+sourceType, err)
+		}
+		printIngestPlan(c, plan)
+		return
+	}
+
+	// Reject bad patterns before spending time loading the embedding model
+	if err := llm.NewDocumentIngester(nil, nil, cacheDir).SetPathFilter(include, exclude); err != nil {
+		c.ExitWithError("Invalid --include or --exclude pattern", err)
+	}
+
+	// A running embed-serve daemon already has its model loaded
+	if embedEndpoint == "" {
+		if embeddingModelPath == "" {
+			embeddingModelPath = llm.DefaultEmbeddingModel
+		}
+		var err error
+		embeddingModelPath, err = llm.ResolveModelPath(embeddingModelPath)
+		if err != nil {
+			c.ExitWithError("Embedding model not found; pass a GGUF file or Ollama model name with --embedding-model", err)
+		}
+	}
+	vectorStore, indexPath := newVectorStore(c, cmd, indexPath)
+
+	progress.Printf("🔧 Initializing RAG document ingestion...\n")
+	if embedEndpoint != "" {
+		progress.Printf("   Embedding endpoint: %s\n", embedEndpoint)
+	} else {
+		progress.Printf("   Embedding model: %s\n", embeddingModelPath)
+	}
+	progress.Printf("   Index path: %s\n", indexPath)
+	progress.Printf("   Cache directory: %s\n", cacheDir)
+
+	var embedder llm.Embedder
+	if embedEndpoint != "" {
+		embedder = llm.NewEmbedClient(embedEndpoint)
+	} else {
+		// Initialize embedding engine
+		progress.Printf("\n📥 Loading embedding model...\n")
+		embeddingEngine, err := llm.NewEmbeddingEnginePool(embeddingModelPath, embeddingOptionsFromFlags(c, cmd))
+		if err != nil {
+			c.ExitWithError("Failed to initialize embedding engine", err)
+		}
+		defer embeddingEngine.Close()
+		embeddingEngine.SetLogger(logger)
+		embedder = embeddingEngine
 	}
-	defer embeddingEngine.Close()
 
 	// Initialize vector store
-	vectorStore := llm.NewVectorStore(indexPath)
 	if err := vectorStore.LoadIndex(); err != nil {
 		c.ExitWithError("Failed to load vector index", err)
 	}
+	defer vectorStore.Close()
 
 	// Initialize document ingester
-	ingester := llm.NewDocumentIngester(vectorStore, embeddingEngine, cacheDir)
+	ingester := llm.NewDocumentIngester(vectorStore, embedder, cacheDir)
+	ingester.SetLogger(logger)
+	if err := ingester.SetPathFilter(include, exclude); err != nil {
+		c.ExitWithError("Invalid --include or --exclude pattern", err)
+	}
+	ingester.SetEmbedTitles(embedTitles)
+	ingester.SetSourceBoosts(boosts)
+	ingester.SetLanguage(lang)
+	ingester.SetMaxFileSize(maxFileSize)
+	ingester.SetChunkSize(int(chunkSize))
+	ingester.SetCodeChunkSize(int(codeChunkSize))
+	ingester.SetMinChunkSize(int(minChunkSize))
+	ingester.SetModifiedSince(since)
+	ingester.SetKeepCode(keepCode)
+	ingester.SetKeepLinks(keepLinks)
 
-	c.Printf("\n📚 Starting document ingestion...\n")
+	progress.Printf("\n📚 Starting document ingestion...\n")
 
+	var err error
 	switch sourceType {
 	case "github":
-		if err := ingester.IngestFromGitHub(); err != nil {
-			c.ExitWithError("Failed to ingest from GitHub", err)
-		}
+		err = ingester.IngestFromGitHub(ctx)
 	case "local":
 		if sourcePath == "" {
 			c.ExitWithError("--path is required when --source=local", nil)
 		}
-		if err := ingester.IngestFromLocalDirectory(sourcePath); err != nil {
-			c.ExitWithError("Failed to ingest from local directory", err)
-		}
+		err = ingester.IngestFromLocalDirectory(ctx, sourcePath)
 	default:
 		c.ExitWithError("Invalid source type. Use 'github' or 'local'", nil)
 	}
 
+	if errors.Is(err, llm.ErrIngestionInterrupted) {
+		// Restore default signal handling so a second Ctrl-C exits immediately
+		stop()
+		c.Printf("\n⚠️  Ingestion interrupted, saving partial vector index...\n")
+		if saveErr := vectorStore.SaveIndex(); saveErr != nil {
+			c.ExitWithError("Failed to save partial vector index", saveErr)
+		}
+		c.ExitWithError(fmt.Sprintf("Partial index with %d documents saved to %s", vectorStore.GetDocumentCount(), indexPath), err)
+	}
+	if err != nil {
+		c.ExitWithError("Failed to ingest from "+sourceType, err)
+	}
+
 	// Save the updated index
-	c.Printf("\n💾 Saving vector index...\n")
+	progress.Printf("\n💾 Saving vector index...\n")
 	if err := vectorStore.SaveIndex(); err != nil {
 		c.ExitWithError("Failed to save vector index", err)
 	}
 
+	c.ExitWithJSON(map[string]interface{}{
+		"status":          "completed",
+		"source":          sourceType,
+		"total_documents": vectorStore.GetDocumentCount(),
+		"index_path":      indexPath,
+	})
+
 	c.Printf("\n✅ Document ingestion completed successfully!\n")
 	c.Printf("   Total documents: %d\n", vectorStore.GetDocumentCount())
 	c.Printf("   Index saved to: %s\n", indexPath)
 }))
 
+// addQuietFlag registers the flag that leaves out ingestion progress
+func addQuietFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("quiet", false, "Only print the final summary, leaving out per-file progress; warnings are still printed")
+}
+
+// ingestOutput returns where an ingest command reports its progress, and fileLogger for its
+// per-file messages. With --quiet progress is dropped, and so are the per-file messages
+// apart from warnings.
+func ingestOutput(c *cli.Cli, fileLogger llm.Logger) (progress, logger llm.Logger) {
+	if !c.Flags.GetOptionalBool("quiet") {
+		return c, fileLogger
+	}
+	return llm.NopLogger(), warningsOnly{logger: fileLogger}
+}
+
+// warningsOnly passes on the ingestion messages that report a problem, which start with
+// "Warning", and drops the rest
+type warningsOnly struct {
+	logger llm.Logger
+}
+
+func (w warningsOnly) Printf(format string, args ...interface{}) {
+	if strings.HasPrefix(format, "Warning") {
+		w.logger.Printf(format, args...)
+	}
+}
+
+// addBoostFlag registers the flag that boosts the retrieval score of chosen sources
+func addBoostFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArray("boost", nil, "Multiply the retrieval score of documents whose relative path, file name or directory matches a glob, e.g. 'spec=1.5' or 'blog=0.8' (repeatable; the last match wins)")
+}
+
+// sourceBoostsFromFlags reads the --boost values, exiting if one is malformed
+func sourceBoostsFromFlags(c *cli.Cli, cmd *cobra.Command) llm.SourceBoosts {
+	values, _ := cmd.Flags().GetStringArray("boost")
+	boosts, err := llm.ParseSourceBoosts(values)
+	if err != nil {
+		c.ExitWithError("Invalid --boost", err)
+	}
+	return boosts
+}
+
+// sinceFromFlags reads the --since cutoff, or the zero time when it isn't set, exiting if it is
+// malformed or the source has no modification times to compare
+func sinceFromFlags(c *cli.Cli, cmd *cobra.Command, sourceType string) time.Time {
+	value, _ := cmd.Flags().GetString("since")
+	if value == "" {
+		return time.Time{}
+	}
+	if sourceType != "local" {
+		c.ExitWithError("--since requires --source=local", nil)
+	}
+	since, err := llm.ParseSince(value, time.Now())
+	if err != nil {
+		c.ExitWithError("Invalid --since", err)
+	}
+	return since
+}
+
+// printIngestPlan reports the files and chunks a dry run would ingest
+func printIngestPlan(c *cli.Cli, plan *llm.IngestPlan) {
+	c.ExitWithJSON(plan)
+
+	c.Printf("🔍 Dry run: nothing was embedded or written\n\n")
+	for _, file := range plan.Files {
+		c.Printf("   %-60s %4d chunks  ~%d tokens\n", file.FilePath, file.Chunks, file.EstimatedTokens)
+	}
+	c.Printf("\n   Files: %d\n", len(plan.Files))
+	c.Printf("   Total chunks: %d\n", plan.TotalChunks)
+	c.Printf("   Estimated tokens: %d\n", plan.EstimatedTokens)
+}
+
 func init() {
 	// TODO: Fix flag documentation parsing and use proper doc-driven flags
 	// For now, hardcode flags temporarily
-	llmIngestCmd.Flags().String("embedding-model", "", "Path to embedding model (defaults to llama3.2:1b)")
-	llmIngestCmd.Flags().String("index-path", "", "Path to save vector index (default: ~/.otdfctl/rag_index.json)")
+	llmIngestCmd.Flags().String("embedding-model", "", "Path to embedding model file or Ollama model name (default: llama3.2:1b)")
+	llmIngestCmd.Flags().String("embed-endpoint", "", "URL of an 'llm embed-serve' daemon to embed with instead of loading --embedding-model")
+	llmIngestCmd.Flags().String("index-path", "", "Path to save vector index (default: ~/.otdfctl/rag_index.json, or ~/.otdfctl/rag_index.db with --store sqlite)")
+	addStoreFlag(&llmIngestCmd.Command)
+	addContextFlags(&llmIngestCmd.Command)
 	llmIngestCmd.Flags().String("source", "github", "Source type: 'github' or 'local'")
-	llmIngestCmd.Flags().String("path", "", "Path to local docs directory (required for --source=local)")
+	llmIngestCmd.Flags().String("path", "", "Path to a local docs directory, or a single .md or .txt file (required for --source=local)")
+	llmIngestCmd.Flags().String("lang", "", "Only ingest documents detected as this language, e.g. 'en' (documents too short to detect are kept)")
+	llmIngestCmd.Flags().StringArray("include", nil, "Only ingest documents whose relative path, file name or directory matches this glob, e.g. 'guides/*.md' (repeatable)")
+	llmIngestCmd.Flags().StringArray("exclude", nil, "Skip documents whose relative path, file name or directory matches this glob, e.g. 'CHANGELOG.md' or 'vendor' (repeatable)")
+	addBoostFlag(&llmIngestCmd.Command)
+	addQuietFlag(&llmIngestCmd.Command)
+	llmIngestCmd.Flags().Int64("max-file-size", llm.DefaultMaxFileSize, "Skip files larger than this many bytes (0 for no limit)")
+	llmIngestCmd.Flags().String("since", "", "Only ingest local files modified after this cutoff, a duration such as '24h' or '7d' or a date such as '2024-01-01'; re-ingested files replace their earlier chunks")
+	llmIngestCmd.Flags().Int32("chunk-size", 300, "Number of words per indexed chunk")
+	llmIngestCmd.Flags().Int32("code-chunk-size", 0, "Number of words per chunk of code kept by --keep-code; each code block is chunked on its own (0 uses half of --chunk-size)")
+	llmIngestCmd.Flags().Int32("min-chunk-size", 0, "Merge chunks of fewer than this many words, such as a lone heading, into a neighbouring chunk, and skip documents shorter than this (0 keeps every chunk)")
+	llmIngestCmd.Flags().Bool("keep-code", false, "Embed the code in fenced code blocks so CLI examples and config snippets are searchable, instead of replacing it with [CODE_BLOCK]")
+	llmIngestCmd.Flags().Bool("embed-titles", false, "Also embed each document's title so 'llm chat --rag-title-boost' can favor chunks whose title matches the question")
+	llmIngestCmd.Flags().Bool("keep-links", false, "Keep link URLs in parentheses after the link text so answers can cite sources")
+	llmIngestCmd.Flags().Bool("dry-run", false, "Report the files and chunks that would be ingested without loading the embedding model or writing the index")
 	llmIngestCmd.Flags().String("cache-dir", "", "Directory for caching downloaded docs (default: ~/.otdfctl/doc_cache)")
 
 	// Add ingest command to llm parent
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"bufio"
 
@@ -21,31 +22,104 @@ var llmIngestSimpleCmd = &cobra.Command{
 	Long:  "Ingest OpenTDF documentation into a simple keyword-based index for RAG (no embeddings required)",
 	Run: func(cmd *cobra.Command, args []string) {
 	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
 
 	indexPath := c.Flags.GetOptionalString("index-path")
 	sourcePath := c.Flags.GetOptionalString("path")
+	dryRun := c.Flags.GetOptionalBool("dry-run")
+	lang := strings.ToLower(c.Flags.GetOptionalString("lang"))
+	keepCode := c.Flags.GetOptionalBool("keep-code")
+	keepLinks := c.Flags.GetOptionalBool("keep-links")
+	keywordMinCount := int(c.Flags.GetOptionalInt32("keyword-min-count"))
+	keywordMinLength := int(c.Flags.GetOptionalInt32("keyword-min-length"))
+	progress, logger := ingestOutput(c, c)
+
+	if keywordMinCount < 1 || keywordMinLength < 1 {
+		c.ExitWithError("--keyword-min-count and --keyword-min-length must be at least 1", nil)
+	}
+	boosts := sourceBoostsFromFlags(c, cmd)
 
 	// Set defaults
-	if indexPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		indexPath = filepath.Join(homeDir, ".otdfctl", "simple_rag_index.json")
-	}
+	store, indexPath := newKeywordStore(c, cmd, indexPath)
 	if sourcePath == "" {
 		sourcePath = "./docs-main"
 	}
 
-	c.Printf("🔧 Initializing Simple RAG document ingestion...\n")
-	c.Printf("   Index path: %s\n", indexPath)
-	c.Printf("   Source path: %s\n", sourcePath)
+	progress.Printf("🔧 Initializing Simple RAG document ingestion...\n")
+	progress.Printf("   Index path: %s\n", indexPath)
+	progress.Printf("   Source path: %s\n", sourcePath)
 
-	// Initialize simple RAG store
-	store := llm.NewSimpleRAGStore(indexPath)
-	if err := store.LoadIndex(); err != nil {
-		c.ExitWithError("Failed to load simple RAG index", err)
+	// Initialize simple RAG store; a dry run leaves the index alone
+	if !dryRun {
+		if err := store.LoadIndex(); err != nil {
+			c.ExitWithError("Failed to load simple RAG index", err)
+		}
+		defer store.Close()
+	}
+
+	progress.Printf("\n📚 Starting document ingestion...\n")
+
+	opts := simpleIngestOptions{
+		lang:             lang,
+		keepCode:         keepCode,
+		keepLinks:        keepLinks,
+		keywordMinCount:  keywordMinCount,
+		keywordMinLength: keywordMinLength,
+		boosts:           boosts,
+	}
+	if dryRun {
+		opts.plan = &llm.IngestPlan{}
+	}
+	totalProcessed, err := ingestSimpleDocuments(logger, store, sourcePath, opts)
+	if err != nil {
+		c.ExitWithError("Failed to process documents", err)
 	}
 
-	c.Printf("\n📚 Starting document ingestion...\n")
+	if dryRun {
+		printIngestPlan(c, opts.plan)
+		return
+	}
 
+	// Save the updated index
+	progress.Printf("\n💾 Saving simple RAG index...\n")
+	if err := store.SaveIndex(); err != nil {
+		c.ExitWithError("Failed to save simple RAG index", err)
+	}
+
+	c.ExitWithJSON(map[string]interface{}{
+		"status":          "completed",
+		"documents_added": totalProcessed,
+		"total_documents": store.GetDocumentCount(),
+		"index_path":      indexPath,
+	})
+
+	c.Printf("\n✅ Simple document ingestion completed successfully!\n")
+	c.Printf("   Total documents: %d\n", totalProcessed)
+	c.Printf("   Index saved to: %s\n", indexPath)
+	},
+}
+
+// simpleIngestOptions controls ingestSimpleDocuments
+type simpleIngestOptions struct {
+	// lang skips documents detected as another language when set
+	lang string
+	// keepCode indexes the code in fenced code blocks instead of dropping it
+	keepCode bool
+	// keepLinks indexes link URLs alongside their text
+	keepLinks bool
+	// keywordMinCount and keywordMinLength select a document's keywords; zero uses the
+	// defaults, words of at least 4 characters appearing at least twice
+	keywordMinCount  int
+	keywordMinLength int
+	// boosts multiply the score of documents from matching sources
+	boosts llm.SourceBoosts
+	// plan, when set, records each file instead of adding it to the store
+	plan *llm.IngestPlan
+}
+
+// ingestSimpleDocuments adds each markdown file under sourcePath, or sourcePath itself when it is a
+// file, to the keyword store and returns how many documents were added. Progress goes to out.
+func ingestSimpleDocuments(out llm.Logger, store llm.KeywordStore, sourcePath string, opts simpleIngestOptions) (int, error) {
 	totalProcessed := 0
 
 	err := filepath.WalkDir(sourcePath, func(path string, d fs.DirEntry, err error) error {
@@ -56,19 +130,34 @@ var llmIngestSimpleCmd = &cobra.Command{
 		// Only process markdown files
 		if !d.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
 			relPath, _ := filepath.Rel(sourcePath, path)
-			c.Printf("Processing: %s\n", relPath)
+			if path == filepath.Clean(sourcePath) {
+				relPath = filepath.Base(path)
+			}
+			out.Printf("Processing: %s\n", relPath)
 
 			content, err := os.ReadFile(path)
 			if err != nil {
-				c.Printf("Warning: failed to read %s: %v\n", path, err)
+				out.Printf("Warning: failed to read %s: %v\n", path, err)
 				return nil
 			}
 
-			processed := processMarkdownSimple(string(content))
+			processed := processMarkdownSimple(string(content), opts.keepCode, opts.keepLinks)
 			if strings.TrimSpace(processed) == "" {
 				return nil
 			}
 
+			language := llm.DetectLanguage(processed)
+			if opts.lang != "" && language != "" && language != opts.lang {
+				out.Printf("Skipping %s: detected language %q\n", relPath, language)
+				return nil
+			}
+
+			// Each file is stored as a single document
+			if opts.plan != nil {
+				opts.plan.Add(llm.NewFilePlan(relPath, []string{processed}))
+				return nil
+			}
+
 			// Generate document ID
 			hash := sha256.Sum256([]byte(relPath))
 			docID := hex.EncodeToString(hash[:])[:16]
@@ -84,11 +173,15 @@ var llmIngestSimpleCmd = &cobra.Command{
 				Content:  processed,
 				URL:      "file://" + path,
 				FilePath: relPath,
-				Keywords: extractKeywordsSimple(processed),
+				Keywords: extractKeywordsSimple(processed, opts.keywordMinCount, opts.keywordMinLength),
+				Language: language,
+				Boost:    opts.boosts.Weight(relPath),
 			}
 
+			// Re-ingesting a file replaces its earlier copy
+			store.RemoveDocuments(llm.DocumentFilter{ID: docID})
 			if err := store.AddDocument(doc); err != nil {
-				c.Printf("Warning: failed to add document to store: %v\n", err)
+				out.Printf("Warning: failed to add document to store: %v\n", err)
 				return nil
 			}
 
@@ -98,44 +191,82 @@ var llmIngestSimpleCmd = &cobra.Command{
 		return nil
 	})
 
+	return totalProcessed, err
+}
+
+// ingestIntoChat adds the markdown at path to the storeKind keyword index at indexPath, saves the index,
+// and swaps the refreshed store into the running engine, searched together with any searchAlso indexes.
+// It returns the store and how many documents were added.
+func ingestIntoChat(out llm.Logger, engine *llm.SimpleChatEngine, storeKind, indexPath, path string, searchAlso ...llm.KeywordStore) (llm.KeywordStore, int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, 0, err
+	}
+
+	// Build on the index as saved rather than the store the engine is reading from
+	store, err := llm.NewKeywordStore(storeKind, indexPath)
 	if err != nil {
-		c.ExitWithError("Failed to process documents", err)
+		return nil, 0, err
+	}
+	if err := store.LoadIndex(); err != nil {
+		return nil, 0, err
 	}
 
-	// Save the updated index
-	c.Printf("\n💾 Saving simple RAG index...\n")
+	added, err := ingestSimpleDocuments(out, store, path, simpleIngestOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	if added == 0 {
+		return store, 0, nil
+	}
 	if err := store.SaveIndex(); err != nil {
-		c.ExitWithError("Failed to save simple RAG index", err)
+		return nil, 0, err
 	}
 
-	c.Printf("\n✅ Simple document ingestion completed successfully!\n")
-	c.Printf("   Total documents: %d\n", totalProcessed)
-	c.Printf("   Index saved to: %s\n", indexPath)
-	},
+	if len(searchAlso) > 0 {
+		engine.EnableSimpleRAG(llm.NewMultiKeywordStore(append([]llm.KeywordStore{store}, searchAlso...)...))
+	} else {
+		engine.EnableSimpleRAG(store)
+	}
+	return store, added, nil
 }
 
-// processMarkdownSimple cleans markdown content for simple text matching
-func processMarkdownSimple(content string) string {
+// processMarkdownSimple cleans markdown content for simple text matching.
+// With keepCode the code in fenced code blocks is kept, minus the fences, and with
+// keepLinks each link's URL is kept in parentheses after its text.
+func processMarkdownSimple(content string, keepCode, keepLinks bool) string {
 	// Remove YAML frontmatter
 	frontmatterRegex := regexp.MustCompile(`(?s)^---\n.*?\n---\n`)
 	content = frontmatterRegex.ReplaceAllString(content, "")
 
-	// Remove code blocks but keep inline code
-	codeBlockRegex := regexp.MustCompile("(?s)```.*?```")
-	content = codeBlockRegex.ReplaceAllString(content, " [CODE_BLOCK] ")
+	// Set code blocks aside so the cleanup below doesn't mangle them, or drop them but keep inline code
+	var codeBlocks []string
+	if keepCode {
+		content, codeBlocks = llm.StashCodeBlocks(content)
+	} else {
+		codeBlockRegex := regexp.MustCompile("(?s)```.*?```")
+		content = codeBlockRegex.ReplaceAllString(content, " [CODE_BLOCK] ")
+	}
+
+	// Rewrite tables as "header: value" rows before their pipes are lost
+	content = llm.FlattenTables(content)
 
 	// Remove HTML tags
 	htmlRegex := regexp.MustCompile(`<[^>]*>`)
 	content = htmlRegex.ReplaceAllString(content, "")
 
-	// Remove markdown links but keep text
-	linkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
-	content = linkRegex.ReplaceAllString(content, "$1")
-
 	// Remove image references
 	imageRegex := regexp.MustCompile(`!\[[^\]]*\]\([^)]+\)`)
 	content = imageRegex.ReplaceAllString(content, "")
 
+	// Set links aside as "text (url)", or remove them but keep text
+	var links []string
+	if keepLinks {
+		content, links = llm.StashLinks(content)
+	} else {
+		linkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+		content = linkRegex.ReplaceAllString(content, "$1")
+	}
+
 	// Clean up markdown formatting
 	content = regexp.MustCompile(`#{1,6}\s*`).ReplaceAllString(content, "") // Remove headers
 	content = regexp.MustCompile(`\*{1,2}([^*]+)\*{1,2}`).ReplaceAllString(content, "$1") // Remove bold/italic
@@ -156,7 +287,8 @@ func processMarkdownSimple(content string) string {
 		}
 	}
 
-	return strings.Join(cleanLines, "\n")
+	content = llm.RestoreLinks(strings.Join(cleanLines, "\n"), links)
+	return llm.RestoreCodeBlocks(content, codeBlocks)
 }
 
 // extractTitleSimple extracts the title from markdown content
@@ -176,8 +308,21 @@ func extractTitleSimple(content string) string {
 	return ""
 }
 
-// extractKeywordsSimple extracts keywords for basic search
-func extractKeywordsSimple(content string) []string {
+const (
+	defaultKeywordMinCount  = 2
+	defaultKeywordMinLength = 4
+)
+
+// extractKeywordsSimple extracts keywords for basic search: words of at least minLength
+// characters that appear at least minCount times, sorted. Zero selects the defaults.
+func extractKeywordsSimple(content string, minCount, minLength int) []string {
+	if minCount <= 0 {
+		minCount = defaultKeywordMinCount
+	}
+	if minLength <= 0 {
+		minLength = defaultKeywordMinLength
+	}
+
 	// Simple keyword extraction
 	words := strings.FieldsFunc(strings.ToLower(content), func(c rune) bool {
 		return !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'))
@@ -185,7 +330,7 @@ func extractKeywordsSimple(content string) []string {
 
 	keywordMap := make(map[string]int)
 	for _, word := range words {
-		if len(word) > 3 {
+		if len(word) >= minLength {
 			keywordMap[word]++
 		}
 	}
@@ -193,18 +338,28 @@ func extractKeywordsSimple(content string) []string {
 	// Get most frequent words
 	var keywords []string
 	for word, count := range keywordMap {
-		if count >= 2 { // Must appear at least twice
+		if count >= minCount {
 			keywords = append(keywords, word)
 		}
 	}
+	sort.Strings(keywords)
 
 	return keywords
 }
 
 func init() {
 	// TODO: Fix flag documentation parsing and use proper doc-driven flags
-	llmIngestSimpleCmd.Flags().String("index-path", "", "Path to save simple RAG index (default: ~/.otdfctl/simple_rag_index.json)")
+	llmIngestSimpleCmd.Flags().String("index-path", "", "Path to save simple RAG index (default: ~/.otdfctl/simple_rag_index.json, or ~/.otdfctl/simple_rag_index.db with --store sqlite)")
+	addStoreFlag(llmIngestSimpleCmd)
 	llmIngestSimpleCmd.Flags().String("path", "./docs-main", "Path to local docs directory")
+	llmIngestSimpleCmd.Flags().String("lang", "", "Only ingest documents detected as this language, e.g. 'en' (documents too short to detect are kept)")
+	addBoostFlag(llmIngestSimpleCmd)
+	addQuietFlag(llmIngestSimpleCmd)
+	llmIngestSimpleCmd.Flags().Bool("keep-code", false, "Index the code in fenced code blocks so CLI examples are searchable, instead of replacing it with [CODE_BLOCK]")
+	llmIngestSimpleCmd.Flags().Bool("keep-links", false, "Index link URLs in parentheses after the link text instead of dropping them")
+	llmIngestSimpleCmd.Flags().Int32("keyword-min-count", defaultKeywordMinCount, "Minimum number of times a word must appear in a document to be stored as a keyword")
+	llmIngestSimpleCmd.Flags().Int32("keyword-min-length", defaultKeywordMinLength, "Minimum length in characters of a stored keyword")
+	llmIngestSimpleCmd.Flags().Bool("dry-run", false, "Report the files that would be ingested without writing the index")
 
 	// Add ingest-simple command to llm parent
 	llmCmd.AddCommand(llmIngestSimpleCmd)
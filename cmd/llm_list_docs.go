@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+// documentIndex is the part of the vector and keyword stores used by the index management commands
+type documentIndex interface {
+	LoadIndex() error
+	SaveIndex() error
+	GetDocumentCount() int
+	ListDocuments(filter llm.DocumentFilter) []llm.DocumentInfo
+	RemoveDocuments(filter llm.DocumentFilter) int
+	Clear()
+}
+
+// addDocumentIndexFlags registers the flags that select which index a command operates on
+func addDocumentIndexFlags(cmd *cobra.Command) {
+	cmd.Flags().String("index-path", "", "Path to the index (default: ~/.otdfctl/rag_index.json, or ~/.otdfctl/simple_rag_index.json with --simple; .db instead of .json with --store sqlite)")
+	cmd.Flags().Bool("simple", false, "Operate on a keyword index built by ingest-simple instead of a vector index")
+	addStoreFlag(cmd)
+}
+
+// addStoreFlag registers the flag that selects the index backend
+func addStoreFlag(cmd *cobra.Command) {
+	cmd.Flags().String("store", llm.StoreJSON, "Index backend: json, or sqlite for large indexes (default paths: ~/.otdfctl/rag_index.db and ~/.otdfctl/simple_rag_index.db)")
+}
+
+// defaultVectorIndexPath returns where a vector index of the given store kind lives by default
+func defaultVectorIndexPath(kind string) string {
+	homeDir, _ := os.UserHomeDir()
+	if kind == llm.StoreSQLite {
+		return filepath.Join(homeDir, ".otdfctl", "rag_index.db")
+	}
+	return filepath.Join(homeDir, ".otdfctl", "rag_index.json")
+}
+
+// newVectorStore creates the vector store selected by --store, defaulting indexPath for that
+// store, and returns it with the path it uses
+func newVectorStore(c *cli.Cli, cmd *cobra.Command, indexPath string) (llm.Store, string) {
+	kind := c.Flags.GetOptionalString("store")
+	if indexPath == "" {
+		indexPath = defaultVectorIndexPath(kind)
+	}
+	store, err := llm.NewStore(kind, indexPath)
+	if err != nil {
+		c.ExitWithError("Invalid --store", err)
+	}
+	store.SetLogger(llmLogger(cmd))
+	return store, indexPath
+}
+
+// defaultKeywordIndexPath returns where a keyword index of the given store kind lives by default
+func defaultKeywordIndexPath(kind string) string {
+	homeDir, _ := os.UserHomeDir()
+	if kind == llm.StoreSQLite {
+		return filepath.Join(homeDir, ".otdfctl", "simple_rag_index.db")
+	}
+	return filepath.Join(homeDir, ".otdfctl", "simple_rag_index.json")
+}
+
+// newKeywordStore creates the keyword store selected by --store, defaulting indexPath for that
+// store, and returns it with the path it uses
+func newKeywordStore(c *cli.Cli, cmd *cobra.Command, indexPath string) (llm.KeywordStore, string) {
+	kind := c.Flags.GetOptionalString("store")
+	if indexPath == "" {
+		indexPath = defaultKeywordIndexPath(kind)
+	}
+	store, err := llm.NewKeywordStore(kind, indexPath)
+	if err != nil {
+		c.ExitWithError("Invalid --store", err)
+	}
+	store.SetLogger(llmLogger(cmd))
+	return store, indexPath
+}
+
+// firstPath returns the first of paths, or "" for the default index
+func firstPath(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	return paths[0]
+}
+
+// newVectorStores creates the vector store for each of indexPaths, or for the default index
+// when there are none
+func newVectorStores(c *cli.Cli, cmd *cobra.Command, indexPaths []string) []llm.Store {
+	if len(indexPaths) == 0 {
+		indexPaths = []string{""}
+	}
+	stores := make([]llm.Store, len(indexPaths))
+	for i, path := range indexPaths {
+		stores[i], _ = newVectorStore(c, cmd, path)
+	}
+	return stores
+}
+
+// newKeywordStores creates the keyword store for each of indexPaths, or for the default index
+// when there are none
+func newKeywordStores(c *cli.Cli, cmd *cobra.Command, indexPaths []string) []llm.KeywordStore {
+	if len(indexPaths) == 0 {
+		indexPaths = []string{""}
+	}
+	stores := make([]llm.KeywordStore, len(indexPaths))
+	for i, path := range indexPaths {
+		stores[i], _ = newKeywordStore(c, cmd, path)
+	}
+	return stores
+}
+
+// loadDocumentIndex loads the index selected by the flags registered with addDocumentIndexFlags
+func loadDocumentIndex(c *cli.Cli, cmd *cobra.Command) (documentIndex, string) {
+	indexPath := c.Flags.GetOptionalString("index-path")
+	simple := c.Flags.GetOptionalBool("simple")
+
+	var index documentIndex
+	if simple {
+		index, indexPath = newKeywordStore(c, cmd, indexPath)
+	} else {
+		index, indexPath = newVectorStore(c, cmd, indexPath)
+	}
+
+	if err := index.LoadIndex(); err != nil {
+		c.ExitWithError("Failed to load index", err)
+	}
+	return index, indexPath
+}
+
+var llmListDocsCmd = man.Docs.GetCommand("llm/list-docs", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	index, _ := loadDocumentIndex(c, cmd)
+	docs := index.ListDocuments(llm.DocumentFilter{
+		SourceFile: c.Flags.GetOptionalString("source-file"),
+		Search:     c.Flags.GetOptionalString("search"),
+		Offset:     int(c.Flags.GetOptionalInt32("offset")),
+		Limit:      int(c.Flags.GetOptionalInt32("limit")),
+	})
+
+	c.ExitWithJSON(docs)
+
+	if len(docs) == 0 {
+		c.Println("No documents found.")
+		return
+	}
+	for _, doc := range docs {
+		c.Printf("%s\n", doc.ID)
+		c.Printf("   Title:  %s\n", doc.Title)
+		c.Printf("   Source: %s (chunk %d)\n", doc.FilePath, doc.ChunkIndex)
+		c.Printf("   Length: %d characters\n", doc.ContentLength)
+		if doc.Language != "" {
+			c.Printf("   Language: %s\n", doc.Language)
+		}
+	}
+	c.Printf("\n📄 Showing %d of %d documents\n", len(docs), index.GetDocumentCount())
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	addDocumentIndexFlags(&llmListDocsCmd.Command)
+	llmListDocsCmd.Flags().String("source-file", "", "Only list documents ingested from this file")
+	llmListDocsCmd.Flags().String("search", "", "Only list documents whose title or content contains this text")
+	llmListDocsCmd.Flags().Int32("limit", 0, "Maximum number of documents to list (0 for all)")
+	llmListDocsCmd.Flags().Int32("offset", 0, "Number of matching documents to skip")
+	llmListDocsCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add list-docs command to llm parent
+	llmCmd.AddCommand(&llmListDocsCmd.Command)
+}
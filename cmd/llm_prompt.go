@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opentdf/otdfctl/pkg/llm"
+)
+
+// promptOptions configures a single non-interactive chat turn
+type promptOptions struct {
+	stream     bool
+	outputPath string
+	appendFile bool
+	// markdown renders the finished response instead of streaming raw tokens
+	markdown *markdownRenderer
+	// wrapWidth word-wraps a plain response to this many columns; 0 leaves it unwrapped
+	wrapWidth int
+	// exportPath receives a Markdown transcript of the prompt and response
+	exportPath string
+	// echo prints the question before its answer, and echoSystem the system prompt too
+	echo       bool
+	echoSystem bool
+}
+
+// promptResult is printed for `llm chat --prompt` in JSON mode
+type promptResult struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Output   string `json:"output,omitempty"`
+	// SystemPrompt is the system prompt the answer was generated with, for --echo-system-prompt
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// RetrievalScore is the best RAG match for the prompt, omitted when RAG is off
+	RetrievalScore *float32 `json:"retrieval_score,omitempty"`
+	LowConfidence  bool     `json:"low_confidence,omitempty"`
+	// Cached is set when the response came from the response cache
+	Cached bool `json:"cached,omitempty"`
+}
+
+// newPromptResult describes the answer to a prompt for JSON output
+func newPromptResult(prompt string, response llm.SimpleResponse, outputPath string) promptResult {
+	result := promptResult{
+		Prompt:        prompt,
+		Response:      response.Content,
+		Output:        outputPath,
+		LowConfidence: response.LowConfidence,
+		Cached:        response.Cached,
+	}
+	if response.RAGUsed {
+		result.RetrievalScore = &response.RetrievalScore
+	}
+	return result
+}
+
+// runPrompt answers a single prompt, writing the response to out (token by token when
+// streaming) and saving it to opts.outputPath when set
+func runPrompt(ctx context.Context, engine llm.ChatGenerator, out io.Writer, systemPrompt, prompt string, opts promptOptions) (llm.SimpleResponse, error) {
+	if systemPrompt == "" {
+		systemPrompt = getDefaultSystemPrompt()
+	}
+	messages := llm.SetSystemMessage(nil, systemPrompt)
+	messages = append(messages, llm.ChatMessage{Role: "user", Content: prompt, Time: time.Now()})
+	return runConversation(ctx, engine, out, messages, opts)
+}
+
+// loadConversation reads a JSON Lines conversation to replay from path, or from stdin when
+// path is "" or "-". A non-empty systemPrompt replaces the conversation's own system message;
+// a conversation without one gets the default system prompt.
+func loadConversation(path, systemPrompt string) ([]llm.ChatMessage, error) {
+	var r io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	messages, err := llm.ReadConversation(r)
+	if err != nil {
+		return nil, err
+	}
+	if systemPrompt == "" && messages[0].Role != "system" {
+		systemPrompt = getDefaultSystemPrompt()
+	}
+	if systemPrompt != "" {
+		messages = llm.SetSystemMessage(messages, systemPrompt)
+	}
+	return messages, nil
+}
+
+// runConversation generates the next assistant turn of messages, writing it to out (token by
+// token when streaming) and saving it to opts.outputPath when set
+func runConversation(ctx context.Context, engine llm.ChatGenerator, out io.Writer, messages []llm.ChatMessage, opts promptOptions) (llm.SimpleResponse, error) {
+	if opts.echo || opts.echoSystem {
+		writeEcho(out, messages, opts.echoSystem)
+	}
+
+	var response llm.SimpleResponse
+	if opts.stream && !opts.markdown.enabled() {
+		wrapper := newTextWrapper(opts.wrapWidth)
+		response = engine.ChatStream(ctx, messages, func(token string) {
+			fmt.Fprint(out, wrapper.Add(token))
+		})
+		fmt.Fprint(out, wrapper.Flush())
+	} else {
+		response = engine.Chat(messages)
+		if response.Error == nil {
+			fmt.Fprint(out, renderResponse(response.Content, opts.markdown, opts.wrapWidth))
+		}
+	}
+	if response.Error != nil {
+		return response, response.Error
+	}
+	fmt.Fprintln(out)
+
+	if opts.outputPath != "" {
+		if err := writeOutputFile(opts.outputPath, response.Content, opts.appendFile); err != nil {
+			return response, err
+		}
+	}
+
+	if opts.exportPath != "" {
+		messages = append(messages, llm.ChatMessage{Role: "assistant", Content: response.Content, Time: time.Now()})
+		if err := exportTranscript(opts.exportPath, messages); err != nil {
+			return response, err
+		}
+	}
+
+	return response, nil
+}
+
+// writeEcho prints the question being answered, the last of messages, and with system the
+// system prompt before it, so saved output keeps each answer with what it answers
+func writeEcho(out io.Writer, messages []llm.ChatMessage, system bool) {
+	if system && len(messages) > 0 && messages[0].Role == "system" {
+		fmt.Fprintf(out, "System: %s\n\n", messages[0].Content)
+	}
+	if len(messages) > 0 {
+		fmt.Fprintf(out, "Question: %s\n\n", messages[len(messages)-1].Content)
+	}
+	fmt.Fprint(out, "Answer: ")
+}
+
+// exportTranscript saves the conversation to path as a Markdown transcript
+func exportTranscript(path string, messages []llm.ChatMessage) error {
+	var b strings.Builder
+	if err := llm.WriteTranscript(&b, messages, time.Now()); err != nil {
+		return err
+	}
+	return writeOutputFile(path, b.String(), false)
+}
+
+// writeOutputFile saves content to path, creating parent directories as needed.
+// Content always ends with a newline so appended responses don't run together.
+func writeOutputFile(path, content string, appendFile bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendFile {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmRemoveDocCmd = man.Docs.GetCommand("llm/remove-doc", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	filter := llm.DocumentFilter{
+		ID:         c.Flags.GetOptionalString("id"),
+		SourceFile: c.Flags.GetOptionalString("source-file"),
+	}
+	if filter.ID == "" && filter.SourceFile == "" {
+		c.ExitWithError("Either --id or --source-file is required", nil)
+	}
+	force := c.Flags.GetOptionalBool("yes")
+
+	index, indexPath := loadDocumentIndex(c, cmd)
+
+	removed, err := removeMatchingDocuments(index, filter, func(matching int) {
+		cli.ConfirmAction(cli.ActionDelete, fmt.Sprintf("%d documents from", matching), indexPath, force)
+	})
+	if errors.Is(err, errNoMatchingDocuments) {
+		c.ExitWithError("No matching documents found in "+indexPath, nil)
+	}
+	if err != nil {
+		c.ExitWithError("Failed to save index", err)
+	}
+
+	c.ExitWithJSON(map[string]interface{}{
+		"removed":         removed,
+		"total_documents": index.GetDocumentCount(),
+		"index_path":      indexPath,
+	})
+
+	c.Printf("🗑️  Removed %d chunks from %s\n", removed, indexPath)
+	c.Printf("   Remaining documents: %d\n", index.GetDocumentCount())
+}))
+
+// errNoMatchingDocuments is returned by removeMatchingDocuments when the filter matches nothing
+var errNoMatchingDocuments = errors.New("no matching documents")
+
+// removeMatchingDocuments removes the documents matching filter from index and saves it, once
+// confirm, which exits if the user declines, has been shown how many match. When nothing
+// matches the index is left as it is and errNoMatchingDocuments is returned.
+func removeMatchingDocuments(index documentIndex, filter llm.DocumentFilter, confirm func(matching int)) (int, error) {
+	matching := len(index.ListDocuments(filter))
+	if matching == 0 {
+		return 0, errNoMatchingDocuments
+	}
+	confirm(matching)
+
+	removed := index.RemoveDocuments(filter)
+	if err := index.SaveIndex(); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	addDocumentIndexFlags(&llmRemoveDocCmd.Command)
+	llmRemoveDocCmd.Flags().String("id", "", "ID of the document to remove")
+	llmRemoveDocCmd.Flags().String("source-file", "", "Remove every document ingested from this file")
+	llmRemoveDocCmd.Flags().Bool("yes", false, "Skip the confirmation prompt")
+	llmRemoveDocCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add remove-doc command to llm parent
+	llmCmd.AddCommand(&llmRemoveDocCmd.Command)
+}
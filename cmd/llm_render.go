@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"golang.org/x/term"
+)
+
+const (
+	renderPlain    = "plain"
+	renderMarkdown = "markdown"
+
+	renderWidthDefault = 80
+	renderWidthMax     = 120
+)
+
+// resolveRenderMode validates the --render value and falls back to plain text when
+// out is not a terminal, so piped or redirected output never contains escape codes
+func resolveRenderMode(mode string, out *os.File) (string, error) {
+	switch mode {
+	case renderPlain:
+		return renderPlain, nil
+	case renderMarkdown:
+		if !term.IsTerminal(int(out.Fd())) {
+			return renderPlain, nil
+		}
+		return renderMarkdown, nil
+	default:
+		return "", fmt.Errorf("unknown render mode %q, expected %s or %s", mode, renderMarkdown, renderPlain)
+	}
+}
+
+// markdownRenderer formats assistant responses as styled terminal output.
+// A nil *markdownRenderer leaves responses as plain text.
+type markdownRenderer struct {
+	renderer *glamour.TermRenderer
+}
+
+// colorEnabled reports whether rendered output may use color, honoring --no-color and
+// the NO_COLOR convention (https://no-color.org)
+func colorEnabled(noColor bool) bool {
+	return !noColor && os.Getenv("NO_COLOR") == ""
+}
+
+// newMarkdownRenderer returns a renderer for the given mode, or nil for plain output.
+// Code blocks are syntax highlighted unless color is disabled.
+func newMarkdownRenderer(mode string, out *os.File, color bool) (*markdownRenderer, error) {
+	if mode != renderMarkdown {
+		return nil, nil
+	}
+
+	width := min(terminalWidth(out), renderWidthMax)
+
+	// Auto style would pick glamour's unstyled output whenever stdout isn't a terminal;
+	// resolveRenderMode already made that decision
+	style := styles.LightStyle
+	if lipgloss.HasDarkBackground() {
+		style = styles.DarkStyle
+	}
+	profile := termenv.TrueColor
+	if !color {
+		profile = termenv.Ascii
+	}
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(style),
+		glamour.WithColorProfile(profile),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create markdown renderer: %w", err)
+	}
+	return &markdownRenderer{renderer: r}, nil
+}
+
+// enabled reports whether responses are rendered rather than printed as they stream
+func (m *markdownRenderer) enabled() bool {
+	return m != nil
+}
+
+// Render formats a complete response, returning it unchanged when rendering is off or fails
+func (m *markdownRenderer) Render(text string) string {
+	if m == nil {
+		return text
+	}
+	out, err := m.renderer.Render(labelCodeFences(text))
+	if err != nil {
+		return text
+	}
+	return strings.Trim(out, "\n")
+}
+
+// labelCodeFences adds a language to fenced code blocks that don't name one, so the
+// highlighter doesn't have to guess from a few lines of code
+func labelCodeFences(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		fence := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(fence, "```") {
+			continue
+		}
+
+		// Find the closing fence
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+			end++
+		}
+
+		if fence == "```" {
+			if lang := guessCodeLanguage(strings.Join(lines[i+1:min(end, len(lines))], "\n")); lang != "" {
+				lines[i] += lang
+			}
+		}
+		i = end
+	}
+	return strings.Join(lines, "\n")
+}
+
+// guessCodeLanguage recognizes the languages common in OpenTDF examples: shell commands,
+// Go, JSON and YAML. It returns "" when the code matches none of them.
+func guessCodeLanguage(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	first := strings.SplitN(trimmed, "\n", 2)[0]
+	for _, prefix := range []string{"package ", "func ", "import ("} {
+		if strings.HasPrefix(first, prefix) {
+			return "go"
+		}
+	}
+	for _, prefix := range []string{"$ ", "otdfctl ", "curl ", "docker ", "export ", "go ", "brew ", "kubectl ", "helm ", "#!/"} {
+		if strings.HasPrefix(first, prefix) {
+			return "bash"
+		}
+	}
+
+	// YAML documents start with a key, a list item or a document marker
+	if first == "---" || strings.HasPrefix(first, "- ") || (strings.Contains(first, ":") && !strings.ContainsAny(first, "{(;")) {
+		return "yaml"
+	}
+	return ""
+}
+
+// chatPrinter is where chat writes its status messages and responses; *cli.Cli satisfies it
+type chatPrinter interface {
+	Printf(format string, args ...interface{})
+	Println(args ...interface{})
+}
+
+// newChatPrinter returns c, or with plain a printer that strips emoji from everything
+func newChatPrinter(c *cli.Cli, plain bool) chatPrinter {
+	if plain {
+		return plainPrinter{out: c}
+	}
+	return c
+}
+
+// plainPrinter removes emoji from messages before printing them, for --plain output
+type plainPrinter struct {
+	out chatPrinter
+}
+
+func (p plainPrinter) Printf(format string, args ...interface{}) {
+	p.out.Printf("%s", llm.StripEmoji(fmt.Sprintf(format, args...)))
+}
+
+func (p plainPrinter) Println(args ...interface{}) {
+	p.out.Println(llm.StripEmoji(fmt.Sprint(args...)))
+}
+
+// plainWriter removes emoji from everything written to w, for --plain output
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p plainWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(p.w, llm.StripEmoji(string(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+// searchHit is one document returned by `llm search`
+type searchHit struct {
+	Rank     int     `json:"rank"`
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	FilePath string  `json:"file_path"`
+	Score    float32 `json:"score"`
+	Content  string  `json:"content"`
+	// Explanation is a *llm.KeywordExplanation or *llm.VectorExplanation when --explain is set
+	Explanation interface{} `json:"explanation,omitempty"`
+}
+
+var llmSearchCmd = man.Docs.GetCommand("llm/search", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+	loadLLMDefaults(c, cmd)
+
+	query := strings.Join(args, " ")
+	if strings.TrimSpace(query) == "" {
+		c.ExitWithError("A search query is required", nil)
+	}
+	embeddingModelPath := c.Flags.GetOptionalString("embedding-model")
+	simple := c.Flags.GetOptionalBool("simple")
+	topK := int(c.Flags.GetOptionalInt32("top-k"))
+	titleBoost, _ := cmd.Flags().GetFloat32("title-boost")
+	explain := c.Flags.GetOptionalBool("explain")
+
+	if !simple && embeddingModelPath == "" {
+		c.ExitWithError("--embedding-model is required to search a vector index (use --simple for a keyword index)", nil)
+	}
+	if titleBoost < 0 || titleBoost > 1 {
+		c.ExitWithError("--title-boost must be between 0 and 1", nil)
+	}
+
+	index, _ := loadDocumentIndex(c, cmd)
+	var embedder llm.Embedder
+	if vectorStore, ok := index.(llm.Store); ok {
+		vectorStore.SetTitleBoost(titleBoost)
+
+		embeddingEngine, err := llm.NewEmbeddingEngine(resolveModelArg(c, embeddingModelPath))
+		if err != nil {
+			c.ExitWithError("Failed to initialize embedding engine", err)
+		}
+		defer embeddingEngine.Close()
+		embedder = embeddingEngine
+	}
+
+	hits, err := runSearch(index, embedder, query, topK, explain)
+	if err != nil {
+		c.ExitWithError("Search failed", err)
+	}
+
+	c.ExitWithJSON(hits)
+
+	if len(hits) == 0 {
+		c.Println("No matching documents found.")
+		return
+	}
+	for _, hit := range hits {
+		c.Printf("%d. %s (%s) - score %.4f\n", hit.Rank, hit.Title, hit.FilePath, hit.Score)
+		c.Printf("   %s\n", previewLine(hit.Content, 160))
+		if explain {
+			c.Printf("%s", formatExplanation(hit.Explanation))
+		}
+	}
+}))
+
+// runSearch searches a keyword or vector index for query, explaining each hit's score when
+// explain is set. A vector index needs an embedder for the query.
+func runSearch(index documentIndex, embedder llm.Embedder, query string, topK int, explain bool) ([]searchHit, error) {
+	hits := []searchHit{}
+
+	switch store := index.(type) {
+	case llm.KeywordStore:
+		results, err := store.Search(query, topK)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range results {
+			hit := searchHit{
+				Rank:     i + 1,
+				ID:       result.Document.ID,
+				Title:    result.Document.Title,
+				FilePath: result.Document.FilePath,
+				Score:    result.Score,
+				Content:  result.Document.Content,
+			}
+			if explain {
+				explanation := store.ExplainScore(query, result.Document)
+				hit.Explanation = &explanation
+			}
+			hits = append(hits, hit)
+		}
+
+	case llm.Store:
+		if embedder == nil {
+			return nil, fmt.Errorf("an embedding model is required to search a vector index")
+		}
+		queryEmbedding, err := embedder.GenerateEmbedding(query)
+		if err != nil {
+			return nil, err
+		}
+		results, err := store.Search(queryEmbedding, topK)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range results {
+			hit := searchHit{
+				Rank:     i + 1,
+				ID:       result.Document.ID,
+				Title:    result.Document.Title,
+				FilePath: result.Document.FilePath,
+				Score:    result.Similarity,
+				Content:  result.Document.Content,
+			}
+			if explain {
+				explanation := store.ExplainSimilarity(queryEmbedding, result.Document)
+				hit.Explanation = &explanation
+			}
+			hits = append(hits, hit)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported index type %T", index)
+	}
+
+	return hits, nil
+}
+
+// formatExplanation renders a hit's score explanation as indented lines
+func formatExplanation(explanation interface{}) string {
+	var b strings.Builder
+	switch e := explanation.(type) {
+	case *llm.KeywordExplanation:
+		for _, term := range e.Terms {
+			fmt.Fprintf(&b, "   + %.4f  %q (query x%d, document x%d, idf %.2f", term.Contribution, term.Term, term.QueryCount, term.DocumentCount, term.IDF)
+			if term.TitleMatch {
+				b.WriteString(", in title")
+			}
+			b.WriteString(")\n")
+		}
+		for _, bigram := range e.Bigrams {
+			fmt.Fprintf(&b, "   + %.4f  %q (adjacent)\n", bigram.Contribution, bigram.Bigram)
+		}
+		if e.PhraseBoost > 0 {
+			fmt.Fprintf(&b, "   + %.4f  exact phrase match\n", e.PhraseBoost)
+		}
+		if e.SourceBoost > 0 {
+			fmt.Fprintf(&b, "   x %.2f    source boost\n", e.SourceBoost)
+		}
+		if len(e.Missing) > 0 {
+			fmt.Fprintf(&b, "   not found: %s\n", strings.Join(e.Missing, ", "))
+		}
+
+	case *llm.VectorExplanation:
+		fmt.Fprintf(&b, "   cosine %.4f = dot %.4f / (|query| %.4f * |document| %.4f)\n", e.Cosine, e.DotProduct, e.QueryNorm, e.DocumentNorm)
+		// The source boost is applied last, so without it the similarity is the blended score
+		label, blended := "similarity", e.Similarity
+		if e.SourceBoost > 0 {
+			label, blended = "blended", e.Similarity/e.SourceBoost
+		}
+		if e.TitleBoost > 0 {
+			fmt.Fprintf(&b, "   %s %.4f = %.2f * cosine + %.2f * title cosine %.4f\n", label, blended, 1-e.TitleBoost, e.TitleBoost, e.TitleCosine)
+		}
+		if e.SourceBoost > 0 {
+			fmt.Fprintf(&b, "   similarity %.4f = %.4f * source boost %.2f\n", e.Similarity, blended, e.SourceBoost)
+		}
+	}
+	return b.String()
+}
+
+// previewLine flattens text onto one line, cut to at most maxRunes runes
+func previewLine(text string, maxRunes int) string {
+	line := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(line); len(runes) > maxRunes {
+		return string(runes[:maxRunes]) + "..."
+	}
+	return line
+}
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	addDocumentIndexFlags(&llmSearchCmd.Command)
+	llmSearchCmd.Flags().String("embedding-model", "", "Path to the embedding model used to build the vector index (required unless --simple)")
+	llmSearchCmd.Flags().Int32("top-k", 5, "Number of documents to return")
+	llmSearchCmd.Flags().Float32("title-boost", 0, "Weight (0-1) of document title similarity in vector scores")
+	llmSearchCmd.Flags().Bool("explain", false, "Show how each document's score was calculated")
+	llmSearchCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add search command to llm parent
+	llmCmd.AddCommand(&llmSearchCmd.Command)
+}
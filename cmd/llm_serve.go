@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmServeCmd = man.Docs.GetCommand("llm/serve", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	if len(args) == 0 {
+		c.ExitWithError("Model path is required", nil)
+	}
+
+	modelPath := resolveModelArg(c, args[0])
+	addr := c.Flags.GetOptionalString("addr")
+	maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent")
+	queueDepth, _ := cmd.Flags().GetInt("queue-depth")
+	sessionTTL, _ := cmd.Flags().GetDuration("session-ttl")
+	historyLimit, _ := cmd.Flags().GetInt("history-limit")
+	if historyLimit < 0 {
+		c.ExitWithError("--history-limit must not be negative", nil)
+	}
+
+	sampling := samplingOptionsFromFlags(cmd)
+	if sampling.StopOnRepeat < 0 {
+		c.ExitWithError("--stop-on-repeat must not be negative", nil)
+	}
+	threadsFromFlags(c, cmd)
+	modelParams, err := modelParamsFromFlags(cmd)
+	if err != nil {
+		c.ExitWithError("Invalid llama settings", err)
+	}
+
+	engine := llm.NewSimpleChatEngine(modelPath)
+	engine.SetLogger(llmLogger(cmd))
+	engine.SetSamplingOptions(sampling)
+	engine.SetModelParams(modelParams)
+	engine.SetContextSize(int(c.Flags.GetOptionalInt32("context-size")))
+	engine.SetBatchSize(int(c.Flags.GetOptionalInt32("batch-size")))
+
+	if err := engine.Start(); err != nil {
+		c.ExitWithError("Failed to start simple chat engine", err)
+	}
+	defer engine.Stop()
+
+	opts := llm.ServerOptions{
+		MaxConcurrent: maxConcurrent,
+		QueueDepth:    queueDepth,
+		SessionTTL:    sessionTTL,
+		// Leave room in the context window for the reply
+		HistoryTokens: engine.HistoryTokens(replyTokens),
+		HistoryTurns:  historyLimit,
+	}
+	if c.Flags.GetOptionalBool("metrics") {
+		opts.Metrics = llm.NewPrometheusMetrics()
+		engine.SetMetrics(opts.Metrics)
+	}
+
+	server := llm.NewServer(engine, opts)
+	server.SetLogger(llmLogger(cmd))
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	c.Printf("🚀 Serving %s on http://%s\n", modelPath, addr)
+	c.Printf("   Max concurrent generations: %d, queue depth: %d\n", maxConcurrent, queueDepth)
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		c.ExitWithError("Server failed", err)
+	}
+	c.Println("Server stopped.")
+}))
+
+// replyTokens is the share of the context window kept free for generating the next reply to a conversation
+const replyTokens = 512
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmServeCmd.Flags().String("addr", "127.0.0.1:8181", "Address to listen on")
+	llmServeCmd.Flags().Int("max-concurrent", 1, "Maximum number of generations running at once")
+	llmServeCmd.Flags().Int("queue-depth", 8, "Maximum number of requests waiting for a generation slot before returning 429")
+	llmServeCmd.Flags().Int32("context-size", 0, "Maximum context window size (0 uses the model's trained context size, up to 8192)")
+	llmServeCmd.Flags().Int32("batch-size", 512, "Prompt tokens decoded per pass; longer prompts take several passes")
+	llmServeCmd.Flags().Duration("session-ttl", llm.DefaultSessionTTL, "Drop a session's history after it has been idle this long (0 keeps sessions until deleted)")
+	llmServeCmd.Flags().Int("history-limit", 0, "Keep at most this many earlier turns of each session, dropping the oldest first (0 keeps every turn that fits in the context)")
+	llmServeCmd.Flags().Bool("metrics", false, "Expose Prometheus metrics at /metrics")
+	addSamplingFlags(&llmServeCmd.Command)
+	addContextFlags(&llmServeCmd.Command)
+
+	// Add serve command to llm parent
+	llmCmd.AddCommand(&llmServeCmd.Command)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/opentdf/otdfctl/pkg/man"
+	"github.com/spf13/cobra"
+)
+
+var llmSimilarityCmd = man.Docs.GetCommand("llm/similarity", man.WithRun(func(cmd *cobra.Command, args []string) {
+	c := cli.New(cmd, args)
+
+	modelPath := c.Flags.GetRequiredString("model")
+	fileA := c.Flags.GetOptionalString("file-a")
+	fileB := c.Flags.GetOptionalString("file-b")
+
+	// Texts not read from a file are taken from the arguments in order
+	var textA, textB string
+	if fileA != "" {
+		textA = string(cli.ReadFromFile(fileA))
+	} else if len(args) > 0 {
+		textA, args = args[0], args[1:]
+	}
+	if fileB != "" {
+		textB = string(cli.ReadFromFile(fileB))
+	} else if len(args) > 0 {
+		textB = args[0]
+	}
+	if textA == "" || textB == "" {
+		c.ExitWithError("Two texts are required (pass them as arguments or with --file-a/--file-b)", nil)
+	}
+
+	embeddingEngine, err := llm.NewEmbeddingEngine(resolveModelArg(c, modelPath))
+	if err != nil {
+		c.ExitWithError("Failed to initialize embedding engine", err)
+	}
+	defer embeddingEngine.Close()
+
+	similarity, err := llm.CompareTexts(embeddingEngine, textA, textB)
+	if err != nil {
+		c.ExitWithError("Failed to compare texts", err)
+	}
+
+	c.ExitWithJSON(map[string]interface{}{
+		"model":      modelPath,
+		"similarity": similarity,
+	})
+
+	c.Printf("🔍 Cosine similarity: %.4f\n", similarity)
+}))
+
+func init() {
+	// TODO: Fix flag documentation parsing and use proper doc-driven flags
+	llmSimilarityCmd.Flags().String("model", "", "Path to embedding model (required)")
+	llmSimilarityCmd.Flags().String("file-a", "", "Read the first text from a file")
+	llmSimilarityCmd.Flags().String("file-b", "", "Read the second text from a file")
+	llmSimilarityCmd.Flags().Bool("json", false, "Output in JSON format")
+
+	// Add similarity command to llm parent
+	llmCmd.AddCommand(&llmSimilarityCmd.Command)
+}
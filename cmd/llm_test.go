@@ -0,0 +1,1225 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/fs/ggml"
+	"github.com/opentdf/otdfctl/pkg/cli"
+	"github.com/opentdf/otdfctl/pkg/config"
+	"github.com/opentdf/otdfctl/pkg/llm"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SamplingOptionsFromFlags(t *testing.T) {
+	cmd := &cobra.Command{Use: "sampling"}
+	addSamplingFlags(cmd)
+
+	require.NoError(t, cmd.ParseFlags([]string{
+		"--temperature", "0.2",
+		"--seed", "7",
+		"--repeat-penalty", "1.3",
+		"--frequency-penalty", "0.4",
+		"--presence-penalty", "0.6",
+		"--repeat-last-n", "128",
+		"--stop-on-repeat", "32",
+	}))
+
+	opts := samplingOptionsFromFlags(cmd)
+	assert.InDelta(t, 0.2, opts.Temperature, 0.0001)
+	assert.Equal(t, uint32(7), opts.Seed)
+	assert.False(t, opts.Greedy)
+	assert.InDelta(t, 1.3, opts.RepeatPenalty, 0.0001)
+	assert.InDelta(t, 0.4, opts.FrequencyPenalty, 0.0001)
+	assert.InDelta(t, 0.6, opts.PresencePenalty, 0.0001)
+	assert.Equal(t, 128, opts.RepeatLastN)
+	assert.Equal(t, 32, opts.StopOnRepeat)
+}
+
+func Test_SamplingOptionsFromFlagsDefaults(t *testing.T) {
+	cmd := &cobra.Command{Use: "sampling"}
+	addSamplingFlags(cmd)
+	require.NoError(t, cmd.ParseFlags(nil))
+
+	opts := samplingOptionsFromFlags(cmd)
+	assert.InDelta(t, 1.1, opts.RepeatPenalty, 0.0001)
+	assert.Equal(t, 64, opts.RepeatLastN)
+	assert.Zero(t, opts.StopOnRepeat, "off unless asked for")
+}
+
+func Test_ModelParamsFromFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	addSamplingFlags(cmd)
+
+	params, err := modelParamsFromFlags(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, llm.DefaultModelParams(), params)
+
+	require.NoError(t, cmd.Flags().Set("model-params", `{"use_mmap":false,"n_threads":8}`))
+	params, err = modelParamsFromFlags(cmd)
+	require.NoError(t, err)
+	assert.False(t, params.UseMmap)
+	assert.Equal(t, 8, params.NumThreads)
+	assert.Equal(t, llm.DefaultModelParams().TopK, params.TopK)
+
+	require.NoError(t, cmd.Flags().Set("model-params", `{"threads":8}`))
+	_, err = modelParamsFromFlags(cmd)
+	require.ErrorIs(t, err, llm.ErrInvalidModelParams)
+}
+
+func Test_ContextFlagsOverrideModelParams(t *testing.T) {
+	cmd := &cobra.Command{}
+	addSamplingFlags(cmd)
+	addContextFlags(cmd)
+
+	// The flag's default leaves n_threads alone
+	require.NoError(t, cmd.Flags().Set("model-params", `{"n_threads":8}`))
+	params, err := modelParamsFromFlags(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 8, params.NumThreads)
+
+	require.NoError(t, cmd.Flags().Set("threads", "24"))
+	params, err = modelParamsFromFlags(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 24, params.NumThreads)
+	assert.False(t, params.FlashAttention)
+
+	// A quantized KV cache needs flash attention
+	require.NoError(t, cmd.Flags().Set("kv-cache-type", "q8_0"))
+	_, err = modelParamsFromFlags(cmd)
+	require.ErrorIs(t, err, llm.ErrInvalidModelParams)
+
+	require.NoError(t, cmd.Flags().Set("flash-attention", "true"))
+	params, err = modelParamsFromFlags(cmd)
+	require.NoError(t, err)
+	assert.True(t, params.FlashAttention)
+	assert.Equal(t, "q8_0", params.KVCacheType)
+
+	require.NoError(t, cmd.Flags().Set("kv-cache-type", "q2_k"))
+	_, err = modelParamsFromFlags(cmd)
+	require.ErrorIs(t, err, llm.ErrInvalidModelParams)
+}
+
+func Test_SystemPromptFromFlags(t *testing.T) {
+	persona := filepath.Join(t.TempDir(), "persona.txt")
+	require.NoError(t, os.WriteFile(persona, []byte("You are an OpenTDF expert.\n"), 0o644))
+
+	cmd := &cobra.Command{Use: "chat"}
+	cmd.Flags().StringArray("system-prompt", nil, "")
+	cmd.Flags().StringArray("system-prompt-file", nil, "")
+	require.NoError(t, cmd.ParseFlags([]string{
+		"--system-prompt", "Answer with a single otdfctl command.",
+		"--system-prompt-file", persona,
+		"--system-prompt", "Use the staging platform endpoint.",
+	}))
+
+	systemPrompt, err := systemPromptFromFlags(cmd)
+	require.NoError(t, err)
+	messages := llm.SetSystemMessage(nil, systemPrompt)
+	assert.Equal(t, "You are an OpenTDF expert.\n\nAnswer with a single otdfctl command.\n\nUse the staging platform endpoint.", messages[0].Content)
+
+	cmd = &cobra.Command{Use: "chat"}
+	cmd.Flags().StringArray("system-prompt", nil, "")
+	cmd.Flags().StringArray("system-prompt-file", nil, "")
+	require.NoError(t, cmd.ParseFlags([]string{"--system-prompt-file", filepath.Join(t.TempDir(), "missing.txt")}))
+	_, err = systemPromptFromFlags(cmd)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_ResponseCache(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		sampling llm.SamplingOptions
+		want     bool
+	}{
+		{args: nil, sampling: llm.DefaultSamplingOptions(), want: false},
+		{args: nil, sampling: llm.SamplingOptions{Greedy: true}, want: true},
+		{args: nil, sampling: llm.SamplingOptions{Seed: 7}, want: true},
+		{args: []string{"--no-cache"}, sampling: llm.SamplingOptions{Greedy: true}, want: false},
+		{args: []string{"--no-cache"}, sampling: llm.SamplingOptions{Seed: 7}, want: false},
+	} {
+		cmd := &cobra.Command{Use: "chat"}
+		cmd.Flags().Bool("no-cache", false, "")
+		require.NoError(t, cmd.ParseFlags(tc.args))
+		assert.Equal(t, tc.want, responseCache(cmd, tc.sampling) != nil, "%v %+v", tc.args, tc.sampling)
+	}
+}
+
+func Test_LLMLoggerJSONModeKeepsStdoutClean(t *testing.T) {
+	r, w, _ := os.Pipe()
+	origStdout := os.Stdout
+	os.Stdout = w
+
+	cmd := &cobra.Command{Use: "ingest"}
+	cmd.Flags().Bool("json", false, "")
+	require.NoError(t, cmd.ParseFlags([]string{"--json"}))
+
+	store := llm.NewVectorStore(filepath.Join(t.TempDir(), "missing.json"))
+	store.SetLogger(llmLogger(cmd))
+	require.NoError(t, store.LoadIndex())
+
+	cli.New(cmd, nil).PrintJson(map[string]interface{}{"total_documents": store.GetDocumentCount()})
+
+	w.Close()
+	os.Stdout = origStdout
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &doc), "stdout must be a single JSON document: %s", out)
+	assert.InDelta(t, 0, doc["total_documents"], 0)
+}
+
+type stubEmbedder struct {
+	dimension int
+}
+
+func (s stubEmbedder) GenerateEmbedding(text string) ([]float32, error) {
+	vector := make([]float32, s.dimension)
+	vector[len(text)%s.dimension] = 1
+	return vector, nil
+}
+
+func Test_RunEmbedReportsModelDimension(t *testing.T) {
+	result, err := runEmbed(stubEmbedder{dimension: 8}, "emb.gguf", "attribute based access control")
+	require.NoError(t, err)
+
+	assert.Equal(t, "emb.gguf", result.Model)
+	assert.Equal(t, 8, result.Dimension)
+	assert.Len(t, result.Embedding, 8)
+
+	out := formatVector(result.Embedding)
+	assert.True(t, strings.HasPrefix(out, "[") && strings.HasSuffix(out, "]"))
+	assert.Len(t, strings.Split(out, ","), 8)
+}
+
+func Test_IngestIntoChat(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "simple_rag_index.json")
+	existing := llm.NewSimpleRAGStore(indexPath)
+	require.NoError(t, existing.AddDocument(llm.SimpleDocument{ID: "kas", Title: "KAS", Content: "key access service", Keywords: []string{"access"}}))
+	require.NoError(t, existing.SaveIndex())
+
+	docs := t.TempDir()
+	content := "# Obligations\n\nObligations are conditions a client must fulfill before decrypting. Obligations travel with the policy.\n"
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "obligations.md"), []byte(content), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "notes.txt"), []byte("ignored"), 0o600))
+
+	engine := llm.NewSimpleChatEngine("missing.gguf")
+	store, added, err := ingestIntoChat(llm.NopLogger(), engine, llm.StoreJSON, indexPath, docs)
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 2, store.GetDocumentCount())
+
+	results, err := store.Search("what are obligations", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "obligations.md", results[0].Document.FilePath)
+
+	// The index on disk has the new document too
+	reloaded := llm.NewSimpleRAGStore(indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	assert.Equal(t, 2, reloaded.GetDocumentCount())
+
+	// Ingesting the same file again replaces it rather than adding a duplicate
+	store, added, err = ingestIntoChat(llm.NopLogger(), engine, llm.StoreJSON, indexPath, docs)
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 2, store.GetDocumentCount())
+
+	// A single file works as well
+	store, added, err = ingestIntoChat(llm.NopLogger(), engine, llm.StoreJSON, indexPath, filepath.Join(docs, "obligations.md"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 2, store.GetDocumentCount())
+
+	_, _, err = ingestIntoChat(llm.NopLogger(), engine, llm.StoreJSON, indexPath, filepath.Join(docs, "missing"))
+	require.Error(t, err)
+}
+
+func Test_IngestIntoChatKeepsOtherIndexes(t *testing.T) {
+	dir := t.TempDir()
+	sdk := llm.NewSimpleRAGStore(filepath.Join(dir, "sdk_index.json"))
+	require.NoError(t, sdk.AddDocument(llm.SimpleDocument{ID: "sdk", Title: "SDK", Content: "The SDK decrypts TDF files for clients.", Keywords: []string{"decrypts", "clients"}}))
+
+	docs := t.TempDir()
+	content := "# Obligations\n\nObligations are conditions a client must fulfill before decrypting.\n"
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "obligations.md"), []byte(content), 0o600))
+
+	engine := llm.NewSimpleChatEngine("missing.gguf")
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	_, added, err := ingestIntoChat(llm.NopLogger(), engine, llm.StoreJSON, filepath.Join(dir, "platform_index.json"), docs, sdk)
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+	engine.Chat([]llm.ChatMessage{{Role: "user", Content: "What must clients do before decrypting?"}})
+	assert.Contains(t, shown.String(), "conditions a client must fulfill", "the ingested index is searched")
+	assert.Contains(t, shown.String(), "decrypts TDF files", "and so is the other index")
+}
+
+func Test_IngestIntoChatSQLite(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "simple_rag_index.db")
+	docs := t.TempDir()
+	content := "# Obligations\n\nObligations are conditions a client must fulfill before decrypting. Obligations travel with the policy.\n"
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "obligations.md"), []byte(content), 0o600))
+
+	engine := llm.NewSimpleChatEngine("missing.gguf")
+	for i := 0; i < 2; i++ {
+		store, added, err := ingestIntoChat(llm.NopLogger(), engine, llm.StoreSQLite, indexPath, docs)
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+		assert.Equal(t, 1, store.GetDocumentCount(), "re-ingesting replaces the document")
+		require.NoError(t, store.Close())
+	}
+
+	reloaded := llm.NewSQLiteKeywordStore(indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	defer reloaded.Close()
+	results, err := reloaded.Search("what are obligations", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "obligations.md", results[0].Document.FilePath)
+}
+
+type stubChatGenerator struct {
+	reply string
+}
+
+func (s stubChatGenerator) Chat([]llm.ChatMessage) llm.SimpleResponse {
+	return llm.SimpleResponse{Content: s.reply}
+}
+
+func (s stubChatGenerator) ChatStream(_ context.Context, _ []llm.ChatMessage, callback llm.StreamingCallback) llm.SimpleResponse {
+	for _, token := range strings.SplitAfter(s.reply, " ") {
+		callback(token)
+	}
+	return llm.SimpleResponse{Content: s.reply}
+}
+
+// recordingChatGenerator is a stubChatGenerator that records each conversation it answers
+type recordingChatGenerator struct {
+	stubChatGenerator
+	calls [][]llm.ChatMessage
+}
+
+func (r *recordingChatGenerator) Chat(messages []llm.ChatMessage) llm.SimpleResponse {
+	r.calls = append(r.calls, messages)
+	return r.stubChatGenerator.Chat(messages)
+}
+
+func (r *recordingChatGenerator) ChatStream(ctx context.Context, messages []llm.ChatMessage, callback llm.StreamingCallback) llm.SimpleResponse {
+	r.calls = append(r.calls, messages)
+	return r.stubChatGenerator.ChatStream(ctx, messages, callback)
+}
+
+func Test_ReplayConversation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversation.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"role": "user", "content": "What is a KAS?"}
+{"role": "assistant", "content": "The key access service."}
+{"role": "user", "content": "How do I list them?"}
+`), 0o644))
+
+	messages, err := loadConversation(path, "")
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, getDefaultSystemPrompt(), messages[0].Content, "a conversation without a system message gets the default")
+
+	engine := &recordingChatGenerator{stubChatGenerator: stubChatGenerator{reply: "otdfctl policy kas-registry list"}}
+	var out strings.Builder
+	response, err := runConversation(context.Background(), engine, &out, messages, promptOptions{stream: true})
+	require.NoError(t, err)
+	assert.Equal(t, "otdfctl policy kas-registry list", response.Content)
+	assert.Equal(t, "otdfctl policy kas-registry list\n", out.String(), "a single assistant turn is printed")
+	require.Len(t, engine.calls, 1)
+	assert.Equal(t, messages, engine.calls[0], "the whole conversation is sent")
+
+	// --system-prompt replaces the conversation's own system message
+	messages, err = loadConversation(path, "Answer with commands only.")
+	require.NoError(t, err)
+	assert.Equal(t, "Answer with commands only.", messages[0].Content)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"role": "assistant", "content": "Hello!"}`), 0o644))
+	_, err = loadConversation(path, "")
+	require.ErrorIs(t, err, llm.ErrInvalidConversation)
+}
+
+func Test_RunPromptWritesOutputFile(t *testing.T) {
+	engine := stubChatGenerator{reply: "Create the attribute namespace first."}
+	outputPath := filepath.Join(t.TempDir(), "docs", "policy", "answer.md")
+
+	for _, stream := range []bool{true, false} {
+		var out strings.Builder
+		response, err := runPrompt(context.Background(), engine, &out, "", "How do I create an attribute?", promptOptions{stream: stream, outputPath: outputPath})
+		require.NoError(t, err)
+		assert.Equal(t, engine.reply, response.Content)
+		assert.Equal(t, engine.reply+"\n", out.String())
+
+		saved, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		assert.Equal(t, engine.reply+"\n", string(saved))
+	}
+}
+
+func Test_RunPromptAppendsOutputFile(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "answers.md")
+
+	_, err := runPrompt(context.Background(), stubChatGenerator{reply: "first"}, io.Discard, "", "one", promptOptions{outputPath: outputPath, appendFile: true})
+	require.NoError(t, err)
+	_, err = runPrompt(context.Background(), stubChatGenerator{reply: "second"}, io.Discard, "", "two", promptOptions{outputPath: outputPath, appendFile: true})
+	require.NoError(t, err)
+
+	saved, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(saved))
+
+	// Without --append the file is replaced
+	_, err = runPrompt(context.Background(), stubChatGenerator{reply: "third"}, io.Discard, "", "three", promptOptions{outputPath: outputPath})
+	require.NoError(t, err)
+	saved, err = os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(saved))
+}
+
+func Test_RunPromptErrorSkipsOutputFile(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "answer.md")
+	engine := llm.NewSimpleChatEngine("missing.gguf")
+
+	_, err := runPrompt(context.Background(), engine, io.Discard, "", "hello", promptOptions{outputPath: outputPath})
+	require.ErrorIs(t, err, llm.ErrEngineNotRunning)
+	assert.NoFileExists(t, outputPath)
+}
+
+func Test_ResolveRenderModeFallsBackWhenNotATerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	mode, err := resolveRenderMode(renderMarkdown, w)
+	require.NoError(t, err)
+	assert.Equal(t, renderPlain, mode)
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	require.NoError(t, err)
+	defer file.Close()
+	mode, err = resolveRenderMode(renderMarkdown, file)
+	require.NoError(t, err)
+	assert.Equal(t, renderPlain, mode)
+
+	markdown, err := newMarkdownRenderer(mode, file, true)
+	require.NoError(t, err)
+	assert.False(t, markdown.enabled())
+	assert.Equal(t, "**bold**", markdown.Render("**bold**"))
+
+	_, err = resolveRenderMode("html", w)
+	require.Error(t, err)
+}
+
+func Test_MarkdownRendererFormatsResponse(t *testing.T) {
+	markdown, err := newMarkdownRenderer(renderMarkdown, os.Stdout, true)
+	require.NoError(t, err)
+	require.True(t, markdown.enabled())
+
+	out := markdown.Render("Use **otdfctl**:\n\n```shell\notdfctl policy attributes list\n```\n")
+	assert.Contains(t, out, "otdfctl policy attributes list")
+	assert.NotContains(t, out, "**")
+	assert.NotContains(t, out, "```")
+}
+
+func Test_RunPromptRendersMarkdown(t *testing.T) {
+	markdown, err := newMarkdownRenderer(renderMarkdown, os.Stdout, true)
+	require.NoError(t, err)
+	outputPath := filepath.Join(t.TempDir(), "answer.md")
+	engine := stubChatGenerator{reply: "Run **otdfctl auth login** first."}
+
+	var out strings.Builder
+	_, err = runPrompt(context.Background(), engine, &out, "", "How do I log in?", promptOptions{stream: true, outputPath: outputPath, markdown: markdown})
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "**")
+
+	// The saved file keeps the original Markdown
+	saved, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, engine.reply+"\n", string(saved))
+}
+
+func Test_MarkdownRendererHighlightsCode(t *testing.T) {
+	response := "List attributes:\n\n```bash\notdfctl policy attributes list --json\n```\n"
+
+	t.Setenv("NO_COLOR", "")
+	markdown, err := newMarkdownRenderer(renderMarkdown, os.Stdout, colorEnabled(false))
+	require.NoError(t, err)
+	assert.Contains(t, markdown.Render(response), "\x1b[")
+}
+
+func Test_MarkdownRendererNoColor(t *testing.T) {
+	response := "List attributes:\n\n```bash\notdfctl policy attributes list --json\n```\n\n```json\n{\"name\": \"classification\"}\n```\n"
+
+	t.Setenv("NO_COLOR", "1")
+	markdown, err := newMarkdownRenderer(renderMarkdown, os.Stdout, colorEnabled(false))
+	require.NoError(t, err)
+	out := markdown.Render(response)
+	assert.NotContains(t, out, "\x1b[")
+	assert.Contains(t, out, "otdfctl policy attributes list --json")
+	assert.Contains(t, out, `{"name": "classification"}`)
+
+	t.Setenv("NO_COLOR", "")
+	markdown, err = newMarkdownRenderer(renderMarkdown, os.Stdout, colorEnabled(true))
+	require.NoError(t, err)
+	assert.NotContains(t, markdown.Render(response), "\x1b[")
+}
+
+func Test_LabelCodeFences(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{name: "shell", code: "otdfctl auth login --host https://platform", want: "bash"},
+		{name: "prompt", code: "$ otdfctl policy attributes list", want: "bash"},
+		{name: "go", code: "package main\n\nfunc main() {}", want: "go"},
+		{name: "json", code: "{\n  \"namespace\": \"example.com\"\n}", want: "json"},
+		{name: "yaml", code: "services:\n  kas:\n    enabled: true", want: "yaml"},
+		{name: "unknown", code: "SELECT * FROM attributes;", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := "Example:\n\n```\n" + tt.code + "\n```\nDone."
+			assert.Equal(t, "Example:\n\n```"+tt.want+"\n"+tt.code+"\n```\nDone.", labelCodeFences(text))
+		})
+	}
+
+	// Fences that already name a language are left alone
+	tagged := "```go\notdfctl version\n```"
+	assert.Equal(t, tagged, labelCodeFences(tagged))
+}
+
+func Test_RunPromptExportsTranscript(t *testing.T) {
+	exportPath := filepath.Join(t.TempDir(), "issues", "transcript.md")
+	engine := stubChatGenerator{reply: "Rotate keys with otdfctl policy kas-registry key rotate."}
+
+	_, err := runPrompt(context.Background(), engine, io.Discard, "You are an OpenTDF expert.", "How do I rotate KAS keys?", promptOptions{exportPath: exportPath})
+	require.NoError(t, err)
+
+	saved, err := os.ReadFile(exportPath)
+	require.NoError(t, err)
+	transcript := string(saved)
+
+	system := strings.Index(transcript, "## System\n\nYou are an OpenTDF expert.")
+	user := strings.Index(transcript, "## User (")
+	assistant := strings.Index(transcript, "## Assistant (")
+	require.NotEqual(t, -1, system)
+	assert.Greater(t, user, system)
+	assert.Greater(t, assistant, user)
+	assert.Contains(t, transcript[user:assistant], "How do I rotate KAS keys?")
+	assert.Contains(t, transcript[assistant:], engine.reply)
+}
+
+func Test_TurnSummary(t *testing.T) {
+	assert.Equal(t, "⏱️  Response time: 2s", turnSummary(2*time.Second, 50, false))
+	assert.Equal(t, "⏱️  Response time: 2s | 50 tokens | 25.0 tokens/s", turnSummary(2*time.Second, 50, true))
+	assert.Equal(t, "⏱️  Response time: 0s | 0 tokens", turnSummary(0, 0, true))
+}
+
+func Test_ShowPromptFlags(t *testing.T) {
+	for _, args := range [][]string{{"--show-prompt"}, {"--verbose"}} {
+		cmd := &cobra.Command{Use: "chat"}
+		cmd.Flags().Bool("show-prompt", false, "")
+		cmd.Flags().Bool("verbose", false, "")
+		require.NoError(t, cmd.ParseFlags(args))
+		assert.True(t, showPrompt(cmd), args)
+	}
+
+	cmd := &cobra.Command{Use: "chat"}
+	cmd.Flags().Bool("show-prompt", false, "")
+	cmd.Flags().Bool("verbose", false, "")
+	require.NoError(t, cmd.ParseFlags(nil))
+	assert.False(t, showPrompt(cmd))
+}
+
+func Test_IngestSimpleKeepCode(t *testing.T) {
+	docs := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "scs.md"), []byte(
+		"# Condition Sets\n\nConditions are evaluated against entity claims.\n\n```bash\notdfctl policy subject-condition-sets create --subject-sets-file-json scs.json\n```\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "kas.md"), []byte(
+		"# Key Access Service\n\nThe key access service rewraps keys for entitled clients. Create a KAS entry in the policy registry.\n"), 0o600))
+
+	search := func(keepCode bool) []llm.SearchResult {
+		store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+		_, err := ingestSimpleDocuments(llm.NopLogger(), store, docs, simpleIngestOptions{keepCode: keepCode})
+		require.NoError(t, err)
+		results, err := store.Search("subject-condition-sets create command", 2)
+		require.NoError(t, err)
+		return results
+	}
+
+	results := search(true)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "scs.md", results[0].Document.FilePath)
+	assert.Contains(t, results[0].Document.Content, "otdfctl policy subject-condition-sets create")
+
+	for _, result := range search(false) {
+		assert.NotContains(t, result.Document.Content, "subject-condition-sets")
+	}
+}
+
+func Test_IngestSimpleBoost(t *testing.T) {
+	docs := t.TempDir()
+	for _, name := range []string{"blog", "spec"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(docs, name), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(docs, name, "obligations.md"), []byte(
+			"# Obligations\n\nObligations are conditions a client must fulfill before decrypting.\n"), 0o600))
+	}
+
+	boosts, err := llm.ParseSourceBoosts([]string{"spec=1.5"})
+	require.NoError(t, err)
+	store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	_, err = ingestSimpleDocuments(llm.NopLogger(), store, docs, simpleIngestOptions{boosts: boosts})
+	require.NoError(t, err)
+
+	results, err := store.Search("obligations", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, filepath.Join("spec", "obligations.md"), results[0].Document.FilePath)
+	assert.Greater(t, results[0].Score, results[1].Score)
+}
+
+func Test_ProcessMarkdownSimpleKeepLinks(t *testing.T) {
+	content := "Read the [quickstart](https://opentdf.io/quickstart) first.\n"
+
+	assert.Equal(t, "Read the quickstart first.", processMarkdownSimple(content, false, false))
+	assert.Equal(t, "Read the quickstart (https://opentdf.io/quickstart) first.", processMarkdownSimple(content, false, true))
+}
+
+func Test_RunSearchExplainKeyword(t *testing.T) {
+	store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "sm", Title: "Subject Mappings", FilePath: "policy/subject-mappings.md", Content: "Subject mappings entitle entities to attribute values."}))
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "kas", Title: "Key Access Service", FilePath: "kas.md", Content: "The KAS rewraps keys."}))
+
+	hits, err := runSearch(store, nil, "subject mappings for attributes", 5, true)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "policy/subject-mappings.md", hits[0].FilePath)
+
+	explanation := formatExplanation(hits[0].Explanation)
+	assert.Contains(t, explanation, `"subject" (query x1, document x2, idf 1.10, in title)`)
+	assert.Contains(t, explanation, `"mappings" (query x1, document x2, idf 1.10, in title)`)
+	assert.Contains(t, explanation, "not found: attributes")
+
+	hits, err = runSearch(store, nil, "subject mappings", 5, false)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Nil(t, hits[0].Explanation)
+}
+
+func Test_RunSearchSQLiteStore(t *testing.T) {
+	store := llm.NewSQLiteStore(filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, store.LoadIndex())
+	defer store.Close()
+	embedder := stubEmbedder{dimension: 8}
+	for _, text := range []string{"rewrap", "subject mappings"} {
+		embedding, err := embedder.GenerateEmbedding(text)
+		require.NoError(t, err)
+		require.NoError(t, store.AddDocument(llm.Document{ID: text, Title: text, Content: text, Embedding: embedding}))
+	}
+
+	hits, err := runSearch(store, embedder, "rewrap", 1, true)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "rewrap", hits[0].ID)
+	assert.NotNil(t, hits[0].Explanation)
+}
+
+func Test_IndexRetrieverEvaluatesKeywordIndex(t *testing.T) {
+	store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "sm", Title: "Subject Mappings", Content: "Subject mappings entitle entities to attribute values."}))
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "kas", Title: "Key Access Service", Content: "The KAS rewraps keys."}))
+
+	report, err := llm.EvaluateRetrieval([]llm.EvalQuery{
+		{Query: "subject mappings", RelevantIDs: []string{"sm"}},
+		{Query: "rewraps keys", RelevantIDs: []string{"sm"}},
+	}, 3, indexRetriever(store, nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sm"}, report.Results[0].Retrieved)
+	assert.Equal(t, []string{"kas"}, report.Results[1].Retrieved)
+	assert.InDelta(t, 0.5, report.RecallAtK, 0.0001)
+	assert.InDelta(t, 0.5, report.MRR, 0.0001)
+}
+
+func Test_ExtractKeywordsSimpleThresholds(t *testing.T) {
+	content := "Obligations are enforced by the PEP. The PEP reads obligations from the KAS response."
+
+	// By default a keyword needs 4+ characters and 2+ occurrences
+	assert.Equal(t, []string{"obligations"}, extractKeywordsSimple(content, 0, 0))
+	assert.Equal(t, extractKeywordsSimple(content, 0, 0), extractKeywordsSimple(content, defaultKeywordMinCount, defaultKeywordMinLength))
+
+	// Lowering the length keeps short acronyms
+	assert.Equal(t, []string{"obligations", "pep", "the"}, extractKeywordsSimple(content, 2, 3))
+
+	// Lowering the count keeps terms a short snippet mentions once
+	keywords := extractKeywordsSimple(content, 1, 4)
+	assert.Contains(t, keywords, "enforced")
+	assert.Contains(t, keywords, "response")
+	assert.NotContains(t, keywords, "kas")
+	assert.Contains(t, extractKeywordsSimple(content, 1, 3), "kas")
+}
+
+func Test_NewPromptResultRetrievalScore(t *testing.T) {
+	result := newPromptResult("q", llm.SimpleResponse{Content: "a"}, "")
+	assert.Nil(t, result.RetrievalScore)
+
+	result = newPromptResult("q", llm.SimpleResponse{Content: "a", RAGUsed: true, RetrievalScore: 0.25, LowConfidence: true}, "")
+	require.NotNil(t, result.RetrievalScore)
+	assert.InDelta(t, 0.25, *result.RetrievalScore, 0.0001)
+	assert.True(t, result.LowConfidence)
+}
+
+func Test_LLMProjectConfigDefaults(t *testing.T) {
+	root := t.TempDir()
+	project := "index_path: indexes/rag.json\nsimple_index_path: indexes/simple.json\nchunk_size: 120\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".otdfctl-llm.yaml"), []byte(project), 0o644))
+	sub := filepath.Join(root, "docs", "guides")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	t.Chdir(sub)
+
+	cfg, err := llmConfig(config.LLM{EmbeddingModel: "global-model", IndexPath: "/global/rag.json"})
+	require.NoError(t, err)
+	// Settings missing from the project file keep their global value
+	assert.Equal(t, "global-model", cfg.EmbeddingModel)
+	assert.Equal(t, filepath.Join(root, "indexes", "rag.json"), cfg.IndexPath)
+	assert.Equal(t, filepath.Join(root, "indexes", "simple.json"), cfg.SimpleIndexPath)
+
+	newIngestCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "ingest"}
+		cmd.Flags().String("embedding-model", "", "")
+		cmd.Flags().String("index-path", "", "")
+		cmd.Flags().Int32("chunk-size", 300, "")
+		require.NoError(t, cmd.ParseFlags(args))
+		return cmd
+	}
+
+	cmd := newIngestCmd()
+	require.NoError(t, applyLLMDefaults(cmd, cfg))
+	indexPath, _ := cmd.Flags().GetString("index-path")
+	chunkSize, _ := cmd.Flags().GetInt32("chunk-size")
+	assert.Equal(t, cfg.IndexPath, indexPath)
+	assert.Equal(t, int32(120), chunkSize)
+
+	// Flags passed on the command line win over the project config
+	cmd = newIngestCmd("--index-path", "cli.json", "--chunk-size", "50")
+	require.NoError(t, applyLLMDefaults(cmd, cfg))
+	indexPath, _ = cmd.Flags().GetString("index-path")
+	chunkSize, _ = cmd.Flags().GetInt32("chunk-size")
+	assert.Equal(t, "cli.json", indexPath)
+	assert.Equal(t, int32(50), chunkSize)
+}
+
+// writeDoctorModel writes a GGUF file whose metadata reports the given embedding size
+func writeDoctorModel(t *testing.T, dir string, embeddingLength uint32) string {
+	t.Helper()
+	path := filepath.Join(dir, fmt.Sprintf("model-%d.gguf", embeddingLength))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	kv := ggml.KV{"general.architecture": "llama", "llama.embedding_length": embeddingLength, "llama.context_length": uint32(2048)}
+	tensors := []*ggml.Tensor{
+		{Name: "token_embd.weight", Shape: []uint64{2, 3}, WriterTo: bytes.NewBuffer(make([]byte, 2*3))},
+	}
+	require.NoError(t, ggml.WriteGGUF(f, kv, tensors))
+	return path
+}
+
+// doctorStatuses maps each doctor check to its status
+func doctorStatuses(report doctorReport) map[string]string {
+	statuses := map[string]string{}
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func Test_RunDoctor(t *testing.T) {
+	dir := t.TempDir()
+	model := writeDoctorModel(t, dir, 3)
+
+	vectorIndex := filepath.Join(dir, "rag_index.json")
+	store := llm.NewVectorStore(vectorIndex)
+	require.NoError(t, store.AddDocument(llm.Document{ID: "doc", Content: "policy", Embedding: []float32{1, 0, 0}}))
+	require.NoError(t, store.SaveIndex())
+
+	keywordIndex := filepath.Join(dir, "simple_rag_index.json")
+	simple := llm.NewSimpleRAGStore(keywordIndex)
+	require.NoError(t, simple.AddDocument(llm.SimpleDocument{ID: "doc", Content: "policy", Keywords: []string{"policy"}}))
+	require.NoError(t, simple.SaveIndex())
+
+	healthy := doctorSettings{Model: model, EmbeddingModel: model, IndexPath: vectorIndex, SimpleIndexPath: keywordIndex}
+
+	t.Run("healthy", func(t *testing.T) {
+		report := runDoctor(healthy, doctorReport{Healthy: true})
+		assert.True(t, report.Healthy)
+		assert.Equal(t, map[string]string{
+			"chat model":           doctorPass,
+			"embedding model":      doctorPass,
+			"vector index":         doctorPass,
+			"embedding dimensions": doctorPass,
+			"keyword index":        doctorPass,
+		}, doctorStatuses(report))
+	})
+
+	t.Run("dimension mismatch", func(t *testing.T) {
+		settings := healthy
+		settings.EmbeddingModel = writeDoctorModel(t, dir, 8)
+		report := runDoctor(settings, doctorReport{Healthy: true})
+		assert.False(t, report.Healthy)
+		assert.Equal(t, doctorFail, doctorStatuses(report)["embedding dimensions"])
+	})
+
+	t.Run("missing and invalid files", func(t *testing.T) {
+		notGGUF := filepath.Join(dir, "model.bin")
+		require.NoError(t, os.WriteFile(notGGUF, []byte("not a model"), 0o600))
+		corrupt := filepath.Join(dir, "corrupt.json")
+		require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+		report := runDoctor(doctorSettings{
+			Model:           notGGUF,
+			EmbeddingModel:  filepath.Join(dir, "missing.gguf"),
+			IndexPath:       corrupt,
+			SimpleIndexPath: filepath.Join(dir, "missing.json"),
+		}, doctorReport{Healthy: true})
+		assert.False(t, report.Healthy)
+		assert.Equal(t, map[string]string{
+			"chat model":           doctorFail,
+			"embedding model":      doctorFail,
+			"vector index":         doctorFail,
+			"embedding dimensions": doctorSkip,
+			"keyword index":        doctorWarn,
+		}, doctorStatuses(report))
+	})
+
+	t.Run("no model configured", func(t *testing.T) {
+		settings := healthy
+		settings.Model = ""
+		report := runDoctor(settings, doctorReport{Healthy: true})
+		assert.True(t, report.Healthy, "a missing default model is only a warning")
+		assert.Equal(t, doctorWarn, doctorStatuses(report)["chat model"])
+	})
+}
+
+// recordingPrinter captures chat output for tests
+type recordingPrinter struct {
+	strings.Builder
+}
+
+func (p *recordingPrinter) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(&p.Builder, format, args...)
+}
+
+func (p *recordingPrinter) Println(args ...interface{}) {
+	fmt.Fprintln(&p.Builder, args...)
+}
+
+func Test_NoRAGPrefix(t *testing.T) {
+	message, ok := noRAGPrefix("/norag  What is the capital of France?")
+	assert.True(t, ok)
+	assert.Equal(t, "What is the capital of France?", message)
+
+	for _, input := range []string{"/norag", "/norag \u200b", "What is /norag?", "/noragging"} {
+		message, ok := noRAGPrefix(input)
+		assert.False(t, ok, input)
+		assert.Equal(t, input, message)
+	}
+}
+
+func Test_SummarizeHistory(t *testing.T) {
+	messages := []llm.ChatMessage{{Role: "system", Content: "You are helpful."}}
+	for _, content := range []string{"first question", "first answer", "second question", "second answer", "third question"} {
+		messages = append(messages, llm.ChatMessage{Role: "user", Content: strings.Repeat(content, 10)})
+	}
+
+	rec := &recordingPrinter{}
+	got := summarizeHistory(rec, messages, 160, func(turns []llm.ChatMessage) (string, error) {
+		return "earlier questions", nil
+	})
+	require.Len(t, got, 4)
+	assert.Equal(t, messages[0], got[0])
+	assert.Equal(t, llm.SummaryPrefix+"earlier questions", got[1].Content)
+	assert.Contains(t, rec.String(), "Summarized 3 earlier messages")
+
+	rec.Reset()
+	got = summarizeHistory(rec, messages, 160, func([]llm.ChatMessage) (string, error) {
+		return "", llm.ErrModelNotLoaded
+	})
+	assert.Equal(t, messages, got)
+	assert.Contains(t, rec.String(), "Failed to summarize earlier messages")
+}
+
+// containsEmoji reports whether text has any rune from the common emoji blocks
+func containsEmoji(text string) bool {
+	return strings.ContainsFunc(text, func(r rune) bool {
+		return (r >= 0x1F000 && r <= 0x1FAFF) || (r >= 0x2300 && r <= 0x27BF) || r == 0xFE0F
+	})
+}
+
+func Test_PlainOutputHasNoEmoji(t *testing.T) {
+	rec := &recordingPrinter{}
+	out := plainPrinter{out: rec}
+	out.Printf("🔧 Initializing Simple RAG support...\n")
+	out.Printf("✅ Simple RAG enabled with %d documents\n", 3)
+	out.Printf("\n\n%s\n", turnSummary(time.Second, 10, true))
+	require.NoError(t, exportOnExit(out, filepath.Join(t.TempDir(), "chat.md"), nil))
+	out.Println("Goodbye! 👋")
+	printHelp(out)
+
+	assert.False(t, containsEmoji(rec.String()), rec.String())
+	assert.Contains(t, rec.String(), "Simple RAG enabled with 3 documents\n")
+	assert.Contains(t, rec.String(), "Response time: 1s")
+	assert.Contains(t, rec.String(), "Saved transcript to")
+
+	// Model responses, such as simulated ones, are stripped as they stream
+	var response strings.Builder
+	engine := stubChatGenerator{reply: "🤖 **Simulated** answer ⚠️ check the model path 💡"}
+	_, err := runPrompt(context.Background(), engine, plainWriter{w: &response}, "", "hello", promptOptions{stream: true})
+	require.NoError(t, err)
+	assert.False(t, containsEmoji(response.String()), response.String())
+	assert.Contains(t, response.String(), "**Simulated** answer check the model path")
+}
+
+func Test_CompareModelsSendsIdenticalPrompts(t *testing.T) {
+	store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "kas", Title: "Key Access Service", FilePath: "kas.md", Content: "The KAS rewraps keys for authorized clients."}))
+
+	prompts := map[string]*strings.Builder{}
+	start := func(modelPath string) (llm.ChatGenerator, func(), error) {
+		engine := llm.NewSimpleChatEngine(modelPath)
+		prompts[modelPath] = &strings.Builder{}
+		engine.SetPromptWriter(prompts[modelPath])
+		engine.EnableSimpleRAG(store)
+		if err := engine.Start(); err != nil {
+			return nil, nil, err
+		}
+		return engine, engine.Stop, nil
+	}
+	messages := llm.SetSystemMessage(nil, "You are helpful.")
+	messages = append(messages, llm.ChatMessage{Role: "user", Content: "How does the KAS rewrap keys?"})
+
+	answers := compareModels([]string{"a.gguf", "b.gguf"}, messages, start)
+	require.Len(t, answers, 2)
+	for _, answer := range answers {
+		// Neither model file exists, so each engine stops short of generating
+		assert.Contains(t, answer.Error, llm.ErrModelNotLoaded.Error())
+	}
+	assert.Contains(t, prompts["a.gguf"].String(), "The KAS rewraps keys for authorized clients.", "the RAG context is in the prompt")
+	assert.Equal(t, prompts["a.gguf"].String(), prompts["b.gguf"].String())
+}
+
+func Test_CompareModelsCapturesBothAnswers(t *testing.T) {
+	engines := map[string]*recordingChatGenerator{
+		"a.gguf": {stubChatGenerator: stubChatGenerator{reply: "Use otdfctl policy attributes create."}},
+		"b.gguf": {stubChatGenerator: stubChatGenerator{reply: "Create the namespace, then the attribute."}},
+	}
+	var stopped []string
+	start := func(modelPath string) (llm.ChatGenerator, func(), error) {
+		if modelPath == "missing.gguf" {
+			return nil, nil, llm.ErrModelNotFound
+		}
+		return engines[modelPath], func() { stopped = append(stopped, modelPath) }, nil
+	}
+	messages := []llm.ChatMessage{{Role: "user", Content: "How do I create an attribute?"}}
+
+	answers := compareModels([]string{"a.gguf", "b.gguf", "missing.gguf"}, messages, start)
+	require.Len(t, answers, 3)
+	assert.Equal(t, "a.gguf", answers[0].Model)
+	assert.Equal(t, "Use otdfctl policy attributes create.", answers[0].Response)
+	assert.Equal(t, "Create the namespace, then the attribute.", answers[1].Response)
+	assert.Equal(t, llm.ErrModelNotFound.Error(), answers[2].Error, "a failed model doesn't stop the comparison")
+	assert.Equal(t, []string{"a.gguf", "b.gguf"}, stopped, "each model is stopped after answering")
+	assert.Equal(t, [][]llm.ChatMessage{messages}, engines["a.gguf"].calls)
+	assert.Equal(t, engines["a.gguf"].calls, engines["b.gguf"].calls)
+
+	var out strings.Builder
+	printComparison(&out, answers[:2], 80)
+	lines := strings.Split(out.String(), "\n")
+	assert.Contains(t, lines[0], "a.gguf")
+	assert.Contains(t, lines[0], "b.gguf", "the answers are printed side by side")
+	assert.Contains(t, out.String(), "Use otdfctl policy")
+	assert.Contains(t, out.String(), "Create the namespace")
+}
+
+// interruptedEngine generates tokens until its context is cancelled, pressing Ctrl-C on
+// interrupts after the third
+type interruptedEngine struct {
+	interrupts chan os.Signal
+	tokens     int
+}
+
+func (e *interruptedEngine) ChatContext(ctx context.Context, _ []llm.ChatMessage) llm.SimpleResponse {
+	e.interrupts <- os.Interrupt
+	<-ctx.Done()
+	return llm.SimpleResponse{Error: ctx.Err()}
+}
+
+func (e *interruptedEngine) ChatStream(ctx context.Context, _ []llm.ChatMessage, callback llm.StreamingCallback) llm.SimpleResponse {
+	var content strings.Builder
+	for ctx.Err() == nil {
+		callback("token ")
+		content.WriteString("token ")
+		e.tokens++
+		if e.tokens == 3 {
+			e.interrupts <- os.Interrupt
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+				return llm.SimpleResponse{Content: content.String(), TokenCount: e.tokens}
+			}
+		}
+	}
+	return llm.SimpleResponse{Content: content.String(), TokenCount: e.tokens, Error: ctx.Err()}
+}
+
+func Test_InterruptStopsGenerationAndKeepsSession(t *testing.T) {
+	interrupts := make(chan os.Signal, 1)
+	input, feed := io.Pipe()
+	defer feed.Close()
+	in := newChatInput(input, interrupts)
+	defer in.Close()
+	messages := []llm.ChatMessage{{Role: "user", Content: "How do I create a KAS key?"}}
+
+	out := &recordingPrinter{}
+	engine := &interruptedEngine{interrupts: interrupts}
+	reply := answerTurn(out, engine, in, messages, true, interactiveChatOptions{})
+	assert.Equal(t, 3, engine.tokens, "no tokens after the interrupt")
+	assert.Equal(t, "token token token ", reply, "the streamed part is kept")
+	assert.Contains(t, out.String(), "Stopped after 3 tokens")
+	assert.NotContains(t, out.String(), "Error")
+
+	// Without streaming nothing was printed, so nothing is kept
+	assert.Empty(t, answerTurn(out, engine, in, messages, false, interactiveChatOptions{}))
+
+	// The session carries on with the next line
+	go feed.Write([]byte("next question\n"))
+	line, err := in.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "next question", line)
+
+	// A turn that isn't interrupted reports so
+	assert.False(t, in.Interruptible(func(context.Context) {}))
+
+	// Ctrl-C at the prompt ends the session
+	interrupts <- os.Interrupt
+	_, err = in.Next()
+	assert.ErrorIs(t, err, errChatInterrupted)
+}
+
+func Test_InterruptAtPromptEndsChat(t *testing.T) {
+	interrupts := make(chan os.Signal, 1)
+	input, feed := io.Pipe()
+	defer feed.Close()
+	exportPath := filepath.Join(t.TempDir(), "chat.md")
+	out := &recordingPrinter{}
+
+	done := make(chan error)
+	go func() {
+		engine := llm.NewSimpleChatEngine(filepath.Join(t.TempDir(), "missing.gguf"))
+		done <- startSimpleInteractiveChat(out, engine, interactiveChatOptions{input: input, interrupts: interrupts, exportPath: exportPath, plain: true})
+	}()
+	// The second line is only read once the first has been taken
+	for _, line := range []string{"clear\n", "/help\n"} {
+		_, err := feed.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	interrupts <- os.Interrupt
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ctrl-C at the prompt did not end the chat")
+	}
+	assert.Contains(t, out.String(), "Chat history cleared.")
+	assert.FileExists(t, exportPath, "the transcript is saved as on exit")
+}
+
+func Test_WrapText(t *testing.T) {
+	response := "Subject mappings connect the attributes of an entity to the attribute values it is entitled to.\n" +
+		"\n" +
+		"```bash\n" +
+		"otdfctl policy subject-mappings create --attribute-value-id 4c8d0b8e --action-standard DECRYPT --subject-condition-set-new '[]'\n" +
+		"```\n" +
+		"- Create the subject condition set first, then reference it from the mapping.\n" +
+		"| Flag | Description of what the flag does in the command |\n" +
+		"See https://opentdf.io/docs/components/policy/subject_mappings/creating-subject-mappings for details."
+
+	wrapped := wrapText(response, 30)
+	inCode := false
+	for _, line := range strings.Split(wrapped, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || strings.HasPrefix(line, "|") || strings.Contains(line, "https://") {
+			continue
+		}
+		assert.LessOrEqual(t, len(line), 30, "line %q", line)
+	}
+	assert.Contains(t, wrapped, "Subject mappings connect the\nattributes of an entity to the\n")
+	assert.Contains(t, wrapped, "otdfctl policy subject-mappings create --attribute-value-id 4c8d0b8e --action-standard DECRYPT --subject-condition-set-new '[]'\n", "code is not wrapped")
+	assert.Contains(t, wrapped, "- Create the subject condition\n  set first, then reference it\n", "list items keep a hanging indent")
+	assert.Contains(t, wrapped, "| Flag | Description of what the flag does in the command |", "table rows are not wrapped")
+	assert.Contains(t, wrapped, "\nhttps://opentdf.io/docs/components/policy/subject_mappings/creating-subject-mappings\n", "long words are not split")
+	assert.Equal(t, strings.Fields(response), strings.Fields(wrapped), "no text is lost")
+
+	assert.Equal(t, response, wrapText(response, 0))
+}
+
+func Test_TextWrapperStreamsByLine(t *testing.T) {
+	response := "Key access servers hold the private keys used to unwrap data encryption keys.\n```\nlong code line that stays exactly as it was written\n```\nDone."
+	w := newTextWrapper(24)
+	var streamed strings.Builder
+	for i := 0; i < len(response); i += 5 {
+		chunk := w.Add(response[i:min(i+5, len(response))])
+		assert.True(t, chunk == "" || strings.HasSuffix(chunk, "\n"), "only complete lines are released")
+		streamed.WriteString(chunk)
+	}
+	streamed.WriteString(w.Flush())
+	assert.Equal(t, wrapText(response, 24), streamed.String())
+}
+
+func Test_RunPromptWrapsResponse(t *testing.T) {
+	engine := stubChatGenerator{reply: "Attribute namespaces group attribute definitions, which hold the values entities are entitled to."}
+	for _, stream := range []bool{true, false} {
+		var out strings.Builder
+		_, err := runPrompt(context.Background(), engine, &out, "", "What is a namespace?", promptOptions{stream: stream, wrapWidth: 40})
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			assert.LessOrEqual(t, len(line), 40, "line %q", line)
+		}
+		assert.Equal(t, strings.Fields(engine.reply), strings.Fields(out.String()))
+	}
+}
+
+func Test_RunPromptEcho(t *testing.T) {
+	engine := stubChatGenerator{reply: "Use otdfctl policy attributes create."}
+	question := "How do I create an attribute?"
+
+	for _, stream := range []bool{true, false} {
+		var out strings.Builder
+		response, err := runPrompt(context.Background(), engine, &out, "", question, promptOptions{stream: stream, echo: true})
+		require.NoError(t, err)
+		assert.Equal(t, engine.reply, response.Content, "the response itself has no echo")
+		assert.Equal(t, "Question: "+question+"\n\nAnswer: "+engine.reply+"\n", out.String())
+
+		out.Reset()
+		_, err = runPrompt(context.Background(), engine, &out, "Answer with commands only.", question, promptOptions{stream: stream, echoSystem: true})
+		require.NoError(t, err)
+		assert.Equal(t, "System: Answer with commands only.\n\nQuestion: "+question+"\n\nAnswer: "+engine.reply+"\n", out.String())
+	}
+
+	// JSON output always has the prompt, and the system prompt when it is echoed
+	result := newPromptResult(question, llm.SimpleResponse{Content: engine.reply}, "")
+	result.SystemPrompt = "Answer with commands only."
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prompt": "How do I create an attribute?", "response": "Use otdfctl policy attributes create.", "system_prompt": "Answer with commands only."}`, string(data))
+}
+
+func Test_IngestQuietDropsPerFileOutput(t *testing.T) {
+	docs := t.TempDir()
+	for _, name := range []string{"kas.md", "policy.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(docs, name), []byte("# Key Access Service\n\nThe key access service rewraps keys for entitled clients.\n"), 0o600))
+	}
+	output := func(quiet bool) (*recordingPrinter, llm.Logger) {
+		cmd := &cobra.Command{Use: "ingest"}
+		addQuietFlag(cmd)
+		if quiet {
+			require.NoError(t, cmd.ParseFlags([]string{"--quiet"}))
+		}
+		logs := &recordingPrinter{}
+		progress, logger := ingestOutput(cli.New(cmd, nil), logs)
+		if quiet {
+			assert.Equal(t, llm.NopLogger(), progress, "quiet leaves out progress")
+		}
+		return logs, logger
+	}
+
+	// Vector ingestion
+	for _, quiet := range []bool{false, true} {
+		logs, logger := output(quiet)
+		store := llm.NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+		ingester := llm.NewDocumentIngester(store, stubEmbedder{dimension: 4}, t.TempDir())
+		ingester.SetLogger(logger)
+		require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), docs))
+		assert.Equal(t, 2, store.GetDocumentCount())
+		if quiet {
+			assert.Empty(t, logs.String())
+		} else {
+			assert.Contains(t, logs.String(), "Processing: kas.md")
+		}
+	}
+
+	// Keyword ingestion
+	for _, quiet := range []bool{false, true} {
+		logs, logger := output(quiet)
+		store := llm.NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+		added, err := ingestSimpleDocuments(logger, store, docs, simpleIngestOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, added)
+		if quiet {
+			assert.Empty(t, logs.String())
+		} else {
+			assert.Contains(t, logs.String(), "Processing: kas.md")
+		}
+	}
+
+	// Warnings are still reported
+	logs, logger := output(true)
+	logger.Printf("Processing: %s", "kas.md")
+	logger.Printf("Warning: failed to read %s: %v", "policy.md", os.ErrPermission)
+	assert.Equal(t, "Warning: failed to read policy.md: permission denied", logs.String())
+}
+
+func Test_RemoveMatchingDocumentsLeavesIndexWhenNothingMatches(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	store := llm.NewSimpleRAGStore(indexPath)
+	require.NoError(t, store.AddDocument(llm.SimpleDocument{ID: "kas", Title: "KAS", FilePath: "kas.md", Content: "The KAS rewraps keys."}))
+	require.NoError(t, store.SaveIndex())
+	before, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	modified := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(indexPath, modified, modified))
+
+	index := llm.NewSimpleRAGStore(indexPath)
+	require.NoError(t, index.LoadIndex())
+	confirmed := false
+	removed, err := removeMatchingDocuments(index, llm.DocumentFilter{SourceFile: "missing.md"}, func(int) { confirmed = true })
+	require.ErrorIs(t, err, errNoMatchingDocuments)
+	assert.Zero(t, removed)
+	assert.False(t, confirmed, "nothing to confirm")
+
+	after, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	info, err := os.Stat(indexPath)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(modified), "the index was not rewritten")
+
+	removed, err = removeMatchingDocuments(index, llm.DocumentFilter{SourceFile: "kas.md"}, func(matching int) {
+		confirmed = true
+		assert.Equal(t, 1, matching)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.True(t, confirmed)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// terminalWidth returns the width of the terminal out is attached to, or renderWidthDefault
+// when it isn't one
+func terminalWidth(out *os.File) int {
+	width, _, err := term.GetSize(int(out.Fd()))
+	if err != nil || width <= 0 {
+		return renderWidthDefault
+	}
+	return width
+}
+
+// textWrapper word-wraps a response to a width as it streams in. Text is held back until its
+// line is complete, so a word is never split across tokens. Fenced code blocks and table rows
+// are left as they are, since wrapping would break them. A nil *textWrapper passes text
+// through unchanged.
+type textWrapper struct {
+	width  int
+	line   strings.Builder
+	inCode bool
+}
+
+// newTextWrapper returns a wrapper for width columns, or nil when width is 0 and wrapping is
+// off
+func newTextWrapper(width int) *textWrapper {
+	if width <= 0 {
+		return nil
+	}
+	return &textWrapper{width: width}
+}
+
+// Add takes the next piece of the response and returns the lines it completed, wrapped
+func (w *textWrapper) Add(text string) string {
+	if w == nil {
+		return text
+	}
+	var out strings.Builder
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			w.line.WriteString(text)
+			return out.String()
+		}
+		w.line.WriteString(text[:i])
+		out.WriteString(w.wrapLine(w.line.String()))
+		out.WriteByte('\n')
+		w.line.Reset()
+		text = text[i+1:]
+	}
+}
+
+// Flush returns the rest of the response, wrapped, once it is complete
+func (w *textWrapper) Flush() string {
+	if w == nil {
+		return ""
+	}
+	line := w.line.String()
+	w.line.Reset()
+	if line == "" {
+		return ""
+	}
+	return w.wrapLine(line)
+}
+
+// renderResponse formats a complete response: rendered with markdown when it is set, which
+// wraps to the terminal itself, and otherwise wrapped to wrapWidth
+func renderResponse(text string, markdown *markdownRenderer, wrapWidth int) string {
+	if markdown.enabled() {
+		return markdown.Render(text)
+	}
+	return wrapText(text, wrapWidth)
+}
+
+// wrapText wraps a complete response to width columns; 0 leaves it unchanged
+func wrapText(text string, width int) string {
+	w := newTextWrapper(width)
+	return w.Add(text) + w.Flush()
+}
+
+// wrapLine breaks one line of the response between words so each part fits the width.
+// Continuation lines are indented to line up with the text of a list item. A word longer
+// than the width, such as a URL, gets a line of its own rather than being split.
+func (w *textWrapper) wrapLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		w.inCode = !w.inCode
+		return line
+	}
+	if w.inCode || strings.HasPrefix(trimmed, "|") || lipgloss.Width(line) <= w.width {
+		return line
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	hanging := indent + strings.Repeat(" ", len(listMarker(trimmed)))
+
+	var out strings.Builder
+	out.WriteString(indent)
+	column := lipgloss.Width(indent)
+	start := true
+	for _, word := range strings.Fields(trimmed) {
+		wordWidth := lipgloss.Width(word)
+		if !start && column+1+wordWidth > w.width {
+			out.WriteString("\n" + hanging)
+			column = lipgloss.Width(hanging)
+			start = true
+		}
+		if !start {
+			out.WriteByte(' ')
+			column++
+		}
+		out.WriteString(word)
+		column += wordWidth
+		start = false
+	}
+	return out.String()
+}
+
+// listMarker returns the bullet or number that starts a list item, with the space after
+// it, or "" when line isn't one
+func listMarker(line string) string {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return bullet
+		}
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	if digits > 0 && (strings.HasPrefix(line[digits:], ". ") || strings.HasPrefix(line[digits:], ") ")) {
+		return line[:digits+2]
+	}
+	return ""
+}
@@ -39,6 +39,12 @@ type LLM struct {
 	Temperature      float64 `yaml:"temperature" default:"0.7"`
 	Stream           bool    `yaml:"stream" default:"true"`
 	SystemPrompt     string  `yaml:"system_prompt" default:""`
+	// RAG defaults, which a project's .otdfctl-llm.yaml can also set
+	IndexPath       string `yaml:"index_path" default:""`
+	Store           string `yaml:"store" default:""`
+	SimpleIndexPath string `yaml:"simple_index_path" default:""`
+	EmbeddingModel  string `yaml:"embedding_model" default:""`
+	ChunkSize       int    `yaml:"chunk_size" default:"0"`
 }
 
 type Config struct {
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LLMProjectFile is the name of a per-project config holding LLM and RAG defaults. The llm
+// commands discover it by walking up from the working directory.
+const LLMProjectFile = ".otdfctl-llm.yaml"
+
+// FindLLMProjectConfig returns the path of the LLMProjectFile in dir or the nearest parent
+// directory that has one, or "" if none does
+func FindLLMProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, LLMProjectFile)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadLLMProjectConfig overlays the settings in the project config at path onto base, which
+// is usually the global config's LLM section. Settings the file leaves out keep their base
+// value. Relative paths in the file are resolved against the file's directory.
+func LoadLLMProjectConfig(path string, base LLM) (LLM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return base, errors.Join(err, ErrLoadingConfig)
+	}
+
+	merged := base
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return base, errors.Join(fmt.Errorf("invalid %s: %w", path, err), ErrLoadingConfig)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []struct {
+		merged *string
+		base   string
+	}{
+		{&merged.DefaultModelPath, base.DefaultModelPath},
+		{&merged.IndexPath, base.IndexPath},
+		{&merged.SimpleIndexPath, base.SimpleIndexPath},
+		{&merged.EmbeddingModel, base.EmbeddingModel},
+	} {
+		if *p.merged != p.base {
+			*p.merged = resolveProjectPath(dir, *p.merged)
+		}
+	}
+	return merged, nil
+}
+
+// resolveProjectPath makes a relative file path from a project config relative to its
+// directory. Values without a path separator, such as Ollama model names, are left alone.
+func resolveProjectPath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if !strings.ContainsRune(p, '/') && !strings.HasSuffix(p, ".gguf") {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomicInterruptedKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"documents": []}`), 0o644))
+
+	interrupted := errors.New("interrupted")
+	err := writeFileAtomic(path, 0o644, func(w io.Writer) error {
+		if _, err := io.WriteString(w, `{"documents": [{"id": "half`); err != nil {
+			return err
+		}
+		return interrupted
+	})
+	require.ErrorIs(t, err, interrupted)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"documents": []}`, string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file is cleaned up")
+}
+
+func TestWriteFileAtomicReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+	require.NoError(t, writeFileAtomic(path, 0o644, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+}
+
+func TestFailedSaveLeavesPriorIndexIntact(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	store := NewVectorStore(indexPath)
+	require.NoError(t, store.AddDocument(Document{ID: "saved", Embedding: []float32{1, 0}}))
+	require.NoError(t, store.SaveIndex())
+
+	// NaN can't be encoded as JSON, so this save fails part way through
+	injectDocument(t, store, Document{ID: "broken", Embedding: []float32{float32(math.NaN()), 0}})
+	require.ErrorIs(t, store.SaveIndex(), ErrIndexSave)
+
+	reloaded := NewVectorStore(indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	docs := reloaded.ListDocuments(DocumentFilter{})
+	require.Len(t, docs, 1)
+	assert.Equal(t, "saved", docs[0].ID)
+}
+
+func TestPartialWriteLeavesIndexLoadable(t *testing.T) {
+	dir := t.TempDir()
+	vectorPath := filepath.Join(dir, "rag_index.json")
+	simplePath := filepath.Join(dir, "simple_rag_index.json")
+
+	vectors := NewVectorStore(vectorPath)
+	require.NoError(t, vectors.AddDocument(Document{ID: "kas", Embedding: []float32{1, 0}}))
+	require.NoError(t, vectors.SaveIndex())
+	keywords := NewSimpleRAGStore(simplePath)
+	require.NoError(t, keywords.AddDocument(SimpleDocument{ID: "kas", Title: "Key Access Service", Content: "rewrap"}))
+	require.NoError(t, keywords.SaveIndex())
+
+	// A save that dies part way through writes only a prefix of the new index
+	for _, path := range []string{vectorPath, simplePath} {
+		err := writeFileAtomic(path, 0o644, func(w io.Writer) error {
+			if _, err := io.WriteString(w, `{"documents": [`); err != nil {
+				return err
+			}
+			return io.ErrUnexpectedEOF
+		})
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+
+	reloadedVectors := NewVectorStore(vectorPath)
+	require.NoError(t, reloadedVectors.LoadIndex())
+	assert.Equal(t, 1, reloadedVectors.GetDocumentCount())
+	reloadedKeywords := NewSimpleRAGStore(simplePath)
+	require.NoError(t, reloadedKeywords.LoadIndex())
+	assert.Equal(t, 1, reloadedKeywords.GetDocumentCount())
+}
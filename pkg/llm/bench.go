@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultBenchmarkPrompt is the fixed prompt used by `llm bench` so results are comparable across models
+const DefaultBenchmarkPrompt = "Explain in a few sentences what the OpenTDF Key Access Service does and why attribute-based access control is useful."
+
+// GenerationTimings captures how long each phase of a single generation took
+type GenerationTimings struct {
+	PromptTokens     int           `json:"prompt_tokens"`
+	GeneratedTokens  int           `json:"generated_tokens"`
+	PromptEval       time.Duration `json:"prompt_eval_ns"`
+	TimeToFirstToken time.Duration `json:"time_to_first_token_ns"`
+	Total            time.Duration `json:"total_ns"`
+}
+
+// TokensPerSecond returns the token generation rate after the prompt was evaluated
+func (t GenerationTimings) TokensPerSecond() float64 {
+	generation := t.Total - t.PromptEval
+	if t.GeneratedTokens == 0 || generation <= 0 {
+		return 0
+	}
+	return float64(t.GeneratedTokens) / generation.Seconds()
+}
+
+// Benchmarker runs a single timed generation for a prompt
+type Benchmarker interface {
+	TimedGenerate(prompt string) (GenerationTimings, error)
+}
+
+// BenchmarkReport summarizes timings averaged over several runs
+type BenchmarkReport struct {
+	Runs                  int                 `json:"runs"`
+	PromptTokens          int                 `json:"prompt_tokens"`
+	AvgGeneratedTokens    float64             `json:"avg_generated_tokens"`
+	AvgPromptEvalMs       float64             `json:"avg_prompt_eval_ms"`
+	AvgTimeToFirstTokenMs float64             `json:"avg_time_to_first_token_ms"`
+	AvgTotalMs            float64             `json:"avg_total_ms"`
+	AvgTokensPerSecond    float64             `json:"avg_tokens_per_second"`
+	Results               []GenerationTimings `json:"results"`
+}
+
+// RunBenchmark runs the prompt the given number of times and averages the timings
+func RunBenchmark(b Benchmarker, prompt string, runs int) (BenchmarkReport, error) {
+	if runs < 1 {
+		return BenchmarkReport{}, fmt.Errorf("runs must be at least 1, got %d", runs)
+	}
+
+	report := BenchmarkReport{
+		Runs:    runs,
+		Results: make([]GenerationTimings, 0, runs),
+	}
+
+	for i := 0; i < runs; i++ {
+		timings, err := b.TimedGenerate(prompt)
+		if err != nil {
+			return BenchmarkReport{}, fmt.Errorf("benchmark run %d failed: %w", i+1, err)
+		}
+
+		report.PromptTokens = timings.PromptTokens
+		report.AvgGeneratedTokens += float64(timings.GeneratedTokens)
+		report.AvgPromptEvalMs += durationMs(timings.PromptEval)
+		report.AvgTimeToFirstTokenMs += durationMs(timings.TimeToFirstToken)
+		report.AvgTotalMs += durationMs(timings.Total)
+		report.AvgTokensPerSecond += timings.TokensPerSecond()
+		report.Results = append(report.Results, timings)
+	}
+
+	n := float64(runs)
+	report.AvgGeneratedTokens /= n
+	report.AvgPromptEvalMs /= n
+	report.AvgTimeToFirstTokenMs /= n
+	report.AvgTotalMs /= n
+	report.AvgTokensPerSecond /= n
+
+	return report, nil
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
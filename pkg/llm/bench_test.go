@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubBenchmarker struct {
+	calls int
+	err   error
+}
+
+func (s *stubBenchmarker) TimedGenerate(string) (GenerationTimings, error) {
+	s.calls++
+	if s.err != nil {
+		return GenerationTimings{}, s.err
+	}
+	return GenerationTimings{
+		PromptTokens:     12,
+		GeneratedTokens:  20,
+		PromptEval:       100 * time.Millisecond,
+		TimeToFirstToken: 150 * time.Millisecond,
+		Total:            1100 * time.Millisecond,
+	}, nil
+}
+
+func TestRunBenchmark(t *testing.T) {
+	stub := &stubBenchmarker{}
+
+	report, err := RunBenchmark(stub, DefaultBenchmarkPrompt, 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, stub.calls)
+	assert.Equal(t, 3, report.Runs)
+	assert.Len(t, report.Results, 3)
+	assert.Equal(t, 12, report.PromptTokens)
+	assert.GreaterOrEqual(t, report.AvgPromptEvalMs, 0.0)
+	assert.GreaterOrEqual(t, report.AvgTimeToFirstTokenMs, 0.0)
+	assert.InDelta(t, 100.0, report.AvgPromptEvalMs, 0.001)
+	assert.InDelta(t, 20.0, report.AvgTokensPerSecond, 0.001)
+}
+
+func TestRunBenchmarkErrors(t *testing.T) {
+	_, err := RunBenchmark(&stubBenchmarker{}, DefaultBenchmarkPrompt, 0)
+	require.Error(t, err)
+
+	failure := errors.New("decode failed")
+	_, err = RunBenchmark(&stubBenchmarker{err: failure}, DefaultBenchmarkPrompt, 2)
+	require.ErrorIs(t, err, failure)
+}
+
+func TestTokensPerSecondNoTokens(t *testing.T) {
+	assert.Zero(t, GenerationTimings{Total: time.Second}.TokensPerSecond())
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SourceBoost multiplies the retrieval score of documents from matching sources by Weight,
+// e.g. to rank official spec docs above blog posts. Pattern matches as for SetPathFilter: the
+// whole relative path, the file name, or a parent directory.
+type SourceBoost struct {
+	Pattern string
+	Weight  float32
+}
+
+// SourceBoosts are the boosts given to documents as they are ingested. When several patterns
+// match a document, the last one wins.
+type SourceBoosts []SourceBoost
+
+// ParseSourceBoosts parses boosts written as pattern=weight, such as "spec=1.5" or
+// "blog/*.md=0.5". Weights must be positive; a weight below 1 ranks a source lower.
+func ParseSourceBoosts(values []string) (SourceBoosts, error) {
+	boosts := make(SourceBoosts, 0, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w %q: expected pattern=weight", ErrInvalidBoost, value)
+		}
+		pattern := value[:i]
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidBoost, value, err)
+		}
+		weight, err := strconv.ParseFloat(value[i+1:], 32)
+		if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("%w %q: the weight must be a positive number", ErrInvalidBoost, value)
+		}
+		boosts = append(boosts, SourceBoost{Pattern: pattern, Weight: float32(weight)})
+	}
+	return boosts, nil
+}
+
+// Weight returns the boost for a document at relPath, or 0 when no pattern matches it
+func (b SourceBoosts) Weight(relPath string) float32 {
+	relPath = filepath.ToSlash(relPath)
+	for i := len(b) - 1; i >= 0; i-- {
+		if matchAny([]string{b[i].Pattern}, relPath) {
+			return b[i].Weight
+		}
+	}
+	return 0
+}
+
+// boosted multiplies score by a document's boost. A boost of 0, which documents indexed
+// without one have, leaves the score as it is.
+func boosted(score, boost float32) float32 {
+	if boost <= 0 {
+		return score
+	}
+	return score * boost
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourceBoosts(t *testing.T) {
+	boosts, err := ParseSourceBoosts([]string{"spec=1.5", "blog/*.md=0.5", "spec/draft.md=1"})
+	require.NoError(t, err)
+	require.Len(t, boosts, 3)
+	assert.Equal(t, SourceBoost{Pattern: "spec", Weight: 1.5}, boosts[0])
+
+	assert.Equal(t, float32(1.5), boosts.Weight("spec/tdf.md"))
+	assert.Equal(t, float32(0.5), boosts.Weight("blog/launch.md"))
+	assert.Equal(t, float32(1), boosts.Weight("spec/draft.md"), "the last matching pattern wins")
+	assert.Zero(t, boosts.Weight("guides/kas.md"))
+	assert.Zero(t, SourceBoosts(nil).Weight("spec/tdf.md"))
+
+	for _, value := range []string{"spec", "=1.5", "spec=", "spec=fast", "spec=0", "spec=-2", "spec=Inf", "spec/[.md=2"} {
+		_, err := ParseSourceBoosts([]string{value})
+		require.ErrorIs(t, err, ErrInvalidBoost, value)
+	}
+}
+
+func TestStoreSourceBoost(t *testing.T) {
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			store := newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			// Equally similar documents, the boosted one added last
+			require.NoError(t, store.AddDocument(Document{ID: "blog", FilePath: "blog/tdf.md", Embedding: []float32{1, 0}}))
+			require.NoError(t, store.AddDocument(Document{ID: "spec", FilePath: "spec/tdf.md", Embedding: []float32{1, 0}, Boost: 1.5}))
+			require.NoError(t, store.SaveIndex())
+			require.NoError(t, store.Close())
+
+			store = newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+			results, err := store.Search([]float32{1, 0}, 2)
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, "spec", results[0].Document.ID)
+			assert.InDelta(t, 1.5, results[0].Similarity, 0.0001)
+			assert.Equal(t, float32(1.5), results[0].Document.Boost)
+			assert.InDelta(t, 1.0, results[1].Similarity, 0.0001)
+
+			explanation := store.ExplainSimilarity([]float32{1, 0}, results[0].Document)
+			assert.Equal(t, results[0].Similarity, explanation.Similarity)
+			assert.Equal(t, float32(1.5), explanation.SourceBoost)
+		})
+	}
+}
+
+func TestKeywordStoreSourceBoost(t *testing.T) {
+	backends := map[string]func(dir string) KeywordStore{
+		StoreJSON:   func(dir string) KeywordStore { return NewSimpleRAGStore(filepath.Join(dir, "simple.json")) },
+		StoreSQLite: func(dir string) KeywordStore { return NewSQLiteKeywordStore(filepath.Join(dir, "simple.db")) },
+	}
+	content := "Obligations are conditions a client must fulfill before decrypting."
+	for kind, newStore := range backends {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			store := newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			require.NoError(t, store.AddDocument(SimpleDocument{ID: "blog", Title: "Obligations", FilePath: "blog/obligations.md", Content: content}))
+			require.NoError(t, store.AddDocument(SimpleDocument{ID: "spec", Title: "Obligations", FilePath: "spec/obligations.md", Content: content, Boost: 2}))
+			require.NoError(t, store.SaveIndex())
+			require.NoError(t, store.Close())
+
+			store = newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+			results, err := store.Search("obligations", 2)
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, "spec", results[0].Document.ID)
+			assert.InDelta(t, 2*results[1].Score, results[0].Score, 0.0001)
+			assert.Equal(t, float32(2), store.ExplainScore("obligations", results[0].Document).SourceBoost)
+		})
+	}
+}
+
+func TestCalculateScoreAppliesBoost(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "simple.json"))
+	for _, doc := range openTDFDocs {
+		require.NoError(t, store.AddDocument(doc))
+	}
+
+	q := parseKeywordQuery("subject mappings")
+	doc := openTDFDocs[2]
+	score := store.calculateScore(q, doc)
+	require.Positive(t, score)
+	doc.Boost = 1.5
+	assert.InDelta(t, 1.5*score, store.calculateScore(q, doc), 0.0001)
+}
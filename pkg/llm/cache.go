@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ResponseCache stores finished completions on disk, one JSON file per prompt, so a repeated
+// question is answered without running the model. Only deterministic generation should be
+// cached: with a time-based seed the same prompt is expected to give different answers.
+type ResponseCache struct {
+	dir string
+}
+
+// cachedResponse is what a ResponseCache entry holds
+type cachedResponse struct {
+	Content          string     `json:"content"`
+	StopReason       StopReason `json:"stop_reason"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+}
+
+// cachedRerank is what a ResponseCache rerank entry holds: the positions of the candidates the
+// reranker kept, in the order it ranked them
+type cachedRerank struct {
+	Order []int `json:"rerank_order"`
+}
+
+// NewResponseCache creates a cache in dir, which is created on the first Put
+func NewResponseCache(dir string) *ResponseCache {
+	return &ResponseCache{dir: dir}
+}
+
+// ResponseCacheKey identifies a completion by everything that decides its text: the model,
+// the full prompt, the sampling options and advanced llama settings, and the context size,
+// which decides how the prompt is truncated. The model file's size and modification time are
+// included so replacing the model at the same path misses the cache.
+func ResponseCacheKey(modelPath, prompt string, sampling SamplingOptions, params ModelParams, contextSize int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "model=%s\n", modelPath)
+	if info, err := os.Stat(modelPath); err == nil {
+		fmt.Fprintf(h, "size=%d modified=%d\n", info.Size(), info.ModTime().UnixNano())
+	}
+	fmt.Fprintf(h, "sampling=%+v\nparams=%+v\ncontext=%d\n", sampling, params, contextSize)
+	io.WriteString(h, prompt)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the response stored under key, if any. Unreadable entries are treated as misses.
+func (c *ResponseCache) Get(key string) (SimpleResponse, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return SimpleResponse{}, false
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return SimpleResponse{}, false
+	}
+	return SimpleResponse{
+		Content:          entry.Content,
+		TokenCount:       entry.CompletionTokens,
+		PromptTokens:     entry.PromptTokens,
+		CompletionTokens: entry.CompletionTokens,
+		StopReason:       entry.StopReason,
+		Cached:           true,
+	}, true
+}
+
+// Put stores resp under key, replacing any earlier entry
+func (c *ResponseCache) Put(key string, resp SimpleResponse) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	entry := cachedResponse{
+		Content:          resp.Content,
+		StopReason:       resp.StopReason,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+	}
+	return writeFileAtomic(c.path(key), 0644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+// GetRerank returns the rerank order stored under key, if any, for a retrieval of candidates
+// results. Unreadable entries, and orders that don't fit that many candidates, are misses.
+func (c *ResponseCache) GetRerank(key string, candidates int) ([]int, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cachedRerank
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Order == nil {
+		return nil, false
+	}
+	for _, i := range entry.Order {
+		if i < 0 || i >= candidates {
+			return nil, false
+		}
+	}
+	return entry.Order, true
+}
+
+// PutRerank stores a rerank order under key, replacing any earlier entry
+func (c *ResponseCache) PutRerank(key string, order []int) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return writeFileAtomic(c.path(key), 0644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(cachedRerank{Order: order})
+	})
+}
+
+// path returns the file an entry is stored in
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
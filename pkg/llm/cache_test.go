@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "llm_cache")
+	cache := NewResponseCache(dir)
+
+	_, ok := cache.Get("missing")
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Put("key", SimpleResponse{Content: "otdfctl policy attributes list", StopReason: StopReasonEOG, PromptTokens: 30, CompletionTokens: 6}))
+	resp, ok := cache.Get("key")
+	require.True(t, ok)
+	assert.Equal(t, SimpleResponse{
+		Content:          "otdfctl policy attributes list",
+		TokenCount:       6,
+		PromptTokens:     30,
+		CompletionTokens: 6,
+		StopReason:       StopReasonEOG,
+		Cached:           true,
+	}, resp)
+
+	// A damaged entry is a miss rather than an error
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"content": `), 0o644))
+	_, ok = cache.Get("broken")
+	assert.False(t, ok)
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	greedy := SamplingOptions{Greedy: true, Temperature: 0.7}
+	key := ResponseCacheKey("model.gguf", "prompt", greedy, DefaultModelParams(), 4096)
+	assert.Equal(t, key, ResponseCacheKey("model.gguf", "prompt", greedy, DefaultModelParams(), 4096))
+
+	seeded := greedy
+	seeded.Seed = 42
+	warmer := greedy
+	warmer.Temperature = 0.9
+	topK := DefaultModelParams()
+	topK.TopK = 20
+	for name, other := range map[string]string{
+		"model":        ResponseCacheKey("other.gguf", "prompt", greedy, DefaultModelParams(), 4096),
+		"prompt":       ResponseCacheKey("model.gguf", "other prompt", greedy, DefaultModelParams(), 4096),
+		"seed":         ResponseCacheKey("model.gguf", "prompt", seeded, DefaultModelParams(), 4096),
+		"temperature":  ResponseCacheKey("model.gguf", "prompt", warmer, DefaultModelParams(), 4096),
+		"context size": ResponseCacheKey("model.gguf", "prompt", greedy, DefaultModelParams(), 2048),
+		"model params": ResponseCacheKey("model.gguf", "prompt", greedy, topK, 4096),
+	} {
+		assert.NotEqual(t, key, other, name)
+	}
+}
+
+func TestCacheHitSkipsInference(t *testing.T) {
+	cache := NewResponseCache(t.TempDir())
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetSamplingOptions(SamplingOptions{Greedy: true})
+	engine.SetResponseCache(cache)
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "How do I list attributes?"}}
+
+	// A miss falls through to inference, which fails without a model
+	resp := engine.Chat(messages)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+
+	prompt := strings.TrimSuffix(strings.TrimPrefix(shown.String(), "----- prompt -----\n"), "\n----- end of prompt -----\n")
+	key := ResponseCacheKey("missing.gguf", prompt, SamplingOptions{Greedy: true}, DefaultModelParams(), engine.ContextSize())
+	require.NoError(t, cache.Put(key, SimpleResponse{Content: "otdfctl policy attributes list", StopReason: StopReasonEOG}))
+
+	// The model never loaded, so these answers can only have come from the cache
+	resp = engine.Chat(messages)
+	require.NoError(t, resp.Error)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "otdfctl policy attributes list", resp.Content)
+
+	var streamed strings.Builder
+	resp = engine.ChatStream(context.Background(), messages, func(token string) { streamed.WriteString(token) })
+	require.NoError(t, resp.Error)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "otdfctl policy attributes list", streamed.String())
+
+	// Changing the seed misses the cache
+	engine.SetSamplingOptions(SamplingOptions{Greedy: true, Seed: 7})
+	resp = engine.Chat(messages)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+}
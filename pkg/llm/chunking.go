@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// codePlaceholderRegex matches a line holding nothing but a StashCodeBlocks placeholder
+var codePlaceholderRegex = regexp.MustCompile(`^@@CODE(\d+)@@$`)
+
+// markdownSection is a run of prose, or a single fenced code block, from a processed document
+type markdownSection struct {
+	text string
+	code bool
+}
+
+// joinSections returns the processed document the sections were split from
+func joinSections(sections []markdownSection) string {
+	texts := make([]string, len(sections))
+	for i, section := range sections {
+		texts[i] = section.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// splitCodeSections splits content at the lines left by StashCodeBlocks, putting each block's
+// code back as a section of its own between the runs of prose
+func splitCodeSections(content string, blocks []string) []markdownSection {
+	var sections []markdownSection
+	var prose []string
+	flush := func() {
+		if len(prose) > 0 {
+			sections = append(sections, markdownSection{text: strings.Join(prose, "\n")})
+			prose = nil
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if match := codePlaceholderRegex.FindStringSubmatch(line); match != nil {
+			if i, err := strconv.Atoi(match[1]); err == nil && i < len(blocks) {
+				flush()
+				sections = append(sections, markdownSection{text: blocks[i], code: true})
+				continue
+			}
+		}
+		prose = append(prose, line)
+	}
+	flush()
+	return sections
+}
+
+// chunkSections chunks prose at chunkSize words and code at codeChunkSize words. Code packs
+// more tokens into each word, so it gets the smaller size, and each code block is chunked on
+// its own so an example that fits stays whole in a single chunk. A document without code is
+// chunked as ChunkText would.
+func chunkSections(sections []markdownSection, chunkSize, codeChunkSize, overlap int) []string {
+	hasCode := false
+	for _, section := range sections {
+		hasCode = hasCode || section.code
+	}
+	if !hasCode {
+		return ChunkText(joinSections(sections), chunkSize, overlap)
+	}
+
+	var chunks []string
+	for _, section := range sections {
+		if strings.TrimSpace(section.text) == "" {
+			continue
+		}
+		size := chunkSize
+		if section.code {
+			size = codeChunkSize
+		}
+		chunks = append(chunks, ChunkText(section.text, size, min(overlap, size/2))...)
+	}
+	return chunks
+}
+
+// mergeShortChunks folds each chunk of fewer than minWords words into the chunk after it, or
+// into the one before it at the end of the document, so a stray heading line doesn't become a
+// chunk of its own. A document shorter than minWords as a whole gives no chunks. minWords
+// below 1 keeps every chunk.
+func mergeShortChunks(chunks []string, minWords int) []string {
+	if minWords < 1 {
+		return chunks
+	}
+
+	var merged []string
+	pending := ""
+	for _, chunk := range chunks {
+		if pending != "" {
+			chunk = pending + "\n" + chunk
+			pending = ""
+		}
+		if len(strings.Fields(chunk)) < minWords {
+			pending = chunk
+			continue
+		}
+		merged = append(merged, chunk)
+	}
+	if pending != "" && len(merged) > 0 {
+		merged[len(merged)-1] += "\n" + pending
+	}
+	return merged
+}
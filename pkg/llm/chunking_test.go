@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// words returns n numbered words starting with prefix
+func words(prefix string, n int) string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(w, " ")
+}
+
+func TestChunkSectionsSizesCodeAndProseApart(t *testing.T) {
+	sections := []markdownSection{
+		{text: words("prose", 100)},
+		{text: words("code", 100), code: true},
+	}
+
+	chunks := chunkSections(sections, 60, 30, 10)
+	var prose, code []int
+	for _, chunk := range chunks {
+		n := len(strings.Fields(chunk))
+		if strings.HasPrefix(chunk, "code") {
+			code = append(code, n)
+		} else {
+			assert.NotContains(t, chunk, "code", "code is never mixed into prose chunks")
+			prose = append(prose, n)
+		}
+	}
+	// Prose is chunked at 60 words and code at 30, each with its own overlap
+	assert.Equal(t, []int{60, 50}, prose)
+	assert.Equal(t, []int{30, 30, 30, 30, 20}, code)
+}
+
+func TestChunkSectionsKeepsCodeBlocksWhole(t *testing.T) {
+	block := "otdfctl policy attributes create \\\n  --name classification \\\n  --rule HIERARCHY"
+	sections := []markdownSection{
+		{text: "Create the attribute with the CLI:"},
+		{text: block, code: true},
+		{text: "Then add its values."},
+	}
+
+	chunks := chunkSections(sections, 300, 150, 50)
+	assert.Equal(t, []string{"Create the attribute with the CLI:", block, "Then add its values."}, chunks)
+
+	// Without code, prose is chunked across section boundaries just as before
+	prose := []markdownSection{{text: words("a", 40)}, {text: words("b", 40)}}
+	assert.Equal(t, ChunkText(joinSections(prose), 50, 10), chunkSections(prose, 50, 25, 10))
+}
+
+func TestProcessMarkdownSectionsSplitsOutCode(t *testing.T) {
+	content := "# Subject Condition Sets\n\nCreate one with the CLI:\n\n```shell\notdfctl policy subject-condition-sets create\n```\n\nThen map it.\n"
+
+	ingester := NewDocumentIngester(nil, nil, t.TempDir())
+	ingester.SetKeepCode(true)
+	sections := ingester.processMarkdownSections(content)
+	assert.Equal(t, []markdownSection{
+		{text: "Subject Condition Sets\nCreate one with the CLI:"},
+		{text: "otdfctl policy subject-condition-sets create", code: true},
+		{text: "Then map it."},
+	}, sections)
+	assert.Equal(t, ingester.processMarkdown(content), joinSections(sections))
+}
+
+func TestIngestChunksCodeOnItsOwn(t *testing.T) {
+	dir := t.TempDir()
+	example := "otdfctl policy subject-mappings create \\\n  --attribute-value-id 123 \\\n  --action-standard DECRYPT"
+	content := "# Subject Mappings\n\n" + words("prose", 40) + "\n\n```shell\n" + example + "\n```\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mappings.md"), []byte(content), 0o600))
+
+	ingester := NewDocumentIngester(nil, nil, t.TempDir())
+	ingester.SetChunkSize(50)
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, plan.Files, 1)
+	assert.Equal(t, 1, plan.Files[0].Chunks, "without --keep-code the placeholder is chunked with the prose")
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester = NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetChunkSize(50)
+	ingester.SetKeepCode(true)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+	var contents []string
+	for _, info := range store.ListDocuments(DocumentFilter{}) {
+		doc, ok := store.GetDocument(info.ID)
+		require.True(t, ok)
+		contents = append(contents, doc.Content)
+	}
+	require.Len(t, contents, 2)
+	assert.NotContains(t, contents[0], "otdfctl")
+	assert.Equal(t, example, contents[1], "the example is a chunk of its own, formatting intact")
+}
+
+func TestMergeShortChunks(t *testing.T) {
+	heading := "Key Access Service"
+	chunks := []string{heading, words("a", 20), "See also", words("b", 20), "Next steps"}
+
+	merged := mergeShortChunks(chunks, 5)
+	assert.Equal(t, []string{
+		heading + "\n" + words("a", 20),
+		"See also\n" + words("b", 20) + "\nNext steps",
+	}, merged, "short chunks join the next chunk, or the previous one at the end")
+	for _, chunk := range merged {
+		assert.GreaterOrEqual(t, len(strings.Fields(chunk)), 5)
+	}
+	assert.Equal(t, strings.Fields(strings.Join(chunks, " ")), strings.Fields(strings.Join(merged, " ")), "no text is lost")
+
+	assert.Empty(t, mergeShortChunks([]string{heading}, 5), "a document shorter than the minimum is dropped")
+	assert.Equal(t, chunks, mergeShortChunks(chunks, 0))
+}
+
+func TestIngestMinChunkSize(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Attributes\n\n" + words("prose", 40) + "\n\n```shell\notdfctl policy attributes list\n```\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "attributes.md"), []byte(content), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stub.md"), []byte("# TODO\n\nComing soon.\n"), 0o600))
+
+	plan := func(minChunkSize int) map[string]int {
+		ingester := NewDocumentIngester(nil, nil, t.TempDir())
+		ingester.SetChunkSize(50)
+		ingester.SetKeepCode(true)
+		ingester.SetMinChunkSize(minChunkSize)
+		plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+		require.NoError(t, err)
+		chunks := map[string]int{}
+		for _, file := range plan.Files {
+			chunks[file.FilePath] = file.Chunks
+		}
+		return chunks
+	}
+
+	assert.Equal(t, map[string]int{"attributes.md": 2, "stub.md": 1}, plan(0))
+	assert.Equal(t, map[string]int{"attributes.md": 1, "stub.md": 0}, plan(10), "the short code chunk is merged and the stub dropped")
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetMinChunkSize(10)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.Len(t, store.ListDocuments(DocumentFilter{SourceFile: "stub.md"}), 0)
+	assert.Len(t, store.ListDocuments(DocumentFilter{SourceFile: "attributes.md"}), 1)
+}
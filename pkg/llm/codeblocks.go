@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// codeFenceRegex matches a fenced code block, capturing the code between the fences
+	codeFenceRegex = regexp.MustCompile("(?s)```[^\n]*\n?(.*?)```")
+	// markdownLinkRegex matches an inline link, capturing its text and target
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+)
+
+// StashCodeBlocks replaces each fenced code block with a placeholder line that markdown
+// cleanup leaves alone, and returns the code of each block with its fences stripped.
+// RestoreCodeBlocks puts the code back once the surrounding text has been cleaned.
+func StashCodeBlocks(content string) (string, []string) {
+	return stash(content, codeFenceRegex, "CODE", true, func(match []string) string {
+		return strings.TrimSpace(match[1])
+	})
+}
+
+// RestoreCodeBlocks replaces the placeholders left by StashCodeBlocks with their code
+func RestoreCodeBlocks(content string, blocks []string) string {
+	return restore(content, "CODE", blocks)
+}
+
+// StashLinks replaces each inline link with a placeholder that markdown cleanup leaves alone,
+// and returns each link rendered as its text followed by the URL in parentheses.
+// RestoreLinks puts the links back once the surrounding text has been cleaned.
+func StashLinks(content string) (string, []string) {
+	return stash(content, markdownLinkRegex, "LINK", false, func(match []string) string {
+		return fmt.Sprintf("%s (%s)", match[1], match[2])
+	})
+}
+
+// RestoreLinks replaces the placeholders left by StashLinks with their links
+func RestoreLinks(content string, links []string) string {
+	return restore(content, "LINK", links)
+}
+
+// stash replaces every match of re with a numbered @@TAG<n>@@ placeholder and returns the
+// rendered matches in order. Block placeholders are put on a line of their own.
+func stash(content string, re *regexp.Regexp, tag string, block bool, render func(match []string) string) (string, []string) {
+	var values []string
+	stashed := re.ReplaceAllStringFunc(content, func(match string) string {
+		values = append(values, render(re.FindStringSubmatch(match)))
+		placeholder := fmt.Sprintf("@@%s%d@@", tag, len(values)-1)
+		if block {
+			return "\n" + placeholder + "\n"
+		}
+		return placeholder
+	})
+	return stashed, values
+}
+
+// restore replaces the @@TAG<n>@@ placeholders left by stash with their values
+func restore(content, tag string, values []string) string {
+	if len(values) == 0 {
+		return content
+	}
+	placeholderRegex := regexp.MustCompile(`@@` + tag + `(\d+)@@`)
+	return placeholderRegex.ReplaceAllStringFunc(content, func(placeholder string) string {
+		i, err := strconv.Atoi(placeholderRegex.FindStringSubmatch(placeholder)[1])
+		if err != nil || i >= len(values) {
+			return placeholder
+		}
+		return values[i]
+	})
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultContextFileTokens is the estimated token budget shared by the files added to the
+// system prompt with SetContextFiles
+const DefaultContextFileTokens = 1500
+
+// ContextFile is a local file injected whole into the system prompt, grounding answers in it
+// without ingesting it into an index
+type ContextFile struct {
+	Path    string
+	Content string
+}
+
+// LoadContextFiles reads the files at paths, in order. Empty files are rejected, since they
+// would add a heading with nothing under it.
+func LoadContextFiles(paths []string) ([]ContextFile, error) {
+	files := make([]ContextFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if IsBlank(string(data)) {
+			return nil, fmt.Errorf("context file %s is empty", path)
+		}
+		files = append(files, ContextFile{Path: path, Content: string(data)})
+	}
+	return files, nil
+}
+
+// FileContext is the system prompt section built from context files
+type FileContext struct {
+	Text string
+	// Truncated lists the files cut short, or left out, to fit the token budget
+	Truncated []string
+}
+
+// BuildFileContext formats files, in order, as a system prompt section of at most an estimated
+// maxTokens tokens. Once the budget runs out the file being added is cut short and any after
+// it are left out.
+func BuildFileContext(files []ContextFile, maxTokens int) FileContext {
+	var fc FileContext
+	if len(files) == 0 {
+		return fc
+	}
+
+	var b strings.Builder
+	b.WriteString("# Reference Files\n\n")
+	remaining := maxTokens
+	added := 0
+	for _, file := range files {
+		content := strings.TrimSpace(file.Content)
+		if remaining <= 0 {
+			fc.Truncated = append(fc.Truncated, file.Path)
+			continue
+		}
+		if tokens := estimateTokens(content); tokens > remaining {
+			content = truncateToTokens(content, remaining) + "\n[truncated]"
+			fc.Truncated = append(fc.Truncated, file.Path)
+		}
+		remaining -= estimateTokens(content)
+
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n---\n\n", file.Path, content)
+		added++
+	}
+	if added > 0 {
+		fc.Text = b.String()
+	}
+	return fc
+}
+
+// truncateToTokens cuts text to about maxTokens estimated tokens without splitting a character
+func truncateToTokens(text string, maxTokens int) string {
+	limit := maxTokens * 4
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
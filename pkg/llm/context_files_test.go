@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadContextFiles(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(policy, []byte("attributes:\n  - classification\n"), 0o644))
+
+	files, err := LoadContextFiles([]string{policy})
+	require.NoError(t, err)
+	assert.Equal(t, []ContextFile{{Path: policy, Content: "attributes:\n  - classification\n"}}, files)
+
+	empty := filepath.Join(dir, "empty.txt")
+	require.NoError(t, os.WriteFile(empty, []byte(" \n"), 0o644))
+	_, err = LoadContextFiles([]string{policy, empty})
+	require.Error(t, err)
+
+	_, err = LoadContextFiles([]string{filepath.Join(dir, "missing.txt")})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestBuildFileContext(t *testing.T) {
+	files := []ContextFile{
+		{Path: "kas.yaml", Content: "kas_uri: https://kas.example.com\n"},
+		{Path: "long.md", Content: strings.Repeat("word ", 100)},
+		{Path: "late.md", Content: "Never added."},
+	}
+
+	fc := BuildFileContext(files[:1], 100)
+	assert.Contains(t, fc.Text, "## kas.yaml\n\nkas_uri: https://kas.example.com\n")
+	assert.Empty(t, fc.Truncated)
+
+	// 500 characters of the long file are cut to the 50 tokens the first file leaves
+	fc = BuildFileContext(files, 58)
+	assert.Contains(t, fc.Text, "kas_uri: https://kas.example.com")
+	assert.Contains(t, fc.Text, "## long.md\n\n"+strings.Repeat("word ", 40)+"\n[truncated]")
+	assert.NotContains(t, fc.Text, "Never added.")
+	assert.Equal(t, []string{"long.md", "late.md"}, fc.Truncated)
+
+	fc = BuildFileContext(files, 0)
+	assert.Empty(t, fc.Text)
+	assert.Len(t, fc.Truncated, 3)
+	assert.Empty(t, BuildFileContext(nil, 100).Text)
+}
+
+func TestFileContextAppearsInPrompt(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetFileContext(BuildFileContext([]ContextFile{{Path: "policy.yaml", Content: "attributes:\n  - classification"}}, DefaultContextFileTokens).Text)
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "Which attributes are defined?"},
+	}
+	prompt, _, err := engine.buildPromptWithRAG(messages, "Which attributes are defined?")
+	require.NoError(t, err)
+
+	system := strings.Index(prompt, "You are helpful.")
+	file := strings.Index(prompt, "## policy.yaml\n\nattributes:\n  - classification")
+	end := strings.Index(prompt, "<|im_end|>")
+	require.NotEqual(t, -1, file, "the file content is in the prompt")
+	assert.Less(t, system, file, "the file follows the system prompt")
+	assert.Less(t, file, end, "the file is part of the system message")
+
+	// The file stays when the system message is replaced, or when there is none
+	prompt, _, err = engine.buildPromptWithRAG(messages[1:], "Which attributes are defined?")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "<|im_start|>system\n# Reference Files\n\n## policy.yaml")
+}
+
+func TestHistoryTokensSetsAsideFileContext(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetContextSize(1000)
+	engine.SetFileContext(strings.Repeat("a", 400))
+	assert.Equal(t, 800, engine.HistoryTokens(100))
+}
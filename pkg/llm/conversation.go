@@ -0,0 +1,257 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// SetSystemMessage replaces the system message at the head of the conversation
+// without touching the rest of the history. If the conversation does not start
+// with a system message, one is inserted at index 0.
+func SetSystemMessage(messages []ChatMessage, content string) []ChatMessage {
+	system := ChatMessage{
+		Role:    "system",
+		Content: content,
+	}
+
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0] = system
+		return messages
+	}
+
+	return append([]ChatMessage{system}, messages...)
+}
+
+// ReadConversation reads a conversation to replay from JSON Lines, one ChatMessage object per
+// line; blank lines are skipped. The conversation must pass ValidateConversation.
+func ReadConversation(r io.Reader) ([]ChatMessage, error) {
+	var messages []ChatMessage
+	scanner := bufio.NewScanner(r)
+	// Messages can be long, e.g. a pasted document
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var msg ChatMessage
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidConversation, line, err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := ValidateConversation(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ValidateConversation checks that messages can be answered: an optional leading system
+// message followed by user and assistant turns that alternate, starting and ending with a
+// non-blank user message
+func ValidateConversation(messages []ChatMessage) error {
+	if len(messages) == 0 {
+		return fmt.Errorf("%w: no messages", ErrInvalidConversation)
+	}
+	want := "user"
+	for i, msg := range messages {
+		switch {
+		case msg.Role == "system" && i == 0:
+			continue
+		case msg.Role == "system":
+			return fmt.Errorf("%w: message %d: a system message can only come first", ErrInvalidConversation, i+1)
+		case msg.Role != "user" && msg.Role != "assistant":
+			return fmt.Errorf("%w: message %d: unknown role %q, want system, user or assistant", ErrInvalidConversation, i+1, msg.Role)
+		case msg.Role != want:
+			return fmt.Errorf("%w: message %d: expected a %s message, got %s; turns must alternate starting with user", ErrInvalidConversation, i+1, want, msg.Role)
+		case msg.Role == "user" && IsBlank(msg.Content):
+			return fmt.Errorf("%w: message %d: user message is blank", ErrInvalidConversation, i+1)
+		}
+		if want == "user" {
+			want = "assistant"
+		} else {
+			want = "user"
+		}
+	}
+	if want != "assistant" {
+		return fmt.Errorf("%w: the last message must be from the user, since it is the one answered", ErrInvalidConversation)
+	}
+	return nil
+}
+
+// JoinSystemPrompts layers prompts into one system prompt, in order and separated by blank
+// lines, so a base persona can be combined with task-specific instructions. Blank prompts
+// are skipped.
+func JoinSystemPrompts(prompts ...string) string {
+	var parts []string
+	for _, prompt := range prompts {
+		if !IsBlank(prompt) {
+			parts = append(parts, strings.TrimSpace(prompt))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// TruncateHistory drops the oldest turns until the conversation's estimated token count fits
+// within maxTokens. A leading system message and the latest message are always kept.
+// A maxTokens of 0 or less leaves the conversation untouched.
+func TruncateHistory(messages []ChatMessage, maxTokens int) []ChatMessage {
+	if maxTokens <= 0 || len(messages) == 0 {
+		return messages
+	}
+
+	var head []ChatMessage
+	rest := messages
+	if rest[0].Role == "system" {
+		head, rest = rest[:1], rest[1:]
+	}
+
+	used := 0
+	for _, msg := range head {
+		used += estimateTokens(msg.Content)
+	}
+
+	start := newestThatFit(rest, maxTokens-used)
+	if start == 0 {
+		return messages
+	}
+	truncated := make([]ChatMessage, 0, len(head)+len(rest)-start)
+	truncated = append(truncated, head...)
+	return append(truncated, rest[start:]...)
+}
+
+// LimitHistory keeps at most turns earlier turns, a user message and the replies to it, before
+// the latest message, dropping the oldest first. A leading system message and the latest
+// message are always kept. A turns of 0 or less leaves the conversation untouched.
+func LimitHistory(messages []ChatMessage, turns int) []ChatMessage {
+	if turns <= 0 || len(messages) == 0 {
+		return messages
+	}
+
+	var head []ChatMessage
+	rest := messages
+	if rest[0].Role == "system" {
+		head, rest = rest[:1], rest[1:]
+	}
+	if len(rest) == 0 {
+		return messages
+	}
+
+	// Walk back from the latest message, keeping earlier turns until the limit is reached
+	start := len(rest) - 1
+	kept := 0
+	for start > 0 {
+		if rest[start-1].Role == "user" {
+			if kept == turns {
+				break
+			}
+			kept++
+		}
+		start--
+	}
+	if start == 0 {
+		return messages
+	}
+	// Replies whose question was dropped go too
+	for start < len(rest)-1 && rest[start].Role != "user" {
+		start++
+	}
+
+	limited := make([]ChatMessage, 0, len(head)+len(rest)-start)
+	limited = append(limited, head...)
+	return append(limited, rest[start:]...)
+}
+
+// newestThatFit returns the index of the oldest message such that it and every message after
+// it fit within budget tokens. The latest message always fits.
+func newestThatFit(messages []ChatMessage, budget int) int {
+	used := 0
+	// Walk back from the newest message, keeping turns while they fit
+	start := len(messages)
+	for start > 0 {
+		tokens := estimateTokens(messages[start-1].Content)
+		if used+tokens > budget && start < len(messages) {
+			break
+		}
+		used += tokens
+		start--
+	}
+	return start
+}
+
+// Summarizer condenses conversation turns into a short summary
+type Summarizer func(turns []ChatMessage) (string, error)
+
+// SummaryPrefix starts the message SummarizeHistory puts in place of the turns it compressed
+const SummaryPrefix = "Summary of the earlier conversation:\n"
+
+// SummarizeHistory compresses the oldest turns into a single summary message once the
+// conversation's estimated token count exceeds maxTokens. The newest turns that fit in half
+// the budget are kept as they are, as are a leading system message and the latest message.
+// A maxTokens of 0 or less leaves the conversation untouched, and so does a failed summary,
+// whose error is returned.
+func SummarizeHistory(messages []ChatMessage, maxTokens int, summarize Summarizer) ([]ChatMessage, error) {
+	if maxTokens <= 0 {
+		return messages, nil
+	}
+	used := 0
+	for _, msg := range messages {
+		used += estimateTokens(msg.Content)
+	}
+	if used <= maxTokens {
+		return messages, nil
+	}
+
+	var head []ChatMessage
+	rest := messages
+	if len(rest) > 0 && rest[0].Role == "system" {
+		head, rest = rest[:1], rest[1:]
+	}
+
+	// The newest turns that fit in half the budget stay; the rest are summarized
+	start := newestThatFit(rest, maxTokens/2)
+	old, kept := rest[:start], rest[start:]
+	// Replacing a single turn with a summary wouldn't shorten the conversation
+	if len(old) < 2 {
+		return messages, nil
+	}
+
+	summary, err := summarize(old)
+	if err != nil {
+		return messages, err
+	}
+
+	summarized := make([]ChatMessage, 0, len(head)+1+len(kept))
+	summarized = append(summarized, head...)
+	summarized = append(summarized, ChatMessage{
+		Role:    "system",
+		Content: SummaryPrefix + strings.TrimSpace(summary),
+		Time:    old[len(old)-1].Time,
+	})
+	return append(summarized, kept...), nil
+}
+
+// estimateTokens approximates a text's token count at four characters per token
+func estimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// IsBlank reports whether text has nothing to answer: only whitespace or invisible
+// characters such as zero-width spaces
+func IsBlank(text string) bool {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && unicode.IsGraphic(r) {
+			return false
+		}
+	}
+	return true
+}
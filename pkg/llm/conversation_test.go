@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSystemMessage(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: "system", Content: "default"},
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "hi"},
+	}
+
+	messages = SetSystemMessage(messages, "You are a pirate.")
+	require.Len(t, messages, 3)
+	assert.Equal(t, ChatMessage{Role: "system", Content: "You are a pirate."}, messages[0])
+	assert.Equal(t, "hello", messages[1].Content)
+	assert.Equal(t, "hi", messages[2].Content)
+
+	// Resetting restores the original prompt and keeps the history
+	messages = SetSystemMessage(messages, "default")
+	require.Len(t, messages, 3)
+	assert.Equal(t, "default", messages[0].Content)
+	assert.Equal(t, "hello", messages[1].Content)
+}
+
+func TestSetSystemMessageInsertsWhenMissing(t *testing.T) {
+	messages := SetSystemMessage([]ChatMessage{{Role: "user", Content: "hello"}}, "system")
+	require.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "user", messages[1].Role)
+
+	messages = SetSystemMessage(nil, "system")
+	require.Len(t, messages, 1)
+	assert.Equal(t, "system", messages[0].Role)
+}
+
+func TestReadConversation(t *testing.T) {
+	messages, err := ReadConversation(strings.NewReader(`{"role": "system", "content": "You are helpful."}
+{"role": "user", "content": "What is a KAS?"}
+
+{"role": "assistant", "content": "The key access service."}
+{"role": "user", "content": "How do I register one?", "time": "2025-01-02T03:04:05Z"}
+`))
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "How do I register one?", messages[3].Content)
+	assert.Equal(t, 2025, messages[3].Time.Year())
+
+	// The system message is optional
+	messages, err = ReadConversation(strings.NewReader(`{"role": "user", "content": "hi"}`))
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+}
+
+func TestReadConversationRejectsInvalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":              "\n\n",
+		"not json":           `{"role": "user", "content": `,
+		"unknown field":      `{"role": "user", "text": "hi"}`,
+		"unknown role":       `{"role": "tool", "content": "hi"}`,
+		"late system":        `{"role": "user", "content": "hi"}` + "\n" + `{"role": "system", "content": "sys"}`,
+		"starts with reply":  `{"role": "assistant", "content": "hi"}` + "\n" + `{"role": "user", "content": "hi"}`,
+		"two user turns":     `{"role": "user", "content": "hi"}` + "\n" + `{"role": "user", "content": "again"}`,
+		"ends with reply":    `{"role": "user", "content": "hi"}` + "\n" + `{"role": "assistant", "content": "hello"}`,
+		"only system":        `{"role": "system", "content": "sys"}`,
+		"blank user message": `{"role": "user", "content": "  "}`,
+	} {
+		_, err := ReadConversation(strings.NewReader(input))
+		require.ErrorIs(t, err, ErrInvalidConversation, name)
+	}
+}
+
+func TestJoinSystemPrompts(t *testing.T) {
+	assert.Equal(t, "base persona\n\ntask one\n\ntask two", JoinSystemPrompts("base persona\n", "  ", "task one", "task two"))
+	assert.Equal(t, "only", JoinSystemPrompts("only"))
+	assert.Empty(t, JoinSystemPrompts())
+}
+
+func TestTruncateHistory(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: "system", Content: strings.Repeat("s", 40)},
+		{Role: "user", Content: strings.Repeat("a", 40)},
+		{Role: "assistant", Content: strings.Repeat("b", 40)},
+		{Role: "user", Content: strings.Repeat("c", 40)},
+	}
+
+	// Room for the system message and the two newest turns
+	truncated := TruncateHistory(messages, 30)
+	require.Len(t, truncated, 3)
+	assert.Equal(t, "system", truncated[0].Role)
+	assert.Equal(t, messages[2:], truncated[1:])
+
+	assert.Equal(t, messages, TruncateHistory(messages, 0))
+	assert.Equal(t, messages, TruncateHistory(messages, 1000))
+
+	// The latest message survives even when it alone exceeds the budget
+	truncated = TruncateHistory(messages, 5)
+	require.Len(t, truncated, 2)
+	assert.Equal(t, messages[3], truncated[1])
+}
+
+func TestLimitHistory(t *testing.T) {
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}}
+	for i := 1; i <= 6; i++ {
+		messages = append(messages,
+			ChatMessage{Role: "user", Content: fmt.Sprintf("question %d", i)},
+			ChatMessage{Role: "assistant", Content: fmt.Sprintf("answer %d", i)},
+		)
+		asking := append(messages[:len(messages)-1:len(messages)-1], ChatMessage{Role: "user", Content: "next"})
+
+		for limit := 1; limit <= 3; limit++ {
+			for _, conversation := range [][]ChatMessage{messages, asking} {
+				limited := LimitHistory(conversation, limit)
+				assert.Equal(t, conversation[0], limited[0], "the system message is kept")
+				assert.Equal(t, conversation[len(conversation)-1], limited[len(limited)-1], "the latest message is kept")
+				// Only the turns before the latest message count toward the limit
+				assert.LessOrEqual(t, len(limited), 1+2*limit+1)
+				assert.Equal(t, "user", limited[1].Role, "no reply is left without its question")
+				assert.Equal(t, conversation[len(conversation)-len(limited)+1:], limited[1:], "the newest turns are kept")
+			}
+		}
+	}
+
+	assert.Equal(t, messages, LimitHistory(messages, 0))
+	assert.Equal(t, messages, LimitHistory(messages, 100))
+	assert.Equal(t, messages[1:], LimitHistory(messages[1:], 100))
+
+	limited := LimitHistory(messages, 2)
+	assert.Equal(t, []string{"You are helpful.", "question 5", "answer 5", "question 6", "answer 6"}, contents(limited))
+	// Without a system message the turns are counted just the same
+	assert.Equal(t, []string{"question 5", "answer 5", "question 6", "answer 6"}, contents(LimitHistory(messages[1:], 2)))
+}
+
+// contents returns the content of each message
+func contents(messages []ChatMessage) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	messages := []ChatMessage{{Role: "system", Content: strings.Repeat("s", 40)}}
+	for i := 0; i < 6; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, ChatMessage{Role: role, Content: strings.Repeat(string(rune('a'+i)), 40)})
+	}
+
+	var summarized []ChatMessage
+	summarize := func(turns []ChatMessage) (string, error) {
+		summarized = turns
+		return "  they discussed the alphabet\n", nil
+	}
+
+	// Under budget nothing is summarized
+	got, err := SummarizeHistory(messages, 1000, summarize)
+	require.NoError(t, err)
+	assert.Equal(t, messages, got)
+	assert.Nil(t, summarized)
+
+	// 70 tokens in all; the two newest turns fit in half of a 40 token budget
+	got, err = SummarizeHistory(messages, 40, summarize)
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	assert.Less(t, len(got), len(messages))
+	assert.Equal(t, messages[0], got[0], "the system message is preserved")
+	assert.Equal(t, ChatMessage{Role: "system", Content: SummaryPrefix + "they discussed the alphabet"}, got[1])
+	assert.Equal(t, messages[5:], got[2:])
+	assert.Equal(t, messages[1:5], summarized)
+
+	// A later pass folds the earlier summary into the new one
+	got = append(got, ChatMessage{Role: "user", Content: strings.Repeat("g", 40)}, ChatMessage{Role: "assistant", Content: strings.Repeat("h", 40)})
+	got, err = SummarizeHistory(got, 40, summarize)
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	assert.Equal(t, messages[0], got[0])
+	assert.Equal(t, SummaryPrefix, summarized[0].Content[:len(SummaryPrefix)])
+}
+
+func TestSummarizeHistoryFailureKeepsHistory(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: strings.Repeat("a", 40)},
+		{Role: "assistant", Content: strings.Repeat("b", 40)},
+		{Role: "user", Content: strings.Repeat("c", 40)},
+	}
+	failure := errors.New("model unloaded")
+	got, err := SummarizeHistory(messages, 12, func([]ChatMessage) (string, error) { return "", failure })
+	require.ErrorIs(t, err, failure)
+	assert.Equal(t, messages, got)
+
+	got, err = SummarizeHistory(messages, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, messages, got)
+}
+
+func TestIsBlank(t *testing.T) {
+	assert.True(t, IsBlank(""))
+	assert.True(t, IsBlank(" \t\n"))
+	assert.True(t, IsBlank("\u200b\ufeff  "))
+	assert.False(t, IsBlank("  hi "))
+	assert.False(t, IsBlank("鍵"))
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/ollama/ollama/llama"
+)
+
+// defaultBatchSize is how many prompt tokens are decoded per pass unless SetBatchSize says otherwise
+const defaultBatchSize = 512
+
+// promptDecoder decodes a run of consecutive prompt tokens starting at position pos.
+// wantLogits asks for logits of the run's last token so generation can sample from it.
+type promptDecoder interface {
+	decode(tokens []int, pos int, wantLogits bool) error
+}
+
+// llamaBatchDecoder decodes through a llama context, reusing one batch for every pass
+type llamaBatchDecoder struct {
+	context *llama.Context
+	batch   *llama.Batch
+}
+
+func (d *llamaBatchDecoder) decode(tokens []int, pos int, wantLogits bool) error {
+	d.batch.Clear()
+	for i, token := range tokens {
+		d.batch.Add(token, nil, pos+i, wantLogits && i == len(tokens)-1, 0)
+	}
+	return d.context.Decode(d.batch)
+}
+
+// decodePrompt feeds the whole prompt to the decoder in passes of at most batchSize tokens,
+// continuing the positions from one pass to the next. It returns the number of passes.
+func decodePrompt(d promptDecoder, tokens []int, batchSize int) (int, error) {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+
+	passes := 0
+	for start := 0; start < len(tokens); start += batchSize {
+		end := min(start+batchSize, len(tokens))
+		if err := d.decode(tokens[start:end], start, end == len(tokens)); err != nil {
+			return passes, fmt.Errorf("%w: pass %d at position %d: %w", ErrDecode, passes+1, start, err)
+		}
+		passes++
+	}
+	return passes, nil
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodePass struct {
+	tokens     []int
+	pos        int
+	wantLogits bool
+}
+
+// recordingDecoder remembers every pass and can fail on one of them
+type recordingDecoder struct {
+	passes []decodePass
+	failOn int
+}
+
+func (d *recordingDecoder) decode(tokens []int, pos int, wantLogits bool) error {
+	d.passes = append(d.passes, decodePass{tokens: append([]int(nil), tokens...), pos: pos, wantLogits: wantLogits})
+	if d.failOn == len(d.passes) {
+		return errors.New("out of memory")
+	}
+	return nil
+}
+
+func TestDecodePromptInPasses(t *testing.T) {
+	tokens := make([]int, 1100)
+	for i := range tokens {
+		tokens[i] = i
+	}
+	decoder := &recordingDecoder{}
+
+	passes, err := decodePrompt(decoder, tokens, 512)
+	require.NoError(t, err)
+	assert.Equal(t, 3, passes)
+
+	// Every token is decoded exactly once, at its own position
+	var decoded []int
+	for i, pass := range decoder.passes {
+		for j, token := range pass.tokens {
+			assert.Equal(t, pass.pos+j, token)
+		}
+		decoded = append(decoded, pass.tokens...)
+		assert.Equal(t, i == len(decoder.passes)-1, pass.wantLogits)
+	}
+	assert.Equal(t, tokens, decoded)
+	assert.Len(t, decoder.passes[2].tokens, 76)
+}
+
+func TestDecodePromptSinglePass(t *testing.T) {
+	decoder := &recordingDecoder{}
+	passes, err := decodePrompt(decoder, []int{1, 2, 3}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, passes)
+	assert.Equal(t, []decodePass{{tokens: []int{1, 2, 3}, pos: 0, wantLogits: true}}, decoder.passes)
+}
+
+func TestDecodePromptError(t *testing.T) {
+	decoder := &recordingDecoder{failOn: 2}
+	passes, err := decodePrompt(decoder, make([]int, 10), 4)
+	require.ErrorIs(t, err, ErrDecode)
+	assert.Equal(t, 1, passes)
+	assert.Contains(t, err.Error(), "position 4")
+}
@@ -0,0 +1,188 @@
+package llm
+
+import "strings"
+
+// DocumentInfo summarizes an indexed document without its content or embedding
+type DocumentInfo struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	FilePath      string `json:"file_path"`
+	ChunkIndex    int    `json:"chunk_index"`
+	ContentLength int    `json:"content_length"`
+	Language      string `json:"language,omitempty"`
+}
+
+// DocumentFilter selects and pages the documents returned by ListDocuments
+type DocumentFilter struct {
+	// ID only matches the document with this ID
+	ID string
+	// SourceFile only matches documents ingested from this file path
+	SourceFile string
+	// Search only matches documents whose title or content contains this text (case-insensitive)
+	Search string
+	Offset int
+	// Limit caps the number of documents returned (0 means no limit)
+	Limit int
+}
+
+// matches reports whether a document passes the source file and search filters
+func (f DocumentFilter) matches(id, title, content, filePath string) bool {
+	if f.ID != "" && id != f.ID {
+		return false
+	}
+	if f.SourceFile != "" && filePath != f.SourceFile {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(title), search) && !strings.Contains(strings.ToLower(content), search) {
+			return false
+		}
+	}
+	return true
+}
+
+// page applies the offset and limit to the matching documents
+func (f DocumentFilter) page(docs []DocumentInfo) []DocumentInfo {
+	if f.Offset >= len(docs) {
+		return []DocumentInfo{}
+	}
+	if f.Offset > 0 {
+		docs = docs[f.Offset:]
+	}
+	if f.Limit > 0 && f.Limit < len(docs) {
+		docs = docs[:f.Limit]
+	}
+	return docs
+}
+
+// GetDocument returns the full document with the given ID. If the ID was added more than
+// once, the latest copy is returned.
+func (vs *VectorStore) GetDocument(id string) (Document, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	for i := len(vs.documents) - 1; i >= 0; i-- {
+		if vs.documents[i].ID == id {
+			return vs.documents[i], true
+		}
+	}
+	return Document{}, false
+}
+
+// EachDocument calls fn with every stored document in index order, stopping at the first error
+func (vs *VectorStore) EachDocument(fn func(Document) error) error {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	for _, doc := range vs.documents {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDocuments returns the stored documents that match the filter, in index order
+func (vs *VectorStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	docs := make([]DocumentInfo, 0)
+	for _, doc := range vs.documents {
+		if !filter.matches(doc.ID, doc.Title, doc.Content, doc.FilePath) {
+			continue
+		}
+		docs = append(docs, DocumentInfo{
+			ID:            doc.ID,
+			Title:         doc.Title,
+			FilePath:      doc.FilePath,
+			ChunkIndex:    doc.ChunkIndex,
+			ContentLength: len(doc.Content),
+			Language:      doc.Language,
+		})
+	}
+	return filter.page(docs)
+}
+
+// ListDocuments returns the stored documents that match the filter, in index order.
+// Simple documents are never chunked, so ChunkIndex is always 0.
+func (s *SimpleRAGStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	docs := make([]DocumentInfo, 0)
+	for _, doc := range s.documents {
+		if !filter.matches(doc.ID, doc.Title, doc.Content, doc.FilePath) {
+			continue
+		}
+		docs = append(docs, DocumentInfo{
+			ID:            doc.ID,
+			Title:         doc.Title,
+			FilePath:      doc.FilePath,
+			ContentLength: len(doc.Content),
+			Language:      doc.Language,
+		})
+	}
+	return filter.page(docs)
+}
+
+// RemoveDocuments deletes the documents matching the filter's ID and source file and returns how many were removed.
+// Search and paging are ignored, and a filter without an ID or source file removes nothing.
+func (vs *VectorStore) RemoveDocuments(filter DocumentFilter) int {
+	if filter.ID == "" && filter.SourceFile == "" {
+		return 0
+	}
+	filter.Search = ""
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	kept := make([]Document, 0, len(vs.documents))
+	for _, doc := range vs.documents {
+		if !filter.matches(doc.ID, doc.Title, doc.Content, doc.FilePath) {
+			kept = append(kept, doc)
+		}
+	}
+
+	removed := len(vs.documents) - len(kept)
+	vs.documents = kept
+	// An empty store accepts embeddings of any dimension again
+	if len(vs.documents) == 0 {
+		vs.embeddingDim = 0
+	}
+	return removed
+}
+
+// RemoveDocuments deletes the documents matching the filter's ID and source file and returns how many were removed.
+// Search and paging are ignored, and a filter without an ID or source file removes nothing.
+func (s *SimpleRAGStore) RemoveDocuments(filter DocumentFilter) int {
+	if filter.ID == "" && filter.SourceFile == "" {
+		return 0
+	}
+	filter.Search = ""
+
+	kept := make([]SimpleDocument, 0, len(s.documents))
+	for _, doc := range s.documents {
+		if !filter.matches(doc.ID, doc.Title, doc.Content, doc.FilePath) {
+			kept = append(kept, doc)
+		}
+	}
+
+	removed := len(s.documents) - len(kept)
+	s.documents = kept
+	s.recomputeDocFreq()
+	return removed
+}
+
+// Clear removes every document and resets the embedding dimension so a new model can be used
+func (vs *VectorStore) Clear() {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.documents = make([]Document, 0)
+	vs.embeddingDim = 0
+}
+
+// Clear removes every document
+func (s *SimpleRAGStore) Clear() {
+	s.documents = make([]SimpleDocument, 0)
+	s.docFreq = make(map[string]int)
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newListingStore(t *testing.T) *VectorStore {
+	t.Helper()
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.AddDocument(Document{
+			ID:         fmt.Sprintf("kas_chunk_%d", i),
+			Title:      "Key Access Service",
+			Content:    fmt.Sprintf("KAS rewrap step %d", i),
+			FilePath:   "protocol/kas.md",
+			ChunkIndex: i,
+			Embedding:  []float32{1, 0},
+		}))
+	}
+	require.NoError(t, store.AddDocument(Document{
+		ID:        "policy_chunk_0",
+		Title:     "Policy",
+		Content:   "Attribute definitions and subject mappings",
+		FilePath:  "protocol/policy.md",
+		Embedding: []float32{0, 1},
+	}))
+	return store
+}
+
+func TestListDocumentsFilters(t *testing.T) {
+	store := newListingStore(t)
+
+	all := store.ListDocuments(DocumentFilter{})
+	require.Len(t, all, 4)
+	assert.Equal(t, DocumentInfo{ID: "kas_chunk_1", Title: "Key Access Service", FilePath: "protocol/kas.md", ChunkIndex: 1, ContentLength: 17}, all[1])
+
+	bySource := store.ListDocuments(DocumentFilter{SourceFile: "protocol/policy.md"})
+	require.Len(t, bySource, 1)
+	assert.Equal(t, "policy_chunk_0", bySource[0].ID)
+
+	bySearch := store.ListDocuments(DocumentFilter{Search: "SUBJECT MAPPINGS"})
+	require.Len(t, bySearch, 1)
+	assert.Equal(t, "policy_chunk_0", bySearch[0].ID)
+
+	byTitle := store.ListDocuments(DocumentFilter{Search: "key access"})
+	assert.Len(t, byTitle, 3)
+}
+
+func TestListDocumentsPaging(t *testing.T) {
+	store := newListingStore(t)
+
+	page := store.ListDocuments(DocumentFilter{Offset: 1, Limit: 2})
+	require.Len(t, page, 2)
+	assert.Equal(t, "kas_chunk_1", page[0].ID)
+	assert.Equal(t, "kas_chunk_2", page[1].ID)
+
+	last := store.ListDocuments(DocumentFilter{SourceFile: "protocol/kas.md", Offset: 2, Limit: 5})
+	require.Len(t, last, 1)
+	assert.Equal(t, "kas_chunk_2", last[0].ID)
+
+	assert.Empty(t, store.ListDocuments(DocumentFilter{Offset: 10}))
+}
+
+func TestSimpleListDocuments(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "simple.json"))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "a", Title: "Getting Started", Content: "install otdfctl", FilePath: "README.md"}))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "b", Title: "SDK", Content: "Go SDK usage", FilePath: "sdk/go.md"}))
+
+	docs := store.ListDocuments(DocumentFilter{Search: "sdk", Limit: 1})
+	require.Len(t, docs, 1)
+	assert.Equal(t, "b", docs[0].ID)
+	assert.Equal(t, len("Go SDK usage"), docs[0].ContentLength)
+}
+
+func TestRemoveDocuments(t *testing.T) {
+	store := newListingStore(t)
+
+	assert.Zero(t, store.RemoveDocuments(DocumentFilter{}))
+	assert.Equal(t, 1, store.RemoveDocuments(DocumentFilter{ID: "kas_chunk_1"}))
+	assert.Equal(t, 2, store.RemoveDocuments(DocumentFilter{SourceFile: "protocol/kas.md"}))
+	assert.Equal(t, 1, store.GetDocumentCount())
+	require.NoError(t, store.SaveIndex())
+
+	reloaded := NewVectorStore(store.indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	docs := reloaded.ListDocuments(DocumentFilter{})
+	require.Len(t, docs, 1)
+	assert.Equal(t, "policy_chunk_0", docs[0].ID)
+}
+
+func TestSimpleRemoveDocuments(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "simple.json")
+	store := NewSimpleRAGStore(indexPath)
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "a", FilePath: "README.md"}))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "b", FilePath: "sdk/go.md"}))
+
+	assert.Equal(t, 1, store.RemoveDocuments(DocumentFilter{ID: "a"}))
+	require.NoError(t, store.SaveIndex())
+
+	reloaded := NewSimpleRAGStore(indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	assert.Equal(t, 1, reloaded.GetDocumentCount())
+	assert.Equal(t, "b", reloaded.ListDocuments(DocumentFilter{})[0].ID)
+}
+
+func TestClear(t *testing.T) {
+	store := newListingStore(t)
+	store.Clear()
+	assert.Zero(t, store.GetDocumentCount())
+
+	// A cleared store accepts embeddings from a different model
+	require.NoError(t, store.AddDocument(Document{ID: "new", Embedding: []float32{1, 0, 0, 0}}))
+	require.NoError(t, store.SaveIndex())
+
+	simple := NewSimpleRAGStore(filepath.Join(t.TempDir(), "simple.json"))
+	require.NoError(t, simple.AddDocument(SimpleDocument{ID: "a"}))
+	simple.Clear()
+	assert.Zero(t, simple.GetDocumentCount())
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BatchEmbedder embeds several texts at once. *EmbeddingEngine satisfies it.
+type BatchEmbedder interface {
+	Embedder
+	GenerateEmbeddings(texts []string) ([][]float32, error)
+}
+
+// EmbedRequest is the body of POST /embed
+type EmbedRequest struct {
+	Input []string `json:"input"`
+}
+
+// EmbedResponse is returned by POST /embed, with one embedding per input text in the same order
+type EmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Dimension  int         `json:"dimension"`
+}
+
+// EmbedServer exposes an embedding model over HTTP, so repeated ingestion runs can share one
+// resident model instead of each loading their own
+type EmbedServer struct {
+	embedder BatchEmbedder
+	logger   Logger
+	mux      *http.ServeMux
+}
+
+// NewEmbedServer creates an HTTP server that answers embedding requests with the given embedder
+func NewEmbedServer(embedder BatchEmbedder) *EmbedServer {
+	s := &EmbedServer{
+		embedder: embedder,
+		logger:   NopLogger(),
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /embed", s.handleEmbed)
+	return s
+}
+
+// SetLogger routes the server's diagnostic messages to the given logger
+func (s *EmbedServer) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+// ServeHTTP implements http.Handler
+func (s *EmbedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleEmbed embeds each of the posted texts
+func (s *EmbedServer) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Input) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "input must contain at least one text")
+		return
+	}
+
+	start := time.Now()
+	embeddings, err := s.embedder.GenerateEmbeddings(req.Input)
+	if err != nil {
+		s.logger.Printf("Embedding %d texts failed: %v", len(req.Input), err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Printf("Embedded %d texts in %v", len(req.Input), time.Since(start))
+
+	response := EmbedResponse{Embeddings: embeddings}
+	if len(embeddings) > 0 {
+		response.Dimension = len(embeddings[0])
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// EmbedClient is an Embedder backed by an EmbedServer, such as one started with `llm embed-serve`
+type EmbedClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewEmbedClient creates a client for the embedding server at endpoint, either its base URL
+// such as http://127.0.0.1:8182 or the full /embed URL
+func NewEmbedClient(endpoint string) *EmbedClient {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/embed") {
+		url += "/embed"
+	}
+	return &EmbedClient{url: url, client: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+// GenerateEmbedding asks the server for the embedding of text
+func (ec *EmbedClient) GenerateEmbedding(text string) ([]float32, error) {
+	embeddings, err := ec.GenerateEmbeddings([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings asks the server for the embeddings of texts, returned in the same order
+func (ec *EmbedClient) GenerateEmbeddings(texts []string) ([][]float32, error) {
+	body, err := json.Marshal(EmbedRequest{Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbedding, err)
+	}
+
+	resp, err := ec.client.Post(ec.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbedding, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return nil, fmt.Errorf("%w: %s: %s", ErrEmbedding, ec.url, apiErr.Error)
+	}
+
+	var result EmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: invalid response from %s: %w", ErrEmbedding, ec.url, err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%w: %s returned %d embeddings for %d texts", ErrEmbedding, ec.url, len(result.Embeddings), len(texts))
+	}
+	return result.Embeddings, nil
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lengthEmbedder returns a vector of dim values derived from each text's length
+type lengthEmbedder struct {
+	dim int
+	err error
+}
+
+func (e lengthEmbedder) GenerateEmbedding(text string) ([]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	embedding := make([]float32, e.dim)
+	embedding[0] = float32(len(text))
+	for i := 1; i < e.dim; i++ {
+		embedding[i] = 1
+	}
+	return embedding, nil
+}
+
+func (e lengthEmbedder) GenerateEmbeddings(texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := e.GenerateEmbedding(text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+func TestEmbedServerReturnsVectors(t *testing.T) {
+	server := httptest.NewServer(NewEmbedServer(lengthEmbedder{dim: 4}))
+	defer server.Close()
+
+	client := NewEmbedClient(server.URL)
+	embeddings, err := client.GenerateEmbeddings([]string{"a", "abc"})
+	require.NoError(t, err)
+	require.Len(t, embeddings, 2)
+	for _, embedding := range embeddings {
+		assert.Len(t, embedding, 4)
+	}
+	assert.Equal(t, float32(1), embeddings[0][0], "embeddings are returned in input order")
+	assert.Equal(t, float32(3), embeddings[1][0])
+
+	// The full endpoint URL works too
+	embedding, err := NewEmbedClient(server.URL + "/embed/").GenerateEmbedding("abcd")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{4, 1, 1, 1}, embedding)
+}
+
+func TestEmbedServerErrors(t *testing.T) {
+	server := httptest.NewServer(NewEmbedServer(lengthEmbedder{dim: 4, err: errors.New("model unloaded")}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/embed", "application/json", strings.NewReader(`{"input": []}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, err = NewEmbedClient(server.URL).GenerateEmbedding("text")
+	require.ErrorIs(t, err, ErrEmbedding)
+	assert.Contains(t, err.Error(), "model unloaded")
+}
+
+func TestIngestWithEmbedClient(t *testing.T) {
+	server := httptest.NewServer(NewEmbedServer(lengthEmbedder{dim: 8}))
+	defer server.Close()
+
+	dir := writeDocs(t, 3)
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, NewEmbedClient(server.URL), t.TempDir())
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.Equal(t, 3, store.GetDocumentCount())
+	assert.Equal(t, 8, store.EmbeddingDim())
+}
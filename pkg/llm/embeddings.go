@@ -3,7 +3,7 @@ package llm
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -22,14 +22,17 @@ type Document struct {
 	URL         string    `json:"url"`
 	FilePath    string    `json:"file_path"`
 	Embedding   []float32 `json:"embedding"`
+	// TitleEmbedding is the embedding of the source document's title, used for title boosting
+	TitleEmbedding []float32 `json:"title_embedding,omitempty"`
 	ChunkIndex  int       `json:"chunk_index"`
 	TotalChunks int       `json:"total_chunks"`
-}
-
-// DocumentChunk represents a smaller piece of a document for better retrieval
-type DocumentChunk struct {
-	Document
-	ParentID string `json:"parent_id"`
+	// Language is the ISO 639-1 code detected for the source document, if any
+	Language string `json:"language,omitempty"`
+	// ParentID is the ID of the document the chunk was cut from. Indexes built before it was
+	// recorded leave it empty.
+	ParentID string `json:"parent_id,omitempty"`
+	// Boost multiplies the document's retrieval score; 0 leaves it as it is. See SourceBoosts.
+	Boost float32 `json:"boost,omitempty"`
 }
 
 // VectorStore manages document embeddings and similarity search
@@ -38,6 +41,8 @@ type VectorStore struct {
 	embeddingDim int
 	mu           sync.RWMutex
 	indexPath    string
+	titleBoost   float32
+	logger       Logger
 }
 
 // SimilarityResult represents a document with its similarity score
@@ -52,37 +57,62 @@ func NewVectorStore(indexPath string) *VectorStore {
 		documents:    make([]Document, 0),
 		embeddingDim: 0,
 		indexPath:    indexPath,
+		logger:       NopLogger(),
 	}
 }
 
+// SetLogger routes the store's diagnostic messages to the given logger
+func (vs *VectorStore) SetLogger(logger Logger) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.logger = logger
+}
+
+// SetTitleBoost sets how much title similarity contributes to a document's score.
+// A weight of 0 scores on the chunk body alone; 1 scores on the title alone.
+func (vs *VectorStore) SetTitleBoost(weight float32) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.titleBoost = weight
+}
+
 // LoadIndex loads the vector store from disk
 func (vs *VectorStore) LoadIndex() error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
 	if _, err := os.Stat(vs.indexPath); os.IsNotExist(err) {
-		log.Printf("Vector index not found at %s, will create new one", vs.indexPath)
+		vs.logger.Printf("Vector index not found at %s, will create new one", vs.indexPath)
 		return nil
 	}
 
 	data, err := os.ReadFile(vs.indexPath)
 	if err != nil {
-		return fmt.Errorf("failed to read index file: %v", err)
+		return fmt.Errorf("%w: failed to read index file: %w", ErrIndexLoad, err)
 	}
 
 	var indexData struct {
+		Version      int        `json:"version"`
 		Documents    []Document `json:"documents"`
 		EmbeddingDim int        `json:"embedding_dim"`
 	}
 
 	if err := json.Unmarshal(data, &indexData); err != nil {
-		return fmt.Errorf("failed to unmarshal index: %v", err)
+		if err := checkIndexVersion(vs.indexPath, peekIndexVersion(data)); err != nil {
+			return fmt.Errorf("%w: %w", ErrIndexLoad, err)
+		}
+		return fmt.Errorf("%w: failed to unmarshal index: %w", ErrIndexLoad, err)
+	}
+	if err := checkIndexVersion(vs.indexPath, indexData.Version); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexLoad, err)
 	}
 
 	vs.documents = indexData.Documents
 	vs.embeddingDim = indexData.EmbeddingDim
 	
-	log.Printf("Loaded %d documents from vector index", len(vs.documents))
+	vs.logger.Printf("Loaded %d documents from vector index", len(vs.documents))
 	return nil
 }
 
@@ -92,42 +122,68 @@ func (vs *VectorStore) SaveIndex() error {
 	defer vs.mu.RUnlock()
 
 	indexData := struct {
+		Version      int        `json:"version"`
 		Documents    []Document `json:"documents"`
 		EmbeddingDim int        `json:"embedding_dim"`
 	}{
-		Documents:    vs.documents,
+		Version:      IndexFormatVersion,
+		Documents:    sortDocumentsForSave(vs.documents),
 		EmbeddingDim: vs.embeddingDim,
 	}
 
-	data, err := json.MarshalIndent(indexData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal index: %v", err)
-	}
-
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(vs.indexPath), 0755); err != nil {
-		return fmt.Errorf("failed to create index directory: %v", err)
+		return fmt.Errorf("%w: failed to create index directory: %w", ErrIndexSave, err)
 	}
 
-	if err := os.WriteFile(vs.indexPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write index file: %v", err)
+	// Write to a temporary file and rename it into place so an interrupted save can't corrupt the index
+	err := writeFileAtomic(vs.indexPath, 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(indexData)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to write index file: %w", ErrIndexSave, err)
 	}
 
-	log.Printf("Saved vector index with %d documents", len(vs.documents))
+	vs.logger.Printf("Saved vector index with %d documents", len(vs.documents))
 	return nil
 }
 
+// sortDocumentsForSave returns a copy of docs in the order the index file lists them: by
+// source file, then chunk, then ID. The order documents are added in depends on how ingestion
+// was scheduled, so sorting keeps the file byte for byte the same when the same corpus is
+// ingested again.
+func sortDocumentsForSave(docs []Document) []Document {
+	sorted := append([]Document(nil), docs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		if a.ChunkIndex != b.ChunkIndex {
+			return a.ChunkIndex < b.ChunkIndex
+		}
+		return a.ID < b.ID
+	})
+	return sorted
+}
+
 // AddDocument adds a document with its embedding to the store
 func (vs *VectorStore) AddDocument(doc Document) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
+	if err := checkDocumentEmbeddings(doc); err != nil {
+		return fmt.Errorf("document %s: %w", doc.ID, err)
+	}
+
 	if vs.embeddingDim == 0 && len(doc.Embedding) > 0 {
 		vs.embeddingDim = len(doc.Embedding)
 	}
 
 	if len(doc.Embedding) != vs.embeddingDim && vs.embeddingDim > 0 {
-		return fmt.Errorf("embedding dimension mismatch: expected %d, got %d", vs.embeddingDim, len(doc.Embedding))
+		return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, vs.embeddingDim, len(doc.Embedding))
 	}
 
 	vs.documents = append(vs.documents, doc)
@@ -140,7 +196,10 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SimilarityR
 	defer vs.mu.RUnlock()
 
 	if len(queryEmbedding) != vs.embeddingDim {
-		return nil, fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.embeddingDim, len(queryEmbedding))
+		return nil, fmt.Errorf("query %w: expected %d, got %d", ErrDimensionMismatch, vs.embeddingDim, len(queryEmbedding))
+	}
+	if err := checkEmbeddingValues(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("query %w", err)
 	}
 
 	if topK > len(vs.documents) {
@@ -150,10 +209,13 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SimilarityR
 	results := make([]SimilarityResult, 0, len(vs.documents))
 
 	for _, doc := range vs.documents {
-		similarity := cosineSimilarity(queryEmbedding, doc.Embedding)
+		// Documents from an index written before embeddings were checked could poison the sort
+		if checkDocumentEmbeddings(doc) != nil {
+			continue
+		}
 		results = append(results, SimilarityResult{
 			Document:   doc,
-			Similarity: similarity,
+			Similarity: vs.explainSimilarity(queryEmbedding, doc).Similarity,
 		})
 	}
 
@@ -176,15 +238,75 @@ func (vs *VectorStore) GetDocumentCount() int {
 	return len(vs.documents)
 }
 
-// EmbeddingEngine handles text embeddings using Ollama models
+// Close satisfies Store; the JSON store holds no open resources
+func (vs *VectorStore) Close() error {
+	return nil
+}
+
+// EmbeddingDim returns the size of the embeddings in the store, or 0 if it has none
+func (vs *VectorStore) EmbeddingDim() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.embeddingDim
+}
+
+// Embedder turns text into an embedding vector
+type Embedder interface {
+	GenerateEmbedding(text string) ([]float32, error)
+}
+
+const (
+	// embeddingBatchTokens caps how many tokens are decoded in one embedding batch
+	embeddingBatchTokens = 512
+	// embeddingBatchSequences caps how many texts share one embedding batch
+	embeddingBatchSequences = 8
+)
+
+// EmbeddingEngine handles text embeddings using Ollama models.
+// It owns a pool of llama contexts that share one loaded model, so several
+// embeddings can be computed concurrently.
 type EmbeddingEngine struct {
-	model   *llama.Model
-	context *llama.Context
-	mu      sync.Mutex
+	model    *llama.Model
+	contexts chan *llama.Context
+	size     int
+	logger   Logger
 }
 
-// NewEmbeddingEngine creates a new embedding engine
+// EmbeddingOptions configures the contexts of an embedding engine
+type EmbeddingOptions struct {
+	// Contexts is how many contexts share the loaded model, and so how many requests are
+	// embedded at once. 0 means 1.
+	Contexts int
+	// Threads each context runs on. 0 uses DefaultThreads.
+	Threads int
+	// FlashAttention enables flash attention where the build supports it
+	FlashAttention bool
+	// KVCacheType is one of KVCacheTypes, or "" for the default. Quantized types need
+	// FlashAttention.
+	KVCacheType string
+}
+
+// NewEmbeddingEngine creates a new embedding engine with a single context and default settings
 func NewEmbeddingEngine(modelPath string) (*EmbeddingEngine, error) {
+	return NewEmbeddingEnginePool(modelPath, EmbeddingOptions{})
+}
+
+// NewEmbeddingEnginePool creates an embedding engine with opts.Contexts contexts sharing one
+// loaded model
+func NewEmbeddingEnginePool(modelPath string, opts EmbeddingOptions) (*EmbeddingEngine, error) {
+	n := max(opts.Contexts, 1)
+	threads := opts.Threads
+	if threads < 1 {
+		threads = DefaultThreads()
+	}
+	if err := CheckKVCacheType(opts.KVCacheType, opts.FlashAttention); err != nil {
+		return nil, err
+	}
+
+	if err := checkModelFile(modelPath); err != nil {
+		return nil, err
+	}
+
 	// Initialize llama backend
 	llama.BackendInit()
 
@@ -198,31 +320,50 @@ func NewEmbeddingEngine(modelPath string) (*EmbeddingEngine, error) {
 	// Load model
 	model, err := llama.LoadModelFromFile(modelPath, modelParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load embedding model: %v", err)
+		return nil, fmt.Errorf("%w: embedding model: %w", ErrModelLoad, err)
 	}
 
-	// Create context for embeddings
-	contextParams := llama.NewContextParams(
-		512,  // numCtx - smaller for embeddings
-		1,    // batchSize
-		1,    // numSeqMax
-		4,    // threads
-		false, // flashAttention
-		"",   // kvCacheType
-	)
-
-	context, err := llama.NewContextWithModel(model, contextParams)
-	if err != nil {
-		llama.FreeModel(model)
-		return nil, fmt.Errorf("failed to create embedding context: %v", err)
+	contexts := make(chan *llama.Context, n)
+	for i := 0; i < n; i++ {
+		context, err := llama.NewContextWithModel(model, embeddingContextParams(threads, opts.FlashAttention, opts.KVCacheType))
+		if err != nil && opts.FlashAttention {
+			// Builds without flash attention support can't create the context with it, nor
+			// quantize its KV cache
+			context, err = llama.NewContextWithModel(model, embeddingContextParams(threads, false, ""))
+		}
+		if err != nil {
+			llama.FreeModel(model)
+			return nil, fmt.Errorf("%w: failed to create embedding context: %w", ErrModelLoad, err)
+		}
+		contexts <- context
 	}
 
 	return &EmbeddingEngine{
-		model:   model,
-		context: context,
+		model:    model,
+		contexts: contexts,
+		size:     n,
+		logger:   NopLogger(),
 	}, nil
 }
 
+// SetLogger routes the engine's diagnostic messages to the given logger. Set it before
+// embedding anything.
+func (ee *EmbeddingEngine) SetLogger(logger Logger) {
+	ee.logger = logger
+}
+
+// embeddingContextParams configures a context for embedding batches of texts
+func embeddingContextParams(threads int, flashAttention bool, kvCacheType string) llama.ContextParams {
+	return newContextParams(
+		embeddingBatchTokens,    // numCtx - smaller for embeddings
+		embeddingBatchTokens,    // batchSize
+		embeddingBatchSequences, // numSeqMax - one sequence per text in a batch
+		threads,                 // threads
+		flashAttention,          // flashAttention
+		kvCacheType,             // kvCacheType
+	)
+}
+
 // Close cleans up the embedding engine resources
 func (ee *EmbeddingEngine) Close() {
 	if ee.model != nil {
@@ -230,62 +371,242 @@ func (ee *EmbeddingEngine) Close() {
 	}
 }
 
-// GenerateEmbedding creates an embedding vector for the given text
+// GenerateEmbedding creates an embedding vector for the given text.
+// It is safe to call concurrently; calls block until a context is free.
 func (ee *EmbeddingEngine) GenerateEmbedding(text string) ([]float32, error) {
-	ee.mu.Lock()
-	defer ee.mu.Unlock()
-
-	// Tokenize the text
-	tokens, err := ee.model.Tokenize(text, true, true)
+	embeddings, err := ee.generateBatch([]string{text})
 	if err != nil {
-		return nil, fmt.Errorf("tokenization failed: %v", err)
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings creates embeddings for several texts, batching them into
+// multi-sequence decodes spread across the context pool.
+// The returned vectors are in the same order as texts.
+func (ee *EmbeddingEngine) GenerateEmbeddings(texts []string) ([][]float32, error) {
+	return embedConcurrently(texts, ee.size, embeddingBatchSequences, ee.generateBatch)
+}
+
+// generateBatch embeds texts in a single decode on the next free context
+func (ee *EmbeddingEngine) generateBatch(texts []string) ([][]float32, error) {
+	context := <-ee.contexts
+	defer func() { ee.contexts <- context }()
+
+	return ee.embedBatch(context, texts)
+}
+
+// embedBatch embeds several texts in one decode, giving each text its own sequence id
+func (ee *EmbeddingEngine) embedBatch(context *llama.Context, texts []string) ([][]float32, error) {
+	tokenized := make([][]int, len(texts))
+	totalTokens := 0
+	for i, text := range texts {
+		tokens, err := tokenize(ee.model, text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrTokenization, err)
+		}
+		tokenized[i] = tokens
+		totalTokens += len(tokens)
+	}
+
+	// Split batches that don't fit in one decode
+	if len(texts) > 1 && totalTokens > embeddingBatchTokens {
+		mid := len(texts) / 2
+		first, err := ee.embedBatch(context, texts[:mid])
+		if err != nil {
+			return nil, err
+		}
+		second, err := ee.embedBatch(context, texts[mid:])
+		if err != nil {
+			return nil, err
+		}
+		return append(first, second...), nil
+	}
+
+	plan, truncated := planEmbeddingBatch(tokenized)
+	for _, i := range truncated {
+		ee.logger.Printf("Warning: embedding only the first %d of %d tokens of a text longer than the batch", embeddingBatchTokens, len(tokenized[i]))
 	}
 
 	// Create batch for embedding
-	batch, err := llama.NewBatch(len(tokens), 1, 0)
+	batch, err := llama.NewBatch(len(plan), len(texts), 0)
 	if err != nil {
-		return nil, fmt.Errorf("batch creation failed: %v", err)
+		return nil, fmt.Errorf("%w: %w", ErrBatchCreation, err)
 	}
 	defer batch.Free()
 
-	// Add tokens to batch
-	for i, token := range tokens {
-		batch.Add(token, nil, i, false, 0) // No logits needed for embeddings
+	for _, bt := range plan {
+		batch.Add(bt.token, nil, bt.pos, bt.logits, bt.seqID)
 	}
 
+	// Contexts are reused across batches, so drop the previous batch's cache first
+	context.KvCacheClear()
+
 	// Process the batch
-	err = ee.context.Decode(batch)
+	err = context.Decode(batch)
 	if err != nil {
-		return nil, fmt.Errorf("context decode failed: %v", err)
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
 	}
 
-	// Get embeddings from the last sequence
-	embeddings := ee.context.GetEmbeddingsSeq(0)
-	if embeddings == nil {
-		return nil, fmt.Errorf("failed to get embeddings")
+	// Read each text's pooled embedding from its own sequence
+	embeddings := make([][]float32, len(texts))
+	for seqID := range texts {
+		embeddings[seqID] = context.GetEmbeddingsSeq(seqID)
+		if embeddings[seqID] == nil {
+			return nil, fmt.Errorf("%w: no embeddings returned for sequence %d", ErrEmbedding, seqID)
+		}
 	}
 
 	return embeddings, nil
 }
 
+// batchToken is one token's placement in a multi-sequence embedding batch
+type batchToken struct {
+	token  int
+	pos    int
+	seqID  int
+	logits bool
+}
+
+// planEmbeddingBatch lays out tokenized texts in one batch. Each text gets its own
+// sequence id with positions starting at 0, and only its final token requests output
+// so the pooled embedding is produced once per sequence. A text longer than the batch
+// can't be decoded whole, so it is cut to its first embeddingBatchTokens tokens and its
+// index returned in truncated.
+func planEmbeddingBatch(tokenized [][]int) (plan []batchToken, truncated []int) {
+	plan = make([]batchToken, 0)
+	for seqID, tokens := range tokenized {
+		if len(tokens) > embeddingBatchTokens {
+			tokens = tokens[:embeddingBatchTokens]
+			truncated = append(truncated, seqID)
+		}
+		for pos, token := range tokens {
+			plan = append(plan, batchToken{
+				token:  token,
+				pos:    pos,
+				seqID:  seqID,
+				logits: pos == len(tokens)-1,
+			})
+		}
+	}
+	return plan, truncated
+}
+
+// embedConcurrently groups texts into batches of up to batchSize and embeds the
+// batches with up to workers goroutines, preserving input order
+func embedConcurrently(texts []string, workers int, batchSize int, embed func([]string) ([][]float32, error)) ([][]float32, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	type job struct {
+		start int
+		end   int
+	}
+
+	results := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+	jobs := make(chan job)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				vectors, err := embed(texts[j.start:j.end])
+				if err == nil && len(vectors) != j.end-j.start {
+					err = fmt.Errorf("%w: expected %d embeddings, got %d", ErrEmbedding, j.end-j.start, len(vectors))
+				}
+				if err != nil {
+					errs[j.start] = err
+					continue
+				}
+				copy(results[j.start:j.end], vectors)
+			}
+		}()
+	}
+
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		jobs <- job{start: start, end: end}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("text %d: %w", i, err)
+		}
+	}
+
+	return results, nil
+}
+
+// CompareTexts embeds two texts and returns their cosine similarity
+func CompareTexts(embedder Embedder, a, b string) (float32, error) {
+	embeddingA, err := embedder.GenerateEmbedding(a)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed first text: %w", err)
+	}
+
+	embeddingB, err := embedder.GenerateEmbedding(b)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed second text: %w", err)
+	}
+
+	if len(embeddingA) != len(embeddingB) {
+		return 0, fmt.Errorf("%w: %d vs %d", ErrDimensionMismatch, len(embeddingA), len(embeddingB))
+	}
+
+	return cosineSimilarity(embeddingA, embeddingB), nil
+}
+
+// checkEmbeddingValues rejects an empty embedding or one holding a NaN or infinite value, which
+// would turn every similarity computed from it into NaN and scramble the ranking
+func checkEmbeddingValues(embedding []float32) error {
+	if len(embedding) == 0 {
+		return fmt.Errorf("%w: embedding is empty", ErrInvalidEmbedding)
+	}
+	for i, v := range embedding {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("%w: NaN or infinite value at index %d", ErrInvalidEmbedding, i)
+		}
+	}
+	return nil
+}
+
+// checkDocumentEmbeddings checks a document's embedding and, if it has one, its title embedding
+func checkDocumentEmbeddings(doc Document) error {
+	if err := checkEmbeddingValues(doc.Embedding); err != nil {
+		return err
+	}
+	if len(doc.TitleEmbedding) > 0 {
+		if err := checkEmbeddingValues(doc.TitleEmbedding); err != nil {
+			return fmt.Errorf("title %w", err)
+		}
+	}
+	return nil
+}
+
 // cosineSimilarity calculates the cosine similarity between two vectors
 func cosineSimilarity(a, b []float32) float32 {
 	if len(a) != len(b) {
 		return 0.0
 	}
 
-	var dotProduct, normA, normB float32
-	for i := range a {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
+	dotProduct, normA, normB := vectorProducts(a, b)
 	if normA == 0.0 || normB == 0.0 {
 		return 0.0
 	}
 
-	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
+	return dotProduct / (normA * normB)
 }
 
 // ChunkText splits text into overlapping chunks for better retrieval
@@ -323,28 +644,139 @@ type RAGContext struct {
 	Results      []SimilarityResult `json:"results"`
 	ContextText  string             `json:"context_text"`
 	NumDocuments int                `json:"num_documents"`
+	// TopScore is the best search score, including results too weak to make it into the context
+	TopScore float32 `json:"top_score"`
+}
+
+// DefaultNoContextNote tells the model that retrieval found nothing, so it doesn't present a guess as documented fact
+const DefaultNoContextNote = "No relevant documentation was found; answer from general knowledge and say so."
+
+// RAGContextOptions tunes which search results make it into the RAG context
+type RAGContextOptions struct {
+	// MaxPerSource caps how many chunks from the same source file are included (0 means no cap)
+	MaxPerSource int
+	// MaxResults caps how many chunks are included however many fit the token budget, for
+	// predictable prompts (0 means only the budget limits them)
+	MaxResults int
+	// NoContextNote is added to the system prompt when no document passes the similarity threshold
+	// (empty adds nothing)
+	NoContextNote string
+	// MinConfidence prefixes answers with LowConfidenceWarning when the top retrieval score is
+	// below it (0 never warns). Vector scores are cosine similarities; keyword scores are unbounded.
+	MinConfidence float32
+	// ExpandNeighbors adds up to this many adjacent chunks on each side of a retrieved chunk, from
+	// the same document, to give the model the surrounding section (0 adds none). Vector RAG only.
+	ExpandNeighbors int
+	// QueryTurns searches with the last this many user messages joined together, oldest first, so
+	// a follow-up such as "and what about decryption?" keeps the topic of the turns before it
+	// (0 and 1 search with the latest message only)
+	QueryTurns int
+	// BareContext leaves out the heading and each document's title, source and relevance, so
+	// the context holds only the documents' text, for models the metadata distracts
+	BareContext bool
+}
+
+// DefaultMinConfidence is the top retrieval score below which answers are flagged as weakly grounded
+const DefaultMinConfidence = 0.5
+
+// LowConfidenceWarning prefixes answers whose retrieved documentation only weakly matches the question
+const LowConfidenceWarning = "⚠️  Low confidence: no documentation closely matched this question, so this answer may not be grounded in the OpenTDF docs.\n\n"
+
+// sourceCounter enforces RAGContextOptions.MaxPerSource while results are added
+type sourceCounter struct {
+	max    int
+	counts map[string]int
+}
+
+func newSourceCounter(max int) *sourceCounter {
+	return &sourceCounter{max: max, counts: make(map[string]int)}
+}
+
+// allow reports whether another chunk from the source fits under the cap and counts it if so.
+// Chunks without a file path are keyed by their ID so they never share a source.
+func (sc *sourceCounter) allow(filePath, id string) bool {
+	if sc.max <= 0 {
+		return true
+	}
+
+	key := filePath
+	if key == "" {
+		key = id
+	}
+	if sc.counts[key] >= sc.max {
+		return false
+	}
+	sc.counts[key]++
+	return true
+}
+
+// ragContextHeading starts a RAG context unless RAGContextOptions.BareContext is set
+const ragContextHeading = "# Relevant OpenTDF Documentation\n\n"
+
+// writeContextDocument adds a document to a RAG context, under its title, source and relevance
+// unless opts.BareContext is set
+func writeContextDocument(b *strings.Builder, doc Document, relevance float32, opts RAGContextOptions) {
+	if opts.BareContext {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(doc.Content)
+		return
+	}
+	fmt.Fprintf(b, "## %s\n", doc.Title)
+	fmt.Fprintf(b, "**Source:** %s\n", doc.URL)
+	fmt.Fprintf(b, "**Relevance:** %.3f\n\n", relevance)
+	b.WriteString(doc.Content)
+	b.WriteString("\n\n---\n\n")
+}
+
+// clampRelevance bounds a vector similarity to 0-1 for display. Similarities are already on
+// that scale, so they stay absolute, and a weak best match still reads as weak; only source
+// and title boosts can push one past 1.
+func clampRelevance(similarity float32) float32 {
+	return min(max(similarity, 0), 1)
 }
 
-// BuildRAGContext creates context from similarity search results
-func BuildRAGContext(query string, results []SimilarityResult, maxTokens int) RAGContext {
+// normalizeRelevance scales a keyword score to 0-1 relative to the best score among the
+// results. Keyword scores are unbounded, so they have no absolute scale to show: 1 is the best
+// match.
+func normalizeRelevance(score, best float32) float32 {
+	if best <= 0 || score <= 0 {
+		return 0
+	}
+	return min(score/best, 1)
+}
+
+// BuildRAGContext creates context from similarity search results. Each document's relevance is
+// its similarity, clamped to 0-1 once boosts are applied.
+func BuildRAGContext(query string, results []SimilarityResult, maxTokens int, opts RAGContextOptions) RAGContext {
 	var contextBuilder strings.Builder
-	contextBuilder.WriteString("# Relevant OpenTDF Documentation\n\n")
+	if !opts.BareContext {
+		contextBuilder.WriteString(ragContextHeading)
+	}
 	
 	tokenCount := 0
 	usedResults := make([]SimilarityResult, 0)
+	sources := newSourceCounter(opts.MaxPerSource)
 	
 	for _, result := range results {
+		if opts.MaxResults > 0 && len(usedResults) >= opts.MaxResults {
+			break
+		}
+		
+		// Skip chunks from sources that already filled their slots so other sources get one,
+		// before a skipped chunk's size can end the context early
+		if !sources.allow(result.Document.FilePath, result.Document.ID) {
+			continue
+		}
+		
 		// Estimate token count (rough approximation: 1 token ≈ 4 characters)
 		docTokens := len(result.Document.Content) / 4
 		if tokenCount + docTokens > maxTokens {
 			break
 		}
 		
-		contextBuilder.WriteString(fmt.Sprintf("## %s\n", result.Document.Title))
-		contextBuilder.WriteString(fmt.Sprintf("**Source:** %s\n", result.Document.URL))
-		contextBuilder.WriteString(fmt.Sprintf("**Relevance:** %.3f\n\n", result.Similarity))
-		contextBuilder.WriteString(result.Document.Content)
-		contextBuilder.WriteString("\n\n---\n\n")
+		writeContextDocument(&contextBuilder, result.Document, clampRelevance(result.Similarity), opts)
 		
 		tokenCount += docTokens
 		usedResults = append(usedResults, result)
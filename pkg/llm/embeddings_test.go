@@ -0,0 +1,298 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedConcurrently(t *testing.T) {
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff", "ggggggg", "hhhhhhhh"}
+
+	var inFlight, maxInFlight int32
+	embed := func(batch []string) ([][]float32, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		vectors := make([][]float32, len(batch))
+		for i, text := range batch {
+			vectors[i] = []float32{float32(len(text)), 1}
+		}
+		return vectors, nil
+	}
+
+	vectors, err := embedConcurrently(texts, 3, 2, embed)
+	require.NoError(t, err)
+	require.Len(t, vectors, len(texts))
+
+	// Every vector must belong to the text at the same index
+	for i, text := range texts {
+		assert.Equal(t, []float32{float32(len(text)), 1}, vectors[i])
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(3))
+	assert.Greater(t, maxInFlight, int32(1))
+}
+
+func TestEmbedConcurrentlyError(t *testing.T) {
+	failure := errors.New("decode failed")
+	_, err := embedConcurrently([]string{"ok", "bad"}, 2, 1, func(batch []string) ([][]float32, error) {
+		if batch[0] == "bad" {
+			return nil, failure
+		}
+		return [][]float32{{1}}, nil
+	})
+	require.ErrorIs(t, err, failure)
+
+	// A batch that returns the wrong number of vectors is an error, not a silent gap
+	_, err = embedConcurrently([]string{"a", "b"}, 1, 2, func([]string) ([][]float32, error) {
+		return [][]float32{{1}}, nil
+	})
+	require.ErrorIs(t, err, ErrEmbedding)
+}
+
+func TestPlanEmbeddingBatchUsesDistinctSequences(t *testing.T) {
+	plan, truncated := planEmbeddingBatch([][]int{{11, 12, 13}, {21, 22}})
+	require.Len(t, plan, 5)
+	assert.Empty(t, truncated)
+
+	// Regression: every text used to share sequence 0, so reading the
+	// embeddings back returned the same vector for each text
+	assert.Equal(t, []batchToken{
+		{token: 11, pos: 0, seqID: 0, logits: false},
+		{token: 12, pos: 1, seqID: 0, logits: false},
+		{token: 13, pos: 2, seqID: 0, logits: true},
+		{token: 21, pos: 0, seqID: 1, logits: false},
+		{token: 22, pos: 1, seqID: 1, logits: true},
+	}, plan)
+}
+
+func TestPlanEmbeddingBatchTruncatesOversizedText(t *testing.T) {
+	long := make([]int, embeddingBatchTokens+100)
+	for i := range long {
+		long[i] = i
+	}
+
+	// A single text longer than the batch would fail to decode
+	plan, truncated := planEmbeddingBatch([][]int{long})
+	require.Len(t, plan, embeddingBatchTokens)
+	assert.Equal(t, []int{0}, truncated)
+	last := plan[len(plan)-1]
+	assert.Equal(t, batchToken{token: embeddingBatchTokens - 1, pos: embeddingBatchTokens - 1, seqID: 0, logits: true}, last, "the kept tokens still end with an output")
+}
+
+// lookupEmbedder returns a fixed vector per text
+type lookupEmbedder map[string][]float32
+
+func (l lookupEmbedder) GenerateEmbedding(text string) ([]float32, error) {
+	return l[text], nil
+}
+
+func TestCompareTexts(t *testing.T) {
+	embedder := lookupEmbedder{
+		"policy":     {0.3, 0.9, 0.1},
+		"encryption": {1, 0, 0},
+		"attributes": {0, 1, 0},
+		"short":      {1, 0},
+	}
+
+	similarity, err := CompareTexts(embedder, "policy", "policy")
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, similarity, 0.0001)
+
+	similarity, err = CompareTexts(embedder, "encryption", "attributes")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.0, similarity, 0.0001)
+
+	_, err = CompareTexts(embedder, "encryption", "short")
+	require.ErrorIs(t, err, ErrDimensionMismatch)
+}
+
+func TestSearchTitleBoost(t *testing.T) {
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	query := []float32{1, 0, 0}
+
+	// Both bodies match the query equally; only the first title does
+	require.NoError(t, store.AddDocument(Document{
+		ID:             "body-only",
+		Embedding:      []float32{1, 1, 0},
+		TitleEmbedding: []float32{0, 0, 1},
+	}))
+	require.NoError(t, store.AddDocument(Document{
+		ID:             "title-match",
+		Embedding:      []float32{1, 1, 0},
+		TitleEmbedding: []float32{1, 0, 0},
+	}))
+
+	store.SetTitleBoost(0.3)
+	results, err := store.Search(query, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "title-match", results[0].Document.ID)
+	assert.Greater(t, results[0].Similarity, results[1].Similarity)
+
+	// Without a boost the scores are identical
+	store.SetTitleBoost(0)
+	results, err = store.Search(query, 2)
+	require.NoError(t, err)
+	assert.InDelta(t, results[0].Similarity, results[1].Similarity, 0.0001)
+}
+
+func TestExplainSimilarity(t *testing.T) {
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	doc := Document{ID: "doc", Embedding: []float32{3, 4, 0}, TitleEmbedding: []float32{1, 0, 0}}
+	require.NoError(t, store.AddDocument(doc))
+	query := []float32{2, 0, 0}
+
+	explanation := store.ExplainSimilarity(query, doc)
+	assert.InDelta(t, 6, explanation.DotProduct, 0.0001)
+	assert.InDelta(t, 2, explanation.QueryNorm, 0.0001)
+	assert.InDelta(t, 5, explanation.DocumentNorm, 0.0001)
+	assert.InDelta(t, 0.6, explanation.Cosine, 0.0001)
+	assert.Equal(t, explanation.Cosine, explanation.Similarity)
+	assert.Zero(t, explanation.TitleBoost)
+
+	store.SetTitleBoost(0.5)
+	explanation = store.ExplainSimilarity(query, doc)
+	assert.InDelta(t, 1, explanation.TitleCosine, 0.0001)
+	assert.InDelta(t, 0.8, explanation.Similarity, 0.0001)
+
+	// The explanation matches the score Search ranks by
+	results, err := store.Search(query, 1)
+	require.NoError(t, err)
+	assert.Equal(t, results[0].Similarity, explanation.Similarity)
+}
+
+func TestBuildRAGContextMaxResults(t *testing.T) {
+	var results []SimilarityResult
+	for i := 0; i < 5; i++ {
+		results = append(results, SimilarityResult{
+			Document:   Document{ID: fmt.Sprintf("chunk_%d", i), Content: "spec text", FilePath: fmt.Sprintf("spec/%d.md", i)},
+			Similarity: 0.9 - float32(i)/10,
+		})
+	}
+
+	assert.Equal(t, 5, BuildRAGContext("ztdf", results, 1000, RAGContextOptions{}).NumDocuments, "the budget fits every result")
+	ragContext := BuildRAGContext("ztdf", results, 1000, RAGContextOptions{MaxResults: 3})
+	require.Equal(t, 3, ragContext.NumDocuments)
+	assert.Equal(t, "chunk_2", ragContext.Results[2].Document.ID)
+	assert.NotContains(t, ragContext.ContextText, "chunk_3")
+
+	// Chunks skipped by the per-source cap don't count towards the limit
+	results = append([]SimilarityResult{{Document: Document{ID: "dup", Content: "spec text", FilePath: "spec/0.md"}, Similarity: 0.95}}, results...)
+	ragContext = BuildRAGContext("ztdf", results, 1000, RAGContextOptions{MaxResults: 2, MaxPerSource: 1})
+	require.Equal(t, 2, ragContext.NumDocuments)
+	assert.Equal(t, "dup", ragContext.Results[0].Document.ID)
+	assert.Equal(t, "chunk_1", ragContext.Results[1].Document.ID)
+}
+
+func TestBuildRAGContextMaxPerSource(t *testing.T) {
+	var results []SimilarityResult
+	for i := 0; i < 5; i++ {
+		results = append(results, SimilarityResult{
+			Document:   Document{ID: fmt.Sprintf("spec_chunk_%d", i), Content: "spec text", FilePath: "spec/ztdf.md"},
+			Similarity: 0.9,
+		})
+	}
+	results = append(results,
+		SimilarityResult{Document: Document{ID: "kas_chunk_0", Content: "kas text", FilePath: "protocol/kas.md"}, Similarity: 0.6},
+		SimilarityResult{Document: Document{ID: "sdk_chunk_0", Content: "sdk text", FilePath: "sdk/go.md"}, Similarity: 0.5},
+	)
+
+	ragContext := BuildRAGContext("ztdf", results, 1000, RAGContextOptions{MaxPerSource: 1})
+	sources := make(map[string]int)
+	for _, result := range ragContext.Results {
+		sources[result.Document.FilePath]++
+	}
+	assert.Equal(t, map[string]int{"spec/ztdf.md": 1, "protocol/kas.md": 1, "sdk/go.md": 1}, sources)
+}
+
+func TestBuildRAGContextSkipsCappedSourceBeforeBudget(t *testing.T) {
+	results := []SimilarityResult{
+		{Document: Document{ID: "spec_chunk_0", Content: "spec text", FilePath: "spec/ztdf.md"}, Similarity: 0.9},
+		// Over the budget, but the cap skips it before its size is counted
+		{Document: Document{ID: "spec_chunk_1", Content: strings.Repeat("spec text ", 100), FilePath: "spec/ztdf.md"}, Similarity: 0.8},
+		{Document: Document{ID: "kas_chunk_0", Content: "kas text", FilePath: "protocol/kas.md"}, Similarity: 0.6},
+	}
+
+	ragContext := BuildRAGContext("ztdf", results, 100, RAGContextOptions{MaxPerSource: 1})
+	require.Equal(t, 2, ragContext.NumDocuments)
+	assert.Equal(t, "kas_chunk_0", ragContext.Results[1].Document.ID)
+
+	simple := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		doc := result.Document
+		simple = append(simple, SearchResult{Document: SimpleDocument{ID: doc.ID, Content: doc.Content, FilePath: doc.FilePath}, Score: result.Similarity})
+	}
+	assert.Equal(t, 2, BuildSimpleRAGContext("ztdf", simple, 100, RAGContextOptions{MaxPerSource: 1}).NumDocuments)
+}
+
+func TestBuildRAGContextRelevance(t *testing.T) {
+	results := []SimilarityResult{
+		// A source boost can push a similarity past 1
+		{Document: Document{ID: "kas", Title: "KAS", URL: "https://opentdf.io/kas", Content: "The KAS rewraps keys."}, Similarity: 1.6},
+		{Document: Document{ID: "policy", Title: "Policy", URL: "https://opentdf.io/policy", Content: "Policy binds attributes."}, Similarity: 0.4},
+	}
+
+	ragContext := BuildRAGContext("kas", results, 1000, RAGContextOptions{})
+	assert.Contains(t, ragContext.ContextText, "## KAS\n**Source:** https://opentdf.io/kas\n**Relevance:** 1.000\n\nThe KAS rewraps keys.", "a boosted similarity is clamped to 1")
+	assert.Contains(t, ragContext.ContextText, "**Relevance:** 0.400\n", "a similarity is shown as it is, not relative to the best")
+	assert.InDelta(t, 1.6, ragContext.Results[0].Similarity, 0.0001, "only the displayed relevance is clamped")
+
+	// A weak best match still reads as weak
+	weak := BuildRAGContext("kas", []SimilarityResult{{Document: Document{ID: "kas", Title: "KAS", Content: "The KAS rewraps keys."}, Similarity: 0.3}}, 1000, RAGContextOptions{})
+	assert.Contains(t, weak.ContextText, "**Relevance:** 0.300\n")
+
+	bare := BuildRAGContext("kas", results, 1000, RAGContextOptions{BareContext: true})
+	assert.Equal(t, "The KAS rewraps keys.\n\nPolicy binds attributes.", bare.ContextText)
+	assert.Equal(t, 2, bare.NumDocuments)
+}
+
+func TestSaveIndexIsByteStable(t *testing.T) {
+	docs := writeDocs(t, 4)
+	ingest := func() ([]byte, *VectorStore) {
+		path := filepath.Join(t.TempDir(), "index.json")
+		store := NewVectorStore(path)
+		require.NoError(t, NewDocumentIngester(store, &stubEmbedder{}, t.TempDir()).IngestFromLocalDirectory(context.Background(), docs))
+		require.NoError(t, store.SaveIndex())
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return data, store
+	}
+
+	first, store := ingest()
+	second, _ := ingest()
+	assert.Equal(t, string(first), string(second), "ingesting the same corpus writes the same bytes")
+
+	// Documents added in another order, as parallel ingestion may, are saved the same way
+	var added []Document
+	require.NoError(t, store.EachDocument(func(doc Document) error {
+		added = append(added, doc)
+		return nil
+	}))
+	require.Len(t, added, 4)
+	path := filepath.Join(t.TempDir(), "reversed.json")
+	reversed := NewVectorStore(path)
+	for i := len(added) - 1; i >= 0; i-- {
+		require.NoError(t, reversed.AddDocument(added[i]))
+	}
+	require.NoError(t, reversed.SaveIndex())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(data))
+}
@@ -2,27 +2,42 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/ollama/ollama/llama"
 )
 
-// min returns the smaller of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// previewText shortens text to at most maxRunes runes for log previews. It never splits a
+// multi-byte rune and, when it can, backs up to the last word boundary.
+func previewText(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
 	}
-	return b
+
+	cut := runes[:maxRunes]
+	if !unicode.IsSpace(runes[maxRunes]) {
+		for i := len(cut) - 1; i > 0; i-- {
+			if unicode.IsSpace(cut[i]) {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace)
 }
 
 // ChatMessage represents a single message in the conversation
 type ChatMessage struct {
 	Role    string `json:"role"`    // "user", "assistant", "system"  
 	Content string `json:"content"`
+	Time    time.Time `json:"time,omitzero"` // When the message was sent, if recorded
+	NoRAG   bool `json:"-"` // Answer without retrieval when this is the latest user message
 }
 
 // ChatRequest represents a request to the chat engine
@@ -50,12 +65,19 @@ type ChatEngine struct {
 	cancel          context.CancelFunc
 	mu              sync.RWMutex
 	running         bool
+	// inference tracks the inference goroutine so Stop can wait for it before closing responseChan
+	inference       sync.WaitGroup
 	// RAG components
-	vectorStore     *VectorStore
+	vectorStore     Store
 	embeddingEngine *EmbeddingEngine
-	simpleRAGStore  *SimpleRAGStore
+	simpleRAGStore  KeywordStore
 	ragEnabled      bool
 	simpleRAGEnabled bool
+	sampling        SamplingOptions
+	modelParams     ModelParams
+	ragOptions      RAGContextOptions
+	contextSize     int
+	logger          Logger
 }
 
 // NewChatEngine creates a new chat engine instance
@@ -69,11 +91,64 @@ func NewChatEngine(modelPath string) *ChatEngine {
 		ctx:          ctx,
 		cancel:       cancel,
 		ragEnabled:   false,
+		sampling:     DefaultSamplingOptions(),
+		modelParams:  DefaultModelParams(),
+		logger:       NopLogger(),
 	}
 }
 
+// SetLogger routes the engine's diagnostic messages to the given logger
+func (ce *ChatEngine) SetLogger(logger Logger) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	ce.logger = logger
+}
+
+// SetSamplingOptions configures how tokens are sampled during generation
+func (ce *ChatEngine) SetSamplingOptions(opts SamplingOptions) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	ce.sampling = opts
+}
+
+// SetModelParams sets the context and sampling settings used by Start and each generation.
+// The model is always loaded on the CPU, so the model loading settings aren't used.
+func (ce *ChatEngine) SetModelParams(params ModelParams) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	ce.modelParams = params
+}
+
+// SetContextSize sets the context window created by Start.
+// 0 uses the context size the model was trained with.
+func (ce *ChatEngine) SetContextSize(n int) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	ce.contextSize = n
+}
+
+// ContextSize returns the context window in use, or the requested size before Start
+func (ce *ChatEngine) ContextSize() int {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	return ce.contextSize
+}
+
+// SetRAGOptions configures how retrieved documents are assembled into the RAG context
+func (ce *ChatEngine) SetRAGOptions(opts RAGContextOptions) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	
+	ce.ragOptions = opts
+}
+
 // EnableRAG enables Retrieval-Augmented Generation with the given vector store and embedding engine
-func (ce *ChatEngine) EnableRAG(vectorStore *VectorStore, embeddingEngine *EmbeddingEngine) {
+func (ce *ChatEngine) EnableRAG(vectorStore Store, embeddingEngine *EmbeddingEngine) {
 	ce.mu.Lock()
 	defer ce.mu.Unlock()
 	
@@ -81,18 +156,18 @@ func (ce *ChatEngine) EnableRAG(vectorStore *VectorStore, embeddingEngine *Embed
 	ce.embeddingEngine = embeddingEngine
 	ce.ragEnabled = true
 	
-	log.Printf("RAG enabled with %d documents in vector store", vectorStore.GetDocumentCount())
+	ce.logger.Printf("RAG enabled with %d documents in vector store", vectorStore.GetDocumentCount())
 }
 
 // EnableSimpleRAG enables simple keyword-based RAG with the given store
-func (ce *ChatEngine) EnableSimpleRAG(simpleStore *SimpleRAGStore) {
+func (ce *ChatEngine) EnableSimpleRAG(simpleStore KeywordStore) {
 	ce.mu.Lock()
 	defer ce.mu.Unlock()
 	
 	ce.simpleRAGStore = simpleStore
 	ce.simpleRAGEnabled = true
 	
-	log.Printf("Simple RAG enabled with %d documents", simpleStore.GetDocumentCount())
+	ce.logger.Printf("Simple RAG enabled with %d documents", simpleStore.GetDocumentCount())
 }
 
 // Start initializes and starts the chat engine with Ollama's llama bindings
@@ -101,16 +176,23 @@ func (ce *ChatEngine) Start() error {
 	defer ce.mu.Unlock()
 	
 	if ce.running {
-		return fmt.Errorf("chat engine is already running")
+		return ErrEngineAlreadyRunning
+	}
+	// A stopped engine has closed its response channel and can't be restarted
+	if ce.ctx.Err() != nil {
+		return ErrEngineShuttingDown
 	}
 	
 	// TODO: Complete Ollama llama.cpp integration
 	// Current implementation loads the model but uses simulated responses
 	// Need to implement proper sampling with SamplingContext for real inference
 	
-	log.Printf("Loading model from %s...", ce.modelPath)
+	// A model in the wrong format is a mistake to report, not a reason to fall back to simulation
+	if err := checkModelFile(ce.modelPath); errors.Is(err, ErrNotGGUF) {
+		return err
+	}
 	
-	// TODO: Verify model file exists and is accessible
+	ce.logger.Printf("Loading model from %s...", ce.modelPath)
 	
 	// Initialize llama backend
 	llama.BackendInit()
@@ -123,28 +205,32 @@ func (ce *ChatEngine) Start() error {
 	}
 	
 	// Load model
-	model, err := llama.LoadModelFromFile(ce.modelPath, modelParams)
+	var model *llama.Model
+	err := checkModelFile(ce.modelPath)
+	if err == nil {
+		model, err = llama.LoadModelFromFile(ce.modelPath, modelParams)
+	}
 	if err != nil {
 		// TODO: For POC, continue without actual model loading
-		log.Printf("Model loading failed (expected for POC): %v", err)
-		log.Printf("Continuing with simulated responses to demonstrate architecture...")
+		ce.logger.Printf("Model loading failed (expected for POC): %v", err)
+		ce.logger.Printf("Continuing with simulated responses to demonstrate architecture...")
 		ce.model = nil // Will use simulation
 	} else {
 		ce.model = model
 		
-		// Create context
-		contextParams := llama.NewContextParams(
-			4096, // numCtx
-			512,  // batchSize  
-			1,    // numSeqMax
-			4,    // threads
-			false, // flashAttention
-			"",   // kvCacheType
-		)
+		info, err := ReadModelInfo(ce.modelPath)
+		if err != nil {
+			ce.logger.Printf("Warning: could not read model metadata: %v", err)
+		} else {
+			ce.logger.Printf("Model %s trained with a %d token context (add BOS: %v, add EOS: %v)",
+				info.Architecture, info.ContextLength, info.AddBOS, info.AddEOS)
+		}
+		ce.contextSize = resolveContextSize(ce.contextSize, info, ce.logger)
 		
-		context, err := llama.NewContextWithModel(model, contextParams)
+		// Create context
+		context, err := ce.modelParams.newContext(model, ce.contextSize, defaultBatchSize, ce.logger)
 		if err != nil {
-			log.Printf("Context creation failed: %v", err)
+			ce.logger.Printf("Context creation failed: %v", err)
 			ce.context = nil
 		} else {
 			ce.context = context
@@ -153,10 +239,14 @@ func (ce *ChatEngine) Start() error {
 	
 	ce.running = true
 	
-	log.Printf("Chat engine initialized, starting inference goroutine...")
+	ce.logger.Printf("Chat engine initialized, starting inference goroutine...")
 	
 	// Start the inference goroutine
-	go ce.inferenceLoop()
+	ce.inference.Add(1)
+	go func() {
+		defer ce.inference.Done()
+		ce.inferenceLoop()
+	}()
 	
 	return nil
 }
@@ -173,13 +263,20 @@ func (ce *ChatEngine) Stop() {
 	ce.cancel()
 	ce.running = false
 	
+	// Every send on responseChan comes from the inference goroutine and gives up once the
+	// context is cancelled, so after it exits nothing can send on the closed channel or use the model
+	ce.inference.Wait()
+	
 	// Clean up resources
 	// Context uses finalizer, model needs explicit free
 	if ce.model != nil {
 		llama.FreeModel(ce.model)
+		ce.model = nil
 	}
+	ce.context = nil
 	
-	close(ce.requestChan)
+	// requestChan stays open: Chat may still be selecting on a send to it, and the
+	// cancelled context already tells those callers the engine is gone
 	close(ce.responseChan)
 }
 
@@ -187,9 +284,22 @@ func (ce *ChatEngine) Stop() {
 func (ce *ChatEngine) Chat(messages []ChatMessage, stream bool) <-chan ChatResponse {
 	responseChan := make(chan ChatResponse, 10)
 	
+	if IsBlank(ce.extractUserQuery(messages)) {
+		responseChan <- ChatResponse{Error: ErrEmptyQuery}
+		close(responseChan)
+		return responseChan
+	}
+	
 	go func() {
 		defer close(responseChan)
 		
+		if ce.ctx.Err() != nil {
+			responseChan <- ChatResponse{
+				Error: ErrEngineShuttingDown,
+			}
+			return
+		}
+		
 		select {
 		case ce.requestChan <- ChatRequest{
 			Messages: messages,
@@ -198,7 +308,7 @@ func (ce *ChatEngine) Chat(messages []ChatMessage, stream bool) <-chan ChatRespo
 			// Request sent successfully
 		case <-ce.ctx.Done():
 			responseChan <- ChatResponse{
-				Error: fmt.Errorf("chat engine is shutting down"),
+				Error: ErrEngineShuttingDown,
 			}
 			return
 		}
@@ -208,6 +318,9 @@ func (ce *ChatEngine) Chat(messages []ChatMessage, stream bool) <-chan ChatRespo
 			select {
 			case response, ok := <-ce.responseChan:
 				if !ok {
+					responseChan <- ChatResponse{
+						Error: ErrEngineShuttingDown,
+					}
 					return
 				}
 				responseChan <- response
@@ -216,7 +329,7 @@ func (ce *ChatEngine) Chat(messages []ChatMessage, stream bool) <-chan ChatRespo
 				}
 			case <-ce.ctx.Done():
 				responseChan <- ChatResponse{
-					Error: fmt.Errorf("chat engine is shutting down"),
+					Error: ErrEngineShuttingDown,
 				}
 				return
 			}
@@ -230,17 +343,13 @@ func (ce *ChatEngine) Chat(messages []ChatMessage, stream bool) <-chan ChatRespo
 func (ce *ChatEngine) inferenceLoop() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Chat engine panic recovered: %v", r)
+			ce.logger.Printf("Chat engine panic recovered: %v", r)
 		}
 	}()
 	
 	for {
 		select {
-		case request, ok := <-ce.requestChan:
-			if !ok {
-				return
-			}
-			
+		case request := <-ce.requestChan:
 			ce.processRequest(request)
 			
 		case <-ce.ctx.Done():
@@ -257,19 +366,19 @@ func (ce *ChatEngine) processRequest(request ChatRequest) {
 	// Build prompt from messages with optional RAG context
 	prompt, err := ce.buildPromptWithRAG(request.Messages, userQuery)
 	if err != nil {
-		log.Printf("Failed to build prompt with RAG: %v", err)
-		ce.sendErrorResponse(fmt.Errorf("failed to build prompt: %v", err))
+		ce.logger.Printf("Failed to build prompt with RAG: %v", err)
+		ce.sendErrorResponse(fmt.Errorf("failed to build prompt: %w", err))
 		return
 	}
 	
 	if ce.model != nil && ce.context != nil {
 		// Real inference with loaded model
-		log.Printf("Starting inference for prompt: %s...", prompt[:min(50, len(prompt))])
+		ce.logger.Printf("Starting inference for prompt: %s...", previewText(prompt, 50))
 		
 		response, err := ce.performInference(prompt, request.Options)
 		if err != nil {
-			log.Printf("Inference failed: %v", err)
-			ce.sendErrorResponse(fmt.Errorf("inference failed: %v", err))
+			ce.logger.Printf("Inference failed: %v", err)
+			ce.sendErrorResponse(fmt.Errorf("inference failed: %w", err))
 			return
 		}
 		
@@ -280,7 +389,7 @@ func (ce *ChatEngine) processRequest(request ChatRequest) {
 		}
 	} else {
 		// Fallback to simulation for missing model
-		log.Printf("Model not loaded, using simulation for: %s...", prompt[:min(50, len(prompt))])
+		ce.logger.Printf("Model not loaded, using simulation for: %s...", previewText(prompt, 50))
 		response := fmt.Sprintf("🤖 **Model Loading Failed - Using Simulation**\n\n"+
 			"📝 **Your input:** %s\n\n"+
 			"⚠️ **Status:** Model file could not be loaded. This could be due to:\n"+
@@ -288,7 +397,7 @@ func (ce *ChatEngine) processRequest(request ChatRequest) {
 			"- Unsupported model format\n"+
 			"- Insufficient memory\n\n"+
 			"💡 **Try:** Use a valid GGUF model file path", 
-			prompt[:min(100, len(prompt))])
+			previewText(prompt, 100))
 		
 		if request.Stream {
 			ce.simulateStreamingResponse(response)
@@ -316,8 +425,13 @@ func (ce *ChatEngine) simulateStreamingResponse(response string) {
 			},
 			Done: false,
 		}:
-			// Simulate natural typing speed
-			time.Sleep(100 * time.Millisecond)
+		case <-ce.ctx.Done():
+			return
+		}
+		
+		// Simulate natural typing speed
+		select {
+		case <-time.After(100 * time.Millisecond):
 		case <-ce.ctx.Done():
 			return
 		}
@@ -339,7 +453,11 @@ func (ce *ChatEngine) simulateStreamingResponse(response string) {
 // simulateNonStreamingResponse simulates non-streaming response  
 func (ce *ChatEngine) simulateNonStreamingResponse(response string) {
 	// Simulate processing time
-	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-time.After(500 * time.Millisecond):
+	case <-ce.ctx.Done():
+		return
+	}
 	
 	// Send complete response
 	select {
@@ -358,51 +476,32 @@ func (ce *ChatEngine) simulateNonStreamingResponse(response string) {
 // performInference runs actual model inference using Ollama's llama bindings
 func (ce *ChatEngine) performInference(prompt string, options map[string]interface{}) (string, error) {
 	// Tokenize the prompt
-	tokens, err := ce.model.Tokenize(prompt, true, true)
+	tokens, err := tokenize(ce.model, prompt)
 	if err != nil {
-		return "", fmt.Errorf("tokenization failed: %v", err)
+		return "", fmt.Errorf("%w: %w", ErrTokenization, err)
 	}
 	
 	// Create batch for processing
-	batch, err := llama.NewBatch(len(tokens), 1, 0)
+	batch, err := llama.NewBatch(defaultBatchSize, 1, 0)
 	if err != nil {
-		return "", fmt.Errorf("batch creation failed: %v", err)
+		return "", fmt.Errorf("%w: %w", ErrBatchCreation, err)
 	}
 	defer batch.Free()
 	
-	// Add tokens to batch
-	for i, token := range tokens {
-		batch.Add(token, nil, i, i == len(tokens)-1, 0) // Only get logits for last token
-	}
-	
-	// Process the batch
-	err = ce.context.Decode(batch)
-	if err != nil {
-		return "", fmt.Errorf("context decode failed: %v", err)
-	}
-	
-	// Set up sampling parameters
-	samplingParams := llama.SamplingParams{
-		TopK:           40,
-		TopP:           0.9,
-		MinP:           0.1,
-		Temp:           0.7, // TODO: Use request temperature
-		RepeatLastN:    64,
-		PenaltyRepeat:  1.1,
-		PenaltyFreq:    0.0,
-		PenaltyPresent: 0.0,
-		PenalizeNl:     true,
-		Seed:           0,
+	// Decode the prompt in batch-sized passes; only the last token's logits are needed
+	if _, err := decodePrompt(&llamaBatchDecoder{context: ce.context, batch: batch}, tokens, defaultBatchSize); err != nil {
+		return "", err
 	}
 	
 	// Create sampling context
-	sampler, err := llama.NewSamplingContext(ce.model, samplingParams)
+	sampler, err := llama.NewSamplingContext(ce.model, ce.sampling.paramsWith(ce.modelParams))
 	if err != nil {
-		return "", fmt.Errorf("sampling context creation failed: %v", err)
+		return "", fmt.Errorf("%w: %w", ErrSamplerCreation, err)
 	}
 	
 	var response strings.Builder
 	maxTokens := 512 // TODO: Make configurable
+	repeats := repetitionDetector{window: ce.sampling.StopOnRepeat}
 	
 	// Generate tokens iteratively
 	for i := 0; i < maxTokens; i++ {
@@ -418,6 +517,11 @@ func (ce *ChatEngine) performInference(prompt string, options map[string]interfa
 		piece := ce.model.TokenToPiece(token)
 		response.WriteString(piece)
 		
+		// Stop a model stuck in a loop rather than running to the cap
+		if repeats.add(token) {
+			break
+		}
+		
 		// Accept the token for grammar/repetition tracking
 		sampler.Accept(token, true)
 		
@@ -428,7 +532,7 @@ func (ce *ChatEngine) performInference(prompt string, options map[string]interfa
 		// Decode for next iteration
 		err = ce.context.Decode(batch)
 		if err != nil {
-			log.Printf("Decode failed during generation: %v", err)
+			ce.logger.Printf("Decode failed during generation: %v", err)
 			break
 		}
 	}
@@ -525,26 +629,32 @@ func (ce *ChatEngine) buildPromptWithRAG(messages []ChatMessage, userQuery strin
 	if ce.ragEnabled && userQuery != "" && ce.vectorStore != nil && ce.embeddingEngine != nil {
 		ragContext, err := ce.retrieveRAGContext(userQuery)
 		if err != nil {
-			log.Printf("Warning: RAG retrieval failed: %v", err)
+			ce.logger.Printf("Warning: RAG retrieval failed: %v", err)
 		} else if ragContext.NumDocuments > 0 {
 			// Enhance system message with retrieved context
 			enhancedSystem := fmt.Sprintf("%s\n\n%s\n\nBased on the above documentation, please provide accurate and helpful responses about OpenTDF.", 
 				systemMessage, ragContext.ContextText)
 			systemMessage = enhancedSystem
 			
-			log.Printf("RAG: Retrieved %d relevant documents for query", ragContext.NumDocuments)
+			ce.logger.Printf("RAG: Retrieved %d relevant documents for query", ragContext.NumDocuments)
+		} else if ce.ragOptions.NoContextNote != "" {
+			systemMessage = fmt.Sprintf("%s\n\n%s", systemMessage, ce.ragOptions.NoContextNote)
+			ce.logger.Printf("RAG: No relevant documents found for query")
 		}
 	} else if ce.simpleRAGEnabled && userQuery != "" && ce.simpleRAGStore != nil {
 		ragContext, err := ce.retrieveSimpleRAGContext(userQuery)
 		if err != nil {
-			log.Printf("Warning: Simple RAG retrieval failed: %v", err)
+			ce.logger.Printf("Warning: Simple RAG retrieval failed: %v", err)
 		} else if ragContext.NumDocuments > 0 {
 			// Enhance system message with retrieved context
 			enhancedSystem := fmt.Sprintf("%s\n\n%s\n\nBased on the above documentation, please provide accurate and helpful responses about OpenTDF.", 
 				systemMessage, ragContext.ContextText)
 			systemMessage = enhancedSystem
 			
-			log.Printf("Simple RAG: Retrieved %d relevant documents for query", ragContext.NumDocuments)
+			ce.logger.Printf("Simple RAG: Retrieved %d relevant documents for query", ragContext.NumDocuments)
+		} else if ce.ragOptions.NoContextNote != "" {
+			systemMessage = fmt.Sprintf("%s\n\n%s", systemMessage, ce.ragOptions.NoContextNote)
+			ce.logger.Printf("Simple RAG: No relevant documents found for query")
 		}
 	}
 	
@@ -556,13 +666,13 @@ func (ce *ChatEngine) retrieveRAGContext(query string) (RAGContext, error) {
 	// Generate embedding for the query
 	queryEmbedding, err := ce.embeddingEngine.GenerateEmbedding(query)
 	if err != nil {
-		return RAGContext{}, fmt.Errorf("failed to generate query embedding: %v", err)
+		return RAGContext{}, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 	
 	// Search for similar documents
 	results, err := ce.vectorStore.Search(queryEmbedding, 5) // Top 5 results
 	if err != nil {
-		return RAGContext{}, fmt.Errorf("similarity search failed: %v", err)
+		return RAGContext{}, fmt.Errorf("similarity search failed: %w", err)
 	}
 	
 	// Filter results by similarity threshold
@@ -574,7 +684,7 @@ func (ce *ChatEngine) retrieveRAGContext(query string) (RAGContext, error) {
 	}
 	
 	// Build context with max 2000 tokens to leave room for conversation
-	return BuildRAGContext(query, filteredResults, 2000), nil
+	return BuildRAGContext(query, filteredResults, 2000, ce.ragOptions), nil
 }
 
 // retrieveSimpleRAGContext performs simple keyword search and builds context
@@ -582,7 +692,7 @@ func (ce *ChatEngine) retrieveSimpleRAGContext(query string) (RAGContext, error)
 	// Search for similar documents using simple keyword matching
 	results, err := ce.simpleRAGStore.Search(query, 5) // Top 5 results
 	if err != nil {
-		return RAGContext{}, fmt.Errorf("simple search failed: %v", err)
+		return RAGContext{}, fmt.Errorf("simple search failed: %w", err)
 	}
 	
 	// Filter results by score threshold
@@ -594,7 +704,7 @@ func (ce *ChatEngine) retrieveSimpleRAGContext(query string) (RAGContext, error)
 	}
 	
 	// Build context with max 2000 tokens to leave room for conversation
-	return BuildSimpleRAGContext(query, filteredResults, 2000), nil
+	return BuildSimpleRAGContext(query, filteredResults, 2000, ce.ragOptions), nil
 }
 
 // buildPrompt converts chat messages to a prompt string
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewText(t *testing.T) {
+	assert.Equal(t, "short", previewText("short", 50))
+	assert.Equal(t, "the key", previewText("the key access service", 10))
+	assert.Equal(t, "the key", previewText("the key access service", 7))
+	assert.Equal(t, "unbrokenwo", previewText("unbrokenword", 10))
+}
+
+func TestPreviewTextMultiByte(t *testing.T) {
+	prompt := "Qu'est-ce qu'un service d'accès aux clés? 鍵アクセスサービスとは何ですか？ " + strings.Repeat("é", 60)
+
+	for _, n := range []int{1, 25, 50, 100} {
+		preview := previewText(prompt, n)
+		assert.True(t, utf8.ValidString(preview), "preview of %d runes is not valid UTF-8: %q", n, preview)
+		assert.LessOrEqual(t, utf8.RuneCountInString(preview), n)
+		assert.True(t, strings.HasPrefix(prompt, preview))
+	}
+
+	// Byte slicing at the same limit would have split "è" in two
+	assert.False(t, utf8.ValidString(prompt[:30]))
+	assert.Equal(t, "Qu'est-ce qu'un service", previewText(prompt, 30))
+}
+
+func TestChatEngineStopDuringChat(t *testing.T) {
+	// Stop while requests are queued or mid-response must never send on a closed channel
+	for i := 0; i < 20; i++ {
+		engine := NewChatEngine("missing.gguf")
+		require.NoError(t, engine.Start())
+
+		var wg sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for response := range engine.Chat([]ChatMessage{{Role: "user", Content: "hello"}}, j%2 == 0) {
+					if response.Error != nil {
+						assert.ErrorIs(t, response.Error, ErrEngineShuttingDown)
+					}
+				}
+			}()
+		}
+
+		time.Sleep(time.Duration(i%4) * 5 * time.Millisecond)
+		engine.Stop()
+		wg.Wait()
+
+		// Chatting with a stopped engine reports the shutdown instead of hanging
+		response := <-engine.Chat([]ChatMessage{{Role: "user", Content: "again"}}, false)
+		assert.ErrorIs(t, response.Error, ErrEngineShuttingDown)
+	}
+}
+
+func TestChatEngineStopTwice(t *testing.T) {
+	engine := NewChatEngine("missing.gguf")
+	engine.Stop() // never started
+
+	require.NoError(t, engine.Start())
+	assert.NotPanics(t, func() {
+		engine.Stop()
+		engine.Stop()
+	})
+
+	// Restarting would reuse the closed response channel, so it is refused
+	require.ErrorIs(t, engine.Start(), ErrEngineShuttingDown)
+	assert.NotPanics(t, engine.Stop)
+}
@@ -0,0 +1,34 @@
+package llm
+
+import "errors"
+
+var (
+	ErrModelNotFound        = errors.New("model file not found")
+	ErrModelLoad            = errors.New("failed to load model")
+	ErrNotGGUF              = errors.New("not a GGUF file")
+	ErrModelNotLoaded       = errors.New("model or context not loaded")
+	ErrEngineAlreadyRunning = errors.New("engine already running")
+	ErrEngineNotRunning     = errors.New("engine not running")
+	ErrEngineShuttingDown   = errors.New("chat engine is shutting down")
+	ErrTokenization         = errors.New("tokenization failed")
+	ErrBatchCreation        = errors.New("batch creation failed")
+	ErrDecode               = errors.New("context decode failed")
+	ErrSamplerCreation      = errors.New("sampling context creation failed")
+	ErrEmbedding            = errors.New("failed to generate embedding")
+	ErrDimensionMismatch    = errors.New("embedding dimension mismatch")
+	ErrInvalidEmbedding     = errors.New("invalid embedding")
+	ErrIndexLoad            = errors.New("failed to load index")
+	ErrIndexSave            = errors.New("failed to save index")
+	ErrIndexVersion         = errors.New("unsupported index format version")
+	ErrEmptyDocument        = errors.New("processed content is empty")
+	ErrDownload             = errors.New("failed to download document")
+	ErrIngestionInterrupted = errors.New("ingestion interrupted")
+	ErrFileTooLarge         = errors.New("file exceeds the maximum ingestion size")
+	ErrQueueFull            = errors.New("request queue is full")
+	ErrEmptyQuery           = errors.New("conversation has no user message to answer")
+	ErrInvalidExamples      = errors.New("invalid examples file")
+	ErrInvalidConversation  = errors.New("invalid conversation")
+	ErrInvalidModelParams   = errors.New("invalid model parameters")
+	ErrInvalidBoost         = errors.New("invalid source boost")
+	ErrInvalidEvalQueries   = errors.New("invalid evaluation queries")
+)
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrModelNotFound(t *testing.T) {
+	_, err := NewEmbeddingEngine(filepath.Join(t.TempDir(), "missing.gguf"))
+	require.ErrorIs(t, err, ErrModelNotFound)
+
+	err = checkModelFile(t.TempDir())
+	require.ErrorIs(t, err, ErrModelLoad)
+}
+
+func TestErrDimensionMismatch(t *testing.T) {
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(Document{ID: "a", Embedding: []float32{1, 0, 0}}))
+
+	err := store.AddDocument(Document{ID: "b", Embedding: []float32{1, 0}})
+	require.ErrorIs(t, err, ErrDimensionMismatch)
+
+	_, err = store.Search([]float32{1, 0}, 1)
+	require.ErrorIs(t, err, ErrDimensionMismatch)
+}
+
+func TestErrEngineState(t *testing.T) {
+	engine := NewSimpleChatEngine("model.gguf")
+
+	response := engine.Chat([]ChatMessage{{Role: "user", Content: "hello"}})
+	require.ErrorIs(t, response.Error, ErrEngineNotRunning)
+
+	_, err := engine.TimedGenerate("hello")
+	require.ErrorIs(t, err, ErrEngineNotRunning)
+
+	// Simulate an engine that started but could not load its model
+	engine.running = true
+	require.ErrorIs(t, engine.Start(), ErrEngineAlreadyRunning)
+
+	response = engine.Chat([]ChatMessage{{Role: "user", Content: "hello"}})
+	require.ErrorIs(t, response.Error, ErrModelNotLoaded)
+}
+
+func TestErrIndexLoad(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(indexPath, []byte("{not json"), 0o600))
+
+	err := NewVectorStore(indexPath).LoadIndex()
+	require.ErrorIs(t, err, ErrIndexLoad)
+
+	err = NewSimpleRAGStore(indexPath).LoadIndex()
+	require.ErrorIs(t, err, ErrIndexLoad)
+}
+
+func TestErrIndexSave(t *testing.T) {
+	// A regular file cannot be used as the index directory
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+
+	err := NewSimpleRAGStore(filepath.Join(blocker, "index.json")).SaveIndex()
+	require.ErrorIs(t, err, ErrIndexSave)
+	assert.Contains(t, err.Error(), "failed to create index directory")
+}
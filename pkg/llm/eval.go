@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EvalQuery is a labeled query for EvaluateRetrieval: the IDs of the documents a good search
+// for it should return
+type EvalQuery struct {
+	Query       string   `json:"query"`
+	RelevantIDs []string `json:"relevant_ids"`
+}
+
+// EvalQueryResult is how well retrieval did for one labeled query
+type EvalQueryResult struct {
+	Query       string   `json:"query"`
+	RelevantIDs []string `json:"relevant_ids"`
+	Retrieved   []string `json:"retrieved"`
+	// Recall is the fraction of RelevantIDs found in Retrieved
+	Recall float64 `json:"recall"`
+	// FirstRelevantRank is the 1-based rank of the first relevant document, or 0 if none was found
+	FirstRelevantRank int `json:"first_relevant_rank"`
+}
+
+// EvalReport summarizes retrieval quality over a set of labeled queries
+type EvalReport struct {
+	Queries int `json:"queries"`
+	K       int `json:"k"`
+	// RecallAtK is the mean fraction of each query's relevant documents found in its top K
+	RecallAtK float64 `json:"recall_at_k"`
+	// MRR is the mean reciprocal rank of each query's first relevant document within its top K
+	MRR     float64           `json:"mrr"`
+	Results []EvalQueryResult `json:"results"`
+}
+
+// Retriever returns the IDs of the topK documents that best match a query, best first
+type Retriever func(query string, topK int) ([]string, error)
+
+// ReadEvalQueries reads labeled queries from JSON Lines, one EvalQuery object per line; blank
+// lines are skipped. Every query needs text and at least one relevant document ID.
+func ReadEvalQueries(r io.Reader) ([]EvalQuery, error) {
+	var queries []EvalQuery
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var query EvalQuery
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&query); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidEvalQueries, line, err)
+		}
+		if IsBlank(query.Query) {
+			return nil, fmt.Errorf("%w: line %d: query is empty", ErrInvalidEvalQueries, line)
+		}
+		if len(query.RelevantIDs) == 0 {
+			return nil, fmt.Errorf("%w: line %d: relevant_ids is empty", ErrInvalidEvalQueries, line)
+		}
+		queries = append(queries, query)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%w: no queries", ErrInvalidEvalQueries)
+	}
+	return queries, nil
+}
+
+// EvaluateRetrieval runs every query through retrieve for its top k documents and reports
+// recall@k and mean reciprocal rank against the labeled relevant IDs
+func EvaluateRetrieval(queries []EvalQuery, k int, retrieve Retriever) (EvalReport, error) {
+	if k < 1 {
+		return EvalReport{}, fmt.Errorf("k must be at least 1, got %d", k)
+	}
+
+	report := EvalReport{
+		Queries: len(queries),
+		K:       k,
+		Results: make([]EvalQueryResult, 0, len(queries)),
+	}
+	for i, query := range queries {
+		retrieved, err := retrieve(query.Query, k)
+		if err != nil {
+			return EvalReport{}, fmt.Errorf("query %d (%q) failed: %w", i+1, query.Query, err)
+		}
+		if len(retrieved) > k {
+			retrieved = retrieved[:k]
+		}
+
+		relevant := make(map[string]bool, len(query.RelevantIDs))
+		for _, id := range query.RelevantIDs {
+			relevant[id] = true
+		}
+		result := EvalQueryResult{
+			Query:       query.Query,
+			RelevantIDs: query.RelevantIDs,
+			Retrieved:   retrieved,
+		}
+		found := 0
+		for rank, id := range retrieved {
+			if !relevant[id] {
+				continue
+			}
+			// Count each relevant document once, even if retrieval returned it twice
+			relevant[id] = false
+			found++
+			if result.FirstRelevantRank == 0 {
+				result.FirstRelevantRank = rank + 1
+			}
+		}
+		result.Recall = float64(found) / float64(len(relevant))
+
+		report.RecallAtK += result.Recall
+		if result.FirstRelevantRank > 0 {
+			report.MRR += 1 / float64(result.FirstRelevantRank)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if n := float64(len(queries)); n > 0 {
+		report.RecallAtK /= n
+		report.MRR /= n
+	}
+	return report, nil
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateRetrieval(t *testing.T) {
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(Document{ID: "kas", Content: "The KAS rewraps keys.", Embedding: []float32{1, 0, 0}}))
+	require.NoError(t, store.AddDocument(Document{ID: "policy", Content: "Policy binds attributes.", Embedding: []float32{0, 1, 0}}))
+	require.NoError(t, store.AddDocument(Document{ID: "mappings", Content: "Subject mappings grant entitlements.", Embedding: []float32{0, 0.8, 0.6}}))
+
+	// Each query's embedding is looked up rather than generated, so the ranking is known
+	queryEmbeddings := map[string][]float32{
+		"key access":   {1, 0.1, 0},
+		"attributes":   {0, 1, 0},
+		"entitlements": {0.2, 0.6, 0.8},
+	}
+	retrieve := func(query string, topK int) ([]string, error) {
+		results, err := store.Search(queryEmbeddings[query], topK)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.Document.ID)
+		}
+		return ids, nil
+	}
+
+	queries := []EvalQuery{
+		// kas ranks first
+		{Query: "key access", RelevantIDs: []string{"kas"}},
+		// policy ranks first, mappings second
+		{Query: "attributes", RelevantIDs: []string{"policy", "mappings"}},
+		// mappings ranks first, policy second; kas is third, outside the top 2
+		{Query: "entitlements", RelevantIDs: []string{"policy", "kas"}},
+	}
+
+	report, err := EvaluateRetrieval(queries, 2, retrieve)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Queries)
+	assert.Equal(t, 2, report.K)
+	require.Len(t, report.Results, 3)
+
+	assert.InDelta(t, 1.0, report.Results[0].Recall, 0.0001)
+	assert.Equal(t, 1, report.Results[0].FirstRelevantRank)
+	assert.InDelta(t, 1.0, report.Results[1].Recall, 0.0001)
+	assert.Equal(t, []string{"policy", "mappings"}, report.Results[1].Retrieved)
+	assert.InDelta(t, 0.5, report.Results[2].Recall, 0.0001)
+	assert.Equal(t, 2, report.Results[2].FirstRelevantRank)
+
+	assert.InDelta(t, 2.5/3, report.RecallAtK, 0.0001)
+	assert.InDelta(t, 2.5/3, report.MRR, 0.0001)
+
+	// With k=1 the third query misses entirely
+	report, err = EvaluateRetrieval(queries, 1, retrieve)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5/3, report.RecallAtK, 0.0001)
+	assert.InDelta(t, 2.0/3, report.MRR, 0.0001)
+	assert.Zero(t, report.Results[2].FirstRelevantRank)
+}
+
+func TestEvaluateRetrievalErrors(t *testing.T) {
+	queries := []EvalQuery{{Query: "q", RelevantIDs: []string{"a"}}}
+	_, err := EvaluateRetrieval(queries, 0, func(string, int) ([]string, error) { return nil, nil })
+	require.Error(t, err)
+
+	failure := errors.New("search failed")
+	_, err = EvaluateRetrieval(queries, 3, func(string, int) ([]string, error) { return nil, failure })
+	require.ErrorIs(t, err, failure)
+}
+
+func TestReadEvalQueries(t *testing.T) {
+	queries, err := ReadEvalQueries(strings.NewReader(`{"query": "What does the KAS do?", "relevant_ids": ["kas_0"]}
+
+{"query": "How are attributes defined?", "relevant_ids": ["policy_0", "policy_1"]}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []EvalQuery{
+		{Query: "What does the KAS do?", RelevantIDs: []string{"kas_0"}},
+		{Query: "How are attributes defined?", RelevantIDs: []string{"policy_0", "policy_1"}},
+	}, queries)
+
+	for name, input := range map[string]string{
+		"unknown field": `{"query": "q", "relevant": ["a"]}`,
+		"no relevant":   `{"query": "q", "relevant_ids": []}`,
+		"blank query":   `{"query": " ", "relevant_ids": ["a"]}`,
+		"empty":         "\n",
+		"not JSON":      "query: q",
+	} {
+		_, err := ReadEvalQueries(strings.NewReader(input))
+		require.ErrorIs(t, err, ErrInvalidEvalQueries, name)
+	}
+}
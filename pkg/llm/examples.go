@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Example is a question and the answer the model should imitate
+type Example struct {
+	User      string `yaml:"user"`
+	Assistant string `yaml:"assistant"`
+}
+
+// LoadExamples reads few-shot examples from a YAML or JSON file holding a list of user and
+// assistant pairs, e.g.
+//
+//   - user: List the attribute namespaces
+//     assistant: otdfctl policy attributes namespaces list
+//
+// The pairs are returned as alternating user and assistant messages. Unknown fields, empty
+// answers and empty files are rejected.
+func LoadExamples(path string) ([]ChatMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExamples, err)
+	}
+
+	var examples []Example
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&examples); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidExamples, path, err)
+	}
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("%w: %s has no examples", ErrInvalidExamples, path)
+	}
+
+	messages := make([]ChatMessage, 0, 2*len(examples))
+	for i, example := range examples {
+		if IsBlank(example.User) || IsBlank(example.Assistant) {
+			return nil, fmt.Errorf("%w: %s: example %d needs both a user and an assistant message", ErrInvalidExamples, path, i+1)
+		}
+		messages = append(messages,
+			ChatMessage{Role: "user", Content: example.User},
+			ChatMessage{Role: "assistant", Content: example.Assistant},
+		)
+	}
+	return messages, nil
+}
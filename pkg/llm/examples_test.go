@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExamples(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "examples.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+- user: List the attribute namespaces
+  assistant: otdfctl policy attributes namespaces list
+- user: Encrypt hello.txt
+  assistant: otdfctl encrypt hello.txt --out hello.txt.tdf
+`), 0o644))
+
+	examples, err := LoadExamples(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, []ChatMessage{
+		{Role: "user", Content: "List the attribute namespaces"},
+		{Role: "assistant", Content: "otdfctl policy attributes namespaces list"},
+		{Role: "user", Content: "Encrypt hello.txt"},
+		{Role: "assistant", Content: "otdfctl encrypt hello.txt --out hello.txt.tdf"},
+	}, examples)
+
+	jsonPath := filepath.Join(dir, "examples.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`[{"user": "Decrypt it", "assistant": "otdfctl decrypt hello.txt.tdf"}]`), 0o644))
+	examples, err = LoadExamples(jsonPath)
+	require.NoError(t, err)
+	assert.Len(t, examples, 2)
+}
+
+func TestLoadExamplesRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.yaml":      "",
+		"no-list.yaml":    "user: hi\nassistant: hello\n",
+		"unknown.yaml":    "- question: hi\n  answer: hello\n",
+		"no-answer.yaml":  "- user: hi\n",
+		"blank-user.yaml": "- user: \"  \"\n  assistant: hello\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := LoadExamples(path)
+		require.ErrorIs(t, err, ErrInvalidExamples, name)
+	}
+
+	_, err := LoadExamples(filepath.Join(dir, "missing.yaml"))
+	require.ErrorIs(t, err, ErrInvalidExamples)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// TermMatch is a query term found in a document and what it added to the keyword score
+type TermMatch struct {
+	Term string `json:"term"`
+	// QueryCount and DocumentCount are how often the term appears in the query and the document
+	QueryCount    int  `json:"query_count"`
+	DocumentCount int  `json:"document_count"`
+	TitleMatch    bool `json:"title_match"`
+	// IDF is the term's inverse document frequency across the store
+	IDF float32 `json:"idf"`
+	// Contribution is the term's share of the query weighted by its IDF, boosted 1.5x when it
+	// appears more than once in the document and 2x when it appears in the title
+	Contribution float32 `json:"contribution"`
+}
+
+// BigramMatch is a pair of adjacent query terms found adjacent in a document. Its
+// contribution is the two terms' IDF over the number of query terms, so a phrase such as
+// "subject mapping" counts for more than its words scattered through the document.
+type BigramMatch struct {
+	Bigram       string  `json:"bigram"`
+	Contribution float32 `json:"contribution"`
+}
+
+// KeywordExplanation breaks a SimpleRAGStore score down into the parts that produced it
+type KeywordExplanation struct {
+	// Terms are the matched query terms, largest contribution first
+	Terms []TermMatch `json:"terms"`
+	// Missing are the query terms the document doesn't contain
+	Missing []string `json:"missing,omitempty"`
+	// Bigrams are the pairs of adjacent query terms that are also adjacent in the document
+	Bigrams []BigramMatch `json:"bigrams,omitempty"`
+	// PhraseBoost is added when the document contains the whole query as a phrase
+	PhraseBoost float32 `json:"phrase_boost"`
+	// SourceBoost is the boost the document was ingested with, which multiplies the sum of
+	// the above into Score. It is zero when the document has none.
+	SourceBoost float32 `json:"source_boost,omitempty"`
+	Score       float32 `json:"score"`
+}
+
+// ExplainScore reports how doc's keyword score for query is made up
+func (s *SimpleRAGStore) ExplainScore(query string, doc SimpleDocument) KeywordExplanation {
+	return s.explainKeywordScore(parseKeywordQuery(query), doc)
+}
+
+// explainKeywordScore scores doc against the query's keywords, keeping each term's contribution
+func (s *SimpleRAGStore) explainKeywordScore(q keywordQuery, doc SimpleDocument) KeywordExplanation {
+	return scoreKeywords(q, doc, s.idf)
+}
+
+// scoreKeywords scores doc against the query's keywords, weighting each term by idf
+func scoreKeywords(q keywordQuery, doc SimpleDocument, idf func(term string) float32) KeywordExplanation {
+	explanation := KeywordExplanation{Terms: []TermMatch{}}
+	queryWords := q.words
+	if len(queryWords) == 0 {
+		return explanation
+	}
+
+	docText := strings.ToLower(doc.Title + " " + doc.Content)
+	title := strings.ToLower(doc.Title)
+
+	// Create word frequency maps, keeping the query's word order for the explanation
+	var terms []string
+	queryWordCount := make(map[string]int)
+	for _, word := range queryWords {
+		if queryWordCount[word] == 0 {
+			terms = append(terms, word)
+		}
+		queryWordCount[word]++
+	}
+	docWordCount := make(map[string]int)
+	for _, word := range extractKeywords(docText) {
+		docWordCount[word]++
+	}
+
+	// Calculate score based on common words
+	totalQueryWords := float32(len(queryWords))
+	for _, word := range terms {
+		dCount, exists := docWordCount[word]
+		if !exists {
+			explanation.Missing = append(explanation.Missing, word)
+			continue
+		}
+
+		// Weight by frequency and by how rare the word is across the corpus
+		match := TermMatch{
+			Term:          word,
+			QueryCount:    queryWordCount[word],
+			DocumentCount: dCount,
+			TitleMatch:    strings.Contains(title, word),
+			IDF:           idf(word),
+		}
+		match.Contribution = float32(match.QueryCount) / totalQueryWords * match.IDF
+		if dCount > 1 {
+			match.Contribution *= 1.5 // Boost if word appears multiple times in doc
+		}
+		if match.TitleMatch {
+			match.Contribution *= 2.0
+		}
+
+		explanation.Terms = append(explanation.Terms, match)
+		explanation.Score += match.Contribution
+	}
+	sort.SliceStable(explanation.Terms, func(i, j int) bool {
+		return explanation.Terms[i].Contribution > explanation.Terms[j].Contribution
+	})
+
+	// Boost for query terms that appear next to each other in the document
+	docBigrams := doc.Bigrams
+	if docBigrams == nil {
+		docBigrams = documentBigrams(doc)
+	}
+	adjacent := make(map[string]bool, len(docBigrams))
+	for _, bigram := range docBigrams {
+		adjacent[bigram] = true
+	}
+	counted := make(map[string]bool)
+	for _, bigram := range q.bigrams {
+		if !adjacent[bigram] || counted[bigram] {
+			continue
+		}
+		counted[bigram] = true
+		first, second, _ := strings.Cut(bigram, " ")
+		match := BigramMatch{
+			Bigram:       bigram,
+			Contribution: (idf(first) + idf(second)) / totalQueryWords,
+		}
+		explanation.Bigrams = append(explanation.Bigrams, match)
+		explanation.Score += match.Contribution
+	}
+
+	// Boost for exact phrase matches
+	if strings.Contains(docText, strings.Join(queryWords, " ")) {
+		explanation.PhraseBoost = 1.0
+		explanation.Score += explanation.PhraseBoost
+	}
+
+	explanation.SourceBoost = doc.Boost
+	explanation.Score = boosted(explanation.Score, doc.Boost)
+	return explanation
+}
+
+// VectorExplanation breaks a VectorStore similarity down into the values that produced it
+type VectorExplanation struct {
+	// DotProduct, QueryNorm and DocumentNorm give Cosine = DotProduct / (QueryNorm * DocumentNorm)
+	DotProduct   float32 `json:"dot_product"`
+	QueryNorm    float32 `json:"query_norm"`
+	DocumentNorm float32 `json:"document_norm"`
+	Cosine       float32 `json:"cosine"`
+	// TitleCosine is the query's similarity to the document title, blended into Similarity
+	// with weight TitleBoost. Both are zero when title boosting doesn't apply.
+	TitleCosine float32 `json:"title_cosine,omitempty"`
+	TitleBoost  float32 `json:"title_boost,omitempty"`
+	// SourceBoost is the boost the document was ingested with, which multiplies the blended
+	// similarity. It is zero when the document has none.
+	SourceBoost float32 `json:"source_boost,omitempty"`
+	Similarity  float32 `json:"similarity"`
+}
+
+// ExplainSimilarity reports how doc's similarity to queryEmbedding is made up
+func (vs *VectorStore) ExplainSimilarity(queryEmbedding []float32, doc Document) VectorExplanation {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return vs.explainSimilarity(queryEmbedding, doc)
+}
+
+// explainSimilarity scores doc against the query embedding. The caller must hold vs.mu.
+func (vs *VectorStore) explainSimilarity(queryEmbedding []float32, doc Document) VectorExplanation {
+	return explainVectorSimilarity(queryEmbedding, doc, vs.titleBoost)
+}
+
+// explainVectorSimilarity scores doc against the query embedding, blending in title similarity
+// with weight titleBoost
+func explainVectorSimilarity(queryEmbedding []float32, doc Document, titleBoost float32) VectorExplanation {
+	var explanation VectorExplanation
+	if len(queryEmbedding) == len(doc.Embedding) {
+		explanation.DotProduct, explanation.QueryNorm, explanation.DocumentNorm = vectorProducts(queryEmbedding, doc.Embedding)
+	}
+	explanation.Cosine = cosineSimilarity(queryEmbedding, doc.Embedding)
+	explanation.Similarity = explanation.Cosine
+
+	// Documents indexed without title embeddings are scored on their body only
+	if titleBoost > 0 && len(doc.TitleEmbedding) == len(queryEmbedding) {
+		explanation.TitleCosine = cosineSimilarity(queryEmbedding, doc.TitleEmbedding)
+		explanation.TitleBoost = titleBoost
+		explanation.Similarity = (1-titleBoost)*explanation.Cosine + titleBoost*explanation.TitleCosine
+	}
+
+	explanation.SourceBoost = doc.Boost
+	explanation.Similarity = boosted(explanation.Similarity, doc.Boost)
+	return explanation
+}
+
+// vectorProducts returns the dot product of a and b and their Euclidean norms
+func vectorProducts(a, b []float32) (dotProduct, normA, normB float32) {
+	var sumA, sumB float32
+	for i := range a {
+		dotProduct += a[i] * b[i]
+		sumA += a[i] * a[i]
+		sumB += b[i] * b[i]
+	}
+	return dotProduct, float32(math.Sqrt(float64(sumA))), float32(math.Sqrt(float64(sumB)))
+}
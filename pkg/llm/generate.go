@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/llama"
+)
+
+// maxGeneratedTokens caps the length of a single response
+const maxGeneratedTokens = 512
+
+// StopReason says why generation of a response ended
+type StopReason string
+
+const (
+	// StopReasonEOG means the model emitted an end-of-generation token
+	StopReasonEOG StopReason = "eog"
+	// StopReasonLength means the response hit the token cap or filled the context window
+	StopReasonLength StopReason = "length"
+	// StopReasonCancelled means the caller's context was cancelled mid-generation
+	StopReasonCancelled StopReason = "cancelled"
+	// StopReasonRepetition means the response was stuck repeating itself
+	StopReasonRepetition StopReason = "repetition"
+)
+
+// tokenSampler is the model side of the generation loop
+type tokenSampler interface {
+	// sample picks the next token from the logits of the last decoded position
+	sample() (token int, piece string, eog bool)
+	// accept records the token for repetition tracking and decodes it at pos
+	accept(token int, pos int) error
+}
+
+// llamaTokenSampler samples from a llama context, reusing one batch to decode each new token
+type llamaTokenSampler struct {
+	model   *llama.Model
+	context *llama.Context
+	sampler *llama.SamplingContext
+	batch   *llama.Batch
+}
+
+func (s *llamaTokenSampler) sample() (int, string, bool) {
+	token := s.sampler.Sample(s.context, s.batch.NumTokens()-1)
+	if s.model.TokenIsEog(token) {
+		return token, "", true
+	}
+	return token, s.model.TokenToPiece(token), false
+}
+
+func (s *llamaTokenSampler) accept(token int, pos int) error {
+	s.sampler.Accept(token, true)
+	s.batch.Clear()
+	s.batch.Add(token, nil, pos, true, 0)
+	if err := s.context.Decode(s.batch); err != nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return nil
+}
+
+// repetitionDetector spots a model stuck in a loop. It reports a repeat once the last window
+// tokens are a cycle of at most window/2 tokens, so one that has come round at least twice.
+// A window below 2 never reports one.
+type repetitionDetector struct {
+	window int
+	recent []int
+}
+
+// add records the next generated token and reports whether the recent tokens now repeat
+func (d *repetitionDetector) add(token int) bool {
+	if d.window < 2 {
+		return false
+	}
+	if len(d.recent) == d.window {
+		copy(d.recent, d.recent[1:])
+		d.recent = d.recent[:d.window-1]
+	}
+	d.recent = append(d.recent, token)
+	if len(d.recent) < d.window {
+		return false
+	}
+
+	for period := 1; period <= d.window/2; period++ {
+		if isCycle(d.recent, period) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCycle reports whether tokens repeat every period tokens
+func isCycle(tokens []int, period int) bool {
+	for i := period; i < len(tokens); i++ {
+		if tokens[i] != tokens[i-period] {
+			return false
+		}
+	}
+	return true
+}
+
+// generate samples up to maxTokens tokens, decoding each one at the next position after pos,
+// and streams every piece to callback. It records token counts and time to first token in
+// timings, measured from start, and reports why generation stopped. Cancelling ctx stops
+// generation with the partial response, and with StopOnRepeat set a looping response is cut
+// short.
+func (sce *SimpleChatEngine) generate(ctx context.Context, s tokenSampler, pos, maxTokens int, start time.Time, timings *GenerationTimings, callback StreamingCallback) (string, StopReason, error) {
+	var response strings.Builder
+	reason := StopReasonLength
+	repeats := repetitionDetector{window: sce.sampling.StopOnRepeat}
+
+	for i := 0; i < maxTokens; i++ {
+		// Stop as soon as the caller gives up so the context is freed for the next request
+		if err := ctx.Err(); err != nil {
+			sce.logger.Printf("Generation cancelled after %d tokens: %v", timings.GeneratedTokens, err)
+			return strings.TrimSpace(response.String()), StopReasonCancelled, err
+		}
+
+		token, piece, eog := s.sample()
+		if eog {
+			reason = StopReasonEOG
+			break
+		}
+
+		if timings.GeneratedTokens == 0 {
+			timings.TimeToFirstToken = time.Since(start)
+		}
+		timings.GeneratedTokens++
+
+		response.WriteString(piece)
+		if callback != nil {
+			callback(piece)
+		}
+
+		if repeats.add(token) {
+			sce.logger.Printf("Generation stopped after %d tokens: the last %d tokens repeat", timings.GeneratedTokens, repeats.window)
+			reason = StopReasonRepetition
+			break
+		}
+
+		// The last token is never fed back, so it doesn't need a position in the context
+		if i == maxTokens-1 {
+			break
+		}
+		// A failed decode usually means the KV cache is full, so it counts as running out of room
+		if err := s.accept(token, pos+i); err != nil {
+			sce.logger.Printf("Decode failed during generation: %v", err)
+			break
+		}
+	}
+
+	return strings.TrimSpace(response.String()), reason, nil
+}
@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedSampler returns pieces in order, then end of generation
+type scriptedSampler struct {
+	pieces    []string
+	sampled   int
+	accepted  []int
+	acceptErr error
+}
+
+func (s *scriptedSampler) sample() (int, string, bool) {
+	if s.sampled >= len(s.pieces) {
+		return -1, "", true
+	}
+	s.sampled++
+	return s.sampled, s.pieces[s.sampled-1], false
+}
+
+func (s *scriptedSampler) accept(_ int, pos int) error {
+	s.accepted = append(s.accepted, pos)
+	return s.acceptErr
+}
+
+func TestGenerateCountsSampledTokens(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	sampler := &scriptedSampler{pieces: []string{"Attributes", " define", " access", "."}}
+
+	var streamed []string
+	var timings GenerationTimings
+	text, reason, err := engine.generate(context.Background(), sampler, 10, maxGeneratedTokens, time.Now(), &timings, func(piece string) {
+		streamed = append(streamed, piece)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StopReasonEOG, reason)
+
+	assert.Equal(t, "Attributes define access.", text)
+	assert.Equal(t, 4, timings.GeneratedTokens)
+	assert.Equal(t, sampler.pieces, streamed)
+	assert.Equal(t, []int{10, 11, 12, 13}, sampler.accepted)
+}
+
+func TestGenerateStopsAtTokenCap(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	sampler := &scriptedSampler{pieces: []string{"a", "b", "c", "d", "e"}}
+
+	var timings GenerationTimings
+	text, reason, err := engine.generate(context.Background(), sampler, 0, 3, time.Now(), &timings, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StopReasonLength, reason)
+
+	assert.Equal(t, "abc", text)
+	assert.Equal(t, 3, timings.GeneratedTokens)
+	// The final token isn't decoded since nothing is sampled after it
+	assert.Equal(t, []int{0, 1}, sampler.accepted)
+}
+
+func TestGenerateStopsOnDecodeFailure(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	sampler := &scriptedSampler{pieces: []string{"a", "b"}, acceptErr: errors.New("out of memory")}
+
+	var timings GenerationTimings
+	text, reason, err := engine.generate(context.Background(), sampler, 0, maxGeneratedTokens, time.Now(), &timings, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StopReasonLength, reason)
+	assert.Equal(t, "a", text)
+	assert.Equal(t, 1, timings.GeneratedTokens)
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	sampler := &scriptedSampler{pieces: []string{"a", "b", "c"}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var timings GenerationTimings
+	text, reason, err := engine.generate(ctx, sampler, 0, maxGeneratedTokens, time.Now(), &timings, func(string) {
+		cancel()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StopReasonCancelled, reason)
+	assert.Equal(t, "a", text)
+	assert.Equal(t, 1, timings.GeneratedTokens)
+}
+
+// loopingSampler emits the pieces of intro once, then the pieces of loop forever. Each piece
+// is given its last byte as its token.
+type loopingSampler struct {
+	intro   []string
+	loop    []string
+	sampled int
+}
+
+func (s *loopingSampler) sample() (int, string, bool) {
+	piece := ""
+	if s.sampled < len(s.intro) {
+		piece = s.intro[s.sampled]
+	} else {
+		piece = s.loop[(s.sampled-len(s.intro))%len(s.loop)]
+	}
+	s.sampled++
+	return int(piece[len(piece)-1]), piece, false
+}
+
+func (s *loopingSampler) accept(int, int) error { return nil }
+
+func TestGenerateStopsOnRepetition(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetSamplingOptions(SamplingOptions{StopOnRepeat: 8})
+	sampler := &loopingSampler{intro: []string{"T", "D", "F"}, loop: []string{" a", " b", " c"}}
+
+	var timings GenerationTimings
+	text, reason, err := engine.generate(context.Background(), sampler, 0, maxGeneratedTokens, time.Now(), &timings, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StopReasonRepetition, reason)
+	// The intro plus the 8 tokens that repeat
+	assert.Equal(t, "TDF a b c a b c a b", text)
+	assert.Equal(t, 11, timings.GeneratedTokens)
+}
+
+func TestGenerateRunsToCapWithoutStopOnRepeat(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	sampler := &loopingSampler{loop: []string{"x"}}
+
+	var timings GenerationTimings
+	_, reason, err := engine.generate(context.Background(), sampler, 0, maxGeneratedTokens, time.Now(), &timings, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StopReasonLength, reason)
+	assert.Equal(t, maxGeneratedTokens, timings.GeneratedTokens)
+}
+
+func TestRepetitionDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+		tokens []int
+		want   bool
+	}{
+		{"one token over and over", 4, []int{1, 1, 1, 1}, true},
+		{"a phrase twice", 6, []int{1, 2, 3, 1, 2, 3}, true},
+		{"a phrase once", 6, []int{9, 8, 1, 2, 3, 1, 2}, false},
+		{"only the window counts", 4, []int{5, 6, 7, 1, 2, 1, 2}, true},
+		{"varied text", 6, []int{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"cycle longer than half the window", 5, []int{1, 2, 3, 1, 2}, false},
+		{"fewer tokens than the window", 4, []int{1, 1, 1}, false},
+		{"disabled", 0, []int{1, 1, 1, 1, 1}, false},
+		{"window too small", 1, []int{1, 1, 1, 1, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := repetitionDetector{window: tt.window}
+			repeats := false
+			for _, token := range tt.tokens {
+				repeats = d.add(token)
+			}
+			assert.Equal(t, tt.want, repeats)
+		})
+	}
+}
+
+func TestNewSimpleResponse(t *testing.T) {
+	timings := GenerationTimings{PromptTokens: 120, GeneratedTokens: 42, Total: 3 * time.Second}
+
+	resp := newSimpleResponse("answer", timings, StopReasonEOG)
+	assert.Equal(t, "answer", resp.Content)
+	assert.Equal(t, 120, resp.PromptTokens)
+	assert.Equal(t, 42, resp.CompletionTokens)
+	assert.Equal(t, 42, resp.TokenCount)
+	assert.Equal(t, StopReasonEOG, resp.StopReason)
+	assert.Equal(t, 3*time.Second, resp.Duration)
+	assert.NoError(t, resp.Error)
+}
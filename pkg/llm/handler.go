@@ -2,7 +2,9 @@ package llm
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -43,6 +45,7 @@ type Handler struct {
 	printlnFunc      PrintFunc
 	exitWithJSONFunc ExitWithJSONFunc
 	isJSONMode       bool
+	plain            bool
 }
 
 // NewHandler creates a new LLM handler instance
@@ -56,6 +59,23 @@ func NewHandler(cfg *config.Config, printFunc, printlnFunc PrintFunc, exitWithJS
 	}
 }
 
+// SetPlain strips emoji from everything the handler prints and leaves out the startup
+// banner, for output that is piped or logged
+func (h *Handler) SetPlain() {
+	if h.plain {
+		return
+	}
+	h.plain = true
+	
+	printFunc, printlnFunc := h.printFunc, h.printlnFunc
+	h.printFunc = func(format string, args ...interface{}) {
+		printFunc("%s", StripEmoji(fmt.Sprintf(format, args...)))
+	}
+	h.printlnFunc = func(format string, args ...interface{}) {
+		printlnFunc(StripEmoji(fmt.Sprintf(format, args...)))
+	}
+}
+
 // Close gracefully shuts down the LLM handler
 func (h *Handler) Close() {
 	if h.engine != nil {
@@ -87,6 +107,11 @@ func (h *Handler) StartChatWithRAG(modelPath string, stream bool, contextSize in
 	}
 	
 	h.engine = NewChatEngine(modelPath)
+	h.engine.SetContextSize(contextSize)
+	
+	sampling := DefaultSamplingOptions()
+	sampling.Temperature = float32(temperature)
+	h.engine.SetSamplingOptions(sampling)
 	
 	// Enable RAG if requested
 	if enableRAG {
@@ -114,27 +139,21 @@ func (h *Handler) StartChatWithRAG(modelPath string, stream bool, contextSize in
 	defer h.engine.Stop()
 	
 	// Initialize conversation with system message
-	messages := []ChatMessage{}
-	if systemPrompt != "" {
-		messages = append(messages, ChatMessage{
-			Role:    "system",
-			Content: systemPrompt,
-		})
-	} else {
-		messages = append(messages, ChatMessage{
-			Role:    "system", 
-			Content: h.getDefaultSystemPrompt(),
-		})
+	if systemPrompt == "" {
+		systemPrompt = h.getDefaultSystemPrompt()
 	}
+	messages := SetSystemMessage(nil, systemPrompt)
 	
 	// Check if JSON output is requested
 	if h.isJSONMode {
 		return h.startJSONSession(modelPath, stream, contextSize, temperature, messages)
 	}
 	
-	h.printFunc("🤖 OpenTDF LLM Chat started! Type 'exit' to quit, 'clear' to clear history.\n")
-	h.printFunc("   Use '/stream' to toggle streaming mode, '/help' for commands.\n")
-	h.printFunc("   Model: %s\n\n", modelPath)
+	if !h.plain {
+		h.printFunc("🤖 OpenTDF LLM Chat started! Type 'exit' to quit, 'clear' to clear history.\n")
+		h.printFunc("   Use '/stream' to toggle streaming mode, '/help' for commands.\n")
+		h.printFunc("   Model: %s\n\n", modelPath)
+	}
 	
 	scanner := bufio.NewScanner(os.Stdin)
 	
@@ -151,6 +170,13 @@ func (h *Handler) StartChatWithRAG(modelPath string, stream bool, contextSize in
 			continue
 		}
 		
+		// Replace the system prompt without clearing the conversation
+		if strings.HasPrefix(input, "/system ") {
+			messages = SetSystemMessage(messages, strings.TrimSpace(strings.TrimPrefix(input, "/system ")))
+			h.printlnFunc("System prompt updated.")
+			continue
+		}
+		
 		// Handle commands
 		switch input {
 		case "exit", "quit":
@@ -164,6 +190,13 @@ func (h *Handler) StartChatWithRAG(modelPath string, stream bool, contextSize in
 			stream = !stream
 			h.printFunc("Streaming mode: %v\n", stream)
 			continue
+		case "/system":
+			h.printlnFunc("Usage: /system <prompt>")
+			continue
+		case "/system-reset":
+			messages = SetSystemMessage(messages, systemPrompt)
+			h.printlnFunc("System prompt reset.")
+			continue
 		case "/help":
 			h.printHelp()
 			continue
@@ -236,8 +269,14 @@ func (h *Handler) getDefaultSystemPrompt() string {
 You help users understand OpenTDF concepts, debug issues, write policies, and implement secure data workflows. Provide practical, actionable guidance with code examples when relevant.`
 }
 
-// startJSONSession handles JSON output mode for non-interactive use
+// startJSONSession handles JSON output mode for non-interactive use.
+// With streaming, each line read from stdin is sent as a user message and the replies are
+// written to stdout as NDJSON; otherwise the session configuration is printed.
 func (h *Handler) startJSONSession(modelPath string, stream bool, contextSize int, temperature float64, messages []ChatMessage) error {
+	if stream {
+		return h.streamJSONSession(os.Stdin, os.Stdout, messages)
+	}
+	
 	session := ChatSession{
 		ModelPath: modelPath,
 		Config: ChatConfig{
@@ -258,11 +297,80 @@ func (h *Handler) startJSONSession(modelPath string, stream bool, contextSize in
 	return nil
 }
 
+// streamJSONSession answers each line of in as a user message, streaming the replies to out as NDJSON
+func (h *Handler) streamJSONSession(in io.Reader, out io.Writer, messages []ChatMessage) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		input := strings.TrimSpace(scanner.Text())
+		if IsBlank(input) {
+			continue
+		}
+		
+		messages = append(messages, ChatMessage{Role: "user", Content: input})
+		reply, err := StreamJSON(out, h.engine.Chat(messages, true))
+		if err != nil {
+			return err
+		}
+		if reply != "" {
+			messages = append(messages, ChatMessage{Role: "assistant", Content: reply})
+		}
+	}
+	return scanner.Err()
+}
+
+// ChatResponseRecord is one line of the NDJSON written by StreamJSON
+type ChatResponseRecord struct {
+	Message ChatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// StreamJSON drains a ChatEngine response channel, writing each response to w as a line of JSON.
+// The stream always ends with a record whose done field is true, even when the channel closes
+// without one. It returns the assistant's full reply; engine errors are reported in the stream,
+// so the returned error is only set when writing fails.
+func StreamJSON(w io.Writer, responses <-chan ChatResponse) (string, error) {
+	encoder := json.NewEncoder(w)
+	var reply strings.Builder
+	
+	for response := range responses {
+		record := ChatResponseRecord{Message: response.Message, Done: response.Done}
+		if response.Error != nil {
+			record.Error = response.Error.Error()
+			// An error ends the response, so say so even if the engine didn't
+			record.Done = true
+		}
+		
+		if response.Done {
+			// The final response carries the whole reply
+			reply.Reset()
+		}
+		reply.WriteString(response.Message.Content)
+		
+		if err := encoder.Encode(record); err != nil {
+			return "", err
+		}
+		if record.Done {
+			return strings.TrimSpace(reply.String()), nil
+		}
+	}
+	
+	// The channel closed early, e.g. because the engine shut down
+	err := encoder.Encode(ChatResponseRecord{
+		Message: ChatMessage{Role: "assistant", Content: strings.TrimSpace(reply.String())},
+		Done:    true,
+		Error:   ErrEngineShuttingDown.Error(),
+	})
+	return strings.TrimSpace(reply.String()), err
+}
+
 // printHelp displays available commands
 func (h *Handler) printHelp() {
 	h.printlnFunc("\nAvailable commands:")
 	h.printlnFunc("  exit, quit  - Exit the chat")
 	h.printlnFunc("  clear       - Clear chat history")
 	h.printlnFunc("  /stream     - Toggle streaming mode")
+	h.printlnFunc("  /system <prompt> - Replace the system prompt, keeping history")
+	h.printlnFunc("  /system-reset    - Restore the startup system prompt")
 	h.printlnFunc("  /help       - Show this help")
 }
\ No newline at end of file
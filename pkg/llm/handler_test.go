@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func responses(items ...ChatResponse) <-chan ChatResponse {
+	ch := make(chan ChatResponse, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
+}
+
+func readRecords(t *testing.T, out *bytes.Buffer) []ChatResponseRecord {
+	t.Helper()
+	var records []ChatResponseRecord
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var record ChatResponseRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestStreamJSON(t *testing.T) {
+	var out bytes.Buffer
+	reply, err := StreamJSON(&out, responses(
+		ChatResponse{Message: ChatMessage{Role: "assistant", Content: "Hello "}},
+		ChatResponse{Message: ChatMessage{Role: "assistant", Content: "there "}},
+		ChatResponse{Message: ChatMessage{Role: "assistant", Content: "Hello there"}, Done: true},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", reply)
+
+	records := readRecords(t, &out)
+	require.Len(t, records, 3)
+	assert.Equal(t, "Hello ", records[0].Message.Content)
+	assert.False(t, records[0].Done)
+	assert.True(t, records[2].Done)
+	assert.Empty(t, records[2].Error)
+}
+
+func TestStreamJSONError(t *testing.T) {
+	var out bytes.Buffer
+	_, err := StreamJSON(&out, responses(
+		ChatResponse{Message: ChatMessage{Role: "assistant", Content: "partial "}},
+		ChatResponse{Error: errors.New("decode failed")},
+	))
+	require.NoError(t, err)
+
+	records := readRecords(t, &out)
+	require.Len(t, records, 2)
+	assert.True(t, records[1].Done)
+	assert.Equal(t, "decode failed", records[1].Error)
+}
+
+func TestStreamJSONEndsWithDoneWhenChannelCloses(t *testing.T) {
+	var out bytes.Buffer
+	reply, err := StreamJSON(&out, responses(
+		ChatResponse{Message: ChatMessage{Role: "assistant", Content: "cut "}},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, "cut", reply)
+
+	records := readRecords(t, &out)
+	require.Len(t, records, 2)
+	last := records[len(records)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, ErrEngineShuttingDown.Error(), last.Error)
+	assert.Equal(t, "cut", last.Message.Content)
+}
+
+func TestStreamJSONEmptyConversation(t *testing.T) {
+	// The engine rejects a blank question without starting, and the stream still ends with done
+	engine := NewChatEngine("missing.gguf")
+
+	var out bytes.Buffer
+	_, err := StreamJSON(&out, engine.Chat([]ChatMessage{{Role: "user", Content: ""}}, true))
+	require.NoError(t, err)
+
+	records := readRecords(t, &out)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Done)
+	assert.Equal(t, ErrEmptyQuery.Error(), records[0].Error)
+}
+
+func TestHandlerSetPlain(t *testing.T) {
+	var out bytes.Buffer
+	printFunc := func(format string, args ...interface{}) { fmt.Fprintf(&out, format, args...) }
+	printlnFunc := func(format string, args ...interface{}) { fmt.Fprintln(&out, fmt.Sprintf(format, args...)) }
+	h := NewHandler(nil, printFunc, printlnFunc, nil, false)
+
+	h.SetPlain()
+	h.SetPlain() // Setting it twice must not wrap the printers twice
+	h.printFunc("✅ Simple RAG enabled with %d documents\n", 3)
+	h.printFunc("🤖 ")
+	h.printlnFunc("Goodbye! 👋")
+
+	assert.Equal(t, "Simple RAG enabled with 3 documents\nGoodbye!\n", out.String())
+}
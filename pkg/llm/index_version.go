@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// IndexFormatVersion is the version of the index format this build writes and the newest it
+// can read. Version 1 is the original format, so indexes saved before versions were recorded
+// load as version 1.
+const IndexFormatVersion = 1
+
+// checkIndexVersion rejects an index at path written in a newer format than this build reads
+func checkIndexVersion(path string, version int) error {
+	if version <= IndexFormatVersion {
+		return nil
+	}
+	return fmt.Errorf("%w: %s has format version %d but this otdfctl reads up to version %d; upgrade otdfctl or rebuild the index",
+		ErrIndexVersion, path, version, IndexFormatVersion)
+}
+
+// peekIndexVersion returns the format version recorded in an index file that failed to parse,
+// or 0 if it has none, so a file from a newer otdfctl is reported as such rather than as corrupt
+func peekIndexVersion(data []byte) int {
+	var header struct {
+		Version int `json:"version"`
+	}
+	_ = json.Unmarshal(data, &header)
+	return header.Version
+}
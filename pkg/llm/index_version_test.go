@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadUnversionedIndexAsV1(t *testing.T) {
+	dir := t.TempDir()
+	vectorPath := filepath.Join(dir, "rag_index.json")
+	simplePath := filepath.Join(dir, "simple_rag_index.json")
+	// Indexes saved before versions were recorded have no version field
+	require.NoError(t, os.WriteFile(vectorPath, []byte(`{"documents": [{"id": "kas", "embedding": [1, 0]}], "embedding_dim": 2}`), 0o644))
+	require.NoError(t, os.WriteFile(simplePath, []byte(`{"documents": [{"id": "kas", "title": "KAS", "content": "key access"}]}`), 0o644))
+
+	vectors := NewVectorStore(vectorPath)
+	require.NoError(t, vectors.LoadIndex())
+	assert.Equal(t, 1, vectors.GetDocumentCount())
+	assert.Equal(t, 2, vectors.EmbeddingDim())
+	keywords := NewSimpleRAGStore(simplePath)
+	require.NoError(t, keywords.LoadIndex())
+	assert.Equal(t, 1, keywords.GetDocumentCount())
+
+	// Saving records the version
+	require.NoError(t, vectors.SaveIndex())
+	require.NoError(t, keywords.SaveIndex())
+	for _, path := range []string{vectorPath, simplePath} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var header struct {
+			Version int `json:"version"`
+		}
+		require.NoError(t, json.Unmarshal(data, &header))
+		assert.Equal(t, IndexFormatVersion, header.Version, path)
+	}
+}
+
+func TestRejectFutureIndexVersion(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		// Still parses with the current layout
+		"compatible.json": `{"version": 99, "documents": []}`,
+		// A layout this build can't decode at all
+		"reshaped.json": `{"version": 99, "documents": {"quantized": "AAEC"}}`,
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+		for _, err := range []error{NewVectorStore(path).LoadIndex(), NewSimpleRAGStore(path).LoadIndex()} {
+			require.ErrorIs(t, err, ErrIndexLoad, name)
+			require.ErrorIs(t, err, ErrIndexVersion, name)
+			assert.Contains(t, err.Error(), "format version 99")
+			assert.Contains(t, err.Error(), "upgrade otdfctl")
+		}
+	}
+}
+
+func TestRejectFutureSQLiteIndexVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rag_index.db")
+	store := NewSQLiteStore(path)
+	require.NoError(t, store.LoadIndex())
+	require.NoError(t, store.AddDocument(Document{ID: "kas", Embedding: []float32{1, 0}}))
+	require.NoError(t, store.SaveIndex())
+	require.NoError(t, store.Close())
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	var version string
+	require.NoError(t, db.QueryRow(`SELECT value FROM metadata WHERE key = 'format_version'`).Scan(&version))
+	assert.Equal(t, "1", version)
+	_, err = db.Exec(`UPDATE metadata SET value = '99' WHERE key = 'format_version'`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	err = NewSQLiteStore(path).LoadIndex()
+	require.ErrorIs(t, err, ErrIndexLoad)
+	require.ErrorIs(t, err, ErrIndexVersion)
+}
@@ -2,31 +2,72 @@ package llm
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"crypto/sha256"
 	"encoding/hex"
 )
 
+// githubDocFiles are the documentation files ingested from the OpenTDF docs repository
+var githubDocFiles = []string{
+	"README.md",
+	"platform/README.md",
+	"platform/getting-started.md",
+	"platform/configuration.md",
+	"platform/deployment.md",
+	"platform/architecture.md",
+	"platform/security.md",
+	"sdk/README.md",
+	"sdk/getting-started.md",
+	"sdk/javascript.md",
+	"sdk/python.md",
+	"sdk/go.md",
+	"sdk/java.md",
+	"protocol/README.md",
+	"protocol/tdf-spec.md",
+	"protocol/kas.md",
+	"protocol/policy.md",
+	"protocol/attributes.md",
+	"spec/README.md",
+	"spec/ztdf.md",
+	"spec/nano-tdf.md",
+}
+
+// DefaultMaxFileSize is the largest file ingested unless SetMaxFileSize says otherwise
+const DefaultMaxFileSize int64 = 10 << 20
+
 // DocumentIngester handles downloading and processing OpenTDF documentation
 type DocumentIngester struct {
 	repoURL       string
 	localCachDir  string
-	vectorStore   *VectorStore
-	embeddingEngine *EmbeddingEngine
+	vectorStore   Store
+	embeddingEngine Embedder
 	chunkSize     int
+	codeChunkSize int
+	minChunkSize  int
 	chunkOverlap  int
+	embedTitles   bool
+	language      string
+	pathFilter    pathFilter
+	boosts        SourceBoosts
+	maxFileSize   int64
+	since         time.Time
+	keepCode      bool
+	keepLinks     bool
+	logger        Logger
 }
 
 // NewDocumentIngester creates a new document ingester
-func NewDocumentIngester(vectorStore *VectorStore, embeddingEngine *EmbeddingEngine, cacheDir string) *DocumentIngester {
+func NewDocumentIngester(vectorStore Store, embeddingEngine Embedder, cacheDir string) *DocumentIngester {
 	return &DocumentIngester{
 		repoURL:         "https://raw.githubusercontent.com/opentdf/docs/main",
 		localCachDir:    cacheDir,
@@ -34,83 +75,274 @@ func NewDocumentIngester(vectorStore *VectorStore, embeddingEngine *EmbeddingEng
 		embeddingEngine: embeddingEngine,
 		chunkSize:       300,  // words per chunk
 		chunkOverlap:    50,   // overlapping words
+		maxFileSize:     DefaultMaxFileSize,
+		logger:          NopLogger(),
+	}
+}
+
+// SetLogger routes the ingester's progress and warning messages to the given logger
+func (di *DocumentIngester) SetLogger(logger Logger) {
+	di.logger = logger
+}
+
+// SetChunkSize sets the number of words per chunk. The overlap between chunks is reduced
+// if needed so it stays below the chunk size. Values below 1 keep the current size.
+func (di *DocumentIngester) SetChunkSize(words int) {
+	if words < 1 {
+		return
+	}
+	di.chunkSize = words
+	di.chunkOverlap = min(di.chunkOverlap, words/2)
+}
+
+// SetCodeChunkSize sets the number of words per chunk of code kept by SetKeepCode. Each code
+// block is chunked apart from the prose around it, so one that fits stays whole. Values below
+// 1 use half the chunk size.
+func (di *DocumentIngester) SetCodeChunkSize(words int) {
+	di.codeChunkSize = words
+}
+
+// SetMinChunkSize sets the fewest words a chunk may have. Shorter chunks, such as a stray
+// heading line, are merged into a neighbouring chunk, and a document shorter than this as a
+// whole is left out. Values below 1 keep every chunk.
+func (di *DocumentIngester) SetMinChunkSize(words int) {
+	di.minChunkSize = words
+}
+
+// chunk splits a processed document's sections into chunks
+func (di *DocumentIngester) chunk(sections []markdownSection) []string {
+	codeChunkSize := di.codeChunkSize
+	if codeChunkSize < 1 {
+		codeChunkSize = max(di.chunkSize/2, 1)
 	}
+	return mergeShortChunks(chunkSections(sections, di.chunkSize, codeChunkSize, di.chunkOverlap), di.minChunkSize)
+}
+
+// SetEmbedTitles controls whether each document's title is embedded alongside its chunks,
+// which lets VectorStore.SetTitleBoost blend title similarity into search scores
+func (di *DocumentIngester) SetEmbedTitles(enabled bool) {
+	di.embedTitles = enabled
 }
 
-// IngestFromGitHub downloads and processes documentation from GitHub
-func (di *DocumentIngester) IngestFromGitHub() error {
-	log.Printf("Starting document ingestion from OpenTDF docs repository...")
-	
-	// List of important documentation files to ingest
-	docFiles := []string{
-		"README.md",
-		"platform/README.md",
-		"platform/getting-started.md",
-		"platform/configuration.md",
-		"platform/deployment.md",
-		"platform/architecture.md",
-		"platform/security.md",
-		"sdk/README.md",
-		"sdk/getting-started.md",
-		"sdk/javascript.md",
-		"sdk/python.md",
-		"sdk/go.md",
-		"sdk/java.md",
-		"protocol/README.md",
-		"protocol/tdf-spec.md",
-		"protocol/kas.md",
-		"protocol/policy.md",
-		"protocol/attributes.md",
-		"spec/README.md",
-		"spec/ztdf.md",
-		"spec/nano-tdf.md",
+// SetLanguage makes ingestion skip documents detected as written in a language other than lang,
+// an ISO 639-1 code such as "en". Documents too short to detect are kept. An empty lang keeps all documents.
+func (di *DocumentIngester) SetLanguage(lang string) {
+	di.language = strings.ToLower(lang)
+}
+
+// SetPathFilter limits ingestion to documents whose relative path matches one of the include
+// glob patterns, if any are given, and none of the exclude patterns. Patterns match the whole
+// path, the file name, or a parent directory, e.g. "guides/*.md", "CHANGELOG.md" or "vendor".
+func (di *DocumentIngester) SetPathFilter(include, exclude []string) error {
+	filter, err := newPathFilter(include, exclude)
+	if err != nil {
+		return err
 	}
+	di.pathFilter = filter
+	return nil
+}
+
+// SetSourceBoosts records a boost on the chunks of each document matching one of boosts,
+// which multiplies their retrieval score, e.g. to rank official spec docs above blog posts
+func (di *DocumentIngester) SetSourceBoosts(boosts SourceBoosts) {
+	di.boosts = boosts
+}
+
+// SetKeepCode keeps the code in fenced code blocks, minus the fences, so CLI examples and
+// config snippets are embedded and searchable. By default code blocks are replaced with
+// a [CODE_BLOCK] placeholder.
+func (di *DocumentIngester) SetKeepCode(enabled bool) {
+	di.keepCode = enabled
+}
+
+// SetKeepLinks keeps link URLs, appended to the anchor text in parentheses, so chat answers
+// can point at the source. By default only the anchor text is kept.
+func (di *DocumentIngester) SetKeepLinks(enabled bool) {
+	di.keepLinks = enabled
+}
+
+// SetMaxFileSize skips files larger than n bytes, so one huge generated file can't dominate
+// the index or exhaust memory. 0 or less removes the limit.
+func (di *DocumentIngester) SetMaxFileSize(n int64) {
+	di.maxFileSize = n
+}
+
+// SetModifiedSince limits local ingestion to files modified after since, so a large doc tree
+// can be updated quickly. Files ingested again replace their earlier chunks. The zero time
+// ingests every file.
+func (di *DocumentIngester) SetModifiedSince(since time.Time) {
+	di.since = since
+}
+
+// ParseSince parses a cutoff given as a duration before now, such as "24h" or "7d", or as a
+// date, "2024-01-01", or time, "2024-01-01T09:00:00Z". Dates are midnight local time.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid cutoff %q: expected a duration such as 24h or 7d, or a date such as 2024-01-01", value)
+}
+
+// tooLarge reports whether a file of size bytes exceeds the maximum file size
+func (di *DocumentIngester) tooLarge(size int64) bool {
+	return di.maxFileSize > 0 && size > di.maxFileSize
+}
+
+// skipSize reports whether the document at relPath is too large to ingest, warning if so
+func (di *DocumentIngester) skipSize(relPath string, size int64) bool {
+	if !di.tooLarge(size) {
+		return false
+	}
+	di.logger.Printf("Warning: skipping %s: %d bytes exceeds the %d byte limit", relPath, size, di.maxFileSize)
+	return true
+}
+
+// skipModified reports whether the document at relPath, last modified at modTime, is older
+// than the modified-since cutoff
+func (di *DocumentIngester) skipModified(relPath string, modTime time.Time) bool {
+	if di.since.IsZero() || modTime.After(di.since) {
+		return false
+	}
+	di.logger.Printf("Skipping %s: not modified since %s", relPath, di.since.Format(time.RFC3339))
+	return true
+}
+
+// skipPath reports whether the path filter rejects the document at relPath
+func (di *DocumentIngester) skipPath(relPath string) bool {
+	if di.pathFilter.allows(relPath) {
+		return false
+	}
+	di.logger.Printf("Skipping %s: excluded by --include/--exclude patterns", relPath)
+	return true
+}
+
+// skipLanguage records the document's detected language and reports whether the language filter rejects it
+func (di *DocumentIngester) skipLanguage(doc *Document) bool {
+	doc.Language = DetectLanguage(doc.Content)
+	if di.language == "" || doc.Language == "" || doc.Language == di.language {
+		return false
+	}
+	di.logger.Printf("Skipping %s: detected language %q, want %q", doc.FilePath, doc.Language, di.language)
+	return true
+}
+
+// titleEmbedding embeds a document title when title embedding is enabled
+func (di *DocumentIngester) titleEmbedding(title string) []float32 {
+	if !di.embedTitles {
+		return nil
+	}
+
+	embedding, err := di.embeddingEngine.GenerateEmbedding(title)
+	if err != nil {
+		di.logger.Printf("Warning: failed to generate title embedding for %q: %v", title, err)
+		return nil
+	}
+	return embedding
+}
+
+// embeddingProbe is embedded to learn the size of the embedding model's vectors
+const embeddingProbe = "OpenTDF"
+
+// checkEmbeddingDim embeds a probe string and fails if the vectors the embedding model
+// produces don't match the size of those already in the index, so a misconfigured run stops
+// before any documents are downloaded or processed. An empty index accepts any size.
+func (di *DocumentIngester) checkEmbeddingDim() error {
+	indexDim := di.vectorStore.EmbeddingDim()
+	if indexDim == 0 {
+		return nil
+	}
+	embedding, err := di.embeddingEngine.GenerateEmbedding(embeddingProbe)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEmbedding, err)
+	}
+	if len(embedding) != indexDim {
+		return fmt.Errorf("%w: the index holds %d-dimension embeddings but the embedding model produces %d; use the model the index was built with, or ingest into a new index",
+			ErrDimensionMismatch, indexDim, len(embedding))
+	}
+	return nil
+}
+
+// IngestFromGitHub downloads and processes documentation from GitHub.
+// Cancelling ctx stops ingestion early, leaving the chunks processed so far in the vector store.
+func (di *DocumentIngester) IngestFromGitHub(ctx context.Context) error {
+	if err := di.checkEmbeddingDim(); err != nil {
+		return err
+	}
+	
+	di.logger.Printf("Starting document ingestion from OpenTDF docs repository...")
 	
 	// Create cache directory
 	if err := os.MkdirAll(di.localCachDir, 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %v", err)
+		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 	
 	totalProcessed := 0
 	
-	for _, filePath := range docFiles {
-		log.Printf("Processing: %s", filePath)
+	for _, filePath := range githubDocFiles {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w after %d chunks: %w", ErrIngestionInterrupted, totalProcessed, err)
+		}
+		if di.skipPath(filePath) {
+			continue
+		}
 		
-		doc, err := di.fetchAndProcessDocument(filePath)
+		di.logger.Printf("Processing: %s", filePath)
+		
+		doc, sections, err := di.fetchAndProcessDocument(filePath)
 		if err != nil {
-			log.Printf("Warning: failed to process %s: %v", filePath, err)
+			di.logger.Printf("Warning: failed to process %s: %v", filePath, err)
 			continue
 		}
 		
-		if doc != nil {
-			chunks := ChunkText(doc.Content, di.chunkSize, di.chunkOverlap)
+		if doc != nil && !di.skipLanguage(doc) {
+			chunks := di.chunk(sections)
+			titleEmbedding := di.titleEmbedding(doc.Title)
 			
 			for i, chunk := range chunks {
+				if err := ctx.Err(); err != nil {
+					return fmt.Errorf("%w after %d chunks: %w", ErrIngestionInterrupted, totalProcessed, err)
+				}
 				if strings.TrimSpace(chunk) == "" {
 					continue
 				}
 				
 				chunkDoc := Document{
-					ID:          fmt.Sprintf("%s_chunk_%d", doc.ID, i),
+					ID:          chunkID(doc.ID, i),
 					Title:       fmt.Sprintf("%s (Part %d/%d)", doc.Title, i+1, len(chunks)),
 					Content:     chunk,
 					URL:         doc.URL,
 					FilePath:    doc.FilePath,
 					ChunkIndex:  i,
 					TotalChunks: len(chunks),
+					Language:    doc.Language,
+					ParentID:    doc.ID,
+					Boost:       di.boosts.Weight(filePath),
 				}
 				
 				// Generate embedding for the chunk
 				embedding, err := di.embeddingEngine.GenerateEmbedding(chunk)
 				if err != nil {
-					log.Printf("Warning: failed to generate embedding for %s chunk %d: %v", filePath, i, err)
+					di.logger.Printf("Warning: failed to generate embedding for %s chunk %d: %v", filePath, i, err)
 					continue
 				}
 				
 				chunkDoc.Embedding = embedding
+				chunkDoc.TitleEmbedding = titleEmbedding
 				
 				if err := di.vectorStore.AddDocument(chunkDoc); err != nil {
-					log.Printf("Warning: failed to add document chunk to vector store: %v", err)
+					di.logger.Printf("Warning: failed to add document chunk to vector store: %v", err)
 					continue
 				}
 				
@@ -119,12 +351,13 @@ func (di *DocumentIngester) IngestFromGitHub() error {
 		}
 	}
 	
-	log.Printf("Successfully processed %d document chunks", totalProcessed)
+	di.logger.Printf("Successfully processed %d document chunks", totalProcessed)
 	return nil
 }
 
-// fetchAndProcessDocument downloads and processes a single document
-func (di *DocumentIngester) fetchAndProcessDocument(filePath string) (*Document, error) {
+// fetchAndProcessDocument downloads and processes a single document, returning it along with
+// the sections its content is made of
+func (di *DocumentIngester) fetchAndProcessDocument(filePath string) (*Document, []markdownSection, error) {
 	url := fmt.Sprintf("%s/%s", di.repoURL, filePath)
 	
 	// Check cache first
@@ -133,34 +366,39 @@ func (di *DocumentIngester) fetchAndProcessDocument(filePath string) (*Document,
 	var content string
 	var err error
 	
-	if _, statErr := os.Stat(cacheFile); statErr == nil {
+	if info, statErr := os.Stat(cacheFile); statErr == nil {
+		if di.tooLarge(info.Size()) {
+			return nil, nil, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, info.Size())
+		}
+		
 		// Load from cache
 		data, err := os.ReadFile(cacheFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read cached file: %v", err)
+			return nil, nil, fmt.Errorf("failed to read cached file: %w", err)
 		}
 		content = string(data)
-		log.Printf("Loaded from cache: %s", filePath)
+		di.logger.Printf("Loaded from cache: %s", filePath)
 	} else {
 		// Download from GitHub
 		content, err = di.downloadFile(url)
 		if err != nil {
-			return nil, fmt.Errorf("failed to download file: %v", err)
+			return nil, nil, fmt.Errorf("%w: %w", ErrDownload, err)
 		}
 		
 		// Save to cache
 		if err := os.WriteFile(cacheFile, []byte(content), 0644); err != nil {
-			log.Printf("Warning: failed to cache file %s: %v", filePath, err)
+			di.logger.Printf("Warning: failed to cache file %s: %v", filePath, err)
 		}
 		
-		log.Printf("Downloaded: %s", filePath)
+		di.logger.Printf("Downloaded: %s", filePath)
 		time.Sleep(100 * time.Millisecond) // Be nice to GitHub
 	}
 	
 	// Process the markdown content
-	processed := di.processMarkdown(content)
+	sections := di.processMarkdownSections(content)
+	processed := joinSections(sections)
 	if strings.TrimSpace(processed) == "" {
-		return nil, fmt.Errorf("processed content is empty")
+		return nil, nil, ErrEmptyDocument
 	}
 	
 	// Generate document ID
@@ -179,7 +417,7 @@ func (di *DocumentIngester) fetchAndProcessDocument(filePath string) (*Document,
 		Content:  processed,
 		URL:      url,
 		FilePath: filePath,
-	}, nil
+	}, sections, nil
 }
 
 // downloadFile downloads a file from a URL
@@ -195,39 +433,66 @@ func (di *DocumentIngester) downloadFile(url string) (string, error) {
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return "", fmt.Errorf("%w: HTTP %d: %s", ErrDownload, resp.StatusCode, resp.Status)
 	}
 	
-	body, err := io.ReadAll(resp.Body)
+	// Read one byte past the limit to tell a file at the limit from one over it
+	reader := io.Reader(resp.Body)
+	if di.maxFileSize > 0 {
+		reader = io.LimitReader(resp.Body, di.maxFileSize+1)
+	}
+	body, err := io.ReadAll(reader)
 	if err != nil {
 		return "", err
 	}
+	if di.tooLarge(int64(len(body))) {
+		return "", fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, di.maxFileSize)
+	}
 	
 	return string(body), nil
 }
 
 // processMarkdown cleans and processes markdown content for embedding
 func (di *DocumentIngester) processMarkdown(content string) string {
+	return joinSections(di.processMarkdownSections(content))
+}
+
+// processMarkdownSections cleans markdown content for embedding like processMarkdown, split
+// into sections. With keepCode each code block is a section of its own.
+func (di *DocumentIngester) processMarkdownSections(content string) []markdownSection {
 	// Remove YAML frontmatter
 	frontmatterRegex := regexp.MustCompile(`(?s)^---\n.*?\n---\n`)
 	content = frontmatterRegex.ReplaceAllString(content, "")
 	
-	// Remove code blocks but keep inline code
-	codeBlockRegex := regexp.MustCompile("(?s)```.*?```")
-	content = codeBlockRegex.ReplaceAllString(content, " [CODE_BLOCK] ")
+	// Set code blocks aside so the cleanup below doesn't mangle them, or drop them but keep inline code
+	var codeBlocks []string
+	if di.keepCode {
+		content, codeBlocks = StashCodeBlocks(content)
+	} else {
+		codeBlockRegex := regexp.MustCompile("(?s)```.*?```")
+		content = codeBlockRegex.ReplaceAllString(content, " [CODE_BLOCK] ")
+	}
+	
+	// Rewrite tables as "header: value" rows before their pipes are lost
+	content = FlattenTables(content)
 	
 	// Remove HTML tags
 	htmlRegex := regexp.MustCompile(`<[^>]*>`)
 	content = htmlRegex.ReplaceAllString(content, "")
 	
-	// Remove markdown links but keep text
-	linkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
-	content = linkRegex.ReplaceAllString(content, "$1")
-	
 	// Remove image references
 	imageRegex := regexp.MustCompile(`!\[[^\]]*\]\([^)]+\)`)
 	content = imageRegex.ReplaceAllString(content, "")
 	
+	// Set links aside as "text (url)", or remove them but keep text
+	var links []string
+	if di.keepLinks {
+		content, links = StashLinks(content)
+	} else {
+		linkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+		content = linkRegex.ReplaceAllString(content, "$1")
+	}
+	
 	// Clean up markdown formatting
 	content = regexp.MustCompile(`#{1,6}\s*`).ReplaceAllString(content, "") // Remove headers
 	content = regexp.MustCompile(`\*{1,2}([^*]+)\*{1,2}`).ReplaceAllString(content, "$1") // Remove bold/italic
@@ -248,7 +513,8 @@ func (di *DocumentIngester) processMarkdown(content string) string {
 		}
 	}
 	
-	return strings.Join(cleanLines, "\n")
+	content = RestoreLinks(strings.Join(cleanLines, "\n"), links)
+	return splitCodeSections(content, codeBlocks)
 }
 
 // extractTitle extracts the title from markdown content
@@ -268,92 +534,249 @@ func (di *DocumentIngester) extractTitle(content string) string {
 	return ""
 }
 
-// IngestFromLocalDirectory ingests documentation from a local directory
-func (di *DocumentIngester) IngestFromLocalDirectory(dirPath string) error {
-	log.Printf("Starting document ingestion from local directory: %s", dirPath)
+// walkLocalDocs calls fn for each document under root: every markdown file the filter allows
+// when root is a directory, or root itself when it is a single .md or .txt file. relPath is
+// relative to root, or the file name for a single file.
+func (di *DocumentIngester) walkLocalDocs(root string, fn func(path, relPath string) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		switch strings.ToLower(filepath.Ext(root)) {
+		case ".md", ".txt":
+			if di.skipSize(filepath.Base(root), info.Size()) || di.skipModified(filepath.Base(root), info.ModTime()) {
+				return nil
+			}
+			return fn(root, filepath.Base(root))
+		default:
+			return fmt.Errorf("%s is not a .md or .txt file", root)
+		}
+	}
+	
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".md") {
+			return nil
+		}
+		relPath, _ := filepath.Rel(root, path)
+		if di.skipPath(relPath) || di.skipSize(relPath, info.Size()) || di.skipModified(relPath, info.ModTime()) {
+			return nil
+		}
+		return fn(path, relPath)
+	})
+}
+
+// IngestFromLocalDirectory ingests documentation from a local directory, or from a single
+// .md or .txt file when dirPath points at one.
+// Cancelling ctx stops ingestion early, leaving the chunks processed so far in the vector store.
+func (di *DocumentIngester) IngestFromLocalDirectory(ctx context.Context, dirPath string) error {
+	if err := di.checkEmbeddingDim(); err != nil {
+		return err
+	}
+	
+	di.logger.Printf("Starting document ingestion from local path: %s", dirPath)
 	
 	totalProcessed := 0
 	
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	err := di.walkLocalDocs(dirPath, func(path, relPath string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w after %d chunks: %w", ErrIngestionInterrupted, totalProcessed, err)
+		}
+		
+		di.logger.Printf("Processing: %s", relPath)
+		
+		content, err := os.ReadFile(path)
 		if err != nil {
-			return err
+			di.logger.Printf("Warning: failed to read %s: %v", path, err)
+			return nil
 		}
 		
-		// Only process markdown files
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
-			relPath, _ := filepath.Rel(dirPath, path)
-			log.Printf("Processing: %s", relPath)
-			
-			content, err := os.ReadFile(path)
-			if err != nil {
-				log.Printf("Warning: failed to read %s: %v", path, err)
-				return nil
+		sections := di.processMarkdownSections(string(content))
+		processed := joinSections(sections)
+		if strings.TrimSpace(processed) == "" {
+			return nil
+		}
+		
+		// Generate document ID
+		hash := sha256.Sum256([]byte(relPath))
+		docID := hex.EncodeToString(hash[:])[:16]
+		
+		title := di.extractTitle(string(content))
+		if title == "" {
+			title = filepath.Base(path)
+		}
+		
+		doc := Document{
+			ID:       docID,
+			Title:    title,
+			Content:  processed,
+			URL:      fmt.Sprintf("file://%s", path),
+			FilePath: relPath,
+		}
+		if di.skipLanguage(&doc) {
+			return nil
+		}
+		
+		// Only changed files are read with a cutoff, so each one replaces its earlier chunks
+		if !di.since.IsZero() {
+			if removed := di.vectorStore.RemoveDocuments(DocumentFilter{SourceFile: relPath}); removed > 0 {
+				di.logger.Printf("Replacing %d chunks of %s", removed, relPath)
 			}
-			
-			processed := di.processMarkdown(string(content))
-			if strings.TrimSpace(processed) == "" {
-				return nil
+		}
+		
+		chunks := di.chunk(sections)
+		titleEmbedding := di.titleEmbedding(doc.Title)
+		
+		for i, chunk := range chunks {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("%w after %d chunks: %w", ErrIngestionInterrupted, totalProcessed, err)
+			}
+			if strings.TrimSpace(chunk) == "" {
+				continue
 			}
 			
-			// Generate document ID
-			hash := sha256.Sum256([]byte(relPath))
-			docID := hex.EncodeToString(hash[:])[:16]
-			
-			title := di.extractTitle(string(content))
-			if title == "" {
-				title = filepath.Base(path)
+			chunkDoc := Document{
+				ID:          chunkID(doc.ID, i),
+				Title:       fmt.Sprintf("%s (Part %d/%d)", doc.Title, i+1, len(chunks)),
+				Content:     chunk,
+				URL:         doc.URL,
+				FilePath:    doc.FilePath,
+				ChunkIndex:  i,
+				TotalChunks: len(chunks),
+				Language:    doc.Language,
+				ParentID:    doc.ID,
+				Boost:       di.boosts.Weight(relPath),
 			}
 			
-			doc := Document{
-				ID:       docID,
-				Title:    title,
-				Content:  processed,
-				URL:      fmt.Sprintf("file://%s", path),
-				FilePath: relPath,
+			// Generate embedding for the chunk
+			embedding, err := di.embeddingEngine.GenerateEmbedding(chunk)
+			if err != nil {
+				di.logger.Printf("Warning: failed to generate embedding for %s chunk %d: %v", relPath, i, err)
+				continue
 			}
 			
-			chunks := ChunkText(doc.Content, di.chunkSize, di.chunkOverlap)
+			chunkDoc.Embedding = embedding
+			chunkDoc.TitleEmbedding = titleEmbedding
 			
-			for i, chunk := range chunks {
-				if strings.TrimSpace(chunk) == "" {
-					continue
-				}
-				
-				chunkDoc := Document{
-					ID:          fmt.Sprintf("%s_chunk_%d", doc.ID, i),
-					Title:       fmt.Sprintf("%s (Part %d/%d)", doc.Title, i+1, len(chunks)),
-					Content:     chunk,
-					URL:         doc.URL,
-					FilePath:    doc.FilePath,
-					ChunkIndex:  i,
-					TotalChunks: len(chunks),
-				}
-				
-				// Generate embedding for the chunk
-				embedding, err := di.embeddingEngine.GenerateEmbedding(chunk)
-				if err != nil {
-					log.Printf("Warning: failed to generate embedding for %s chunk %d: %v", relPath, i, err)
-					continue
-				}
-				
-				chunkDoc.Embedding = embedding
-				
-				if err := di.vectorStore.AddDocument(chunkDoc); err != nil {
-					log.Printf("Warning: failed to add document chunk to vector store: %v", err)
-					continue
-				}
-				
-				totalProcessed++
+			if err := di.vectorStore.AddDocument(chunkDoc); err != nil {
+				di.logger.Printf("Warning: failed to add document chunk to vector store: %v", err)
+				continue
 			}
+			
+			totalProcessed++
 		}
 		
 		return nil
 	})
 	
+	if errors.Is(err, ErrIngestionInterrupted) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("failed to walk directory: %v", err)
+		return fmt.Errorf("failed to read local docs: %w", err)
 	}
 	
-	log.Printf("Successfully processed %d document chunks from local directory", totalProcessed)
+	di.logger.Printf("Successfully processed %d document chunks from %s", totalProcessed, dirPath)
 	return nil
-}
\ No newline at end of file
+}
+
+// FilePlan describes how one source file would be ingested
+type FilePlan struct {
+	FilePath        string `json:"file_path"`
+	Chunks          int    `json:"chunks"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// IngestPlan previews an ingestion run: which files would be ingested and how many chunks they produce
+type IngestPlan struct {
+	Files           []FilePlan `json:"files"`
+	TotalChunks     int        `json:"total_chunks"`
+	EstimatedTokens int        `json:"estimated_tokens"`
+}
+
+// NewFilePlan counts the non-empty chunks of a file and estimates how many tokens embedding them takes
+func NewFilePlan(filePath string, chunks []string) FilePlan {
+	plan := FilePlan{FilePath: filePath}
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		plan.Chunks++
+		plan.EstimatedTokens += estimateTokens(chunk)
+	}
+	return plan
+}
+
+// Add records a file in the plan
+func (p *IngestPlan) Add(file FilePlan) {
+	p.Files = append(p.Files, file)
+	p.TotalChunks += file.Chunks
+	p.EstimatedTokens += file.EstimatedTokens
+}
+
+// PlanFromGitHub fetches and chunks the OpenTDF docs like IngestFromGitHub, without embedding anything
+func (di *DocumentIngester) PlanFromGitHub(ctx context.Context) (*IngestPlan, error) {
+	if err := os.MkdirAll(di.localCachDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	plan := &IngestPlan{}
+	for _, filePath := range githubDocFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrIngestionInterrupted, err)
+		}
+		if di.skipPath(filePath) {
+			continue
+		}
+
+		doc, sections, err := di.fetchAndProcessDocument(filePath)
+		if err != nil {
+			di.logger.Printf("Warning: failed to process %s: %v", filePath, err)
+			continue
+		}
+		if di.skipLanguage(doc) {
+			continue
+		}
+		plan.Add(NewFilePlan(filePath, di.chunk(sections)))
+	}
+	return plan, nil
+}
+
+// PlanFromLocalDirectory chunks the markdown files under dirPath, or the single file it
+// points at, like IngestFromLocalDirectory, without embedding anything
+func (di *DocumentIngester) PlanFromLocalDirectory(ctx context.Context, dirPath string) (*IngestPlan, error) {
+	plan := &IngestPlan{}
+	err := di.walkLocalDocs(dirPath, func(path, relPath string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrIngestionInterrupted, err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			di.logger.Printf("Warning: failed to read %s: %v", path, err)
+			return nil
+		}
+
+		sections := di.processMarkdownSections(string(content))
+		processed := joinSections(sections)
+		if strings.TrimSpace(processed) == "" {
+			return nil
+		}
+		if di.skipLanguage(&Document{FilePath: relPath, Content: processed}) {
+			return nil
+		}
+		plan.Add(NewFilePlan(relPath, di.chunk(sections)))
+		return nil
+	})
+
+	if errors.Is(err, ErrIngestionInterrupted) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local docs: %w", err)
+	}
+	return plan, nil
+}
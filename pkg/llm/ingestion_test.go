@@ -0,0 +1,567 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubEmbedder returns a fixed vector and optionally cancels a context after a number of calls
+type stubEmbedder struct {
+	calls       int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (s *stubEmbedder) GenerateEmbedding(string) ([]float32, error) {
+	s.calls++
+	if s.cancel != nil && s.calls == s.cancelAfter {
+		s.cancel()
+	}
+	return []float32{1, 0, 0}, nil
+}
+
+func writeDocs(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 0; i < n; i++ {
+		content := fmt.Sprintf("# Doc %d\n\nSome documentation about attribute %d.\n", i, i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("doc%d.md", i)), []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestIngestFromLocalDirectory(t *testing.T) {
+	dir := writeDocs(t, 3)
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.Equal(t, 3, store.GetDocumentCount())
+}
+
+func TestIngestSingleFile(t *testing.T) {
+	dir := writeDocs(t, 3)
+	long := "# Key Access Service\n\n" + strings.Repeat("The key access service rewraps keys for entitled clients. ", 120)
+	file := filepath.Join(dir, "kas.md")
+	require.NoError(t, os.WriteFile(file, []byte(long), 0o600))
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), file)
+	require.NoError(t, err)
+	require.Len(t, plan.Files, 1)
+	assert.Equal(t, "kas.md", plan.Files[0].FilePath)
+	require.Greater(t, plan.TotalChunks, 1)
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), file))
+
+	// Only the chunks of that file are ingested, not its neighbours
+	docs := store.ListDocuments(DocumentFilter{})
+	assert.Len(t, docs, plan.TotalChunks)
+	for _, doc := range docs {
+		assert.Equal(t, "kas.md", doc.FilePath)
+	}
+}
+
+func TestIngestRecordsParentID(t *testing.T) {
+	dir := t.TempDir()
+	long := "# Key Access Service\n\n" + strings.Repeat("The key access service rewraps keys for entitled clients. ", 120)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kas.md"), []byte(long), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policy.md"), []byte("# Policy\n\nAttributes and subject mappings."), 0o600))
+
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			indexDir := t.TempDir()
+			store := newStore(indexDir)
+			require.NoError(t, store.LoadIndex())
+			ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+			require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+			require.NoError(t, store.SaveIndex())
+			require.NoError(t, store.Close())
+
+			// Reopening shows the parent IDs were persisted
+			store = newStore(indexDir)
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+
+			parents := map[string]map[string]bool{}
+			for _, info := range store.ListDocuments(DocumentFilter{}) {
+				doc, ok := store.GetDocument(info.ID)
+				require.True(t, ok)
+				require.NotEmpty(t, doc.ParentID, doc.ID)
+				assert.Equal(t, chunkID(doc.ParentID, doc.ChunkIndex), doc.ID)
+				if parents[doc.FilePath] == nil {
+					parents[doc.FilePath] = map[string]bool{}
+				}
+				parents[doc.FilePath][doc.ParentID] = true
+			}
+			require.Len(t, parents, 2)
+			assert.Len(t, parents["kas.md"], 1, "every chunk of a file shares its parent")
+			assert.Len(t, parents["policy.md"], 1)
+			assert.NotEqual(t, parents["kas.md"], parents["policy.md"])
+		})
+	}
+}
+
+func TestIngestSingleTextFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(file, []byte("Subject mappings connect entitlements to attribute values."), 0o600))
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), file))
+	assert.Equal(t, 1, store.GetDocumentCount())
+
+	other := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(other, []byte("{}"), 0o600))
+	require.Error(t, ingester.IngestFromLocalDirectory(context.Background(), other))
+	_, err := ingester.PlanFromLocalDirectory(context.Background(), other)
+	require.Error(t, err)
+}
+
+func TestIngestInterruptPersistsPartialIndex(t *testing.T) {
+	dir := writeDocs(t, 5)
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	store := NewVectorStore(indexPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	embedder := &stubEmbedder{cancelAfter: 2, cancel: cancel}
+	ingester := NewDocumentIngester(store, embedder, t.TempDir())
+
+	err := ingester.IngestFromLocalDirectory(ctx, dir)
+	require.ErrorIs(t, err, ErrIngestionInterrupted)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The interrupt handler saves whatever was ingested before the signal
+	require.NoError(t, store.SaveIndex())
+
+	reloaded := NewVectorStore(indexPath)
+	require.NoError(t, reloaded.LoadIndex())
+	assert.Equal(t, 2, reloaded.GetDocumentCount())
+}
+
+func TestIngestEmbedsTitles(t *testing.T) {
+	dir := writeDocs(t, 2)
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	embedder := &stubEmbedder{}
+	ingester := NewDocumentIngester(store, embedder, t.TempDir())
+	ingester.SetEmbedTitles(true)
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	// One title embedding plus one chunk embedding per document
+	assert.Equal(t, 4, embedder.calls)
+	for _, doc := range store.documents {
+		assert.NotEmpty(t, doc.TitleEmbedding)
+	}
+}
+
+func TestPlanFromLocalDirectory(t *testing.T) {
+	dir := writeDocs(t, 3)
+	long := "# Long\n\n" + strings.Repeat("word ", 700)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "long.md"), []byte(long), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not markdown"), 0o600))
+
+	// Planning never touches the embedder or the store, so neither is needed
+	embedder := &stubEmbedder{}
+	ingester := NewDocumentIngester(nil, embedder, t.TempDir())
+
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Zero(t, embedder.calls)
+
+	require.Len(t, plan.Files, 4)
+	chunks := make(map[string]int)
+	for _, file := range plan.Files {
+		chunks[file.FilePath] = file.Chunks
+		assert.Positive(t, file.EstimatedTokens)
+	}
+	assert.Equal(t, 1, chunks["doc0.md"])
+	assert.Equal(t, 3, chunks["long.md"])
+	assert.Equal(t, 6, plan.TotalChunks)
+	assert.Greater(t, plan.EstimatedTokens, 700)
+}
+
+func TestNewFilePlanSkipsEmptyChunks(t *testing.T) {
+	plan := NewFilePlan("a.md", []string{"abcdefgh", "  ", "abcd"})
+	assert.Equal(t, FilePlan{FilePath: "a.md", Chunks: 2, EstimatedTokens: 3}, plan)
+}
+
+func TestIngestFiltersByLanguage(t *testing.T) {
+	dir := t.TempDir()
+	docs := map[string]string{
+		"kas.md":    "# KAS\n\nThe Key Access Service holds the keys that protect each TDF and decides whether a client is entitled to decrypt the data.\n",
+		"kas.de.md": "# KAS\n\nDer Schlüsselzugriffsdienst verwaltet die Schlüssel, die jede TDF schützen, und entscheidet, ob ein Client die Daten entschlüsseln darf.\n",
+		"kas.fr.md": "# KAS\n\nLe service d'accès aux clés conserve les clés qui protègent chaque TDF et décide si un client peut déchiffrer les données.\n",
+		"short.md":  "# Short\n\nKAS\n",
+	}
+	for name, content := range docs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	embedder := &stubEmbedder{}
+	ingester := NewDocumentIngester(store, embedder, t.TempDir())
+	ingester.SetLanguage("en")
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+	// The translations are skipped before embedding; text too short to detect is kept
+	assert.Equal(t, 2, embedder.calls)
+	languages := make(map[string]string)
+	for _, doc := range store.documents {
+		languages[doc.FilePath] = doc.Language
+	}
+	assert.Equal(t, map[string]string{"kas.md": "en", "short.md": ""}, languages)
+
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Len(t, plan.Files, 2)
+}
+
+func TestIngestRecordsLanguageWithoutFilter(t *testing.T) {
+	dir := t.TempDir()
+	content := "# KAS\n\nDer Schlüsselzugriffsdienst verwaltet die Schlüssel, die jede TDF schützen, und entscheidet, ob ein Client die Daten entschlüsseln darf.\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kas.md"), []byte(content), 0o600))
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	require.Len(t, store.documents, 1)
+	assert.Equal(t, "de", store.documents[0].Language)
+}
+
+func TestIngestPathFilter(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":                "# Readme\n\nOverview of the platform.",
+		"CHANGELOG.md":             "# Changelog\n\nFixed things.",
+		"guides/kas.md":            "# KAS\n\nKey access service guide.",
+		"guides/policy.md":         "# Policy\n\nAttribute policy guide.",
+		"guides/CHANGELOG.md":      "# Guide changelog\n\nMore fixes.",
+		"vendor/lib/README.md":     "# Vendored\n\nThird party docs.",
+		"reference/obligations.md": "# Obligations\n\nObligation reference.",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	ingested := func(include, exclude []string) []string {
+		store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+		ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+		require.NoError(t, ingester.SetPathFilter(include, exclude))
+		require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+		var paths []string
+		for _, doc := range store.ListDocuments(DocumentFilter{}) {
+			paths = append(paths, filepath.ToSlash(doc.FilePath))
+		}
+		return paths
+	}
+
+	// Excluded file names and directories are skipped at any depth
+	assert.ElementsMatch(t,
+		[]string{"README.md", "guides/kas.md", "guides/policy.md", "reference/obligations.md"},
+		ingested(nil, []string{"CHANGELOG.md", "vendor"}))
+
+	// Only included paths are ingested, minus anything excluded
+	assert.ElementsMatch(t,
+		[]string{"guides/kas.md", "guides/policy.md"},
+		ingested([]string{"guides/*.md"}, []string{"CHANGELOG.md"}))
+	assert.ElementsMatch(t,
+		[]string{"guides/kas.md", "guides/policy.md", "guides/CHANGELOG.md", "reference/obligations.md"},
+		ingested([]string{"guides", "reference"}, nil))
+
+	// The dry run plan applies the same filter
+	ingester := NewDocumentIngester(nil, nil, t.TempDir())
+	require.NoError(t, ingester.SetPathFilter([]string{"guides/*.md"}, []string{"CHANGELOG.md"}))
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Len(t, plan.Files, 2)
+
+	require.Error(t, ingester.SetPathFilter([]string{"guides/[.md"}, nil))
+}
+
+func TestIngestSourceBoosts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"spec/tdf.md", "blog/launch.md", "guides/kas.md"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("# "+name+"\n\nSome documentation about TDF."), 0o600))
+	}
+
+	boosts, err := ParseSourceBoosts([]string{"spec=1.5", "blog=0.8"})
+	require.NoError(t, err)
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetSourceBoosts(boosts)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+	got := map[string]float32{}
+	for _, info := range store.ListDocuments(DocumentFilter{}) {
+		doc, ok := store.GetDocument(info.ID)
+		require.True(t, ok)
+		got[filepath.ToSlash(doc.FilePath)] = doc.Boost
+	}
+	assert.Equal(t, map[string]float32{"spec/tdf.md": 1.5, "blog/launch.md": 0.8, "guides/kas.md": 0}, got)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for value, want := range map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"90m":                  now.Add(-90 * time.Minute),
+		"7d":                   now.AddDate(0, 0, -7),
+		"2024-01-01":           time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		"2024-01-01T09:00:00Z": time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+	} {
+		got, err := ParseSince(value, now)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(got), "%s: got %s, want %s", value, got, want)
+	}
+
+	for _, value := range []string{"", "yesterday", "-24h", "-1d", "2024-13-01"} {
+		_, err := ParseSince(value, now)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestIngestModifiedSince(t *testing.T) {
+	dir := writeDocs(t, 3)
+	cutoff := time.Now().Add(-24 * time.Hour)
+	old := cutoff.Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "doc0.md"), old, old))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "doc1.md"), old, old))
+
+	paths := func(store Store) []string {
+		var paths []string
+		for _, doc := range store.ListDocuments(DocumentFilter{}) {
+			paths = append(paths, doc.FilePath)
+		}
+		return paths
+	}
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetModifiedSince(cutoff)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.Equal(t, []string{"doc2.md"}, paths(store), "older files are skipped")
+
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, plan.Files, 1)
+	assert.Equal(t, "doc2.md", plan.Files[0].FilePath)
+
+	// An index built in full is brought up to date with just the changed file
+	store = NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, NewDocumentIngester(store, &stubEmbedder{}, t.TempDir()).IngestFromLocalDirectory(context.Background(), dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "doc1.md"), []byte("# Doc 1\n\nRewritten documentation about obligations.\n"), 0o600))
+
+	ingester = NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetModifiedSince(cutoff)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.ElementsMatch(t, []string{"doc0.md", "doc1.md", "doc2.md"}, paths(store), "re-ingested files replace their earlier chunks")
+	docs := store.ListDocuments(DocumentFilter{SourceFile: "doc1.md"})
+	require.Len(t, docs, 1)
+	doc, ok := store.GetDocument(docs[0].ID)
+	require.True(t, ok)
+	assert.Contains(t, doc.Content, "obligations")
+
+	// A single file is skipped the same way
+	store = NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester = NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetModifiedSince(cutoff)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), filepath.Join(dir, "doc0.md")))
+	assert.Zero(t, store.GetDocumentCount())
+}
+
+func TestIngestSkipsFilesOverMaxSize(t *testing.T) {
+	dir := writeDocs(t, 2)
+	huge := "# Generated API reference\n\n" + strings.Repeat("field description ", 200)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "generated.md"), []byte(huge), 0o600))
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.SetMaxFileSize(1024)
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+	assert.Equal(t, 2, store.GetDocumentCount())
+	assert.Empty(t, store.ListDocuments(DocumentFilter{SourceFile: "generated.md"}))
+
+	// Without a limit the large file is ingested too
+	ingester.SetMaxFileSize(0)
+	plan, err := ingester.PlanFromLocalDirectory(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Len(t, plan.Files, 3)
+}
+
+func TestIngestFromGitHubSkipsFilesOverMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small.md":
+			fmt.Fprint(w, "# Small\n\nA short page about attributes.")
+		case "/large.md":
+			fmt.Fprint(w, "# Large\n\n"+strings.Repeat("generated ", 500))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	orig := githubDocFiles
+	githubDocFiles = []string{"small.md", "large.md"}
+	defer func() { githubDocFiles = orig }()
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	ingester := NewDocumentIngester(store, &stubEmbedder{}, t.TempDir())
+	ingester.repoURL = server.URL
+	ingester.SetMaxFileSize(1024)
+
+	require.NoError(t, ingester.IngestFromGitHub(context.Background()))
+	docs := store.ListDocuments(DocumentFilter{})
+	require.Len(t, docs, 1)
+	assert.Equal(t, "small.md", docs[0].FilePath)
+
+	_, _, err := ingester.fetchAndProcessDocument("large.md")
+	require.ErrorIs(t, err, ErrFileTooLarge)
+}
+
+func TestIngestChecksEmbeddingDimFirst(t *testing.T) {
+	dir := writeDocs(t, 2)
+	store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(Document{ID: "existing", Embedding: []float32{1, 0}}))
+
+	// The stub embeds in 3 dimensions, the index holds 2
+	embedder := &stubEmbedder{}
+	ingester := NewDocumentIngester(store, embedder, t.TempDir())
+	err := ingester.IngestFromLocalDirectory(context.Background(), dir)
+	require.ErrorIs(t, err, ErrDimensionMismatch)
+	assert.Contains(t, err.Error(), "holds 2-dimension embeddings but the embedding model produces 3")
+	assert.Equal(t, 1, embedder.calls, "only the probe is embedded")
+	assert.Equal(t, 1, store.GetDocumentCount())
+
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, "# Attributes\n\nAttributes define access.")
+	}))
+	defer server.Close()
+	ingester.repoURL = server.URL
+	require.ErrorIs(t, ingester.IngestFromGitHub(context.Background()), ErrDimensionMismatch)
+	assert.Zero(t, downloads, "nothing is downloaded")
+
+	// An index of the same size is added to
+	store = NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(Document{ID: "existing", Embedding: []float32{0, 1, 0}}))
+	embedder = &stubEmbedder{}
+	ingester = NewDocumentIngester(store, embedder, t.TempDir())
+	require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+	assert.Equal(t, 3, store.GetDocumentCount())
+	assert.Equal(t, 3, embedder.calls, "the probe and one chunk per document")
+}
+
+func TestProcessMarkdownKeepCode(t *testing.T) {
+	content := "# Subject Condition Sets\n\nCreate one with the CLI:\n\n```shell\n# create the set\notdfctl policy subject-condition-sets create --subject-sets '[{\"condition_groups\":[]}]'\n```\n\nThen map it.\n"
+
+	ingester := NewDocumentIngester(nil, nil, t.TempDir())
+	dropped := ingester.processMarkdown(content)
+	assert.Contains(t, dropped, "[CODE_BLOCK]")
+	assert.NotContains(t, dropped, "subject-condition-sets create")
+
+	ingester.SetKeepCode(true)
+	kept := ingester.processMarkdown(content)
+	assert.NotContains(t, kept, "[CODE_BLOCK]")
+	assert.NotContains(t, kept, "```")
+	// Code is left as written, comments and quoting included
+	assert.Contains(t, kept, "# create the set\notdfctl policy subject-condition-sets create --subject-sets '[{\"condition_groups\":[]}]'")
+	assert.Contains(t, kept, "Then map it.")
+}
+
+func TestProcessMarkdownKeepLinks(t *testing.T) {
+	content := "# Policy\n\nSee the [policy docs](https://opentdf.io/components/policy#attributes) and the **[KAS guide](./kas.md \"Key Access\")**.\n\n![architecture](diagram.png)\n"
+
+	ingester := NewDocumentIngester(nil, nil, t.TempDir())
+	stripped := ingester.processMarkdown(content)
+	assert.Contains(t, stripped, "See the policy docs and the KAS guide.")
+	assert.NotContains(t, stripped, "https://")
+	assert.NotContains(t, stripped, "diagram.png")
+
+	ingester.SetKeepLinks(true)
+	kept := ingester.processMarkdown(content)
+	// The URL fragment survives the header cleanup
+	assert.Contains(t, kept, "See the policy docs (https://opentdf.io/components/policy#attributes) and the KAS guide (./kas.md).")
+	assert.NotContains(t, kept, "architecture")
+	assert.NotContains(t, kept, "diagram.png")
+}
+
+func TestProcessMarkdownTables(t *testing.T) {
+	content := "## Attribute Rules\n\n| Rule | Access is granted when |\n| :--- | --- |\n| `ALL_OF` | the entity has **every** value |\n| ANY_OF | the entity has at least one value |\n| HIERARCHY | a value ranks at or above the resource's \\| highest |\n| UNSPECIFIED | |\n\nRules are set per attribute.\n"
+
+	processed := NewDocumentIngester(nil, nil, t.TempDir()).processMarkdown(content)
+	assert.Equal(t, strings.Join([]string{
+		"Attribute Rules",
+		"Rule: ALL_OF; Access is granted when: the entity has every value",
+		"Rule: ANY_OF; Access is granted when: the entity has at least one value",
+		"Rule: HIERARCHY; Access is granted when: a value ranks at or above the resource's | highest",
+		"Rule: UNSPECIFIED",
+		"Rules are set per attribute.",
+	}, "\n"), processed)
+}
+
+// wordEmbedder embeds text as a bag of hashed words, so texts sharing words are similar
+type wordEmbedder struct{}
+
+func (wordEmbedder) GenerateEmbedding(text string) ([]float32, error) {
+	vector := make([]float32, 256)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r == '-')
+	}) {
+		hash := sha256.Sum256([]byte(word))
+		vector[hash[0]]++
+	}
+	return vector, nil
+}
+
+func TestKeepCodeMakesCommandsRetrievable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scs.md"), []byte(
+		"# Subject Condition Sets\n\nConditions are evaluated against entity claims.\n\n```bash\notdfctl policy subject-condition-sets create --subject-sets-file-json scs.json\n```\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kas.md"), []byte(
+		"# Key Access Service\n\nThe key access service rewraps keys for entitled clients.\n"), 0o600))
+
+	query, err := wordEmbedder{}.GenerateEmbedding("otdfctl policy subject-condition-sets create")
+	require.NoError(t, err)
+
+	for _, keepCode := range []bool{false, true} {
+		store := NewVectorStore(filepath.Join(t.TempDir(), "index.json"))
+		ingester := NewDocumentIngester(store, wordEmbedder{}, t.TempDir())
+		ingester.SetKeepCode(keepCode)
+		require.NoError(t, ingester.IngestFromLocalDirectory(context.Background(), dir))
+
+		results, err := store.Search(query, 1)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		if keepCode {
+			assert.Equal(t, "scs.md", results[0].Document.FilePath)
+			assert.Contains(t, results[0].Document.Content, "subject-condition-sets create")
+		} else {
+			assert.NotContains(t, results[0].Document.Content, "subject-condition-sets create")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLanguageLetters is the least amount of text DetectLanguage will guess a language for
+const minLanguageLetters = 40
+
+// languageProfiles hold the most frequent trigrams of each language, most frequent first.
+// Word boundaries are written as '_'.
+var languageProfiles = map[string][]string{
+	"en": {"_th", "the", "he_", "_an", "and", "nd_", "_of", "of_", "ed_", "ing", "ng_", "_to", "to_", "_in", "er_",
+		"ion", "tio", "ent", "_is", "is_", "re_", "at_", "on_", "es_", "_be", "for", "_fo", "or_", "_co", "hat"},
+	"de": {"en_", "er_", "_de", "der", "ich", "ie_", "die", "_di", "sch", "ein", "che", "und", "_un", "nd_", "den",
+		"_ei", "cht", "ch_", "_da", "ung", "ine", "te_", "gen", "in_", "_ge", "ten", "es_", "_zu", "ver", "_ve"},
+	"fr": {"es_", "_de", "de_", "le_", "ent", "_le", "_la", "la_", "ion", "nt_", "les", "_pa", "re_", "_et", "et_",
+		"_co", "tio", "ons", "_qu", "que", "ue_", "_un", "our", "des", "_dé", "men", "_po", "par", "ne_", "_l'"},
+	"es": {"_de", "de_", "os_", "la_", "_la", "el_", "_el", "es_", "en_", "_co", "as_", "ión", "_en", "que", "_qu",
+		"ue_", "ado", "_lo", "los", "con", "ent", "_se", "_es", "ar_", "nte", "_un", "ien", "cio", "ara", "_pa"},
+	"it": {"_di", "di_", "_la", "la_", "che", "_ch", "to_", "_il", "il_", "_de", "del", "one", "ent", "re_", "_co",
+		"zio", "ion", "_e_", "_in", "no_", "ell", "lla", "_pe", "per", "are", "ato", "_un", "ne_", "_no", "ta_"},
+	"pt": {"_de", "de_", "os_", "_a_", "ão_", "_qu", "que", "ue_", "_co", "ção", "ent", "do_", "da_", "_da", "_do",
+		"_e_", "es_", "_pa", "ar_", "_se", "ara", "com", "_um", "nte", "as_", "_em", "em_", "men", "ica", "çõe"},
+}
+
+// DetectLanguage guesses the ISO 639-1 code of the language a text is written in.
+// Texts in CJK, Hangul, or Cyrillic script are identified by script; Latin-script texts are
+// compared against trigram profiles of common European languages. Returns "" when the text is
+// too short or does not resemble any known language.
+func DetectLanguage(text string) string {
+	var letters, han, kana, hangul, cyrillic int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Scripts written without spaces carry far more meaning per letter than Latin text
+	switch {
+	case (kana+han)*2 > letters && kana > 0:
+		return "ja"
+	case han*2 > letters:
+		return "zh"
+	case hangul*2 > letters:
+		return "ko"
+	case cyrillic*2 > letters:
+		return "ru"
+	}
+
+	if letters < minLanguageLetters {
+		return ""
+	}
+	return detectByTrigrams(text)
+}
+
+// detectByTrigrams scores each language profile by how often its trigrams appear in the text,
+// weighting a profile's most frequent trigrams highest
+func detectByTrigrams(text string) string {
+	counts := trigramCounts(text)
+
+	best, bestScore := "", 0
+	for lang, profile := range languageProfiles {
+		score := 0
+		for rank, trigram := range profile {
+			score += counts[trigram] * (len(profile) - rank)
+		}
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
+
+// trigramCounts counts the letter trigrams of each word in text, padded with '_' at word boundaries
+func trigramCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		padded := []rune("_" + word + "_")
+		for i := 0; i+3 <= len(padded); i++ {
+			counts[string(padded[i:i+3])]++
+		}
+	}
+	return counts
+}
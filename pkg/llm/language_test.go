@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "The Key Access Service holds the keys that protect each TDF and decides whether a client is entitled to decrypt the data.", "en"},
+		{"german", "Der Schlüsselzugriffsdienst verwaltet die Schlüssel, die jede TDF schützen, und entscheidet, ob ein Client die Daten entschlüsseln darf.", "de"},
+		{"french", "Le service d'accès aux clés conserve les clés qui protègent chaque TDF et décide si un client peut déchiffrer les données.", "fr"},
+		{"spanish", "El servicio de acceso a claves guarda las claves que protegen cada TDF y decide si un cliente puede descifrar los datos.", "es"},
+		{"italian", "Il servizio di accesso alle chiavi conserva le chiavi che proteggono ogni TDF e decide se un client può decifrare i dati.", "it"},
+		{"portuguese", "O serviço de acesso a chaves guarda as chaves que protegem cada TDF e decide se um cliente pode decifrar os dados com segurança.", "pt"},
+		{"japanese", "鍵アクセスサービスは各TDFを保護する鍵を保持します。", "ja"},
+		{"chinese", "密钥访问服务保存保护每个数据文件的密钥。", "zh"},
+		{"korean", "키 액세스 서비스는 각 TDF를 보호하는 키를 보관합니다.", "ko"},
+		{"russian", "Служба доступа к ключам хранит ключи, защищающие каждый файл.", "ru"},
+		{"too short", "Key Access", ""},
+		{"no letters", "1234 5678", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectLanguage(tt.text))
+		})
+	}
+}
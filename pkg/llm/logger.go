@@ -0,0 +1,17 @@
+package llm
+
+// Logger receives the package's internal diagnostic messages. A *log.Logger
+// satisfies this interface, so callers can route messages wherever they like.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// nopLogger discards all messages
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// NopLogger returns a Logger that discards all messages
+func NopLogger() Logger {
+	return nopLogger{}
+}
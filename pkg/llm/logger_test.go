@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (r *recordingLogger) Printf(format string, args ...interface{}) {
+	r.messages = append(r.messages, format)
+}
+
+func TestVectorStoreLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	store := NewVectorStore(filepath.Join(t.TempDir(), "missing.json"))
+	store.SetLogger(logger)
+
+	require.NoError(t, store.LoadIndex())
+	require.Len(t, logger.messages, 1)
+	assert.Contains(t, logger.messages[0], "Vector index not found")
+}
+
+func TestSimpleRAGStoreStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "simple.json"))
+	store.SetLogger(log.New(&buf, "", 0))
+
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "doc", Content: "content"}))
+	require.NoError(t, store.SaveIndex())
+	assert.Contains(t, buf.String(), "Saved simple RAG index with 1 documents")
+}
+
+func TestDefaultLoggerIsSilent(t *testing.T) {
+	var buf bytes.Buffer
+	orig := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(orig)
+
+	store := NewVectorStore(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, store.LoadIndex())
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics records inference and retrieval measurements
+type Metrics interface {
+	// ObserveRequest counts a chat request
+	ObserveRequest()
+	// ObserveGeneration records the tokens and timings of a completed generation
+	ObserveGeneration(timings GenerationTimings)
+	// ObserveRetrieval records how long a RAG lookup took and how many documents it put in the context
+	ObserveRetrieval(duration time.Duration, documents int)
+}
+
+// nopMetrics discards all measurements
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest()                     {}
+func (nopMetrics) ObserveGeneration(GenerationTimings) {}
+func (nopMetrics) ObserveRetrieval(time.Duration, int) {}
+
+// NopMetrics returns Metrics that discard all measurements
+func NopMetrics() Metrics {
+	return nopMetrics{}
+}
+
+// latencyBuckets are the histogram bucket upper bounds in seconds
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// documentBuckets are the histogram bucket upper bounds for retrieved document counts
+var documentBuckets = []float64{0, 1, 2, 3, 5, 10}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) writeTo(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func writeCounter(w io.Writer, name, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
+
+func writeGauge(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+// PrometheusMetrics collects metrics in memory and serves them in the Prometheus text format
+type PrometheusMetrics struct {
+	mu                 sync.Mutex
+	requests           uint64
+	tokensGenerated    uint64
+	timeToFirstToken   *histogram
+	generationDuration *histogram
+	retrievalDuration  *histogram
+	documentsRetrieved *histogram
+}
+
+// NewPrometheusMetrics creates an empty metrics collector
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		timeToFirstToken:   newHistogram(latencyBuckets),
+		generationDuration: newHistogram(latencyBuckets),
+		retrievalDuration:  newHistogram(latencyBuckets),
+		documentsRetrieved: newHistogram(documentBuckets),
+	}
+}
+
+// ObserveRequest counts a chat request
+func (m *PrometheusMetrics) ObserveRequest() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests++
+}
+
+// ObserveGeneration records the tokens and timings of a completed generation
+func (m *PrometheusMetrics) ObserveGeneration(timings GenerationTimings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokensGenerated += uint64(timings.GeneratedTokens)
+	if timings.GeneratedTokens > 0 {
+		m.timeToFirstToken.observe(timings.TimeToFirstToken.Seconds())
+	}
+	m.generationDuration.observe(timings.Total.Seconds())
+}
+
+// ObserveRetrieval records how long a RAG lookup took and how many documents it put in the context
+func (m *PrometheusMetrics) ObserveRetrieval(duration time.Duration, documents int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retrievalDuration.observe(duration.Seconds())
+	m.documentsRetrieved.observe(float64(documents))
+}
+
+// Render writes every metric in the Prometheus text exposition format
+func (m *PrometheusMetrics) Render(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeCounter(w, "otdfctl_llm_requests_total", "Chat requests handled.", m.requests)
+	writeCounter(w, "otdfctl_llm_tokens_generated_total", "Tokens generated across all requests.", m.tokensGenerated)
+	m.timeToFirstToken.writeTo(w, "otdfctl_llm_time_to_first_token_seconds", "Time from the start of a request to its first generated token.")
+	m.generationDuration.writeTo(w, "otdfctl_llm_generation_duration_seconds", "Time spent evaluating the prompt and generating a reply.")
+	m.retrievalDuration.writeTo(w, "otdfctl_llm_retrieval_duration_seconds", "Time spent retrieving RAG context.")
+	m.documentsRetrieved.writeTo(w, "otdfctl_llm_documents_retrieved", "Documents included in the RAG context per request.")
+}
+
+// ServeHTTP serves the metrics for a Prometheus scrape
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.Render(w)
+}
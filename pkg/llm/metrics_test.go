@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetricsTokenCounter(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.ObserveRequest()
+	metrics.ObserveGeneration(GenerationTimings{
+		GeneratedTokens:  20,
+		TimeToFirstToken: 150 * time.Millisecond,
+		Total:            time.Second,
+	})
+	metrics.ObserveGeneration(GenerationTimings{GeneratedTokens: 5, TimeToFirstToken: 80 * time.Millisecond, Total: 300 * time.Millisecond})
+
+	var out bytes.Buffer
+	metrics.Render(&out)
+	text := out.String()
+
+	assert.Contains(t, text, "otdfctl_llm_requests_total 1\n")
+	assert.Contains(t, text, "otdfctl_llm_tokens_generated_total 25\n")
+	assert.Contains(t, text, "otdfctl_llm_time_to_first_token_seconds_bucket{le=\"0.1\"} 1\n")
+	assert.Contains(t, text, "otdfctl_llm_time_to_first_token_seconds_bucket{le=\"0.25\"} 2\n")
+	assert.Contains(t, text, "otdfctl_llm_generation_duration_seconds_count 2\n")
+}
+
+func TestPrometheusMetricsRetrieval(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.ObserveRetrieval(20*time.Millisecond, 2)
+
+	var out bytes.Buffer
+	metrics.Render(&out)
+	assert.Contains(t, out.String(), "otdfctl_llm_retrieval_duration_seconds_bucket{le=\"0.05\"} 1\n")
+	assert.Contains(t, out.String(), "otdfctl_llm_documents_retrieved_bucket{le=\"1\"} 0\n")
+	assert.Contains(t, out.String(), "otdfctl_llm_documents_retrieved_sum 2\n")
+}
+
+func TestServerMetricsEndpoint(t *testing.T) {
+	generator := newBlockingGenerator()
+	close(generator.release)
+
+	withoutMetrics := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+	rec := httptest.NewRecorder()
+	withoutMetrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	metrics := NewPrometheusMetrics()
+	metrics.ObserveGeneration(GenerationTimings{GeneratedTokens: 7, Total: time.Second})
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1, Metrics: metrics})
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "otdfctl_llm_tokens_generated_total 7\n")
+	assert.Contains(t, rec.Body.String(), "otdfctl_llm_queue_active 0\n")
+}
+
+func TestSimpleEngineCountsRequests(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetMetrics(metrics)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	engine.Chat([]ChatMessage{{Role: "user", Content: "hello"}})
+
+	var out bytes.Buffer
+	metrics.Render(&out)
+	assert.Contains(t, out.String(), "otdfctl_llm_requests_total 1\n")
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ollama/ollama/fs/ggml"
+)
+
+// ggufMagic is the first four bytes of every GGUF file
+var ggufMagic = []byte("GGUF")
+
+// checkModelFile verifies that a model path points at a readable GGUF file
+func checkModelFile(modelPath string) error {
+	info, err := os.Stat(modelPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, modelPath)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s is a directory", ErrModelLoad, modelPath)
+	}
+	return checkGGUFMagic(modelPath)
+}
+
+// checkGGUFMagic reads the start of a model file so that models in another format fail with
+// a precise error rather than a generic load failure
+func checkGGUFMagic(modelPath string) error {
+	f, err := os.Open(modelPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 64)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, ggufMagic) {
+		return nil
+	}
+	return fmt.Errorf("%w: %w: %s%s", ErrModelLoad, ErrNotGGUF, modelPath, modelFormatHint(header))
+}
+
+// modelFormatHint names the format of common files mistaken for GGUF models, or returns ""
+func modelFormatHint(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("version https://git-lfs")):
+		return " (it is a Git LFS pointer; run 'git lfs pull' to download the model)"
+	case len(header) > 8 && header[8] == '{':
+		// safetensors files start with an 8-byte header length followed by a JSON header
+		return " (it looks like a safetensors file; convert it to GGUF with llama.cpp's convert_hf_to_gguf.py)"
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return " (it looks like a PyTorch checkpoint; convert it to GGUF with llama.cpp's convert_hf_to_gguf.py)"
+	case len(header) > 0 && isText(header):
+		return " (it is a text file)"
+	}
+	return ""
+}
+
+// isText reports whether b is printable ASCII text
+func isText(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 || c > 0x7e) && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// defaultContextSize is used when neither the caller nor the model metadata gives a context size
+	defaultContextSize = 4096
+	// maxDefaultContextSize caps the context picked from model metadata, since models trained on very
+	// long contexts would otherwise allocate a KV cache far larger than a chat session needs
+	maxDefaultContextSize = 8192
+)
+
+// ModelInfo is the metadata read from a GGUF model file's header
+type ModelInfo struct {
+	Architecture string `json:"architecture"`
+	// ContextLength is the context size the model was trained with (0 if unknown)
+	ContextLength int `json:"context_length"`
+	// EmbeddingLength is the size of the vectors the model produces (0 if unknown)
+	EmbeddingLength int  `json:"embedding_length"`
+	AddBOS          bool `json:"add_bos"`
+	AddEOS          bool `json:"add_eos"`
+}
+
+// ReadModelInfo reads the architecture, trained context size, embedding size, and BOS/EOS behavior from a GGUF model file
+func ReadModelInfo(modelPath string) (ModelInfo, error) {
+	if err := checkModelFile(modelPath); err != nil {
+		return ModelInfo{}, err
+	}
+
+	f, err := os.Open(modelPath)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	defer f.Close()
+
+	// Array values such as the vocabulary are not needed, so skip collecting them
+	model, err := ggml.Decode(f, 0)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("%w: failed to read model metadata: %w", ErrModelLoad, err)
+	}
+
+	kv := model.KV()
+	return ModelInfo{
+		Architecture:    kv.Architecture(),
+		ContextLength:   int(kv.ContextLength()),
+		EmbeddingLength: int(kv.EmbeddingLength()),
+		AddBOS:          kv.Bool("tokenizer.ggml.add_bos_token", true),
+		AddEOS:          kv.Bool("tokenizer.ggml.add_eos_token", false),
+	}, nil
+}
+
+// resolveContextSize picks the context size to create a model context with.
+// A requested size of 0 means use the model's trained context size, capped at maxDefaultContextSize.
+func resolveContextSize(requested int, info ModelInfo, logger Logger) int {
+	if requested > 0 {
+		if info.ContextLength > 0 && requested > info.ContextLength {
+			logger.Printf("Warning: requested context size %d exceeds the %d tokens the model was trained with; output quality may degrade",
+				requested, info.ContextLength)
+		}
+		return requested
+	}
+
+	if info.ContextLength <= 0 {
+		return defaultContextSize
+	}
+	if info.ContextLength > maxDefaultContextSize {
+		logger.Printf("Model supports a %d token context; using %d (set a context size to use more)",
+			info.ContextLength, maxDefaultContextSize)
+		return maxDefaultContextSize
+	}
+	return info.ContextLength
+}
+
+// promptTokenizer is the part of *llama.Model used to tokenize text
+type promptTokenizer interface {
+	Tokenize(text string, addSpecial bool, parseSpecial bool) ([]int, error)
+	AddBOSToken() bool
+}
+
+// tokenize tokenizes text, adding a BOS token only when the model's vocabulary expects one
+func tokenize(model promptTokenizer, text string) ([]int, error) {
+	return model.Tokenize(text, model.AddBOSToken(), true)
+}
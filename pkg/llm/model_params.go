@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/llama"
+)
+
+// ModelParams are the advanced llama settings that have no flag of their own: how the model
+// is loaded, how its context is run and the sampler settings SamplingOptions doesn't cover.
+// Each field is named after its llama.cpp setting.
+type ModelParams struct {
+	// Model loading
+	NumGpuLayers int  `json:"n_gpu_layers"` // -1 offloads every layer
+	MainGpu      int  `json:"main_gpu"`
+	UseMmap      bool `json:"use_mmap"`
+
+	// Context
+	NumThreads     int    `json:"n_threads"`
+	FlashAttention bool   `json:"flash_attn"`
+	KVCacheType    string `json:"kv_cache_type"` // One of KVCacheTypes; "" means f16
+
+	// Sampling. Greedy decoding keeps its own top-k, top-p and min-p.
+	TopK       int     `json:"top_k"`
+	TopP       float32 `json:"top_p"`
+	MinP       float32 `json:"min_p"`
+	TypicalP   float32 `json:"typical_p"`
+	PenalizeNl bool    `json:"penalize_nl"`
+	Grammar    string  `json:"grammar"`
+}
+
+// maxDefaultThreads caps the default thread count. Past the physical cores extra threads only
+// contend with each other.
+const maxDefaultThreads = 16
+
+// DefaultThreads returns the number of threads a context runs on by default: one per CPU, up
+// to maxDefaultThreads
+func DefaultThreads() int {
+	return min(runtime.NumCPU(), maxDefaultThreads)
+}
+
+// newContextParams creates llama context parameters. Tests replace it to see how contexts
+// are configured without loading a model.
+var newContextParams = llama.NewContextParams
+
+// DefaultModelParams returns the settings used when none are configured
+func DefaultModelParams() ModelParams {
+	return ModelParams{
+		NumGpuLayers: -1,
+		UseMmap:      true,
+		NumThreads:   DefaultThreads(),
+		TopK:         40,
+		TopP:         0.9,
+		MinP:         0.1,
+		TypicalP:     0,
+		PenalizeNl:   true,
+	}
+}
+
+// ParseModelParams overrides the defaults with the settings in a JSON object such as
+// {"use_mmap": false, "n_threads": 8}. Settings the object leaves out keep their defaults.
+// Unknown settings and out of range values are rejected.
+func ParseModelParams(data string) (ModelParams, error) {
+	params := DefaultModelParams()
+	if strings.TrimSpace(data) == "" {
+		return params, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return ModelParams{}, fmt.Errorf("%w: %w", ErrInvalidModelParams, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return ModelParams{}, fmt.Errorf("%w: unexpected data after the JSON object", ErrInvalidModelParams)
+	}
+	if err := params.Validate(); err != nil {
+		return ModelParams{}, err
+	}
+	return params, nil
+}
+
+// Validate rejects settings llama would misbehave with
+func (p ModelParams) Validate() error {
+	switch {
+	case p.NumThreads < 1:
+		return fmt.Errorf("%w: n_threads must be at least 1", ErrInvalidModelParams)
+	case p.TopK < 0:
+		return fmt.Errorf("%w: top_k must not be negative", ErrInvalidModelParams)
+	case p.TopP < 0 || p.TopP > 1:
+		return fmt.Errorf("%w: top_p must be between 0 and 1", ErrInvalidModelParams)
+	case p.MinP < 0 || p.MinP > 1:
+		return fmt.Errorf("%w: min_p must be between 0 and 1", ErrInvalidModelParams)
+	case p.TypicalP < 0 || p.TypicalP > 1:
+		return fmt.Errorf("%w: typical_p must be between 0 and 1", ErrInvalidModelParams)
+	}
+	if err := CheckKVCacheType(p.KVCacheType, p.FlashAttention); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidModelParams, err)
+	}
+	return nil
+}
+
+// KVCacheTypes are the types the KV cache can be stored as. The quantized types use less
+// memory, so larger contexts fit, at some cost in quality.
+var KVCacheTypes = []string{"f16", "q8_0", "q4_0"}
+
+// CheckKVCacheType reports whether kind, one of KVCacheTypes or "" for the default, can be
+// used. llama only quantizes the cache with flash attention on.
+func CheckKVCacheType(kind string, flashAttention bool) error {
+	kind = strings.ToLower(kind)
+	if kind != "" && !slices.Contains(KVCacheTypes, kind) {
+		return fmt.Errorf("unsupported KV cache type %q, expected one of %s", kind, strings.Join(KVCacheTypes, ", "))
+	}
+	if kind != "" && kind != "f16" && !flashAttention {
+		return fmt.Errorf("the %s KV cache type requires flash attention", kind)
+	}
+	return nil
+}
+
+// model converts the loading settings into llama model parameters
+func (p ModelParams) model() llama.ModelParams {
+	return llama.ModelParams{
+		NumGpuLayers: p.NumGpuLayers,
+		MainGpu:      p.MainGpu,
+		UseMmap:      p.UseMmap,
+		VocabOnly:    false,
+	}
+}
+
+// newContext creates a context for model with these settings. Builds without flash attention
+// support fail to create the context with it, so it is retried with flash attention off and,
+// as quantizing it needs flash attention, the default KV cache type.
+func (p ModelParams) newContext(model *llama.Model, contextSize, batchSize int, logger Logger) (*llama.Context, error) {
+	context, err := llama.NewContextWithModel(model, p.context(contextSize, batchSize))
+	if err != nil && p.FlashAttention {
+		logger.Printf("Flash attention is unavailable (%v), continuing without it", err)
+		p.FlashAttention = false
+		p.KVCacheType = ""
+		context, err = llama.NewContextWithModel(model, p.context(contextSize, batchSize))
+	}
+	return context, err
+}
+
+// context converts the context settings into llama context parameters
+func (p ModelParams) context(contextSize, batchSize int) llama.ContextParams {
+	return newContextParams(
+		contextSize,      // numCtx
+		batchSize,        // batchSize
+		1,                // numSeqMax
+		p.NumThreads,     // threads
+		p.FlashAttention, // flashAttention
+		p.KVCacheType,    // kvCacheType
+	)
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/ollama/ollama/llama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModelParamsOverridesDefaults(t *testing.T) {
+	params, err := ParseModelParams(`{"use_mmap": false, "n_threads": 8, "top_k": 20, "grammar": "root ::= \"yes\" | \"no\""}`)
+	require.NoError(t, err)
+
+	want := DefaultModelParams()
+	want.UseMmap = false
+	want.NumThreads = 8
+	want.TopK = 20
+	want.Grammar = `root ::= "yes" | "no"`
+	assert.Equal(t, want, params, "settings left out keep their defaults")
+
+	model := params.model()
+	assert.False(t, model.UseMmap)
+	assert.Equal(t, -1, model.NumGpuLayers)
+
+	sampling := DefaultSamplingOptions().paramsWith(params)
+	assert.Equal(t, 20, sampling.TopK)
+	assert.InDelta(t, 0.9, sampling.TopP, 0.0001)
+	assert.Equal(t, params.Grammar, sampling.Grammar)
+
+	// Greedy decoding keeps its own top-k
+	greedy := SamplingOptions{Greedy: true}.paramsWith(params)
+	assert.Equal(t, 1, greedy.TopK)
+}
+
+func TestParseModelParamsDefaults(t *testing.T) {
+	for _, data := range []string{"", "  ", "{}"} {
+		params, err := ParseModelParams(data)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultModelParams(), params)
+	}
+	assert.Equal(t, DefaultSamplingOptions().params(), DefaultSamplingOptions().paramsWith(DefaultModelParams()))
+}
+
+func TestParseModelParamsRejectsInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown key":    `{"n_threads": 8, "use_mlock": true}`,
+		"wrong type":     `{"n_threads": "eight"}`,
+		"not an object":  `[1, 2]`,
+		"trailing data":  `{"n_threads": 8} {"top_k": 1}`,
+		"no threads":     `{"n_threads": 0}`,
+		"negative top_k": `{"top_k": -1}`,
+		"top_p":          `{"top_p": 1.5}`,
+		"kv cache type":  `{"kv_cache_type": "f32"}`,
+		"quantized kv":   `{"kv_cache_type": "q8_0"}`,
+	} {
+		_, err := ParseModelParams(data)
+		require.ErrorIs(t, err, ErrInvalidModelParams, name)
+	}
+
+	_, err := ParseModelParams(`{"use_mlock": true}`)
+	assert.Contains(t, err.Error(), "use_mlock")
+}
+
+func TestContextSettingsReachContextParams(t *testing.T) {
+	type contextSettings struct {
+		threads        int
+		flashAttention bool
+		kvCacheType    string
+	}
+	var created []contextSettings
+	original := newContextParams
+	newContextParams = func(numCtx, batchSize, numSeqMax, threads int, flashAttention bool, kvCacheType string) llama.ContextParams {
+		created = append(created, contextSettings{threads, flashAttention, kvCacheType})
+		return original(numCtx, batchSize, numSeqMax, threads, flashAttention, kvCacheType)
+	}
+	t.Cleanup(func() { newContextParams = original })
+
+	params := DefaultModelParams()
+	params.NumThreads = 12
+	params.context(2048, 512)
+	params.FlashAttention = true
+	params.KVCacheType = "q8_0"
+	params.context(2048, 512)
+	embeddingContextParams(6, false, "")
+	embeddingContextParams(6, true, "q4_0")
+	assert.Equal(t, []contextSettings{{12, false, ""}, {12, true, "q8_0"}, {6, false, ""}, {6, true, "q4_0"}}, created)
+}
+
+func TestDefaultThreads(t *testing.T) {
+	threads := DefaultThreads()
+	assert.GreaterOrEqual(t, threads, 1)
+	assert.LessOrEqual(t, threads, maxDefaultThreads)
+	assert.Equal(t, threads, DefaultModelParams().NumThreads)
+}
+
+func TestCheckKVCacheType(t *testing.T) {
+	for _, kind := range []string{"", "f16", "F16"} {
+		assert.NoError(t, CheckKVCacheType(kind, false), kind)
+	}
+	for _, kind := range KVCacheTypes {
+		assert.NoError(t, CheckKVCacheType(kind, true), kind)
+	}
+
+	err := CheckKVCacheType("q8_0", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires flash attention")
+
+	err = CheckKVCacheType("q5_1", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "f16, q8_0, q4_0")
+
+	params, err := ParseModelParams(`{"kv_cache_type": "q4_0", "flash_attn": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, "q4_0", params.KVCacheType)
+}
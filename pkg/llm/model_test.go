@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ollama/ollama/fs/ggml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeStubModel writes a GGUF file with the given metadata and a single tiny tensor
+func writeStubModel(t *testing.T, kv ggml.KV) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stub.gguf")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	tensors := []*ggml.Tensor{
+		{Name: "token_embd.weight", Shape: []uint64{2, 3}, WriterTo: bytes.NewBuffer(make([]byte, 2*3))},
+	}
+	require.NoError(t, ggml.WriteGGUF(f, kv, tensors))
+	return path
+}
+
+func TestReadModelInfo(t *testing.T) {
+	path := writeStubModel(t, ggml.KV{
+		"general.architecture":         "llama",
+		"llama.context_length":         uint32(131072),
+		"llama.embedding_length":       uint32(2048),
+		"tokenizer.ggml.add_bos_token": false,
+		"tokenizer.ggml.add_eos_token": true,
+	})
+
+	info, err := ReadModelInfo(path)
+	require.NoError(t, err)
+	assert.Equal(t, ModelInfo{Architecture: "llama", ContextLength: 131072, EmbeddingLength: 2048, AddBOS: false, AddEOS: true}, info)
+}
+
+func TestReadModelInfoDefaults(t *testing.T) {
+	path := writeStubModel(t, ggml.KV{"general.architecture": "bert"})
+
+	info, err := ReadModelInfo(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.ContextLength)
+	assert.True(t, info.AddBOS)
+	assert.False(t, info.AddEOS)
+}
+
+func TestReadModelInfoErrors(t *testing.T) {
+	_, err := ReadModelInfo(filepath.Join(t.TempDir(), "missing.gguf"))
+	require.ErrorIs(t, err, ErrModelNotFound)
+
+	notGGUF := filepath.Join(t.TempDir(), "model.bin")
+	require.NoError(t, os.WriteFile(notGGUF, []byte("not a model"), 0o600))
+	_, err = ReadModelInfo(notGGUF)
+	require.ErrorIs(t, err, ErrModelLoad)
+}
+
+func TestCheckModelFileGGUFMagic(t *testing.T) {
+	require.NoError(t, checkModelFile(writeStubModel(t, ggml.KV{"general.architecture": "llama"})))
+
+	safetensors := append([]byte{0x10, 0, 0, 0, 0, 0, 0, 0}, []byte(`{"__metadata__":{}}`)...)
+	tests := []struct {
+		name    string
+		content []byte
+		hint    string
+	}{
+		{"text", []byte("this is not a model\n"), "text file"},
+		{"safetensors", safetensors, "safetensors"},
+		{"git lfs pointer", []byte("version https://git-lfs.github.com/spec/v1\noid sha256:abc\n"), "git lfs pull"},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "model.gguf")
+			require.NoError(t, os.WriteFile(path, tt.content, 0o600))
+
+			err := checkModelFile(path)
+			require.ErrorIs(t, err, ErrNotGGUF)
+			require.ErrorIs(t, err, ErrModelLoad)
+			assert.Contains(t, err.Error(), tt.hint)
+
+			// The engine reports the wrong format instead of falling back to simulation
+			require.ErrorIs(t, NewSimpleChatEngine(path).Start(), ErrNotGGUF)
+		})
+	}
+}
+
+func TestResolveContextSize(t *testing.T) {
+	logger := &recordingLogger{}
+
+	assert.Equal(t, 2048, resolveContextSize(0, ModelInfo{ContextLength: 2048}, logger))
+	assert.Equal(t, maxDefaultContextSize, resolveContextSize(0, ModelInfo{ContextLength: 131072}, logger))
+	assert.Equal(t, defaultContextSize, resolveContextSize(0, ModelInfo{}, logger))
+	assert.Equal(t, 1024, resolveContextSize(1024, ModelInfo{ContextLength: 2048}, logger))
+	assert.Len(t, logger.messages, 1, "only capping the default is reported")
+
+	assert.Equal(t, 4096, resolveContextSize(4096, ModelInfo{ContextLength: 2048}, logger))
+	require.Len(t, logger.messages, 2)
+	assert.Contains(t, logger.messages[1], "exceeds")
+}
+
+// stubTokenizer records how it was asked to tokenize
+type stubTokenizer struct {
+	addBOS     bool
+	addSpecial bool
+}
+
+func (s *stubTokenizer) Tokenize(text string, addSpecial bool, _ bool) ([]int, error) {
+	s.addSpecial = addSpecial
+	return make([]int, len(text)), nil
+}
+
+func (s *stubTokenizer) AddBOSToken() bool {
+	return s.addBOS
+}
+
+func TestTokenizeFollowsModelBOS(t *testing.T) {
+	withBOS := &stubTokenizer{addBOS: true}
+	_, err := tokenize(withBOS, "hello")
+	require.NoError(t, err)
+	assert.True(t, withBOS.addSpecial)
+
+	withoutBOS := &stubTokenizer{addBOS: false}
+	_, err = tokenize(withoutBOS, "hello")
+	require.NoError(t, err)
+	assert.False(t, withoutBOS.addSpecial)
+}
@@ -0,0 +1,298 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MultiStore searches several vector indexes as one, e.g. separate indexes kept per product.
+// Results from every index are ranked together by similarity. Documents are added to the
+// first index; the others are only read from. All indexes must be built with the same
+// embedding model.
+type MultiStore struct {
+	stores []Store
+}
+
+var _ Store = (*MultiStore)(nil)
+
+// NewMultiStore combines stores, the first of which receives added documents
+func NewMultiStore(stores ...Store) *MultiStore {
+	return &MultiStore{stores: stores}
+}
+
+// SetLogger routes every index's diagnostic messages to the given logger
+func (m *MultiStore) SetLogger(logger Logger) {
+	for _, s := range m.stores {
+		s.SetLogger(logger)
+	}
+}
+
+// SetTitleBoost sets the title boost of every index
+func (m *MultiStore) SetTitleBoost(weight float32) {
+	for _, s := range m.stores {
+		s.SetTitleBoost(weight)
+	}
+}
+
+// LoadIndex loads every index, failing if their embeddings have different sizes
+func (m *MultiStore) LoadIndex() error {
+	dim := 0
+	for _, s := range m.stores {
+		if err := s.LoadIndex(); err != nil {
+			return err
+		}
+		if d := s.EmbeddingDim(); d > 0 {
+			if dim > 0 && d != dim {
+				return fmt.Errorf("%w: indexes hold %d and %d dimension embeddings", ErrDimensionMismatch, dim, d)
+			}
+			dim = d
+		}
+	}
+	return nil
+}
+
+// SaveIndex saves every index, stopping at the first failure
+func (m *MultiStore) SaveIndex() error {
+	for _, s := range m.stores {
+		if err := s.SaveIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every index, discarding unsaved changes
+func (m *MultiStore) Close() error {
+	var errs []error
+	for _, s := range m.stores {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// AddDocument adds a document to the first index
+func (m *MultiStore) AddDocument(doc Document) error {
+	if len(m.stores) == 0 {
+		return errors.New("no index to add the document to")
+	}
+	return m.stores[0].AddDocument(doc)
+}
+
+// Search returns the topK most similar documents across all indexes
+func (m *MultiStore) Search(queryEmbedding []float32, topK int) ([]SimilarityResult, error) {
+	return searchAll(m.stores, topK, func(s Store) ([]SimilarityResult, error) {
+		return s.Search(queryEmbedding, topK)
+	}, func(r SimilarityResult) float32 { return r.Similarity })
+}
+
+// ExplainSimilarity explains doc's score as scored by the index holding it
+func (m *MultiStore) ExplainSimilarity(queryEmbedding []float32, doc Document) VectorExplanation {
+	for _, s := range m.stores {
+		if _, ok := s.GetDocument(doc.ID); ok {
+			return s.ExplainSimilarity(queryEmbedding, doc)
+		}
+	}
+	if len(m.stores) == 0 {
+		return VectorExplanation{}
+	}
+	return m.stores[0].ExplainSimilarity(queryEmbedding, doc)
+}
+
+// GetDocumentCount returns the number of documents in all indexes
+func (m *MultiStore) GetDocumentCount() int {
+	count := 0
+	for _, s := range m.stores {
+		count += s.GetDocumentCount()
+	}
+	return count
+}
+
+// EmbeddingDim returns the size of the stored embeddings, or 0 if there are none
+func (m *MultiStore) EmbeddingDim() int {
+	for _, s := range m.stores {
+		if d := s.EmbeddingDim(); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// GetDocument returns the document with the given ID from the first index that has it
+func (m *MultiStore) GetDocument(id string) (Document, bool) {
+	for _, s := range m.stores {
+		if doc, ok := s.GetDocument(id); ok {
+			return doc, true
+		}
+	}
+	return Document{}, false
+}
+
+// ListDocuments lists the matching documents of every index, in index order
+func (m *MultiStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	return listAll(m.stores, filter, Store.ListDocuments)
+}
+
+// EachDocument calls fn with the documents of every index, in index order
+func (m *MultiStore) EachDocument(fn func(Document) error) error {
+	for _, s := range m.stores {
+		if err := s.EachDocument(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveDocuments removes the matching documents from every index
+func (m *MultiStore) RemoveDocuments(filter DocumentFilter) int {
+	removed := 0
+	for _, s := range m.stores {
+		removed += s.RemoveDocuments(filter)
+	}
+	return removed
+}
+
+// Clear removes every document from every index
+func (m *MultiStore) Clear() {
+	for _, s := range m.stores {
+		s.Clear()
+	}
+}
+
+// MultiKeywordStore searches several keyword indexes as one. Results from every index are
+// ranked together by score. Documents are added to the first index; the others are only
+// read from.
+type MultiKeywordStore struct {
+	stores []KeywordStore
+}
+
+var _ KeywordStore = (*MultiKeywordStore)(nil)
+
+// NewMultiKeywordStore combines stores, the first of which receives added documents
+func NewMultiKeywordStore(stores ...KeywordStore) *MultiKeywordStore {
+	return &MultiKeywordStore{stores: stores}
+}
+
+// SetLogger routes every index's diagnostic messages to the given logger
+func (m *MultiKeywordStore) SetLogger(logger Logger) {
+	for _, s := range m.stores {
+		s.SetLogger(logger)
+	}
+}
+
+// LoadIndex loads every index
+func (m *MultiKeywordStore) LoadIndex() error {
+	for _, s := range m.stores {
+		if err := s.LoadIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveIndex saves every index, stopping at the first failure
+func (m *MultiKeywordStore) SaveIndex() error {
+	for _, s := range m.stores {
+		if err := s.SaveIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every index, discarding unsaved changes
+func (m *MultiKeywordStore) Close() error {
+	var errs []error
+	for _, s := range m.stores {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// AddDocument adds a document to the first index
+func (m *MultiKeywordStore) AddDocument(doc SimpleDocument) error {
+	if len(m.stores) == 0 {
+		return errors.New("no index to add the document to")
+	}
+	return m.stores[0].AddDocument(doc)
+}
+
+// Search returns the topK best keyword matches across all indexes
+func (m *MultiKeywordStore) Search(query string, topK int) ([]SearchResult, error) {
+	return searchAll(m.stores, topK, func(s KeywordStore) ([]SearchResult, error) {
+		return s.Search(query, topK)
+	}, func(r SearchResult) float32 { return r.Score })
+}
+
+// ExplainScore explains doc's score as scored by the index holding it
+func (m *MultiKeywordStore) ExplainScore(query string, doc SimpleDocument) KeywordExplanation {
+	for _, s := range m.stores {
+		if len(s.ListDocuments(DocumentFilter{ID: doc.ID, Limit: 1})) > 0 {
+			return s.ExplainScore(query, doc)
+		}
+	}
+	if len(m.stores) == 0 {
+		return KeywordExplanation{}
+	}
+	return m.stores[0].ExplainScore(query, doc)
+}
+
+// GetDocumentCount returns the number of documents in all indexes
+func (m *MultiKeywordStore) GetDocumentCount() int {
+	count := 0
+	for _, s := range m.stores {
+		count += s.GetDocumentCount()
+	}
+	return count
+}
+
+// ListDocuments lists the matching documents of every index, in index order
+func (m *MultiKeywordStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	return listAll(m.stores, filter, KeywordStore.ListDocuments)
+}
+
+// RemoveDocuments removes the matching documents from every index
+func (m *MultiKeywordStore) RemoveDocuments(filter DocumentFilter) int {
+	removed := 0
+	for _, s := range m.stores {
+		removed += s.RemoveDocuments(filter)
+	}
+	return removed
+}
+
+// Clear removes every document from every index
+func (m *MultiKeywordStore) Clear() {
+	for _, s := range m.stores {
+		s.Clear()
+	}
+}
+
+// searchAll runs search on every store and keeps the topK results by score. Results with
+// equal scores keep the order of the stores they came from.
+func searchAll[S, T any](stores []S, topK int, search func(S) ([]T, error), score func(T) float32) ([]T, error) {
+	var best []T
+	for _, s := range stores {
+		results, err := search(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			best = keepTopK(best, topK, result, score)
+		}
+	}
+	if best == nil {
+		best = []T{}
+	}
+	return best, nil
+}
+
+// listAll lists the documents matching filter in every store, in store order, applying the
+// filter's offset and limit to the combined list
+func listAll[S any](stores []S, filter DocumentFilter, list func(S, DocumentFilter) []DocumentInfo) []DocumentInfo {
+	unpaged := filter
+	unpaged.Offset, unpaged.Limit = 0, 0
+	var docs []DocumentInfo
+	for _, s := range stores {
+		docs = append(docs, list(s, unpaged)...)
+	}
+	return filter.page(docs)
+}
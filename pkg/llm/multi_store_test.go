@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiStoreRanksAcrossIndexes(t *testing.T) {
+	dir := t.TempDir()
+	platform := NewVectorStore(filepath.Join(dir, "platform.json"))
+	sdk := NewSQLiteStore(filepath.Join(dir, "sdk.db"))
+	store := NewMultiStore(platform, sdk)
+	require.NoError(t, store.LoadIndex())
+	defer store.Close()
+
+	require.NoError(t, store.AddDocument(Document{ID: "kas", Embedding: []float32{1, 0}}))
+	require.NoError(t, platform.AddDocument(Document{ID: "policy", Embedding: []float32{0, 1}}))
+	require.NoError(t, sdk.AddDocument(Document{ID: "sdk-encrypt", Embedding: []float32{1, 1}}))
+	require.NoError(t, sdk.AddDocument(Document{ID: "sdk-decrypt", Embedding: []float32{1, 0.1}}))
+	assert.Equal(t, 2, platform.GetDocumentCount(), "documents are added to the first index")
+	assert.Equal(t, 4, store.GetDocumentCount())
+
+	results, err := store.Search([]float32{1, 0}, 3)
+	require.NoError(t, err)
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.Document.ID)
+	}
+	assert.Equal(t, []string{"kas", "sdk-decrypt", "sdk-encrypt"}, ids)
+
+	doc, ok := store.GetDocument("sdk-encrypt")
+	require.True(t, ok)
+	assert.Equal(t, []float32{1, 1}, doc.Embedding)
+
+	docs := store.ListDocuments(DocumentFilter{Offset: 1, Limit: 2})
+	require.Len(t, docs, 2)
+	assert.Equal(t, "policy", docs[0].ID)
+	assert.Equal(t, "sdk-encrypt", docs[1].ID)
+}
+
+func TestMultiStoreRejectsMixedDimensions(t *testing.T) {
+	dir := t.TempDir()
+	small := NewVectorStore(filepath.Join(dir, "small.json"))
+	require.NoError(t, small.AddDocument(Document{ID: "a", Embedding: []float32{1, 0}}))
+	require.NoError(t, small.SaveIndex())
+	large := NewVectorStore(filepath.Join(dir, "large.json"))
+	require.NoError(t, large.AddDocument(Document{ID: "b", Embedding: []float32{1, 0, 0}}))
+	require.NoError(t, large.SaveIndex())
+
+	store := NewMultiStore(NewVectorStore(filepath.Join(dir, "small.json")), NewVectorStore(filepath.Join(dir, "large.json")))
+	require.ErrorIs(t, store.LoadIndex(), ErrDimensionMismatch)
+}
+
+func TestMultiKeywordStoreRanksAcrossIndexes(t *testing.T) {
+	dir := t.TempDir()
+	platform := NewSimpleRAGStore(filepath.Join(dir, "platform.json"))
+	spec := NewSQLiteKeywordStore(filepath.Join(dir, "spec.db"))
+	store := NewMultiKeywordStore(platform, spec)
+	require.NoError(t, store.LoadIndex())
+	defer store.Close()
+
+	// Split the documents between the indexes
+	for i, doc := range openTDFDocs {
+		if i%2 == 0 {
+			require.NoError(t, platform.AddDocument(doc))
+		} else {
+			require.NoError(t, spec.AddDocument(doc))
+		}
+	}
+	assert.Equal(t, len(openTDFDocs), store.GetDocumentCount())
+
+	results, err := store.Search("policy subject mappings attribute values", 3)
+	require.NoError(t, err)
+	require.Len(t, results, 3, "the global top K")
+	fromPlatform := map[string]bool{}
+	for i, r := range results {
+		if i > 0 {
+			assert.GreaterOrEqual(t, results[i-1].Score, r.Score, "ranked by score")
+		}
+		for _, doc := range platform.ListDocuments(DocumentFilter{ID: r.Document.ID}) {
+			fromPlatform[doc.ID] = true
+		}
+	}
+	assert.NotEmpty(t, fromPlatform, "matches come from the first index")
+	assert.Less(t, len(fromPlatform), len(results), "and from the second")
+
+	// Every index's best match is considered
+	for _, index := range []KeywordStore{platform, spec} {
+		best, err := index.Search("policy subject mappings attribute values", 1)
+		require.NoError(t, err)
+		require.Len(t, best, 1)
+		assert.LessOrEqual(t, best[0].Score, results[0].Score)
+	}
+
+	assert.Equal(t, 2, store.RemoveDocuments(DocumentFilter{SourceFile: "components/policy.md"})+store.RemoveDocuments(DocumentFilter{SourceFile: "components/kas.md"}))
+	assert.Equal(t, len(openTDFDocs)-2, store.GetDocumentCount())
+}
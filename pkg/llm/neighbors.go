@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// chunkID returns the ID ingestion gives chunk i of the document with ID parentID
+func chunkID(parentID string, i int) string {
+	return fmt.Sprintf("%s_chunk_%d", parentID, i)
+}
+
+// parentID returns the ID of the document chunk doc was cut from, or "" if doc isn't a chunk.
+// Chunks from indexes built before ParentID was recorded fall back to their ID.
+func parentID(doc Document) string {
+	if doc.ParentID != "" {
+		return doc.ParentID
+	}
+	suffix := fmt.Sprintf("_chunk_%d", doc.ChunkIndex)
+	if !strings.HasSuffix(doc.ID, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(doc.ID, suffix)
+}
+
+// ExpandNeighbors widens each retrieved chunk with up to neighbors adjacent chunks on each side
+// from the same parent document, so the model sees the surrounding section rather than a
+// fragment. Nearer chunks are added first, and only while every result still fits in
+// maxTokens, counting the chunks as retrieved before any is expanded. A chunk is never
+// included twice: expansion stops in a direction at a chunk that was itself retrieved or
+// already added to another result.
+func ExpandNeighbors(store Store, results []SimilarityResult, neighbors, maxTokens int) []SimilarityResult {
+	if neighbors <= 0 || len(results) == 0 {
+		return results
+	}
+
+	used := make(map[string]bool, len(results))
+	budget := maxTokens
+	for _, result := range results {
+		used[result.Document.ID] = true
+		budget -= estimateTokens(result.Document.Content)
+	}
+
+	expanded := make([]SimilarityResult, len(results))
+	copy(expanded, results)
+	for i, result := range expanded {
+		parent := parentID(result.Document)
+		if parent == "" || budget <= 0 {
+			continue
+		}
+
+		chunk, total := result.Document.ChunkIndex, result.Document.TotalChunks
+		// take claims the chunk at index if it exists, isn't used yet and fits the budget
+		take := func(index int) (string, bool) {
+			if index < 0 || (total > 0 && index >= total) {
+				return "", false
+			}
+			id := chunkID(parent, index)
+			if used[id] {
+				return "", false
+			}
+			doc, ok := store.GetDocument(id)
+			tokens := estimateTokens(doc.Content)
+			if !ok || tokens > budget {
+				return "", false
+			}
+			used[id] = true
+			budget -= tokens
+			return doc.Content, true
+		}
+
+		// Each direction stops at the first chunk that can't be added, so the text stays contiguous
+		var before, after []string
+		openBefore, openAfter := true, true
+		for distance := 1; distance <= neighbors && (openBefore || openAfter); distance++ {
+			if openBefore {
+				var content string
+				if content, openBefore = take(chunk - distance); openBefore {
+					before = append([]string{content}, before...)
+				}
+			}
+			if openAfter {
+				var content string
+				if content, openAfter = take(chunk + distance); openAfter {
+					after = append(after, content)
+				}
+			}
+		}
+
+		if len(before) > 0 || len(after) > 0 {
+			parts := append(append(before, result.Document.Content), after...)
+			expanded[i].Document.Content = strings.Join(parts, "\n\n")
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkedStore holds five chunks of a KAS document and two of a policy document
+func chunkedStore(t *testing.T, newStore func(dir string) Store) Store {
+	t.Helper()
+	store := newStore(t.TempDir())
+	require.NoError(t, store.LoadIndex())
+	t.Cleanup(func() { store.Close() })
+	for parent, total := range map[string]int{"kas": 5, "policy": 2} {
+		for i := 0; i < total; i++ {
+			require.NoError(t, store.AddDocument(Document{
+				ID:          chunkID(parent, i),
+				Title:       parent,
+				Content:     fmt.Sprintf("%s section %d.", parent, i),
+				FilePath:    parent + ".md",
+				ChunkIndex:  i,
+				TotalChunks: total,
+				Embedding:   []float32{1, float32(i)},
+			}))
+		}
+	}
+	return store
+}
+
+// hit returns a search result for the stored chunk with the given ID
+func hit(t *testing.T, store Store, id string) SimilarityResult {
+	t.Helper()
+	doc, ok := store.GetDocument(id)
+	require.True(t, ok, id)
+	return SimilarityResult{Document: doc, Similarity: 0.9}
+}
+
+func TestExpandNeighbors(t *testing.T) {
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			store := chunkedStore(t, newStore)
+
+			expanded := ExpandNeighbors(store, []SimilarityResult{hit(t, store, "kas_chunk_2")}, 1, 800)
+			require.Len(t, expanded, 1)
+			assert.Equal(t, "kas section 1.\n\nkas section 2.\n\nkas section 3.", expanded[0].Document.Content)
+			assert.Equal(t, "kas_chunk_2", expanded[0].Document.ID)
+			assert.InDelta(t, 0.9, expanded[0].Similarity, 0.0001)
+
+			// The document's edges stop expansion; the policy chunks are never pulled in
+			expanded = ExpandNeighbors(store, []SimilarityResult{hit(t, store, "kas_chunk_0")}, 10, 800)
+			assert.Equal(t, "kas section 0.\n\nkas section 1.\n\nkas section 2.\n\nkas section 3.\n\nkas section 4.", expanded[0].Document.Content)
+			assert.NotContains(t, expanded[0].Document.Content, "policy")
+
+			// Adjacent hits don't repeat each other's chunks
+			expanded = ExpandNeighbors(store, []SimilarityResult{hit(t, store, "kas_chunk_1"), hit(t, store, "kas_chunk_2")}, 1, 800)
+			assert.Equal(t, "kas section 0.\n\nkas section 1.", expanded[0].Document.Content)
+			assert.Equal(t, "kas section 2.\n\nkas section 3.", expanded[1].Document.Content)
+
+			// The hits take 7 of a 10 token budget, leaving room for one 3 token neighbor
+			expanded = ExpandNeighbors(store, []SimilarityResult{hit(t, store, "kas_chunk_2"), hit(t, store, "policy_chunk_0")}, 2, 10)
+			assert.Equal(t, "kas section 1.\n\nkas section 2.", expanded[0].Document.Content)
+			assert.Equal(t, "policy section 0.", expanded[1].Document.Content)
+
+			// Disabled, the results are unchanged
+			results := []SimilarityResult{hit(t, store, "kas_chunk_2")}
+			assert.Equal(t, results, ExpandNeighbors(store, results, 0, 800))
+		})
+	}
+}
+
+func TestExpandNeighborsSkipsUnchunkedDocuments(t *testing.T) {
+	store := NewVectorStore(t.TempDir() + "/index.json")
+	require.NoError(t, store.AddDocument(Document{ID: "notes", Content: "standalone", Embedding: []float32{1}}))
+	require.NoError(t, store.AddDocument(Document{ID: "notes_chunk_1", Content: "unrelated", Embedding: []float32{1}}))
+
+	expanded := ExpandNeighbors(store, []SimilarityResult{{Document: Document{ID: "notes", Content: "standalone"}}}, 2, 800)
+	assert.Equal(t, "standalone", expanded[0].Document.Content)
+	assert.False(t, strings.Contains(expanded[0].Document.Content, "unrelated"))
+}
+
+func TestParentID(t *testing.T) {
+	assert.Equal(t, "kas.md", parentID(Document{ID: "docs_kas_chunk_3", ChunkIndex: 3, ParentID: "kas.md"}), "a recorded parent ID wins")
+	// Older indexes don't record parents, so the ID convention is parsed
+	assert.Equal(t, "docs_kas", parentID(Document{ID: chunkID("docs_kas", 3), ChunkIndex: 3}))
+	assert.Empty(t, parentID(Document{ID: "docs_kas_chunk_3", ChunkIndex: 2}))
+	assert.Empty(t, parentID(Document{ID: "docs_kas"}))
+}
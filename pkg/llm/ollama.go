@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultEmbeddingModel is the Ollama model `llm ingest` embeds with when no model is given
+const DefaultEmbeddingModel = "llama3.2:1b"
+
+// ollamaModelMediaType marks the manifest layer that holds a model's GGUF weights
+const ollamaModelMediaType = "application/vnd.ollama.image.model"
+
+// ollamaManifest is the part of an Ollama image manifest needed to find the model weights
+type ollamaManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// OllamaModelsDir returns the directory Ollama stores models in: $OLLAMA_MODELS, or ~/.ollama/models
+func OllamaModelsDir() (string, error) {
+	if dir := os.Getenv("OLLAMA_MODELS"); dir != "" {
+		return dir, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".ollama", "models"), nil
+}
+
+// ollamaDefaultRegistry and ollamaDefaultNamespace fill in the parts a short model name leaves out
+const (
+	ollamaDefaultRegistry  = "registry.ollama.ai"
+	ollamaDefaultNamespace = "library"
+)
+
+// ollamaManifestPath returns where the manifest for a model reference such as llama3.2:1b,
+// user/model:tag, or hf.co/org/model:tag is stored under modelsDir
+func ollamaManifestPath(modelsDir, ref string) string {
+	name, tag := ref, "latest"
+	// Only a colon in the last path segment separates the tag; one before it belongs to a host:port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") && i < len(ref)-1 {
+		name, tag = ref[:i], ref[i+1:]
+	}
+
+	parts := strings.Split(name, "/")
+	switch len(parts) {
+	case 1:
+		parts = []string{ollamaDefaultRegistry, ollamaDefaultNamespace, parts[0]}
+	case 2:
+		parts = []string{ollamaDefaultRegistry, parts[0], parts[1]}
+	}
+
+	return filepath.Join(append(append([]string{modelsDir, "manifests"}, parts...), tag)...)
+}
+
+// isOllamaReference reports whether modelRef looks like an Ollama model reference rather than a file path
+func isOllamaReference(modelRef string) bool {
+	if modelRef == "" || filepath.IsAbs(modelRef) || strings.ContainsRune(modelRef, '\\') {
+		return false
+	}
+	if strings.HasPrefix(modelRef, ".") || strings.HasPrefix(modelRef, "~") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(modelRef)) {
+	case ".gguf", ".ggml", ".bin":
+		return false
+	}
+	return strings.Count(modelRef, "/") <= 2
+}
+
+// FindOllamaModel resolves an Ollama model reference such as llama3.2:1b to the path of its GGUF blob in modelsDir.
+// References without a tag use the latest tag, and ones without a namespace use the official library.
+func FindOllamaModel(modelsDir, name string) (string, error) {
+	manifestPath := ollamaManifestPath(modelsDir, name)
+	data, err := os.ReadFile(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: Ollama model %s is not in %s (run 'ollama pull %s')", ErrModelNotFound, name, modelsDir, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read manifest for %s: %w", ErrModelLoad, name, err)
+	}
+
+	var manifest ollamaManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("%w: invalid manifest for %s: %w", ErrModelLoad, name, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ollamaModelMediaType {
+			continue
+		}
+		blobPath := filepath.Join(modelsDir, "blobs", strings.Replace(layer.Digest, ":", "-", 1))
+		if err := checkModelFile(blobPath); err != nil {
+			return "", fmt.Errorf("blob for Ollama model %s: %w", name, err)
+		}
+		return blobPath, nil
+	}
+
+	return "", fmt.Errorf("%w: manifest for %s has no model layer", ErrModelLoad, name)
+}
+
+// ResolveModelPath turns a model argument into a model file path. Existing files are returned unchanged,
+// Ollama model references are looked up in the local Ollama models directory, and anything else is
+// treated as a file path so loading it reports the usual missing-file error.
+func ResolveModelPath(modelRef string) (string, error) {
+	if _, err := os.Stat(modelRef); err == nil || !isOllamaReference(modelRef) {
+		return modelRef, nil
+	}
+
+	modelsDir, err := OllamaModelsDir()
+	if err != nil {
+		return "", err
+	}
+	return FindOllamaModel(modelsDir, modelRef)
+}
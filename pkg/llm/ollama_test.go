@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeOllamaModel lays out a manifest and blob the way `ollama pull` does and returns the blob path
+func writeOllamaModel(t *testing.T, modelsDir, model, tag, digest string) string {
+	t.Helper()
+
+	manifestDir := filepath.Join(modelsDir, "manifests", "registry.ollama.ai", "library", model)
+	require.NoError(t, os.MkdirAll(manifestDir, 0o755))
+	manifest := fmt.Sprintf(`{"layers":[
+		{"mediaType":"application/vnd.ollama.image.template","digest":"sha256:template"},
+		{"mediaType":"application/vnd.ollama.image.model","digest":"sha256:%s"}
+	]}`, digest)
+	require.NoError(t, os.WriteFile(filepath.Join(manifestDir, tag), []byte(manifest), 0o600))
+
+	blobDir := filepath.Join(modelsDir, "blobs")
+	require.NoError(t, os.MkdirAll(blobDir, 0o755))
+	blobPath := filepath.Join(blobDir, "sha256-"+digest)
+	require.NoError(t, os.WriteFile(blobPath, []byte("GGUF"), 0o600))
+	return blobPath
+}
+
+func TestFindOllamaModel(t *testing.T) {
+	modelsDir := t.TempDir()
+	blob := writeOllamaModel(t, modelsDir, "llama3.2", "1b", "74701a8c")
+	latest := writeOllamaModel(t, modelsDir, "nomic-embed-text", "latest", "970aa74c")
+
+	path, err := FindOllamaModel(modelsDir, "llama3.2:1b")
+	require.NoError(t, err)
+	assert.Equal(t, blob, path)
+
+	path, err = FindOllamaModel(modelsDir, "nomic-embed-text")
+	require.NoError(t, err)
+	assert.Equal(t, latest, path)
+
+	_, err = FindOllamaModel(modelsDir, "llama3.2:3b")
+	require.ErrorIs(t, err, ErrModelNotFound)
+}
+
+func TestFindOllamaModelMissingBlob(t *testing.T) {
+	modelsDir := t.TempDir()
+	blob := writeOllamaModel(t, modelsDir, "llama3.2", "1b", "74701a8c")
+	require.NoError(t, os.Remove(blob))
+
+	_, err := FindOllamaModel(modelsDir, "llama3.2:1b")
+	require.ErrorIs(t, err, ErrModelNotFound)
+}
+
+func TestResolveModelPath(t *testing.T) {
+	modelsDir := t.TempDir()
+	t.Setenv("OLLAMA_MODELS", modelsDir)
+	blob := writeOllamaModel(t, modelsDir, "llama3.2", "1b", "74701a8c")
+
+	path, err := ResolveModelPath("llama3.2:1b")
+	require.NoError(t, err)
+	assert.Equal(t, blob, path)
+
+	// Existing files are used as-is
+	path, err = ResolveModelPath(blob)
+	require.NoError(t, err)
+	assert.Equal(t, blob, path)
+
+	_, err = ResolveModelPath(DefaultEmbeddingModel + "-missing")
+	require.ErrorIs(t, err, ErrModelNotFound)
+}
+
+func TestOllamaModelsDir(t *testing.T) {
+	t.Setenv("OLLAMA_MODELS", "/srv/ollama")
+	dir, err := OllamaModelsDir()
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/ollama", dir)
+
+	t.Setenv("OLLAMA_MODELS", "")
+	t.Setenv("HOME", "/home/tester")
+	dir, err = OllamaModelsDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/home/tester", ".ollama", "models"), dir)
+}
+
+func TestOllamaManifestPath(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{"llama3.2", "registry.ollama.ai/library/llama3.2/latest"},
+		{"llama3.2:1b", "registry.ollama.ai/library/llama3.2/1b"},
+		{"jmorgan/tinyllama:q4", "registry.ollama.ai/jmorgan/tinyllama/q4"},
+		{"hf.co/bartowski/Llama-3.2-1B-Instruct-GGUF:Q4_K_M", "hf.co/bartowski/Llama-3.2-1B-Instruct-GGUF/Q4_K_M"},
+		{"localhost:5000/team/model", "localhost:5000/team/model/latest"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			assert.Equal(t, filepath.Join("/models", "manifests", test.expected), ollamaManifestPath("/models", test.ref))
+		})
+	}
+}
+
+func TestResolveModelPathFallsBackToFilePath(t *testing.T) {
+	t.Setenv("OLLAMA_MODELS", t.TempDir())
+
+	for _, ref := range []string{"/models/missing.gguf", "./model.gguf", "models/llama.bin"} {
+		path, err := ResolveModelPath(ref)
+		require.NoError(t, err)
+		assert.Equal(t, ref, path)
+	}
+}
+
+func TestResolveNamespacedModel(t *testing.T) {
+	modelsDir := t.TempDir()
+	t.Setenv("OLLAMA_MODELS", modelsDir)
+
+	manifestDir := filepath.Join(modelsDir, "manifests", "registry.ollama.ai", "opentdf", "assistant")
+	require.NoError(t, os.MkdirAll(manifestDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(manifestDir, "latest"),
+		[]byte(`{"layers":[{"mediaType":"application/vnd.ollama.image.model","digest":"sha256:abc123"}]}`), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(modelsDir, "blobs"), 0o755))
+	blob := filepath.Join(modelsDir, "blobs", "sha256-abc123")
+	require.NoError(t, os.WriteFile(blob, []byte("GGUF"), 0o600))
+
+	path, err := ResolveModelPath("opentdf/assistant")
+	require.NoError(t, err)
+	assert.Equal(t, blob, path)
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter selects documents by glob patterns matched against their relative paths.
+// A pattern matches a path when it matches the whole path, the file name, or one of the
+// directories the file is in, so "CHANGELOG.md" and "vendor" work at any depth.
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+// newPathFilter validates the patterns, which use path.Match syntax
+func newPathFilter(include, exclude []string) (pathFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return pathFilter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return pathFilter{include: include, exclude: exclude}, nil
+}
+
+// allows reports whether a document at relPath passes the filter: it must match an include
+// pattern, if there are any, and no exclude pattern
+func (f pathFilter) allows(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if matchAny(f.exclude, relPath) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, relPath)
+}
+
+// matchAny reports whether any pattern matches relPath, its file name, or a parent directory
+func matchAny(patterns []string, relPath string) bool {
+	candidates := []string{relPath, path.Base(relPath)}
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		candidates = append(candidates, strings.Join(parts[:i], "/"), parts[i-1])
+	}
+
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package llm
+
+import "strings"
+
+// StripEmoji removes emoji from text, along with the spaces that separated them from the
+// words they decorated, so status messages read as plain text
+func StripEmoji(text string) string {
+	if !strings.ContainsFunc(text, isEmoji) {
+		return text
+	}
+
+	runes := []rune(text)
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		if !isEmoji(runes[i]) {
+			out = append(out, runes[i])
+			continue
+		}
+
+		// Skip the rest of a multi-rune emoji such as a flag or a ZWJ sequence
+		for i+1 < len(runes) && isEmoji(runes[i+1]) {
+			i++
+		}
+		switch {
+		case atLineStart(out):
+			for i+1 < len(runes) && runes[i+1] == ' ' {
+				i++
+			}
+		case i+1 == len(runes) || runes[i+1] == '\n':
+			for len(out) > 0 && out[len(out)-1] == ' ' {
+				out = out[:len(out)-1]
+			}
+		case out[len(out)-1] == ' ':
+			// Mid-sentence, keep a single space between the surrounding words
+			for i+1 < len(runes) && runes[i+1] == ' ' {
+				i++
+			}
+		}
+	}
+	return string(out)
+}
+
+// atLineStart reports whether text is empty or ends with a newline followed only by indentation
+func atLineStart(text []rune) bool {
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case '\n':
+			return true
+		case ' ', '\t':
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isEmoji reports whether r is an emoji or a character that only modifies or joins emoji
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, transport and regional indicator symbols
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols and dingbats such as ⚠ and ✅
+	case r >= 0x2300 && r <= 0x23FF: // technical symbols such as ⏱ and ⌛
+	case r >= 0x2B00 && r <= 0x2BFF: // symbols such as ⭐ and ⬆
+	case r >= 0xE0020 && r <= 0xE007F: // tags used by subdivision flags
+	case r == 0x200D, r == 0x20E3, r == 0xFE0E, r == 0xFE0F, r == 0x2139:
+		// zero-width joiner, keycap, variation selectors and ℹ
+	default:
+		return false
+	}
+	return true
+}
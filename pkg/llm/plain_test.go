@@ -0,0 +1,27 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripEmoji(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"no emoji here", "no emoji here"},
+		{"🤖 OpenTDF LLM Chat started!\n", "OpenTDF LLM Chat started!\n"},
+		{"\n\n⏱️  Response time: 2s\n", "\n\nResponse time: 2s\n"},
+		{"⚠️  Warning: No documents found\n", "Warning: No documents found\n"},
+		{"   🔧 Loading model\n", "   Loading model\n"},
+		{"Goodbye! 👋", "Goodbye!"},
+		{"✅ RAG enabled\n❌ failed\n", "RAG enabled\nfailed\n"},
+		{"flags 🇺🇸 and 👩‍💻 in text", "flags and in text"},
+		{"💡 **Try:** use a GGUF file", "**Try:** use a GGUF file"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StripEmoji(tt.in), "input %q", tt.in)
+	}
+}
@@ -0,0 +1,48 @@
+package llm
+
+import "fmt"
+
+// DefaultContextShare is the fraction of a PromptBudget given to RAG context by default
+const DefaultContextShare = 0.5
+
+// PromptBudget splits an estimated token budget for the prompt between RAG context and
+// conversation history, so a large retrieval can't crowd out the question being asked and a
+// long conversation can't crowd out the documentation. The zero value disables it: RAG context
+// gets a fixed budget and the history is sent whole.
+type PromptBudget struct {
+	// Total is the estimated tokens the whole prompt may use (0 disables the budget)
+	Total int
+	// ContextShare is the fraction (0-1) of Total that RAG context may use. The conversation
+	// gets whatever the system prompt and the retrieved context leave, keeping the latest
+	// message however little that is.
+	ContextShare float64
+}
+
+// Validate checks that the total isn't negative and the share is a fraction
+func (b PromptBudget) Validate() error {
+	if b.Total < 0 {
+		return fmt.Errorf("prompt budget must not be negative, got %d", b.Total)
+	}
+	if b.ContextShare < 0 || b.ContextShare > 1 {
+		return fmt.Errorf("context share must be between 0 and 1, got %g", b.ContextShare)
+	}
+	return nil
+}
+
+// ContextTokens returns the estimated tokens RAG context may use
+func (b PromptBudget) ContextTokens() int {
+	if b.Total <= 0 {
+		return ragContextTokens
+	}
+	return int(float64(b.Total) * b.ContextShare)
+}
+
+// HistoryTokens returns the estimated tokens left for the conversation once the system message
+// is set aside, or 0, meaning no limit, when the budget is disabled. However full the system
+// message is, at least 1 is returned so the latest message is still kept.
+func (b PromptBudget) HistoryTokens(systemMessage string) int {
+	if b.Total <= 0 {
+		return 0
+	}
+	return max(b.Total-estimateTokens(systemMessage), 1)
+}
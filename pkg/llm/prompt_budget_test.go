@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptBudget(t *testing.T) {
+	assert.Equal(t, ragContextTokens, PromptBudget{}.ContextTokens(), "disabled keeps the fixed RAG budget")
+	assert.Zero(t, PromptBudget{}.HistoryTokens("system"), "disabled leaves the history whole")
+
+	budget := PromptBudget{Total: 400, ContextShare: 0.25}
+	require.NoError(t, budget.Validate())
+	assert.Equal(t, 100, budget.ContextTokens())
+	assert.Equal(t, 300, budget.HistoryTokens(strings.Repeat("s", 400)))
+	assert.Equal(t, 1, budget.HistoryTokens(strings.Repeat("s", 4000)), "the latest message is always kept")
+
+	require.Error(t, PromptBudget{Total: -1}.Validate())
+	require.Error(t, PromptBudget{Total: 100, ContextShare: 1.5}.Validate())
+}
+
+func TestPromptBudgetKeepsContextAndLatestTurn(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	// Each document is about 60 estimated tokens, so the context share below has room for two
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.AddDocument(SimpleDocument{
+			ID:      fmt.Sprintf("kas-%d", i),
+			Title:   "Key Access Service",
+			Content: fmt.Sprintf("Part %d: the key access service rewraps keys. ", i) + strings.Repeat("Rewrap details. ", 12),
+		}))
+	}
+
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.EnableSimpleRAG(store)
+	engine.SetRAGOptions(RAGContextOptions{MaxResults: 5})
+	engine.SetPromptBudget(PromptBudget{Total: 250, ContextShare: 0.5})
+
+	// A long conversation that alone would overrun the whole budget
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}}
+	for i := 0; i < 10; i++ {
+		messages = append(messages,
+			ChatMessage{Role: "user", Content: fmt.Sprintf("Earlier question %d. %s", i, strings.Repeat("Padding. ", 20))},
+			ChatMessage{Role: "assistant", Content: fmt.Sprintf("Earlier answer %d. %s", i, strings.Repeat("Padding. ", 20))},
+		)
+	}
+	question := "How does the key access service rewrap keys?"
+	messages = append(messages, ChatMessage{Role: "user", Content: question})
+
+	prompt, ragContext, err := engine.buildPromptWithRAG(messages, question)
+	require.NoError(t, err)
+	require.NotNil(t, ragContext)
+
+	assert.Equal(t, 2, ragContext.NumDocuments, "context is held to its share")
+	assert.Contains(t, prompt, "the key access service rewraps keys")
+	assert.Contains(t, prompt, "<|im_start|>user\n"+question+"<|im_end|>", "the latest turn is kept")
+	assert.NotContains(t, prompt, "Earlier question 0.", "the oldest turns are dropped")
+	assert.LessOrEqual(t, estimateTokens(prompt), 300, "the prompt stays near the budget")
+
+	// Without a budget the retrieval fills the fixed budget and the whole history is sent
+	engine.SetPromptBudget(PromptBudget{})
+	prompt, ragContext, err = engine.buildPromptWithRAG(messages, question)
+	require.NoError(t, err)
+	assert.Equal(t, 5, ragContext.NumDocuments)
+	assert.Contains(t, prompt, "Earlier question 0.")
+}
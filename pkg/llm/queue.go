@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// QueueStats reports how many generations are running, waiting, and were turned away
+type QueueStats struct {
+	MaxConcurrent int   `json:"max_concurrent"`
+	QueueDepth    int   `json:"queue_depth"`
+	Active        int   `json:"active"`
+	Queued        int   `json:"queued"`
+	Rejected      int64 `json:"rejected"`
+}
+
+// RequestQueue lets a bounded number of generations run at once and holds a bounded number
+// of further requests until a slot frees up, rejecting the rest with ErrQueueFull
+type RequestQueue struct {
+	// admitted holds one token per running or waiting request
+	admitted chan struct{}
+	// running holds one token per running request
+	running  chan struct{}
+	rejected atomic.Int64
+	depth    int
+}
+
+// NewRequestQueue creates a queue running at most maxConcurrent requests with up to depth more waiting
+func NewRequestQueue(maxConcurrent, depth int) *RequestQueue {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	return &RequestQueue{
+		admitted: make(chan struct{}, maxConcurrent+depth),
+		running:  make(chan struct{}, maxConcurrent),
+		depth:    depth,
+	}
+}
+
+// Acquire waits for a free slot and returns a function that releases it.
+// It fails immediately with ErrQueueFull when the queue is full, or with ctx's error if ctx ends while waiting.
+func (q *RequestQueue) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case q.admitted <- struct{}{}:
+	default:
+		q.rejected.Add(1)
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case q.running <- struct{}{}:
+	case <-ctx.Done():
+		<-q.admitted
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-q.running
+		<-q.admitted
+	}, nil
+}
+
+// Stats returns a snapshot of the queue
+func (q *RequestQueue) Stats() QueueStats {
+	active := len(q.running)
+	return QueueStats{
+		MaxConcurrent: cap(q.running),
+		QueueDepth:    q.depth,
+		Active:        active,
+		Queued:        max(len(q.admitted)-active, 0),
+		Rejected:      q.rejected.Load(),
+	}
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reranker scores how relevant each passage is to a query, so retrieval candidates can be
+// reordered by a slower but more precise judge than the first-stage search. Higher scores are
+// more relevant; only their order matters.
+type Reranker interface {
+	Score(query string, passages []string) ([]float32, error)
+}
+
+// rerankCandidates is how many search results a reranker chooses the RAG context from
+const rerankCandidates = 20
+
+// rerankOrder returns the positions of the best topK passages by r's scores, best first
+func rerankOrder(r Reranker, query string, passages []string, topK int) ([]int, error) {
+	scores, err := r.Score(query, passages)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(passages) {
+		return nil, fmt.Errorf("reranker returned %d scores for %d passages", len(scores), len(passages))
+	}
+
+	order := make([]int, len(passages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	if topK > 0 && topK < len(order) {
+		order = order[:topK]
+	}
+	return order, nil
+}
+
+// passagesOf returns each item's text
+func passagesOf[T any](items []T, text func(T) string) []string {
+	passages := make([]string, len(items))
+	for i, item := range items {
+		passages[i] = text(item)
+	}
+	return passages
+}
+
+// inOrder returns the items at the positions in order
+func inOrder[T any](items []T, order []int) []T {
+	ordered := make([]T, len(order))
+	for i, j := range order {
+		ordered[i] = items[j]
+	}
+	return ordered
+}
+
+// rerankPrompt asks the chat model to judge a passage for modelReranker
+const rerankPrompt = "You judge search results. Rate how well the passage helps answer the question, from 0 (unrelated) to 10 (answers it directly). Reply with the number only."
+
+// relevanceNumber finds the rating in a model's reply
+var relevanceNumber = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// modelReranker scores passages by asking the engine's chat model to rate each one. It runs
+// inference directly, so it must only be used while the engine's lock is held, as it is
+// during retrieval.
+type modelReranker struct {
+	engine *SimpleChatEngine
+}
+
+// Score rates each passage from 0 to 10
+func (m modelReranker) Score(query string, passages []string) ([]float32, error) {
+	sce := m.engine
+	if sce.model == nil || sce.context == nil {
+		return nil, ErrModelNotLoaded
+	}
+
+	// Ratings should be repeatable whatever sampling the conversation uses
+	sampling := sce.sampling
+	sce.sampling.Greedy = true
+	defer func() { sce.sampling = sampling }()
+
+	scores := make([]float32, len(passages))
+	for i, passage := range passages {
+		prompt := sce.buildPrompt(rerankPrompt, []ChatMessage{{Role: "user", Content: fmt.Sprintf("Question: %s\n\nPassage:\n%s", query, passage)}})
+
+		// Only the rating is needed, so generation stops once a complete number has arrived
+		ctx, cancel := context.WithCancel(context.Background())
+		var reply strings.Builder
+		_, _, _, err := sce.performStreamingInference(ctx, prompt, func(token string) {
+			reply.WriteString(token)
+			if ratingComplete(reply.String()) {
+				cancel()
+			}
+		})
+		stopped := ctx.Err() != nil
+		cancel()
+		if err != nil && !stopped {
+			return nil, err
+		}
+		scores[i] = parseRelevance(reply.String())
+	}
+	return scores, nil
+}
+
+// ratingComplete reports whether reply holds a whole rating, or has run on long enough that it
+// never will
+func ratingComplete(reply string) bool {
+	if len(reply) >= 16 {
+		return true
+	}
+	loc := relevanceNumber.FindStringIndex(reply)
+	if loc == nil {
+		return false
+	}
+	// A number at the very end may still be growing, e.g. "1" before "10" or "7." before "7.5"
+	return strings.TrimPrefix(reply[loc[1]:], ".") != ""
+}
+
+// parseRelevance reads the 0-10 rating from a model's reply, treating a reply without one as 0
+func parseRelevance(reply string) float32 {
+	score, err := strconv.ParseFloat(relevanceNumber.FindString(reply), 32)
+	if err != nil {
+		return 0
+	}
+	return float32(min(max(score, 0), 10))
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubReranker scores passages by the first matching substring in scores
+type stubReranker struct {
+	scores map[string]float32
+	err    error
+	calls  int
+}
+
+func (s *stubReranker) Score(_ string, passages []string) ([]float32, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make([]float32, len(passages))
+	for i, passage := range passages {
+		for text, score := range s.scores {
+			if strings.Contains(passage, text) {
+				out[i] = score
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestRerank(t *testing.T) {
+	candidates := []string{"kas rewrap", "policy attributes", "obligations watermark", "subject mappings"}
+	identity := func(s string) string { return s }
+	rerankWith := func(reranker Reranker, maxResults int) []string {
+		engine := NewSimpleChatEngine("missing.gguf")
+		engine.EnableRerank(reranker)
+		engine.SetRAGOptions(RAGContextOptions{MaxResults: maxResults})
+		return rerankResults(engine, "query", candidates, identity)
+	}
+
+	reranker := &stubReranker{scores: map[string]float32{"obligations": 9, "subject": 7, "kas": 7}}
+	reranked := rerankWith(reranker, 3)
+	assert.Equal(t, []string{"obligations watermark", "kas rewrap", "subject mappings"}, reranked, "ties keep retrieval order")
+
+	reranked = rerankWith(reranker, 4)
+	assert.Len(t, reranked, 4, "every candidate fits when the RAG candidates cover them")
+
+	reranked = rerankWith(&stubReranker{err: errors.New("boom")}, 2)
+	assert.Equal(t, candidates[:2], reranked, "a failed rerank keeps the retrieval order")
+}
+
+func TestEngineRerankChangesRAGContext(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	for _, doc := range openTDFDocs {
+		require.NoError(t, store.AddDocument(doc))
+	}
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "How do subject mappings decide access?"}}
+
+	prompt := func(reranker Reranker) string {
+		engine := NewSimpleChatEngine("missing.gguf")
+		engine.EnableSimpleRAG(store)
+		if reranker != nil {
+			engine.EnableRerank(reranker)
+		}
+		var shown strings.Builder
+		engine.SetPromptWriter(&shown)
+		require.NoError(t, engine.Start())
+		defer engine.Stop()
+		engine.Chat(messages)
+		return shown.String()
+	}
+
+	// Keyword search alone puts the subject mapping documents in the context
+	assert.NotContains(t, prompt(nil), "namespaces")
+
+	// The reranker's favorite wins a slot even though keyword search ranked it low
+	reranker := &stubReranker{scores: map[string]float32{"namespaces": 10}}
+	assert.Contains(t, prompt(reranker), "namespaces")
+	assert.Equal(t, 1, reranker.calls)
+
+	// A failing reranker falls back to the retrieval order
+	assert.NotContains(t, prompt(&stubReranker{err: errors.New("boom")}), "namespaces")
+}
+
+func TestCacheHitSkipsRerank(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	for _, doc := range openTDFDocs {
+		require.NoError(t, store.AddDocument(doc))
+	}
+	cache := NewResponseCache(t.TempDir())
+	reranker := &stubReranker{scores: map[string]float32{"namespaces": 10}}
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetSamplingOptions(SamplingOptions{Greedy: true})
+	engine.SetResponseCache(cache)
+	engine.EnableSimpleRAG(store)
+	engine.EnableRerank(reranker)
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "How do subject mappings decide access?"}}
+	resp := engine.Chat(messages)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+	require.Equal(t, 1, reranker.calls)
+	first := shown.String()
+	assert.Contains(t, first, "namespaces")
+
+	prompt := strings.TrimSuffix(strings.TrimPrefix(first, "----- prompt -----\n"), "\n----- end of prompt -----\n")
+	key := ResponseCacheKey("missing.gguf", prompt, SamplingOptions{Greedy: true}, DefaultModelParams(), engine.ContextSize())
+	require.NoError(t, cache.Put(key, SimpleResponse{Content: "Subject mappings match entitlements.", StopReason: StopReasonEOG}))
+
+	// The cached order rebuilds the same prompt, so the answer comes from the cache unreranked
+	shown.Reset()
+	resp = engine.Chat(messages)
+	require.NoError(t, resp.Error)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, first, shown.String())
+	assert.Equal(t, 1, reranker.calls)
+}
+
+func TestModelRerankerNeedsModel(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	_, err := modelReranker{engine: engine}.Score("query", []string{"passage"})
+	require.ErrorIs(t, err, ErrModelNotLoaded)
+}
+
+func TestParseRelevance(t *testing.T) {
+	assert.InDelta(t, 7, parseRelevance("7"), 0.0001)
+	assert.InDelta(t, 8.5, parseRelevance("Rating: 8.5/10"), 0.0001)
+	assert.InDelta(t, 10, parseRelevance("42"), 0.0001)
+	assert.Zero(t, parseRelevance("not relevant"))
+
+	assert.False(t, ratingComplete("1"))
+	assert.False(t, ratingComplete("7."))
+	assert.True(t, ratingComplete("10\n"))
+	assert.True(t, ratingComplete("7.5 because"))
+	assert.True(t, ratingComplete("I would say that this"))
+}
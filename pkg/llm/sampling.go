@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"github.com/ollama/ollama/llama"
+)
+
+// SamplingOptions controls how the engines pick the next token during generation
+type SamplingOptions struct {
+	Temperature float32
+	// Seed for the sampler's random number generator. A seed of 0 lets llama
+	// choose a time-based seed, so output is only reproducible with a non-zero seed.
+	Seed uint32
+	// Greedy forces deterministic decoding (temperature 0, top-k 1)
+	Greedy bool
+	// Penalties applied to recently generated tokens to discourage repetition
+	RepeatPenalty    float32
+	FrequencyPenalty float32
+	PresencePenalty  float32
+	RepeatLastN      int
+	// StopOnRepeat ends a response once its last StopOnRepeat tokens are a short cycle
+	// repeated back to back, as when a small model loops. 0 disables it.
+	StopOnRepeat int
+}
+
+// DefaultSamplingOptions returns the sampling options used when none are configured
+func DefaultSamplingOptions() SamplingOptions {
+	return SamplingOptions{
+		Temperature: 0.7,
+		Seed:        0,
+		Greedy:      false,
+
+		RepeatPenalty:    1.1,
+		FrequencyPenalty: 0.0,
+		PresencePenalty:  0.0,
+		RepeatLastN:      64,
+
+		StopOnRepeat: 0,
+	}
+}
+
+// Deterministic reports whether the same prompt always produces the same response: greedy
+// decoding or a fixed seed
+func (o SamplingOptions) Deterministic() bool {
+	return o.Greedy || o.Seed != 0
+}
+
+// params converts the options into llama sampling parameters, with the default settings for
+// the rest
+func (o SamplingOptions) params() llama.SamplingParams {
+	return o.paramsWith(DefaultModelParams())
+}
+
+// paramsWith converts the options into llama sampling parameters, taking the settings the
+// options don't cover from mp
+func (o SamplingOptions) paramsWith(mp ModelParams) llama.SamplingParams {
+	params := llama.SamplingParams{
+		TopK:           mp.TopK,
+		TopP:           mp.TopP,
+		MinP:           mp.MinP,
+		TypicalP:       mp.TypicalP,
+		Temp:           o.Temperature,
+		RepeatLastN:    o.RepeatLastN,
+		PenaltyRepeat:  o.RepeatPenalty,
+		PenaltyFreq:    o.FrequencyPenalty,
+		PenaltyPresent: o.PresencePenalty,
+		PenalizeNl:     mp.PenalizeNl,
+		Seed:           o.Seed,
+		Grammar:        mp.Grammar,
+	}
+
+	if o.Greedy {
+		params.Temp = 0
+		params.TopK = 1
+		params.TopP = 1.0
+		params.MinP = 0
+	}
+
+	return params
+}
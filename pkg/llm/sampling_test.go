@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingOptionsSeed(t *testing.T) {
+	opts := DefaultSamplingOptions()
+	opts.Seed = 42
+
+	// The same options must always yield the same sampler configuration
+	assert.Equal(t, opts.params(), opts.params())
+	assert.Equal(t, uint32(42), opts.params().Seed)
+	assert.InDelta(t, 0.7, opts.params().Temp, 0.0001)
+}
+
+func TestSamplingOptionsGreedy(t *testing.T) {
+	opts := DefaultSamplingOptions()
+	opts.Temperature = 1.2
+	opts.Greedy = true
+
+	params := opts.params()
+	assert.Zero(t, params.Temp)
+	assert.Equal(t, 1, params.TopK)
+	assert.InDelta(t, 1.0, params.TopP, 0.0001)
+	assert.Zero(t, params.MinP)
+}
+
+func TestSamplingOptionsPenalties(t *testing.T) {
+	opts := DefaultSamplingOptions()
+	opts.RepeatPenalty = 1.3
+	opts.FrequencyPenalty = 0.4
+	opts.PresencePenalty = 0.6
+	opts.RepeatLastN = 128
+
+	params := opts.params()
+	assert.InDelta(t, 1.3, params.PenaltyRepeat, 0.0001)
+	assert.InDelta(t, 0.4, params.PenaltyFreq, 0.0001)
+	assert.InDelta(t, 0.6, params.PenaltyPresent, 0.0001)
+	assert.Equal(t, 128, params.RepeatLastN)
+}
+
+func TestEngineSamplingParams(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	modelParams := DefaultModelParams()
+	modelParams.TopK = 40
+	modelParams.TypicalP = 0.95
+	engine.SetModelParams(modelParams)
+
+	seeded := DefaultSamplingOptions()
+	seeded.Seed = 42
+	engine.SetSamplingOptions(seeded)
+	params := engine.samplingParams()
+	assert.Equal(t, uint32(42), params.Seed, "the seed reaches the sampler")
+	assert.InDelta(t, 0.7, params.Temp, 0.0001)
+	assert.Equal(t, 40, params.TopK, "settings the options don't cover come from the model parameters")
+	assert.InDelta(t, 0.95, params.TypicalP, 0.0001)
+
+	greedy := seeded
+	greedy.Greedy = true
+	engine.SetSamplingOptions(greedy)
+	params = engine.samplingParams()
+	assert.Equal(t, uint32(42), params.Seed)
+	assert.Zero(t, params.Temp, "greedy decoding overrides the temperature")
+	assert.Equal(t, 1, params.TopK, "greedy decoding overrides the model's top-k")
+}
@@ -0,0 +1,262 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ChatGenerator produces a reply to a conversation. *SimpleChatEngine satisfies it.
+type ChatGenerator interface {
+	Chat(messages []ChatMessage) SimpleResponse
+	ChatStream(ctx context.Context, messages []ChatMessage, callback StreamingCallback) SimpleResponse
+}
+
+// ServerOptions configures the HTTP server
+type ServerOptions struct {
+	// MaxConcurrent is how many generations may run at once
+	MaxConcurrent int
+	// QueueDepth is how many more requests may wait for a slot before new ones get 429
+	QueueDepth int
+	// SessionTTL is how long an idle session's history is kept; 0 keeps sessions until deleted
+	SessionTTL time.Duration
+	// HistoryTokens caps each session's history, dropping the oldest turns first; 0 keeps everything
+	HistoryTokens int
+	// HistoryTurns caps how many earlier turns each session keeps, dropping the oldest first;
+	// 0 keeps everything
+	HistoryTurns int
+	// Metrics, when set, is served at GET /metrics along with the request queue's gauges
+	Metrics *PrometheusMetrics
+}
+
+// ChatCompletionRequest is the body of POST /v1/chat
+type ChatCompletionRequest struct {
+	// SessionID, when set, continues that session: Messages are appended to its stored history
+	SessionID string        `json:"session_id,omitempty"`
+	Messages  []ChatMessage `json:"messages"`
+	// Stream sends the reply as server-sent events, one per token
+	Stream bool `json:"stream,omitempty"`
+}
+
+// ChatCompletionResponse is returned by POST /v1/chat
+type ChatCompletionResponse struct {
+	SessionID string      `json:"session_id,omitempty"`
+	Message   ChatMessage `json:"message"`
+}
+
+// ChatStreamEvent is the data of each server-sent event of a streamed POST /v1/chat.
+// Every event carries one token until the last, which has Done set and the full message.
+type ChatStreamEvent struct {
+	Token     string       `json:"token,omitempty"`
+	Done      bool         `json:"done,omitempty"`
+	SessionID string       `json:"session_id,omitempty"`
+	Message   *ChatMessage `json:"message,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// errorResponse is the body of every non-2xx response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes a chat engine over HTTP
+type Server struct {
+	engine        ChatGenerator
+	queue         *RequestQueue
+	sessions      *SessionStore
+	historyTokens int
+	historyTurns  int
+	metrics       *PrometheusMetrics
+	logger        Logger
+	mux           *http.ServeMux
+}
+
+// NewServer creates an HTTP server that answers chat requests with the given engine
+func NewServer(engine ChatGenerator, opts ServerOptions) *Server {
+	s := &Server{
+		engine:        engine,
+		queue:         NewRequestQueue(opts.MaxConcurrent, opts.QueueDepth),
+		sessions:      NewSessionStore(opts.SessionTTL),
+		historyTokens: opts.HistoryTokens,
+		historyTurns:  opts.HistoryTurns,
+		metrics:       opts.Metrics,
+		logger:        NopLogger(),
+		mux:           http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /v1/chat", s.handleChat)
+	s.mux.HandleFunc("GET /v1/queue", s.handleQueue)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.handleDeleteSession)
+	if s.metrics != nil {
+		s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	}
+	return s
+}
+
+// SetLogger routes the server's diagnostic messages to the given logger
+func (s *Server) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleChat generates the next assistant message for the posted conversation
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "messages are required")
+		return
+	}
+
+	release, err := s.queue.Acquire(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			writeJSONError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		// The client went away while waiting for a slot
+		return
+	}
+	defer release()
+
+	messages := s.conversation(req)
+	if req.Stream {
+		s.streamChat(w, r, req, messages)
+		return
+	}
+
+	response := s.engine.Chat(messages)
+	if response.Error != nil {
+		s.logger.Printf("Chat request failed: %v", response.Error)
+		writeJSONError(w, statusForError(response.Error), response.Error.Error())
+		return
+	}
+
+	reply := ChatMessage{Role: "assistant", Content: response.Content}
+	s.saveSession(req, reply)
+	writeJSON(w, http.StatusOK, ChatCompletionResponse{SessionID: req.SessionID, Message: reply})
+}
+
+// conversation returns the messages to generate from: the request's own messages, or for a
+// session its stored history followed by them, limited to the history turns and truncated to
+// fit the history budget. The stored history itself is kept whole until the session expires.
+func (s *Server) conversation(req ChatCompletionRequest) []ChatMessage {
+	if req.SessionID == "" {
+		return req.Messages
+	}
+	messages := append(s.sessions.History(req.SessionID), req.Messages...)
+	return TruncateHistory(LimitHistory(messages, s.historyTurns), s.historyTokens)
+}
+
+// saveSession appends the request's messages and their reply to its session's history.
+// Appending rather than saving the conversation back keeps the turns of concurrent requests on
+// the same session, which each read the history before the other finished.
+func (s *Server) saveSession(req ChatCompletionRequest, reply ChatMessage) {
+	if req.SessionID == "" {
+		return
+	}
+	s.sessions.Append(req.SessionID, append(append([]ChatMessage(nil), req.Messages...), reply)...)
+}
+
+// handleDeleteSession drops a session's history
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.Delete(r.PathValue("id")) {
+		writeJSONError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// streamChat sends each generated token as a server-sent event. Generation is tied to the
+// request's context, so a client that disconnects stops it and frees its queue slot.
+func (s *Server) streamChat(w http.ResponseWriter, r *http.Request, req ChatCompletionRequest, messages []ChatMessage) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming is not supported by this connection")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	response := s.engine.ChatStream(r.Context(), messages, func(token string) {
+		writeEvent(w, ChatStreamEvent{Token: token})
+		flusher.Flush()
+	})
+	if response.Error != nil {
+		if r.Context().Err() != nil {
+			s.logger.Printf("Client disconnected, stopped streaming: %v", response.Error)
+			return
+		}
+		s.logger.Printf("Streaming chat request failed: %v", response.Error)
+		writeEvent(w, ChatStreamEvent{Error: response.Error.Error()})
+		flusher.Flush()
+		return
+	}
+
+	reply := ChatMessage{Role: "assistant", Content: response.Content}
+	s.saveSession(req, reply)
+	writeEvent(w, ChatStreamEvent{Done: true, SessionID: req.SessionID, Message: &reply})
+	flusher.Flush()
+}
+
+// handleQueue reports the request queue's current state
+func (s *Server) handleQueue(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.queue.Stats())
+}
+
+// handleMetrics serves the engine metrics and the request queue's gauges for a Prometheus scrape
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.Render(w)
+
+	stats := s.queue.Stats()
+	writeGauge(w, "otdfctl_llm_queue_active", "Generations currently running.", stats.Active)
+	writeGauge(w, "otdfctl_llm_queue_waiting", "Requests waiting for a generation slot.", stats.Queued)
+	writeCounter(w, "otdfctl_llm_queue_rejected_total", "Requests rejected because the queue was full.", uint64(stats.Rejected))
+}
+
+// statusForError maps engine errors to HTTP status codes
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrEngineNotRunning), errors.Is(err, ErrEngineShuttingDown), errors.Is(err, ErrModelNotLoaded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrEmptyQuery):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeEvent writes one server-sent event whose data is the JSON encoding of v
+func writeEvent(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
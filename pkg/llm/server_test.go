@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingGenerator holds every Chat call until release is closed and tracks peak concurrency
+type blockingGenerator struct {
+	release chan struct{}
+	started chan struct{}
+	active  atomic.Int32
+	peak    atomic.Int32
+}
+
+func newBlockingGenerator() *blockingGenerator {
+	return &blockingGenerator{release: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (g *blockingGenerator) Chat(messages []ChatMessage) SimpleResponse {
+	n := g.active.Add(1)
+	defer g.active.Add(-1)
+	for {
+		peak := g.peak.Load()
+		if n <= peak || g.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	g.started <- struct{}{}
+	<-g.release
+	return SimpleResponse{Content: "echo: " + messages[len(messages)-1].Content}
+}
+
+func (g *blockingGenerator) ChatStream(_ context.Context, messages []ChatMessage, callback StreamingCallback) SimpleResponse {
+	response := g.Chat(messages)
+	callback(response.Content)
+	return response
+}
+
+// tokenGenerator streams numbered tokens until its limit or until the request context ends
+type tokenGenerator struct {
+	limit     int
+	delay     time.Duration
+	generated atomic.Int32
+	stopped   chan error
+}
+
+func (g *tokenGenerator) Chat([]ChatMessage) SimpleResponse {
+	return SimpleResponse{Content: "unused"}
+}
+
+func (g *tokenGenerator) ChatStream(ctx context.Context, _ []ChatMessage, callback StreamingCallback) SimpleResponse {
+	var content strings.Builder
+	for i := 0; i < g.limit; i++ {
+		if err := ctx.Err(); err != nil {
+			g.stopped <- err
+			return SimpleResponse{Error: err}
+		}
+		token := fmt.Sprintf("t%d ", i)
+		content.WriteString(token)
+		callback(token)
+		g.generated.Add(1)
+		time.Sleep(g.delay)
+	}
+	g.stopped <- nil
+	return SimpleResponse{Content: content.String()}
+}
+
+func postChat(t *testing.T, handler http.Handler, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: content}}})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat", bytes.NewReader(body)))
+	return rec
+}
+
+func TestServerChat(t *testing.T) {
+	generator := newBlockingGenerator()
+	close(generator.release)
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+
+	rec := postChat(t, server, "hello")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response ChatCompletionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, ChatMessage{Role: "assistant", Content: "echo: hello"}, response.Message)
+}
+
+func TestServerChatStream(t *testing.T) {
+	generator := &tokenGenerator{limit: 3, stopped: make(chan error, 1)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat",
+		strings.NewReader(`{"messages":[{"role":"user","content":"hi"}],"stream":true}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	var events []ChatStreamEvent
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var event ChatStreamEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			events = append(events, event)
+		}
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, "t0 ", events[0].Token)
+	assert.True(t, events[3].Done)
+	assert.Equal(t, &ChatMessage{Role: "assistant", Content: "t0 t1 t2 "}, events[3].Message)
+}
+
+func TestServerStreamStopsOnDisconnect(t *testing.T) {
+	const limit = 512
+	generator := &tokenGenerator{limit: limit, delay: 2 * time.Millisecond, stopped: make(chan error, 1)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/chat", "application/json",
+		strings.NewReader(`{"messages":[{"role":"user","content":"hi"}],"stream":true}`))
+	require.NoError(t, err)
+
+	// Read the first token, then hang up mid-stream
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "data: "))
+	require.NoError(t, resp.Body.Close())
+
+	select {
+	case err := <-generator.stopped:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("generation kept running after the client disconnected")
+	}
+	assert.Less(t, int(generator.generated.Load()), limit)
+	require.Eventually(t, func() bool { return server.queue.Stats().Active == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestServerBadRequest(t *testing.T) {
+	server := NewServer(newBlockingGenerator(), ServerOptions{MaxConcurrent: 1})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat", bytes.NewReader([]byte(`{"messages":[]}`))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServerConcurrencyLimit(t *testing.T) {
+	const (
+		maxConcurrent = 2
+		queueDepth    = 3
+		requests      = 8
+	)
+	generator := newBlockingGenerator()
+	server := NewServer(generator, ServerOptions{MaxConcurrent: maxConcurrent, QueueDepth: queueDepth})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- postChat(t, server, "hi").Code
+		}()
+	}
+
+	// Wait until the running slots are taken and every request is either queued or rejected
+	for i := 0; i < maxConcurrent; i++ {
+		<-generator.started
+	}
+	require.Eventually(t, func() bool {
+		stats := server.queue.Stats()
+		return stats.Queued == queueDepth && stats.Rejected == requests-maxConcurrent-queueDepth
+	}, time.Second, 5*time.Millisecond)
+
+	stats := server.queue.Stats()
+	assert.Equal(t, maxConcurrent, stats.Active)
+
+	close(generator.release)
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, maxConcurrent+queueDepth, counts[http.StatusOK])
+	assert.Equal(t, requests-maxConcurrent-queueDepth, counts[http.StatusTooManyRequests])
+	assert.LessOrEqual(t, generator.peak.Load(), int32(maxConcurrent))
+}
+
+func TestServerQueueStats(t *testing.T) {
+	server := NewServer(newBlockingGenerator(), ServerOptions{MaxConcurrent: 2, QueueDepth: 4})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/queue", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats QueueStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, QueueStats{MaxConcurrent: 2, QueueDepth: 4}, stats)
+}
+
+func TestRequestQueueCancelWhileWaiting(t *testing.T) {
+	queue := NewRequestQueue(1, 1)
+	release, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = queue.Acquire(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, queue.Stats().Queued)
+
+	release()
+	assert.Zero(t, queue.Stats().Active)
+}
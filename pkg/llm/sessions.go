@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL is how long serve mode keeps an idle session's history
+const DefaultSessionTTL = 30 * time.Minute
+
+// SessionStore keeps a separate conversation history per session id in memory.
+// Sessions that go unused for longer than the TTL are dropped.
+type SessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*session
+	now      func() time.Time
+}
+
+type session struct {
+	messages []ChatMessage
+	lastUsed time.Time
+}
+
+// NewSessionStore creates an empty store whose sessions expire after ttl of inactivity.
+// A ttl of 0 or less keeps sessions until they are deleted.
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*session),
+		now:      time.Now,
+	}
+}
+
+// History returns a copy of the session's messages, or nil for an unknown or expired session
+func (s *SessionStore) History(id string) []ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	sess.lastUsed = s.now()
+	return append([]ChatMessage(nil), sess.messages...)
+}
+
+// Append adds messages to the end of the session's history in one step, creating the session
+// if needed. Unlike reading the history and saving it back, concurrent requests on a session
+// can't overwrite each other's turns.
+func (s *SessionStore) Append(id string, messages ...ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	sess, ok := s.sessions[id]
+	if !ok {
+		sess = &session{}
+		s.sessions[id] = sess
+	}
+	sess.messages = append(sess.messages, messages...)
+	sess.lastUsed = s.now()
+}
+
+// Delete drops a session and reports whether it existed
+func (s *SessionStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	if _, ok := s.sessions[id]; !ok {
+		return false
+	}
+	delete(s.sessions, id)
+	return true
+}
+
+// Len returns the number of live sessions
+func (s *SessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	return len(s.sessions)
+}
+
+// evictExpired drops sessions idle for longer than the TTL. Callers must hold s.mu.
+func (s *SessionStore) evictExpired() {
+	if s.ttl <= 0 {
+		return
+	}
+	now := s.now()
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastUsed) > s.ttl {
+			delete(s.sessions, id)
+		}
+	}
+}
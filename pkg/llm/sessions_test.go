@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingGenerator remembers the conversation it was asked to continue, keyed by its last message
+type recordingGenerator struct {
+	mu   sync.Mutex
+	seen map[string][]ChatMessage
+}
+
+func (g *recordingGenerator) Chat(messages []ChatMessage) SimpleResponse {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	last := messages[len(messages)-1].Content
+	g.seen[last] = append([]ChatMessage(nil), messages...)
+	return SimpleResponse{Content: "reply to " + last}
+}
+
+func (g *recordingGenerator) ChatStream(_ context.Context, messages []ChatMessage, callback StreamingCallback) SimpleResponse {
+	response := g.Chat(messages)
+	callback(response.Content)
+	return response
+}
+
+func postSessionChat(t *testing.T, handler http.Handler, sessionID, content string) ChatCompletionResponse {
+	t.Helper()
+	body, err := json.Marshal(ChatCompletionRequest{
+		SessionID: sessionID,
+		Messages:  []ChatMessage{{Role: "user", Content: content}},
+	})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response ChatCompletionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestServerSessionsAreIsolated(t *testing.T) {
+	generator := &recordingGenerator{seen: make(map[string][]ChatMessage)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+
+	assert.Equal(t, "alice", postSessionChat(t, server, "alice", "alice 1").SessionID)
+	postSessionChat(t, server, "bob", "bob 1")
+	postSessionChat(t, server, "alice", "alice 2")
+	postSessionChat(t, server, "bob", "bob 2")
+
+	assert.Equal(t, []ChatMessage{
+		{Role: "user", Content: "alice 1"},
+		{Role: "assistant", Content: "reply to alice 1"},
+		{Role: "user", Content: "alice 2"},
+	}, generator.seen["alice 2"])
+	assert.Equal(t, []ChatMessage{
+		{Role: "user", Content: "bob 1"},
+		{Role: "assistant", Content: "reply to bob 1"},
+		{Role: "user", Content: "bob 2"},
+	}, generator.seen["bob 2"])
+
+	// Requests without a session stay stateless
+	postSessionChat(t, server, "", "solo")
+	assert.Len(t, generator.seen["solo"], 1)
+	assert.Equal(t, 2, server.sessions.Len())
+}
+
+func TestServerSessionTruncation(t *testing.T) {
+	generator := &recordingGenerator{seen: make(map[string][]ChatMessage)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1, HistoryTokens: 10})
+
+	postSessionChat(t, server, "s", "first question that is long enough")
+	postSessionChat(t, server, "s", "second")
+
+	seen := generator.seen["second"]
+	require.NotEmpty(t, seen)
+	assert.Equal(t, "second", seen[len(seen)-1].Content)
+	assert.NotContains(t, seen, ChatMessage{Role: "user", Content: "first question that is long enough"})
+}
+
+func TestServerSessionHistoryLimit(t *testing.T) {
+	generator := &recordingGenerator{seen: make(map[string][]ChatMessage)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1, HistoryTurns: 2})
+
+	for i := 1; i <= 5; i++ {
+		postSessionChat(t, server, "s", fmt.Sprintf("question %d", i))
+	}
+
+	assert.Equal(t, []ChatMessage{
+		{Role: "user", Content: "question 3"},
+		{Role: "assistant", Content: "reply to question 3"},
+		{Role: "user", Content: "question 4"},
+		{Role: "assistant", Content: "reply to question 4"},
+		{Role: "user", Content: "question 5"},
+	}, generator.seen["question 5"])
+}
+
+// barrierGenerator holds each generation until every expected request has read its history
+type barrierGenerator struct {
+	arrived sync.WaitGroup
+}
+
+func (g *barrierGenerator) Chat(messages []ChatMessage) SimpleResponse {
+	g.arrived.Done()
+	g.arrived.Wait()
+	return SimpleResponse{Content: "reply to " + messages[len(messages)-1].Content}
+}
+
+func (g *barrierGenerator) ChatStream(_ context.Context, messages []ChatMessage, callback StreamingCallback) SimpleResponse {
+	response := g.Chat(messages)
+	callback(response.Content)
+	return response
+}
+
+func TestServerConcurrentSessionRequestsKeepEveryTurn(t *testing.T) {
+	generator := &barrierGenerator{}
+	generator.arrived.Add(2)
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 2})
+	server.sessions.Append("s", ChatMessage{Role: "user", Content: "first"}, ChatMessage{Role: "assistant", Content: "reply to first"})
+
+	// Both requests read the same history before either saves its turn
+	var wg sync.WaitGroup
+	for _, question := range []string{"second", "third"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postSessionChat(t, server, "s", question)
+		}()
+	}
+	wg.Wait()
+
+	history := server.sessions.History("s")
+	require.Len(t, history, 6)
+	for _, question := range []string{"first", "second", "third"} {
+		assert.Contains(t, history, ChatMessage{Role: "user", Content: question})
+		assert.Contains(t, history, ChatMessage{Role: "assistant", Content: "reply to " + question})
+	}
+}
+
+func TestServerDeleteSession(t *testing.T) {
+	generator := &recordingGenerator{seen: make(map[string][]ChatMessage)}
+	server := NewServer(generator, ServerOptions{MaxConcurrent: 1})
+	postSessionChat(t, server, "gone", "hello")
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/sessions/gone", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Zero(t, server.sessions.Len())
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/sessions/gone", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// A deleted session starts over
+	postSessionChat(t, server, "gone", "again")
+	assert.Len(t, generator.seen["again"], 1)
+}
+
+func TestSessionStoreExpires(t *testing.T) {
+	now := time.Now()
+	store := NewSessionStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	store.Append("a", ChatMessage{Role: "user", Content: "hi"})
+	now = now.Add(30 * time.Second)
+	require.Len(t, store.History("a"), 1)
+
+	// Reading the history counts as use, so the session outlives its original TTL
+	now = now.Add(45 * time.Second)
+	require.Len(t, store.History("a"), 1)
+
+	now = now.Add(2 * time.Minute)
+	assert.Nil(t, store.History("a"))
+	assert.Zero(t, store.Len())
+}
@@ -1,10 +1,14 @@
 package llm
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ollama/ollama/llama"
 )
@@ -14,29 +18,193 @@ type SimpleChatEngine struct {
 	modelPath        string
 	model           *llama.Model
 	context         *llama.Context
-	simpleRAGStore  *SimpleRAGStore
+	simpleRAGStore  KeywordStore
+	vectorStore     Store
+	embedder        Embedder
 	ragEnabled      bool
 	mu              sync.Mutex
 	running         bool
+	sampling        SamplingOptions
+	modelParams     ModelParams
+	metrics         Metrics
+	ragOptions      RAGContextOptions
+	budget          PromptBudget
+	requestedSize   int
+	contextSize     int
+	loaded          map[string]loadedModel
+	loadOrder       []string
+	batchSize       int
+	logger          Logger
+	promptWriter    io.Writer
+	examples        []ChatMessage
+	fileContext     string
+	cache           *ResponseCache
+	warnWriter      io.Writer
+	warnThreshold   float64
+	reranker        Reranker
 }
 
 // NewSimpleChatEngine creates a new simplified chat engine
 func NewSimpleChatEngine(modelPath string) *SimpleChatEngine {
 	return &SimpleChatEngine{
-		modelPath:  modelPath,
-		ragEnabled: false,
-		running:    false,
+		modelPath:   modelPath,
+		ragEnabled:  false,
+		running:     false,
+		sampling:    DefaultSamplingOptions(),
+		modelParams: DefaultModelParams(),
+		metrics:     NopMetrics(),
+		logger:      NopLogger(),
 	}
 }
 
+// SetLogger routes the engine's diagnostic messages to the given logger
+func (sce *SimpleChatEngine) SetLogger(logger Logger) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.logger = logger
+}
+
+// SetPromptWriter makes the engine write each assembled prompt, including any injected
+// RAG context, to w before inference. A nil writer turns this off.
+func (sce *SimpleChatEngine) SetPromptWriter(w io.Writer) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.promptWriter = w
+}
+
+// SetContextWarning makes the engine warn on w before generating from a prompt that fills
+// more than threshold, a fraction from 0-1, of the context window, since a prompt that
+// doesn't fit is truncated. A nil writer or a threshold of 0 turns this off.
+func (sce *SimpleChatEngine) SetContextWarning(threshold float64, w io.Writer) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.warnThreshold = threshold
+	sce.warnWriter = w
+}
+
+// SetMetrics records the engine's request, generation, and retrieval measurements in m
+func (sce *SimpleChatEngine) SetMetrics(m Metrics) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.metrics = m
+}
+
+// SetSamplingOptions configures how tokens are sampled during generation
+func (sce *SimpleChatEngine) SetSamplingOptions(opts SamplingOptions) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.sampling = opts
+}
+
+// SetModelParams sets the advanced llama settings used when the model is next loaded and for
+// each generation
+func (sce *SimpleChatEngine) SetModelParams(params ModelParams) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.modelParams = params
+}
+
+// SetContextSize sets the context window created by Start and SwitchModel.
+// 0 uses the context size each model was trained with.
+func (sce *SimpleChatEngine) SetContextSize(n int) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.requestedSize = n
+	sce.contextSize = n
+}
+
+// SetBatchSize sets how many prompt tokens are decoded per pass, which is also the context's
+// batch size. Longer prompts take several passes. 0 uses the default of 512.
+func (sce *SimpleChatEngine) SetBatchSize(n int) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.batchSize = n
+}
+
+// ContextSize returns the context window in use, or the requested size before Start
+func (sce *SimpleChatEngine) ContextSize() int {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	return sce.contextSize
+}
+
+// SetExamples sets few-shot example turns placed after the system message in every prompt.
+// They aren't part of the conversation, so history truncation never drops them.
+func (sce *SimpleChatEngine) SetExamples(examples []ChatMessage) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.examples = examples
+}
+
+// SetFileContext sets reference material, such as the Text of a BuildFileContext, added to
+// the system message of every prompt
+func (sce *SimpleChatEngine) SetFileContext(text string) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.fileContext = text
+}
+
+// HistoryTokens returns the estimated tokens left for conversation history in the context
+// window once the few-shot examples, file context and a reply of replyTokens are set aside
+func (sce *SimpleChatEngine) HistoryTokens(replyTokens int) int {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	available := sce.contextSize - replyTokens - estimateTokens(sce.fileContext)
+	for _, example := range sce.examples {
+		available -= estimateTokens(example.Content)
+	}
+	return max(available, 0)
+}
+
+// SetRAGOptions configures how retrieved documents are assembled into the RAG context
+func (sce *SimpleChatEngine) SetRAGOptions(opts RAGContextOptions) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.ragOptions = opts
+}
+
+// SetPromptBudget splits each prompt's token budget between RAG context and conversation
+// history. The zero PromptBudget, the default, gives RAG context a fixed budget and leaves the
+// history whole.
+func (sce *SimpleChatEngine) SetPromptBudget(budget PromptBudget) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.budget = budget
+}
+
 // EnableSimpleRAG enables RAG with the simple store
-func (sce *SimpleChatEngine) EnableSimpleRAG(store *SimpleRAGStore) {
+func (sce *SimpleChatEngine) EnableSimpleRAG(store KeywordStore) {
 	sce.mu.Lock()
 	defer sce.mu.Unlock()
 	
 	sce.simpleRAGStore = store
 	sce.ragEnabled = true
-	log.Printf("Simple RAG enabled with %d documents", store.GetDocumentCount())
+	sce.logger.Printf("Simple RAG enabled with %d documents", store.GetDocumentCount())
+}
+
+// EnableRAG enables RAG with a vector store, embedding each query with the given embedder
+func (sce *SimpleChatEngine) EnableRAG(store Store, embedder Embedder) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	sce.vectorStore = store
+	sce.embedder = embedder
+	sce.ragEnabled = true
+	sce.logger.Printf("RAG enabled with %d documents in vector store", store.GetDocumentCount())
 }
 
 // Start initializes the model
@@ -45,54 +213,182 @@ func (sce *SimpleChatEngine) Start() error {
 	defer sce.mu.Unlock()
 	
 	if sce.running {
-		return fmt.Errorf("engine already running")
+		return ErrEngineAlreadyRunning
+	}
+	
+	// A model in the wrong format is a mistake to report, not a reason to fall back to simulation
+	if err := checkModelFile(sce.modelPath); errors.Is(err, ErrNotGGUF) {
+		return err
 	}
 	
-	log.Printf("Loading model from %s...", sce.modelPath)
+	sce.loadModel()
+	
+	sce.running = true
+	sce.logger.Printf("Simple chat engine initialized")
+	return nil
+}
+
+// loadedModel is a model the engine has loaded, with the context created for it
+type loadedModel struct {
+	model       *llama.Model
+	context     *llama.Context
+	contextSize int
+}
+
+// loadModel loads the model and creates its context, leaving both nil if loading fails.
+// Callers must hold sce.mu.
+func (sce *SimpleChatEngine) loadModel() {
+	loaded, err := sce.openLoaded(sce.modelPath)
+	if err != nil {
+		sce.logger.Printf("Model loading failed: %v", err)
+		sce.logger.Printf("Continuing without model (simulation mode)")
+		sce.model, sce.context = nil, nil
+		return
+	}
+	sce.use(loaded)
+}
+
+// maxLoadedModels is how many models the engine keeps loaded: the one in use and the one it
+// last switched away from, so switching back and forth between two models doesn't reload them
+const maxLoadedModels = 2
+
+// openLoaded returns the model at modelPath, loading it unless the engine already has. The
+// binding can't free a context, so a model and its context are kept once loaded and reused
+// when the engine switches back to them, rather than leaking a new context on every load.
+// Loading a model beyond maxLoadedModels frees the weights of the least recently used one.
+// Callers must hold sce.mu.
+func (sce *SimpleChatEngine) openLoaded(modelPath string) (loadedModel, error) {
+	if loaded, ok := sce.loaded[modelPath]; ok {
+		sce.touchLoaded(modelPath)
+		return loaded, nil
+	}
+	model, context, contextSize, err := loadModelFile(sce, modelPath)
+	if err != nil {
+		return loadedModel{}, err
+	}
+	loaded := loadedModel{model: model, context: context, contextSize: contextSize}
+	if sce.loaded == nil {
+		sce.loaded = make(map[string]loadedModel)
+	}
+	sce.loaded[modelPath] = loaded
+	sce.touchLoaded(modelPath)
+	for len(sce.loadOrder) > maxLoadedModels {
+		sce.evictLoaded(sce.loadOrder[0])
+	}
+	return loaded, nil
+}
+
+// touchLoaded marks modelPath as the most recently used loaded model. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) touchLoaded(modelPath string) {
+	sce.loadOrder = slices.DeleteFunc(sce.loadOrder, func(path string) bool { return path == modelPath })
+	sce.loadOrder = append(sce.loadOrder, modelPath)
+}
+
+// evictLoaded frees the weights of the loaded model at modelPath and forgets it, so switching
+// back loads it again. Its context can't be freed and is never decoded with again. Callers
+// must hold sce.mu.
+func (sce *SimpleChatEngine) evictLoaded(modelPath string) {
+	if loaded := sce.loaded[modelPath]; loaded.model != nil {
+		llama.FreeModel(loaded.model)
+	}
+	delete(sce.loaded, modelPath)
+	sce.loadOrder = slices.DeleteFunc(sce.loadOrder, func(path string) bool { return path == modelPath })
+	sce.logger.Printf("Unloaded model %s", modelPath)
+}
+
+// use makes loaded the model the engine generates with. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) use(loaded loadedModel) {
+	sce.model = loaded.model
+	sce.context = loaded.context
+	sce.contextSize = loaded.contextSize
+}
+
+// loadModelFile loads a model and creates its context. Tests replace it to switch models
+// without loading one.
+var loadModelFile = (*SimpleChatEngine).openModel
+
+// openModel loads the model at modelPath and creates its context, returning the context size
+// it was created with. Nothing is kept if either step fails. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) openModel(modelPath string) (*llama.Model, *llama.Context, int, error) {
+	sce.logger.Printf("Loading model from %s...", modelPath)
 	
 	// Initialize llama backend
 	llama.BackendInit()
 	
-	// Set up model parameters
-	modelParams := llama.ModelParams{
-		NumGpuLayers: -1, // Use all available GPU layers
-		UseMmap:      true,
-		VocabOnly:    false,
+	// Load model
+	var model *llama.Model
+	err := checkModelFile(modelPath)
+	if err == nil {
+		model, err = llama.LoadModelFromFile(modelPath, sce.modelParams.model())
+	}
+	if err != nil {
+		return nil, nil, 0, err
 	}
 	
-	// Load model
-	model, err := llama.LoadModelFromFile(sce.modelPath, modelParams)
+	info, err := ReadModelInfo(modelPath)
 	if err != nil {
-		log.Printf("Model loading failed: %v", err)
-		log.Printf("Continuing without model (simulation mode)")
-		sce.model = nil
+		sce.logger.Printf("Warning: could not read model metadata: %v", err)
 	} else {
-		sce.model = model
-		
-		// Create context
-		contextParams := llama.NewContextParams(
-			4096, // numCtx
-			512,  // batchSize
-			1,    // numSeqMax
-			4,    // threads
-			false, // flashAttention
-			"",   // kvCacheType
-		)
-		
-		context, err := llama.NewContextWithModel(model, contextParams)
-		if err != nil {
-			log.Printf("Context creation failed: %v", err)
-			sce.context = nil
-		} else {
-			sce.context = context
-		}
+		sce.logger.Printf("Model %s trained with a %d token context (add BOS: %v, add EOS: %v)",
+			info.Architecture, info.ContextLength, info.AddBOS, info.AddEOS)
 	}
+	// Resolve from the requested size, not the last model's, so 0 still means this model's trained size
+	contextSize := resolveContextSize(sce.requestedSize, info, sce.logger)
 	
-	sce.running = true
-	log.Printf("Simple chat engine initialized")
+	// Create context
+	context, err := sce.modelParams.newContext(model, contextSize, sce.promptBatchSize(), sce.logger)
+	if err != nil {
+		llama.FreeModel(model)
+		return nil, nil, 0, fmt.Errorf("context creation failed: %w", err)
+	}
+	return model, context, contextSize, nil
+}
+
+// SwitchModel replaces the running model with the model at modelPath, keeping every other
+// setting. The context size is resolved for the new model from the size requested with
+// SetContextSize. The old model stays loaded and is reused if the engine switches back to it,
+// up to maxLoadedModels; if loading fails it stays active and the error is returned.
+func (sce *SimpleChatEngine) SwitchModel(modelPath string) error {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	if !sce.running {
+		return ErrEngineNotRunning
+	}
+	
+	loaded, err := sce.openLoaded(modelPath)
+	if err != nil {
+		return err
+	}
+	
+	sce.use(loaded)
+	sce.modelPath = modelPath
+	sce.logger.Printf("Switched to model %s", modelPath)
 	return nil
 }
 
+// ModelPath returns the path of the model the engine runs
+func (sce *SimpleChatEngine) ModelPath() string {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	return sce.modelPath
+}
+
+// unloadModels frees every loaded model's weights. Their contexts can't be freed and are never
+// decoded with again. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) unloadModels() {
+	for _, loaded := range sce.loaded {
+		if loaded.model != nil {
+			llama.FreeModel(loaded.model)
+		}
+	}
+	sce.loaded = nil
+	sce.loadOrder = nil
+	sce.model = nil
+	sce.context = nil
+}
+
 // Stop cleans up resources
 func (sce *SimpleChatEngine) Stop() {
 	sce.mu.Lock()
@@ -102,20 +398,36 @@ func (sce *SimpleChatEngine) Stop() {
 		return
 	}
 	
-	if sce.model != nil {
-		llama.FreeModel(sce.model)
-		sce.model = nil
-	}
-	
-	sce.context = nil
+	sce.unloadModels()
 	sce.running = false
-	log.Printf("Simple chat engine stopped")
+	sce.logger.Printf("Simple chat engine stopped")
 }
 
 // SimpleResponse represents a simple response without streaming
 type SimpleResponse struct {
-	Content string
-	Error   error
+	Content          string
+	TokenCount       int           // Number of tokens sampled for Content, the same as CompletionTokens
+	PromptTokens     int           // Tokens in the prompt after any truncation
+	CompletionTokens int           // Tokens sampled for Content
+	StopReason       StopReason    // Why generation ended
+	Duration         time.Duration // Time from tokenizing the prompt to the last token
+	RAGUsed          bool          // Whether documentation was retrieved for this response
+	RetrievalScore   float32       // Best retrieval score when RAGUsed
+	LowConfidence    bool          // RetrievalScore was below the minimum confidence, so Content starts with LowConfidenceWarning
+	Cached           bool          // Content came from the response cache without running the model
+	Error            error
+}
+
+// newSimpleResponse describes a finished generation
+func newSimpleResponse(content string, timings GenerationTimings, reason StopReason) SimpleResponse {
+	return SimpleResponse{
+		Content:          content,
+		TokenCount:       timings.GeneratedTokens,
+		PromptTokens:     timings.PromptTokens,
+		CompletionTokens: timings.GeneratedTokens,
+		StopReason:       reason,
+		Duration:         timings.Total,
+	}
 }
 
 // StreamingCallback is called for each generated token during streaming
@@ -123,68 +435,170 @@ type StreamingCallback func(token string)
 
 // Chat performs a simple chat without streaming
 func (sce *SimpleChatEngine) Chat(messages []ChatMessage) SimpleResponse {
+	return sce.ChatContext(context.Background(), messages)
+}
+
+// ChatContext is Chat that stops generating with ctx's error when ctx is cancelled
+func (sce *SimpleChatEngine) ChatContext(ctx context.Context, messages []ChatMessage) SimpleResponse {
 	sce.mu.Lock()
 	defer sce.mu.Unlock()
 	
 	if !sce.running {
-		return SimpleResponse{Error: fmt.Errorf("engine not running")}
+		return SimpleResponse{Error: ErrEngineNotRunning}
 	}
+	sce.metrics.ObserveRequest()
 	
 	// Extract user query for RAG
 	userQuery := sce.extractUserQuery(messages)
+	if IsBlank(userQuery) {
+		return SimpleResponse{Error: ErrEmptyQuery}
+	}
 	
 	// Build prompt with optional RAG context
-	prompt, err := sce.buildPromptWithRAG(messages, userQuery)
+	prompt, ragContext, err := sce.buildPromptWithRAG(messages, userQuery)
 	if err != nil {
-		return SimpleResponse{Error: fmt.Errorf("failed to build prompt: %v", err)}
+		return SimpleResponse{Error: fmt.Errorf("failed to build prompt: %w", err)}
+	}
+	sce.showPrompt(prompt)
+	
+	if resp, ok := sce.cachedResponse(prompt); ok {
+		sce.addRetrieval(&resp, ragContext)
+		return resp
 	}
 	
 	// Perform inference
 	if sce.model == nil || sce.context == nil {
-		return SimpleResponse{Error: fmt.Errorf("model or context not loaded")}
+		return SimpleResponse{Error: ErrModelNotLoaded}
 	}
 	
-	log.Printf("Starting inference...")
-	response, err := sce.performSimpleInference(prompt)
+	sce.logger.Printf("Starting inference...")
+	response, timings, reason, err := sce.performStreamingInference(ctx, prompt, nil)
 	if err != nil {
-		log.Printf("Inference failed: %v", err)
+		sce.logger.Printf("Inference failed: %v", err)
 		return SimpleResponse{Error: err}
 	}
+	sce.metrics.ObserveGeneration(timings)
 	
-	return SimpleResponse{Content: response}
+	resp := newSimpleResponse(response, timings, reason)
+	sce.cacheResponse(prompt, resp)
+	sce.addRetrieval(&resp, ragContext)
+	return resp
 }
 
-// ChatStream performs a simple chat with streaming output
-func (sce *SimpleChatEngine) ChatStream(messages []ChatMessage, callback StreamingCallback) SimpleResponse {
+// ChatStream performs a simple chat with streaming output.
+// Generation stops early with ctx's error when ctx is cancelled.
+func (sce *SimpleChatEngine) ChatStream(ctx context.Context, messages []ChatMessage, callback StreamingCallback) SimpleResponse {
 	sce.mu.Lock()
 	defer sce.mu.Unlock()
 	
 	if !sce.running {
-		return SimpleResponse{Error: fmt.Errorf("engine not running")}
+		return SimpleResponse{Error: ErrEngineNotRunning}
 	}
+	sce.metrics.ObserveRequest()
 	
 	// Extract user query for RAG
 	userQuery := sce.extractUserQuery(messages)
+	if IsBlank(userQuery) {
+		return SimpleResponse{Error: ErrEmptyQuery}
+	}
 	
 	// Build prompt with optional RAG context
-	prompt, err := sce.buildPromptWithRAG(messages, userQuery)
+	prompt, ragContext, err := sce.buildPromptWithRAG(messages, userQuery)
 	if err != nil {
-		return SimpleResponse{Error: fmt.Errorf("failed to build prompt: %v", err)}
+		return SimpleResponse{Error: fmt.Errorf("failed to build prompt: %w", err)}
 	}
+	sce.showPrompt(prompt)
+	
+	cached, hit := sce.cachedResponse(prompt)
 	
 	// Perform streaming inference
-	if sce.model == nil || sce.context == nil {
-		return SimpleResponse{Error: fmt.Errorf("model or context not loaded")}
+	if !hit && (sce.model == nil || sce.context == nil) {
+		return SimpleResponse{Error: ErrModelNotLoaded}
 	}
 	
-	log.Printf("Starting streaming inference...")
-	response, err := sce.performStreamingInference(prompt, callback)
+	// The low confidence warning comes before the answer, so it is streamed first
+	if sce.lowConfidence(ragContext) && callback != nil {
+		callback(LowConfidenceWarning)
+	}
+	
+	// A cached answer is streamed in one piece
+	if hit {
+		if callback != nil {
+			callback(cached.Content)
+		}
+		sce.addRetrieval(&cached, ragContext)
+		return cached
+	}
+	
+	sce.logger.Printf("Starting streaming inference...")
+	response, timings, reason, err := sce.performStreamingInference(ctx, prompt, callback)
 	if err != nil {
-		log.Printf("Streaming inference failed: %v", err)
-		return SimpleResponse{Error: err}
+		sce.logger.Printf("Streaming inference failed: %v", err)
+		// A cancelled generation still reports what was produced before it stopped
+		resp := newSimpleResponse(response, timings, reason)
+		sce.addRetrieval(&resp, ragContext)
+		resp.Error = err
+		return resp
 	}
+	sce.metrics.ObserveGeneration(timings)
+	
+	resp := newSimpleResponse(response, timings, reason)
+	sce.cacheResponse(prompt, resp)
+	sce.addRetrieval(&resp, ragContext)
+	return resp
+}
+
+// SetResponseCache stores each finished response in cache and answers prompts found there
+// without running the model. Only set a cache for deterministic sampling options. A nil cache
+// disables caching.
+func (sce *SimpleChatEngine) SetResponseCache(cache *ResponseCache) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
 	
-	return SimpleResponse{Content: response}
+	sce.cache = cache
+}
+
+// cachedResponse returns the cached response to prompt, if caching is enabled and there is one
+func (sce *SimpleChatEngine) cachedResponse(prompt string) (SimpleResponse, bool) {
+	if sce.cache == nil {
+		return SimpleResponse{}, false
+	}
+	resp, ok := sce.cache.Get(ResponseCacheKey(sce.modelPath, prompt, sce.sampling, sce.modelParams, sce.contextSize))
+	if ok {
+		sce.logger.Printf("Answering from the response cache")
+	}
+	return resp, ok
+}
+
+// cacheResponse stores resp as the answer to prompt, if caching is enabled. A failed write
+// only costs a future cache hit, so it is logged rather than returned.
+func (sce *SimpleChatEngine) cacheResponse(prompt string, resp SimpleResponse) {
+	if sce.cache == nil {
+		return
+	}
+	if err := sce.cache.Put(ResponseCacheKey(sce.modelPath, prompt, sce.sampling, sce.modelParams, sce.contextSize), resp); err != nil {
+		sce.logger.Printf("Warning: failed to cache response: %v", err)
+	}
+}
+
+// lowConfidence reports whether retrieval ran and its best match scored below the
+// configured minimum confidence
+func (sce *SimpleChatEngine) lowConfidence(ragContext *RAGContext) bool {
+	return ragContext != nil && ragContext.TopScore < sce.ragOptions.MinConfidence
+}
+
+// addRetrieval records the retrieval score on a response and, when it is below the minimum
+// confidence, prefixes the answer with LowConfidenceWarning. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) addRetrieval(resp *SimpleResponse, ragContext *RAGContext) {
+	if ragContext == nil {
+		return
+	}
+	resp.RAGUsed = true
+	resp.RetrievalScore = ragContext.TopScore
+	if sce.lowConfidence(ragContext) {
+		resp.LowConfidence = true
+		resp.Content = LowConfidenceWarning + resp.Content
+	}
 }
 
 // extractUserQuery gets the latest user message
@@ -197,37 +611,260 @@ func (sce *SimpleChatEngine) extractUserQuery(messages []ChatMessage) string {
 	return ""
 }
 
-// buildPromptWithRAG builds prompt with RAG context
-func (sce *SimpleChatEngine) buildPromptWithRAG(messages []ChatMessage, userQuery string) (string, error) {
+// retrievalQuery returns the text RAG searches with: the latest user message, preceded by up to
+// QueryTurns-1 earlier ones. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) retrievalQuery(messages []ChatMessage) string {
+	var turns []string
+	for i := len(messages) - 1; i >= 0 && len(turns) < max(sce.ragOptions.QueryTurns, 1); i-- {
+		if messages[i].Role == "user" {
+			turns = append(turns, messages[i].Content)
+		}
+	}
+	slices.Reverse(turns)
+	return strings.Join(turns, "\n")
+}
+
+// skipsRAG reports whether the latest user message asked to be answered without retrieval
+func skipsRAG(messages []ChatMessage) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].NoRAG
+		}
+	}
+	return false
+}
+
+// buildPromptWithRAG builds prompt with RAG context. The retrieved context is returned
+// too, or nil when RAG is disabled, skipped for this message or retrieval failed.
+func (sce *SimpleChatEngine) buildPromptWithRAG(messages []ChatMessage, userQuery string) (string, *RAGContext, error) {
 	var systemMessage string
 	var conversationMessages []ChatMessage
 	
-	// Separate system message from conversation
+	// Separate system messages from conversation; a history summary follows the system prompt
 	for _, msg := range messages {
 		if msg.Role == "system" {
-			systemMessage = msg.Content
+			if systemMessage != "" {
+				systemMessage += "\n\n"
+			}
+			systemMessage += msg.Content
 		} else {
 			conversationMessages = append(conversationMessages, msg)
 		}
 	}
 	
+	// Reference files stay in every prompt, whatever the system message is replaced with
+	if sce.fileContext != "" {
+		systemMessage = JoinSystemPrompts(systemMessage, sce.fileContext)
+	}
+	
 	// Add RAG context if enabled
-	if sce.ragEnabled && userQuery != "" && sce.simpleRAGStore != nil {
-		results, err := sce.simpleRAGStore.Search(userQuery, 2) // Top 2 results
+	var retrieved *RAGContext
+	if sce.ragEnabled && userQuery != "" && !skipsRAG(messages) {
+		retrievalStart := time.Now()
+		ragContext, err := sce.retrieveContext(sce.retrievalQuery(messages))
+		sce.metrics.ObserveRetrieval(time.Since(retrievalStart), ragContext.NumDocuments)
 		if err != nil {
-			log.Printf("Warning: RAG search failed: %v", err)
-		} else if len(results) > 0 {
-			ragContext := BuildSimpleRAGContext(userQuery, results, 800) // Reduced from 1500 to 800 tokens
-			if ragContext.NumDocuments > 0 {
-				enhancedSystem := fmt.Sprintf("%s\n\n%s\n\nBased on the above documentation, please provide accurate and helpful responses about OpenTDF.",
-					systemMessage, ragContext.ContextText)
-				systemMessage = enhancedSystem
-				log.Printf("Simple RAG: Retrieved %d relevant documents", ragContext.NumDocuments)
-			}
+			sce.logger.Printf("Warning: RAG retrieval failed: %v", err)
+		} else if ragContext.NumDocuments > 0 {
+			enhancedSystem := fmt.Sprintf("%s\n\n%s\n\nBased on the above documentation, please provide accurate and helpful responses about OpenTDF.",
+				systemMessage, ragContext.ContextText)
+			systemMessage = enhancedSystem
+			sce.logger.Printf("RAG: Retrieved %d relevant documents", ragContext.NumDocuments)
+		} else if sce.ragOptions.NoContextNote != "" {
+			systemMessage = fmt.Sprintf("%s\n\n%s", systemMessage, sce.ragOptions.NoContextNote)
+			sce.logger.Printf("RAG: No relevant documents found")
+		}
+		if err == nil {
+			retrieved = &ragContext
+		}
+	}
+	
+	// The conversation gets what the system message, now holding any RAG context, leaves of the
+	// prompt budget and of the context window's room for the prompt, dropping the oldest turns
+	historyTokens := sce.budget.HistoryTokens(systemMessage)
+	if limit := sce.promptTokenLimit(); limit > 0 {
+		fits := max(limit-estimateTokens(systemMessage), 1)
+		if historyTokens == 0 || fits < historyTokens {
+			historyTokens = fits
+		}
+	}
+	if historyTokens > 0 {
+		for _, example := range sce.examples {
+			historyTokens -= estimateTokens(example.Content)
+		}
+		conversationMessages = TruncateHistory(conversationMessages, max(historyTokens, 1))
+	}
+	
+	// Few-shot examples come first so the model answers the conversation in their style
+	if len(sce.examples) > 0 {
+		conversationMessages = append(append([]ChatMessage{}, sce.examples...), conversationMessages...)
+	}
+	
+	return sce.buildPrompt(systemMessage, conversationMessages), retrieved, nil
+}
+
+// showPrompt writes the prompt to the prompt writer, if one is set
+func (sce *SimpleChatEngine) showPrompt(prompt string) {
+	if sce.promptWriter == nil {
+		return
+	}
+	fmt.Fprintf(sce.promptWriter, "----- prompt -----\n%s\n----- end of prompt -----\n", prompt)
+}
+
+// defaultRAGCandidates is how many search results are considered for the RAG context when
+// neither MaxResults nor MaxPerSource asks for more
+const defaultRAGCandidates = 2
+
+// sourceCapOverfetch multiplies the RAG candidates when MaxPerSource is set, so other sources
+// can take the slots the cap frees up
+const sourceCapOverfetch = 4
+
+// ragCandidates returns how many search results are considered for the RAG context: at least
+// MaxResults so that many can be used, and sourceCapOverfetch times as many with a per-source cap.
+func (sce *SimpleChatEngine) ragCandidates() int {
+	candidates := max(defaultRAGCandidates, sce.ragOptions.MaxResults)
+	if sce.ragOptions.MaxPerSource > 0 {
+		candidates *= sourceCapOverfetch
+	}
+	return candidates
+}
+
+// searchCandidates returns how many results to fetch from the store: the RAG candidates, or
+// a wider set for the reranker to choose them from
+func (sce *SimpleChatEngine) searchCandidates() int {
+	if sce.reranker != nil {
+		return max(rerankCandidates, sce.ragCandidates())
+	}
+	return sce.ragCandidates()
+}
+
+// EnableRerank reorders each retrieval's candidates with r and keeps the best before the RAG
+// context is built. A nil r has the chat model itself rate every candidate, which costs a
+// short generation per candidate.
+func (sce *SimpleChatEngine) EnableRerank(r Reranker) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
+	
+	if r == nil {
+		r = modelReranker{engine: sce}
+	}
+	sce.reranker = r
+}
+
+// rerankResults narrows results to the RAG candidates, reordered by the reranker when one is
+// enabled. If reranking fails the retrieval order is kept.
+func rerankResults[T any](sce *SimpleChatEngine, query string, results []T, text func(T) string) []T {
+	if sce.reranker != nil && len(results) > 0 {
+		order, err := sce.rerankOrder(query, passagesOf(results, text))
+		if err == nil {
+			return inOrder(results, order)
+		}
+		sce.logger.Printf("Warning: reranking failed, keeping retrieval order: %v", err)
+	}
+	if len(results) > sce.ragCandidates() {
+		results = results[:sce.ragCandidates()]
+	}
+	return results
+}
+
+// rerankOrder returns the reranker's order for the passages. With a response cache it is
+// checked first, so a question answered from the cache doesn't pay for reranking again to
+// rebuild its prompt, and a fresh order is stored there. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) rerankOrder(query string, passages []string) ([]int, error) {
+	if sce.cache == nil {
+		return rerankOrder(sce.reranker, query, passages, sce.ragCandidates())
+	}
+	
+	key := ResponseCacheKey(sce.modelPath, rerankCachePrompt(query, passages, sce.ragCandidates()), sce.sampling, sce.modelParams, sce.contextSize)
+	if order, ok := sce.cache.GetRerank(key, len(passages)); ok {
+		sce.logger.Printf("Reusing the cached rerank order")
+		return order, nil
+	}
+	order, err := rerankOrder(sce.reranker, query, passages, sce.ragCandidates())
+	if err != nil {
+		return nil, err
+	}
+	if err := sce.cache.PutRerank(key, order); err != nil {
+		sce.logger.Printf("Warning: failed to cache rerank order: %v", err)
+	}
+	return order, nil
+}
+
+// rerankCachePrompt stands in for the prompt in a rerank order's cache key: everything the
+// order depends on besides the model and its settings
+func rerankCachePrompt(query string, passages []string, topK int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rerank top %d\n%s\n", topK, query)
+	for _, passage := range passages {
+		fmt.Fprintf(&b, "\x00%s", passage)
+	}
+	return b.String()
+}
+
+// retrieveContext builds RAG context from the vector store when one is enabled, and the keyword store otherwise
+func (sce *SimpleChatEngine) retrieveContext(query string) (RAGContext, error) {
+	if sce.vectorStore != nil && sce.embedder != nil {
+		return sce.retrieveRAGContext(query)
+	}
+	if sce.simpleRAGStore != nil {
+		return sce.retrieveSimpleRAGContext(query)
+	}
+	return RAGContext{Query: query}, nil
+}
+
+// ragContextTokens is the estimated token budget for documentation added to the system prompt
+// when no PromptBudget is set. It was reduced from 1500 to leave more of the context window for
+// the conversation.
+const ragContextTokens = 800
+
+// retrieveSimpleRAGContext builds context from the best keyword matches
+func (sce *SimpleChatEngine) retrieveSimpleRAGContext(query string) (RAGContext, error) {
+	results, err := sce.simpleRAGStore.Search(query, sce.searchCandidates())
+	if err != nil {
+		return RAGContext{}, fmt.Errorf("simple search failed: %w", err)
+	}
+	
+	var topScore float32
+	if len(results) > 0 {
+		topScore = results[0].Score
+	}
+	results = rerankResults(sce, query, results, func(r SearchResult) string { return r.Document.Content })
+	
+	ragContext := BuildSimpleRAGContext(query, results, sce.budget.ContextTokens(), sce.ragOptions)
+	ragContext.TopScore = topScore
+	return ragContext, nil
+}
+
+// retrieveRAGContext embeds the query and builds context from the most similar documents
+func (sce *SimpleChatEngine) retrieveRAGContext(query string) (RAGContext, error) {
+	queryEmbedding, err := sce.embedder.GenerateEmbedding(query)
+	if err != nil {
+		return RAGContext{}, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+	
+	results, err := sce.vectorStore.Search(queryEmbedding, sce.searchCandidates())
+	if err != nil {
+		return RAGContext{}, fmt.Errorf("similarity search failed: %w", err)
+	}
+	
+	// Filter results by similarity threshold
+	var filteredResults []SimilarityResult
+	for _, result := range results {
+		if result.Similarity > 0.3 { // Minimum similarity threshold
+			filteredResults = append(filteredResults, result)
 		}
 	}
+	filteredResults = rerankResults(sce, query, filteredResults, func(r SimilarityResult) string { return r.Document.Content })
+	
+	if sce.ragOptions.ExpandNeighbors > 0 {
+		filteredResults = ExpandNeighbors(sce.vectorStore, filteredResults, sce.ragOptions.ExpandNeighbors, sce.budget.ContextTokens())
+	}
 	
-	return sce.buildPrompt(systemMessage, conversationMessages), nil
+	ragContext := BuildRAGContext(query, filteredResults, sce.budget.ContextTokens(), sce.ragOptions)
+	if len(results) > 0 {
+		ragContext.TopScore = results[0].Similarity
+	}
+	return ragContext, nil
 }
 
 // buildPrompt creates the final prompt string
@@ -255,188 +892,169 @@ func (sce *SimpleChatEngine) buildPrompt(systemMessage string, messages []ChatMe
 	return prompt.String()
 }
 
-// performSimpleInference does actual model inference
-func (sce *SimpleChatEngine) performSimpleInference(prompt string) (string, error) {
+// DefaultContextWarning is the fraction of the context window a prompt can fill before the
+// engine warns that it is close to being cut off
+const DefaultContextWarning = 0.8
+
+// contextWarning describes how full the context window is when a prompt of promptTokens
+// fills more than threshold of it, or returns "" when it doesn't or either limit is unset
+func contextWarning(promptTokens, contextSize int, threshold float64) string {
+	if threshold <= 0 || contextSize <= 0 || float64(promptTokens) <= threshold*float64(contextSize) {
+		return ""
+	}
+	if promptTokens >= contextSize {
+		return fmt.Sprintf("Warning: the prompt is %d tokens but the context window holds %d, so its beginning will be cut off; clear the history or use a larger context size", promptTokens, contextSize)
+	}
+	return fmt.Sprintf("Warning: the prompt is %d tokens, %d%% of the %d token context window; clear the history or use a larger context size before it is cut off", promptTokens, promptTokens*100/contextSize, contextSize)
+}
+
+// replyReserve returns the tokens of a contextSize token context set aside for the response:
+// the response cap, or half the context when that is smaller
+func replyReserve(contextSize int) int {
+	return min(maxGeneratedTokens, contextSize/2)
+}
+
+// promptTokenLimit returns how many tokens of the context window the prompt may fill, leaving
+// the reply its reserve, or 0 when the context size is unset. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) promptTokenLimit() int {
+	if sce.contextSize <= 0 {
+		return 0
+	}
+	return sce.contextSize - replyReserve(sce.contextSize)
+}
+
+// keepPromptEnd cuts tokens to their last limit, so a prompt that still overflows keeps the
+// latest question and the assistant cue that starts the reply
+func keepPromptEnd(tokens []int, limit int) []int {
+	if limit <= 0 || len(tokens) <= limit {
+		return tokens
+	}
+	return tokens[len(tokens)-limit:]
+}
+
+// samplingParams returns the parameters the sampler is created with: the sampling options,
+// with the model parameters for the settings they don't cover. Callers must hold sce.mu.
+func (sce *SimpleChatEngine) samplingParams() llama.SamplingParams {
+	return sce.sampling.paramsWith(sce.modelParams)
+}
+
+// promptBatchSize returns the configured batch size, or the default when unset
+func (sce *SimpleChatEngine) promptBatchSize() int {
+	if sce.batchSize > 0 {
+		return sce.batchSize
+	}
+	return defaultBatchSize
+}
+
+// performStreamingInference does actual model inference with streaming output
+func (sce *SimpleChatEngine) performStreamingInference(ctx context.Context, prompt string, callback StreamingCallback) (string, GenerationTimings, StopReason, error) {
+	var timings GenerationTimings
+	start := time.Now()
+	
 	// Tokenize the prompt
-	tokens, err := sce.model.Tokenize(prompt, true, true)
+	tokens, err := tokenize(sce.model, prompt)
 	if err != nil {
-		return "", fmt.Errorf("tokenization failed: %v", err)
+		return "", timings, "", fmt.Errorf("%w: %w", ErrTokenization, err)
 	}
 	
-	log.Printf("Prompt tokenized to %d tokens", len(tokens))
+	sce.logger.Printf("Prompt tokenized to %d tokens", len(tokens))
+	if warning := contextWarning(len(tokens), sce.contextSize, sce.warnThreshold); warning != "" && sce.warnWriter != nil {
+		fmt.Fprintln(sce.warnWriter, warning)
+	}
 	
-	// Limit batch size to prevent assertion errors
-	maxBatchSize := 512
-	if len(tokens) > maxBatchSize {
-		log.Printf("Truncating prompt from %d to %d tokens", len(tokens), maxBatchSize)
-		tokens = tokens[:maxBatchSize]
+	// The history was trimmed by estimate; a prompt that still overflows loses its beginning
+	if limit := sce.promptTokenLimit(); limit > 0 && len(tokens) > limit {
+		sce.logger.Printf("Truncating prompt from %d to its last %d tokens to leave room for the response in the %d token context", len(tokens), limit, sce.contextSize)
+		tokens = keepPromptEnd(tokens, limit)
 	}
 	
+	timings.PromptTokens = len(tokens)
+	
+	// Start from an empty KV cache so previous turns don't collide with these positions
+	sce.context.KvCacheClear()
+	
 	// Create batch for processing
-	batch, err := llama.NewBatch(len(tokens), 1, 0)
+	batchSize := sce.promptBatchSize()
+	batch, err := llama.NewBatch(batchSize, 1, 0)
 	if err != nil {
-		return "", fmt.Errorf("batch creation failed: %v", err)
+		return "", timings, "", fmt.Errorf("%w: %w", ErrBatchCreation, err)
 	}
 	defer batch.Free()
 	
-	// Add tokens to batch
-	for i, token := range tokens {
-		batch.Add(token, nil, i, i == len(tokens)-1, 0) // Only get logits for last token
-	}
-	
-	// Process the batch
-	err = sce.context.Decode(batch)
+	// Decode the prompt in batch-sized passes; only the last token's logits are needed
+	passes, err := decodePrompt(&llamaBatchDecoder{context: sce.context, batch: batch}, tokens, batchSize)
 	if err != nil {
-		return "", fmt.Errorf("context decode failed: %v", err)
+		return "", timings, "", err
 	}
-	
-	// Set up sampling parameters
-	samplingParams := llama.SamplingParams{
-		TopK:           40,
-		TopP:           0.9,
-		MinP:           0.1,
-		Temp:           0.7,
-		RepeatLastN:    64,
-		PenaltyRepeat:  1.1,
-		PenaltyFreq:    0.0,
-		PenaltyPresent: 0.0,
-		PenalizeNl:     true,
-		Seed:           0,
+	if passes > 1 {
+		sce.logger.Printf("Decoded %d prompt tokens in %d passes of up to %d", len(tokens), passes, batchSize)
 	}
+	timings.PromptEval = time.Since(start)
 	
 	// Create sampling context
-	sampler, err := llama.NewSamplingContext(sce.model, samplingParams)
+	sampler, err := llama.NewSamplingContext(sce.model, sce.samplingParams())
 	if err != nil {
-		return "", fmt.Errorf("sampling context creation failed: %v", err)
+		return "", timings, "", fmt.Errorf("%w: %w", ErrSamplerCreation, err)
 	}
 	
-	var response strings.Builder
-	maxTokens := 512
-	
-	// Generate tokens iteratively
-	for i := 0; i < maxTokens; i++ {
-		// Sample next token
-		token := sampler.Sample(sce.context, batch.NumTokens()-1)
-		
-		// Check for end of generation
-		if sce.model.TokenIsEog(token) {
-			break
-		}
-		
-		// Convert token to text
-		piece := sce.model.TokenToPiece(token)
-		response.WriteString(piece)
-		
-		// Accept the token for grammar/repetition tracking
-		sampler.Accept(token, true)
-		
-		// Prepare for next iteration - add token to batch
-		batch.Clear()
-		batch.Add(token, nil, len(tokens)+i, true, 0)
-		
-		// Decode for next iteration
-		err = sce.context.Decode(batch)
-		if err != nil {
-			log.Printf("Decode failed during generation: %v", err)
-			break
-		}
+	// Generate until end of generation, the token cap, or a full context window
+	maxTokens := maxGeneratedTokens
+	if sce.contextSize > 0 && sce.contextSize-len(tokens) < maxTokens {
+		maxTokens = sce.contextSize - len(tokens)
+		sce.logger.Printf("Only %d tokens of the %d token context are left for the response", maxTokens, sce.contextSize)
 	}
 	
-	return strings.TrimSpace(response.String()), nil
+	response, reason, err := sce.generate(ctx, &llamaTokenSampler{model: sce.model, context: sce.context, sampler: sampler, batch: batch}, len(tokens), maxTokens, start, &timings, callback)
+	timings.Total = time.Since(start)
+	
+	return response, timings, reason, err
 }
 
-// performStreamingInference does actual model inference with streaming output
-func (sce *SimpleChatEngine) performStreamingInference(prompt string, callback StreamingCallback) (string, error) {
-	// Tokenize the prompt
-	tokens, err := sce.model.Tokenize(prompt, true, true)
-	if err != nil {
-		return "", fmt.Errorf("tokenization failed: %v", err)
-	}
-	
-	log.Printf("Prompt tokenized to %d tokens", len(tokens))
+// summarizePrompt instructs the model how to compress turns for Summarize
+const summarizePrompt = "Summarize the conversation below in a few sentences. Keep the facts, names, decisions and open questions needed to continue it, and leave out pleasantries."
+
+// Summarize asks the model for a short summary of turns, for use as a Summarizer with
+// SummarizeHistory. RAG is not used, since the summary only restates the conversation.
+func (sce *SimpleChatEngine) Summarize(turns []ChatMessage) (string, error) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
 	
-	// Limit batch size to prevent assertion errors
-	maxBatchSize := 512
-	if len(tokens) > maxBatchSize {
-		log.Printf("Truncating prompt from %d to %d tokens", len(tokens), maxBatchSize)
-		tokens = tokens[:maxBatchSize]
+	if !sce.running {
+		return "", ErrEngineNotRunning
 	}
 	
-	// Create batch for processing
-	batch, err := llama.NewBatch(len(tokens), 1, 0)
-	if err != nil {
-		return "", fmt.Errorf("batch creation failed: %v", err)
+	if sce.model == nil || sce.context == nil {
+		return "", ErrModelNotLoaded
 	}
-	defer batch.Free()
 	
-	// Add tokens to batch
-	for i, token := range tokens {
-		batch.Add(token, nil, i, i == len(tokens)-1, 0) // Only get logits for last token
+	var transcript strings.Builder
+	for _, msg := range turns {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
 	}
+	prompt := sce.buildPrompt(summarizePrompt, []ChatMessage{{Role: "user", Content: transcript.String()}})
+	sce.showPrompt(prompt)
 	
-	// Process the batch
-	err = sce.context.Decode(batch)
+	summary, timings, _, err := sce.performStreamingInference(context.Background(), prompt, nil)
 	if err != nil {
-		return "", fmt.Errorf("context decode failed: %v", err)
-	}
-	
-	// Set up sampling parameters
-	samplingParams := llama.SamplingParams{
-		TopK:           40,
-		TopP:           0.9,
-		MinP:           0.1,
-		Temp:           0.7,
-		RepeatLastN:    64,
-		PenaltyRepeat:  1.1,
-		PenaltyFreq:    0.0,
-		PenaltyPresent: 0.0,
-		PenalizeNl:     true,
-		Seed:           0,
+		return "", err
 	}
+	sce.metrics.ObserveGeneration(timings)
+	return summary, nil
+}
+
+// TimedGenerate runs a single generation for the raw user prompt and reports how long each phase took
+func (sce *SimpleChatEngine) TimedGenerate(prompt string) (GenerationTimings, error) {
+	sce.mu.Lock()
+	defer sce.mu.Unlock()
 	
-	// Create sampling context
-	sampler, err := llama.NewSamplingContext(sce.model, samplingParams)
-	if err != nil {
-		return "", fmt.Errorf("sampling context creation failed: %v", err)
+	if !sce.running {
+		return GenerationTimings{}, ErrEngineNotRunning
 	}
 	
-	var response strings.Builder
-	maxTokens := 512
-	
-	// Generate tokens iteratively with streaming
-	for i := 0; i < maxTokens; i++ {
-		// Sample next token
-		token := sampler.Sample(sce.context, batch.NumTokens()-1)
-		
-		// Check for end of generation
-		if sce.model.TokenIsEog(token) {
-			break
-		}
-		
-		// Convert token to text
-		piece := sce.model.TokenToPiece(token)
-		response.WriteString(piece)
-		
-		// Stream the token to the callback
-		if callback != nil {
-			callback(piece)
-		}
-		
-		// Accept the token for grammar/repetition tracking
-		sampler.Accept(token, true)
-		
-		// Prepare for next iteration - add token to batch
-		batch.Clear()
-		batch.Add(token, nil, len(tokens)+i, true, 0)
-		
-		// Decode for next iteration
-		err = sce.context.Decode(batch)
-		if err != nil {
-			log.Printf("Decode failed during generation: %v", err)
-			break
-		}
+	if sce.model == nil || sce.context == nil {
+		return GenerationTimings{}, ErrModelNotLoaded
 	}
 	
-	return strings.TrimSpace(response.String()), nil
+	_, timings, _, err := sce.performStreamingInference(context.Background(), sce.buildPrompt("", []ChatMessage{{Role: "user", Content: prompt}}), nil)
+	return timings, err
 }
-
@@ -0,0 +1,359 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/llama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleEngineRejectsEmptyConversation(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	conversations := map[string][]ChatMessage{
+		"no messages":      nil,
+		"only system":      {{Role: "system", Content: "You are helpful."}},
+		"whitespace":       {{Role: "user", Content: "  \n\t "}},
+		"zero-width space": {{Role: "user", Content: "\u200b"}},
+	}
+	for name, messages := range conversations {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, engine.Chat(messages).Error, ErrEmptyQuery)
+			assert.ErrorIs(t, engine.ChatStream(context.Background(), messages, func(string) {}).Error, ErrEmptyQuery)
+		})
+	}
+
+	// A real question gets past the guard to the missing model
+	assert.ErrorIs(t, engine.Chat([]ChatMessage{{Role: "user", Content: "hi"}}).Error, ErrModelNotLoaded)
+}
+
+func TestChatEngineRejectsEmptyConversation(t *testing.T) {
+	engine := NewChatEngine("missing.gguf")
+
+	var responses []ChatResponse
+	for response := range engine.Chat([]ChatMessage{{Role: "user", Content: " "}}, false) {
+		responses = append(responses, response)
+	}
+	require.Len(t, responses, 1)
+	assert.ErrorIs(t, responses[0].Error, ErrEmptyQuery)
+}
+
+func TestSimpleEngineStopTwice(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.Stop() // never started
+
+	require.NoError(t, engine.Start())
+	assert.NotPanics(t, func() {
+		engine.Stop()
+		engine.Stop()
+	})
+	assert.ErrorIs(t, engine.Chat([]ChatMessage{{Role: "user", Content: "hi"}}).Error, ErrEngineNotRunning)
+
+	// Unlike ChatEngine, a stopped simple engine can start again
+	require.NoError(t, engine.Start())
+	engine.Stop()
+}
+
+func TestPromptWriterShowsRAGContext(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(SimpleDocument{
+		ID:       "scs",
+		Title:    "Subject Condition Sets",
+		Content:  "A subject condition set groups conditions evaluated against entity claims.",
+		FilePath: "scs.md",
+		Keywords: []string{"subject", "condition", "set"},
+	}))
+
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.EnableSimpleRAG(store)
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	// The prompt is shown before inference, so it appears even without a model
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "What is a subject condition set?"}}
+	resp := engine.ChatStream(context.Background(), messages, nil)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+
+	out := shown.String()
+	assert.True(t, strings.HasPrefix(out, "----- prompt -----\n"))
+	assert.Contains(t, out, "What is a subject condition set?")
+	assert.Contains(t, out, "groups conditions evaluated against entity claims")
+	assert.True(t, strings.HasSuffix(out, "----- end of prompt -----\n"))
+
+	// Without a writer nothing is shown
+	engine.SetPromptWriter(nil)
+	shown.Reset()
+	resp = engine.Chat(messages)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+	assert.Empty(t, shown.String())
+}
+
+func TestExamplesArePreservedAcrossTurns(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	engine.SetExamples([]ChatMessage{
+		{Role: "user", Content: "List the attribute namespaces"},
+		{Role: "assistant", Content: "otdfctl policy attributes namespaces list"},
+	})
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}}
+	for _, question := range []string{"How do I create a namespace?", "And delete one?", "What about attributes?"} {
+		messages = append(messages, ChatMessage{Role: "user", Content: question})
+		// A budget this small drops every earlier turn from the history
+		messages = TruncateHistory(messages, 10)
+		shown.Reset()
+		resp := engine.Chat(messages)
+		require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+
+		prompt := shown.String()
+		system := strings.Index(prompt, "You are helpful.")
+		example := strings.Index(prompt, "<|im_start|>user\nList the attribute namespaces<|im_end|>\n<|im_start|>assistant\notdfctl policy attributes namespaces list<|im_end|>")
+		asked := strings.Index(prompt, question)
+		require.NotEqual(t, -1, example, "examples appear in every prompt")
+		assert.Less(t, system, example, "examples follow the system message")
+		assert.Less(t, example, asked, "examples precede the conversation")
+		messages = append(messages, ChatMessage{Role: "assistant", Content: "answer"})
+	}
+	assert.NotContains(t, messages, ChatMessage{Role: "user", Content: "How do I create a namespace?"}, "earlier turns were truncated")
+	assert.NotContains(t, messages, ChatMessage{Role: "user", Content: "List the attribute namespaces"}, "the examples are never part of the history")
+}
+
+func TestHistoryTokensSetsAsideExamples(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetContextSize(1000)
+	assert.Equal(t, 900, engine.HistoryTokens(100))
+
+	engine.SetExamples([]ChatMessage{{Role: "user", Content: strings.Repeat("a", 200)}, {Role: "assistant", Content: strings.Repeat("b", 200)}})
+	assert.Equal(t, 800, engine.HistoryTokens(100))
+	assert.Zero(t, engine.HistoryTokens(2000))
+}
+
+func TestContextWarning(t *testing.T) {
+	assert.Empty(t, contextWarning(3000, 4096, 0.8), "below the threshold")
+	assert.Empty(t, contextWarning(3276, 4096, 0.8), "at the threshold")
+
+	warning := contextWarning(3500, 4096, 0.8)
+	assert.Contains(t, warning, "3500 tokens")
+	assert.Contains(t, warning, "85% of the 4096 token context window")
+
+	warning = contextWarning(5000, 4096, 0.8)
+	assert.Contains(t, warning, "5000 tokens but the context window holds 4096")
+	assert.Contains(t, warning, "cut off")
+
+	assert.Empty(t, contextWarning(5000, 4096, 0), "a threshold of 0 disables the warning")
+	assert.Empty(t, contextWarning(5000, 0, 0.8), "an unknown context size can't be compared")
+	assert.NotEmpty(t, contextWarning(200, 1000, 0.1), "the threshold is configurable")
+}
+
+func TestOverlongPromptEndsWithLatestQuestion(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.SetContextSize(1024)
+
+	// Twenty turns of about 100 estimated tokens each overrun the 512 tokens left for the prompt
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}}
+	for i := 0; i < 20; i++ {
+		messages = append(messages,
+			ChatMessage{Role: "user", Content: fmt.Sprintf("Earlier question %d. %s", i, strings.Repeat("Padding. ", 20))},
+			ChatMessage{Role: "assistant", Content: fmt.Sprintf("Earlier answer %d. %s", i, strings.Repeat("Padding. ", 20))},
+		)
+	}
+	question := "How does the key access service rewrap keys?"
+	messages = append(messages, ChatMessage{Role: "user", Content: question})
+
+	prompt, _, err := engine.buildPromptWithRAG(messages, question)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(prompt, "<|im_start|>user\n"+question+"<|im_end|>\n<|im_start|>assistant\n"), "the latest question and the assistant cue are kept")
+	assert.True(t, strings.HasPrefix(prompt, "<|im_start|>system\nYou are helpful."), "the system message is kept")
+	assert.NotContains(t, prompt, "Earlier question 0.", "the oldest turns are dropped")
+	assert.Less(t, estimateTokens(prompt), 1024-maxGeneratedTokens/2, "most of the reply's reserve is left")
+
+	// Tokens that still overflow keep their end
+	assert.Equal(t, []int{3, 4, 5}, keepPromptEnd([]int{1, 2, 3, 4, 5}, 3))
+	assert.Equal(t, []int{1, 2}, keepPromptEnd([]int{1, 2}, 3))
+	assert.Equal(t, 64, replyReserve(128), "a small context gives the reply half")
+}
+
+func TestNoRAGMessageSkipsRetrieval(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	for _, doc := range openTDFDocs {
+		require.NoError(t, store.AddDocument(doc))
+	}
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.EnableSimpleRAG(store)
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	prompt := func(messages []ChatMessage) string {
+		shown.Reset()
+		resp := engine.Chat(messages)
+		require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+		return shown.String()
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "How do subject mappings decide access?"}}
+	assert.Contains(t, prompt(messages), "Based on the above documentation")
+
+	// Only the message being answered decides; earlier skipped turns don't carry over
+	messages[1].NoRAG = true
+	skipped := prompt(messages)
+	assert.NotContains(t, skipped, "Based on the above documentation")
+	assert.Contains(t, skipped, "How do subject mappings decide access?")
+
+	messages = append(messages,
+		ChatMessage{Role: "assistant", Content: "They match entity claims."},
+		ChatMessage{Role: "user", Content: "Which subject mappings exist?"},
+	)
+	assert.Contains(t, prompt(messages), "Based on the above documentation")
+}
+
+func TestSwitchModelKeepsHistory(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	var shown strings.Builder
+	engine.SetPromptWriter(&shown)
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	original := loadModelFile
+	t.Cleanup(func() { loadModelFile = original })
+	var loaded []string
+	loadModelFile = func(_ *SimpleChatEngine, modelPath string) (*llama.Model, *llama.Context, int, error) {
+		loaded = append(loaded, modelPath)
+		return nil, nil, 2048, nil
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "What is a KAS?"},
+		{Role: "assistant", Content: "The Key Access Server."},
+	}
+	require.NoError(t, engine.SwitchModel("qwen.gguf"))
+	assert.Equal(t, []string{"qwen.gguf"}, loaded)
+	assert.Equal(t, "qwen.gguf", engine.ModelPath())
+	assert.Equal(t, 2048, engine.ContextSize())
+
+	// The new model answers with the conversation so far in its prompt
+	messages = append(messages, ChatMessage{Role: "user", Content: "And how does it rewrap keys?"})
+	resp := engine.Chat(messages)
+	require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+	assert.Contains(t, shown.String(), "What is a KAS?")
+	assert.Contains(t, shown.String(), "The Key Access Server.")
+	assert.Contains(t, shown.String(), "And how does it rewrap keys?")
+}
+
+func TestSwitchModelResolvesRequestedContextSize(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	original := loadModelFile
+	t.Cleanup(func() { loadModelFile = original })
+	trained := map[string]int{"qwen.gguf": 4096, "phi.gguf": 2048}
+	var requested []int
+	var loaded []string
+	loadModelFile = func(sce *SimpleChatEngine, modelPath string) (*llama.Model, *llama.Context, int, error) {
+		requested = append(requested, sce.requestedSize)
+		loaded = append(loaded, modelPath)
+		return nil, nil, trained[modelPath], nil
+	}
+
+	// 0 resolves to each model's trained size, not the size of the model before it
+	require.NoError(t, engine.SwitchModel("qwen.gguf"))
+	assert.Equal(t, 4096, engine.ContextSize())
+	require.NoError(t, engine.SwitchModel("phi.gguf"))
+	assert.Equal(t, 2048, engine.ContextSize())
+	assert.Equal(t, []int{0, 0}, requested)
+
+	// Switching back reuses the loaded model and its context instead of creating another
+	require.NoError(t, engine.SwitchModel("qwen.gguf"))
+	assert.Equal(t, []string{"qwen.gguf", "phi.gguf"}, loaded)
+	assert.Equal(t, 4096, engine.ContextSize())
+	assert.Equal(t, "qwen.gguf", engine.ModelPath())
+}
+
+func TestSwitchModelKeepsTwoModelsLoaded(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	original := loadModelFile
+	t.Cleanup(func() { loadModelFile = original })
+	var loaded []string
+	loadModelFile = func(sce *SimpleChatEngine, modelPath string) (*llama.Model, *llama.Context, int, error) {
+		loaded = append(loaded, modelPath)
+		return nil, nil, 2048, nil
+	}
+
+	require.NoError(t, engine.SwitchModel("qwen.gguf"))
+	require.NoError(t, engine.SwitchModel("phi.gguf"))
+	require.NoError(t, engine.SwitchModel("qwen.gguf"))
+	require.NoError(t, engine.SwitchModel("llama.gguf"))
+	assert.ElementsMatch(t, []string{"qwen.gguf", "llama.gguf"}, slices.Collect(maps.Keys(engine.loaded)),
+		"the least recently used model is unloaded")
+
+	// The unloaded model is loaded again when switched back to
+	require.NoError(t, engine.SwitchModel("phi.gguf"))
+	assert.Equal(t, []string{"qwen.gguf", "phi.gguf", "llama.gguf", "phi.gguf"}, loaded)
+	assert.ElementsMatch(t, []string{"llama.gguf", "phi.gguf"}, slices.Collect(maps.Keys(engine.loaded)))
+}
+
+func TestSwitchModelFailureKeepsCurrentModel(t *testing.T) {
+	engine := NewSimpleChatEngine("missing.gguf")
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	err := engine.SwitchModel(filepath.Join(t.TempDir(), "other.gguf"))
+	require.ErrorIs(t, err, ErrModelNotFound)
+	assert.Equal(t, "missing.gguf", engine.ModelPath())
+
+	stopped := NewSimpleChatEngine("missing.gguf")
+	require.ErrorIs(t, stopped.SwitchModel("other.gguf"), ErrEngineNotRunning)
+}
+
+func TestRAGQueryTurnsCarryFollowUpContext(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	for _, doc := range openTDFDocs {
+		require.NoError(t, store.AddDocument(doc))
+	}
+	messages := []ChatMessage{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "How does a TDF bind its payload key?"},
+		{Role: "assistant", Content: "With a binding."},
+		{Role: "user", Content: "And what about decryption?"},
+	}
+	const tdfDoc = "binds the policy to the ciphertext"
+
+	prompt := func(queryTurns int) string {
+		engine := NewSimpleChatEngine("missing.gguf")
+		engine.EnableSimpleRAG(store)
+		engine.SetRAGOptions(RAGContextOptions{QueryTurns: queryTurns})
+		var shown strings.Builder
+		engine.SetPromptWriter(&shown)
+		require.NoError(t, engine.Start())
+		defer engine.Stop()
+		resp := engine.Chat(messages)
+		require.ErrorIs(t, resp.Error, ErrModelNotLoaded)
+		return shown.String()
+	}
+
+	// The follow-up alone doesn't mention TDFs
+	assert.NotContains(t, prompt(0), tdfDoc)
+	assert.NotContains(t, prompt(1), tdfDoc)
+	assert.Contains(t, prompt(2), tdfDoc, "the earlier question brings the TDF spec back")
+	assert.Contains(t, prompt(5), tdfDoc, "fewer turns than requested are all used")
+}
@@ -3,11 +3,12 @@ package llm
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"log"
 )
 
 // SimpleDocument represents a document for basic text matching
@@ -18,12 +19,22 @@ type SimpleDocument struct {
 	URL      string `json:"url"`
 	FilePath string `json:"file_path"`
 	Keywords []string `json:"keywords"`
+	// Bigrams are the distinct pairs of adjacent keywords, such as "subject mapping"
+	Bigrams []string `json:"bigrams,omitempty"`
+	// Language is the ISO 639-1 code detected for the document, if any
+	Language string `json:"language,omitempty"`
+	// Boost multiplies the document's score; 0 leaves it as it is. See SourceBoosts.
+	Boost float32 `json:"boost,omitempty"`
 }
 
-// SimpleRAGStore provides basic keyword-based document retrieval
+// SimpleRAGStore provides basic keyword-based document retrieval, holding the whole index in
+// memory and saving it as a JSON file
 type SimpleRAGStore struct {
 	documents []SimpleDocument
 	indexPath string
+	logger    Logger
+	// docFreq counts the documents each keyword appears in, for IDF weighting
+	docFreq map[string]int
 }
 
 // NewSimpleRAGStore creates a new simple RAG store
@@ -31,63 +42,109 @@ func NewSimpleRAGStore(indexPath string) *SimpleRAGStore {
 	return &SimpleRAGStore{
 		documents: make([]SimpleDocument, 0),
 		indexPath: indexPath,
+		logger:    NopLogger(),
+		docFreq:   make(map[string]int),
 	}
 }
 
+// SetLogger routes the store's diagnostic messages to the given logger
+func (s *SimpleRAGStore) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
 // LoadIndex loads documents from the simple index
 func (s *SimpleRAGStore) LoadIndex() error {
 	if _, err := os.Stat(s.indexPath); os.IsNotExist(err) {
-		log.Printf("Simple RAG index not found at %s, will create new one", s.indexPath)
+		s.logger.Printf("Simple RAG index not found at %s, will create new one", s.indexPath)
 		return nil
 	}
 
 	data, err := os.ReadFile(s.indexPath)
 	if err != nil {
-		return fmt.Errorf("failed to read simple index: %v", err)
+		return fmt.Errorf("%w: failed to read simple index: %w", ErrIndexLoad, err)
 	}
 
 	var indexData struct {
+		Version   int              `json:"version"`
 		Documents []SimpleDocument `json:"documents"`
 	}
 
 	if err := json.Unmarshal(data, &indexData); err != nil {
-		return fmt.Errorf("failed to unmarshal simple index: %v", err)
+		if err := checkIndexVersion(s.indexPath, peekIndexVersion(data)); err != nil {
+			return fmt.Errorf("%w: %w", ErrIndexLoad, err)
+		}
+		return fmt.Errorf("%w: failed to unmarshal simple index: %w", ErrIndexLoad, err)
+	}
+	if err := checkIndexVersion(s.indexPath, indexData.Version); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexLoad, err)
 	}
 
 	s.documents = indexData.Documents
-	log.Printf("Loaded %d documents from simple RAG index", len(s.documents))
+	// Indexes written before bigram indexing get theirs now
+	for i := range s.documents {
+		if s.documents[i].Bigrams == nil {
+			s.documents[i].Bigrams = documentBigrams(s.documents[i])
+		}
+	}
+	s.recomputeDocFreq()
+	s.logger.Printf("Loaded %d documents from simple RAG index", len(s.documents))
 	return nil
 }
 
 // SaveIndex saves documents to the simple index
 func (s *SimpleRAGStore) SaveIndex() error {
 	indexData := struct {
+		Version   int              `json:"version"`
 		Documents []SimpleDocument `json:"documents"`
 	}{
-		Documents: s.documents,
-	}
-
-	data, err := json.MarshalIndent(indexData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal simple index: %v", err)
+		Version:   IndexFormatVersion,
+		Documents: sortSimpleDocumentsForSave(s.documents),
 	}
 
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(s.indexPath), 0755); err != nil {
-		return fmt.Errorf("failed to create index directory: %v", err)
+		return fmt.Errorf("%w: failed to create index directory: %w", ErrIndexSave, err)
 	}
 
-	if err := os.WriteFile(s.indexPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write simple index: %v", err)
+	// Write to a temporary file and rename it into place so an interrupted save can't corrupt the index
+	err := writeFileAtomic(s.indexPath, 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(indexData)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to write simple index: %w", ErrIndexSave, err)
 	}
 
-	log.Printf("Saved simple RAG index with %d documents", len(s.documents))
+	s.logger.Printf("Saved simple RAG index with %d documents", len(s.documents))
+	return nil
+}
+
+// sortSimpleDocumentsForSave returns a copy of docs in the order the index file lists them,
+// by source file and then ID, so ingesting the same corpus again writes the same file
+func sortSimpleDocumentsForSave(docs []SimpleDocument) []SimpleDocument {
+	sorted := append([]SimpleDocument(nil), docs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].FilePath != sorted[j].FilePath {
+			return sorted[i].FilePath < sorted[j].FilePath
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// Close satisfies KeywordStore; the JSON store holds no open resources
+func (s *SimpleRAGStore) Close() error {
 	return nil
 }
 
 // AddDocument adds a document to the store
 func (s *SimpleRAGStore) AddDocument(doc SimpleDocument) error {
+	if doc.Bigrams == nil {
+		doc.Bigrams = documentBigrams(doc)
+	}
 	s.documents = append(s.documents, doc)
+	s.countTerms(doc)
 	return nil
 }
 
@@ -103,11 +160,11 @@ func (s *SimpleRAGStore) Search(query string, topK int) ([]SearchResult, error)
 		return []SearchResult{}, nil
 	}
 
-	queryWords := extractKeywords(strings.ToLower(query))
+	q := parseKeywordQuery(query)
 	results := make([]SearchResult, 0)
 
 	for _, doc := range s.documents {
-		score := s.calculateScore(queryWords, doc)
+		score := s.calculateScore(q, doc)
 		if score > 0 {
 			results = append(results, SearchResult{
 				Document: doc,
@@ -116,8 +173,8 @@ func (s *SimpleRAGStore) Search(query string, topK int) ([]SearchResult, error)
 		}
 	}
 
-	// Sort by score (descending)
-	sort.Slice(results, func(i, j int) bool {
+	// Sort by score (descending), keeping index order between equal scores
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].Score > results[j].Score
 	})
 
@@ -133,88 +190,124 @@ func (s *SimpleRAGStore) GetDocumentCount() int {
 	return len(s.documents)
 }
 
-// calculateScore computes a basic relevance score
-func (s *SimpleRAGStore) calculateScore(queryWords []string, doc SimpleDocument) float32 {
-	if len(queryWords) == 0 {
-		return 0
-	}
+// calculateScore computes a basic relevance score; ExplainScore shows how it is made up
+func (s *SimpleRAGStore) calculateScore(q keywordQuery, doc SimpleDocument) float32 {
+	return s.explainKeywordScore(q, doc).Score
+}
 
-	docText := strings.ToLower(doc.Title + " " + doc.Content)
-	docWords := extractKeywords(docText)
-	
-	// Create word frequency maps
-	queryWordCount := make(map[string]int)
-	for _, word := range queryWords {
-		queryWordCount[word]++
-	}
-	
-	docWordCount := make(map[string]int)
-	for _, word := range docWords {
-		docWordCount[word]++
+// keywordQuery is a search query split into keywords and adjacent keyword pairs
+type keywordQuery struct {
+	words   []string
+	bigrams []string
+}
+
+// parseKeywordQuery extracts the keywords and bigrams of a query
+func parseKeywordQuery(query string) keywordQuery {
+	query = strings.ToLower(query)
+	return keywordQuery{
+		words:   extractKeywords(query),
+		bigrams: extractBigrams(query),
 	}
-	
-	// Calculate score based on common words
-	var score float32
-	var totalQueryWords float32 = float32(len(queryWords))
-	
-	for word, qCount := range queryWordCount {
-		if dCount, exists := docWordCount[word]; exists {
-			// Weight by frequency and relative importance
-			wordScore := float32(qCount) / totalQueryWords
-			if dCount > 1 {
-				wordScore *= 1.5 // Boost if word appears multiple times in doc
-			}
-			
-			// Boost for title matches
-			if strings.Contains(strings.ToLower(doc.Title), word) {
-				wordScore *= 2.0
+}
+
+// documentBigrams returns the distinct bigrams of a document's title and content, sorted.
+// The title and content are split separately so no bigram spans the two.
+func documentBigrams(doc SimpleDocument) []string {
+	seen := make(map[string]bool)
+	bigrams := []string{}
+	for _, text := range []string{doc.Title, doc.Content} {
+		for _, bigram := range extractBigrams(strings.ToLower(text)) {
+			if !seen[bigram] {
+				seen[bigram] = true
+				bigrams = append(bigrams, bigram)
 			}
-			
-			score += wordScore
 		}
 	}
-	
-	// Boost for exact phrase matches
-	queryLower := strings.ToLower(strings.Join(queryWords, " "))
-	if strings.Contains(docText, queryLower) {
-		score += 1.0
+	sort.Strings(bigrams)
+	return bigrams
+}
+
+// documentTerms returns the distinct keywords in a document's title and content
+func documentTerms(doc SimpleDocument) map[string]struct{} {
+	terms := make(map[string]struct{})
+	for _, word := range extractKeywords(strings.ToLower(doc.Title + " " + doc.Content)) {
+		terms[word] = struct{}{}
 	}
-	
-	return score
+	return terms
 }
 
-// extractKeywords extracts meaningful keywords from text
-func extractKeywords(text string) []string {
-	// Remove common stop words
-	stopWords := map[string]bool{
-		"the": true, "a": true, "an": true, "and": true, "or": true, "but": true,
-		"in": true, "on": true, "at": true, "to": true, "for": true, "of": true,
-		"with": true, "by": true, "from": true, "about": true, "into": true,
-		"through": true, "during": true, "before": true, "after": true, "above": true,
-		"below": true, "up": true, "down": true, "out": true, "off": true, "over": true,
-		"under": true, "again": true, "further": true, "then": true, "once": true,
-		"is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
-		"being": true, "have": true, "has": true, "had": true, "do": true, "does": true,
-		"did": true, "will": true, "would": true, "could": true, "should": true,
-		"this": true, "that": true, "these": true, "those": true, "i": true, "me": true,
-		"my": true, "myself": true, "we": true, "our": true, "ours": true, "ourselves": true,
-		"you": true, "your": true, "yours": true, "yourself": true, "yourselves": true,
-		"he": true, "him": true, "his": true, "himself": true, "she": true, "her": true,
-		"hers": true, "herself": true, "it": true, "its": true, "itself": true, "they": true,
-		"them": true, "their": true, "theirs": true, "themselves": true, "what": true,
-		"which": true, "who": true, "whom": true, "whose": true, "where": true, "when": true,
-		"why": true, "how": true,
+// countTerms adds a document's keywords to the document frequencies
+func (s *SimpleRAGStore) countTerms(doc SimpleDocument) {
+	for term := range documentTerms(doc) {
+		s.docFreq[term]++
 	}
+}
+
+// recomputeDocFreq rebuilds the document frequencies from the current documents
+func (s *SimpleRAGStore) recomputeDocFreq() {
+	s.docFreq = make(map[string]int)
+	for _, doc := range s.documents {
+		s.countTerms(doc)
+	}
+}
+
+// idf returns the smoothed inverse document frequency of a keyword, ln(1 + N/df), so terms
+// found in few documents outweigh ones found in nearly all of them. A term in every document
+// still counts for ln 2.
+func (s *SimpleRAGStore) idf(term string) float32 {
+	return smoothedIDF(s.docFreq[term], len(s.documents))
+}
 
-	// Split into words and filter
+// smoothedIDF returns ln(1 + n/df) for a term found in df of n documents
+func smoothedIDF(df, n int) float32 {
+	// A document scored without being added to the store still contains the term
+	df = max(df, 1)
+	n = max(n, df)
+	return float32(math.Log(1 + float64(n)/float64(df)))
+}
+
+// stopWords are common words too frequent to help keyword matching
+var stopWords = map[string]bool{
+	"the": true, "a": true, "an": true, "and": true, "or": true, "but": true,
+	"in": true, "on": true, "at": true, "to": true, "for": true, "of": true,
+	"with": true, "by": true, "from": true, "about": true, "into": true,
+	"through": true, "during": true, "before": true, "after": true, "above": true,
+	"below": true, "up": true, "down": true, "out": true, "off": true, "over": true,
+	"under": true, "again": true, "further": true, "then": true, "once": true,
+	"is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
+	"being": true, "have": true, "has": true, "had": true, "do": true, "does": true,
+	"did": true, "will": true, "would": true, "could": true, "should": true,
+	"this": true, "that": true, "these": true, "those": true, "i": true, "me": true,
+	"my": true, "myself": true, "we": true, "our": true, "ours": true, "ourselves": true,
+	"you": true, "your": true, "yours": true, "yourself": true, "yourselves": true,
+	"he": true, "him": true, "his": true, "himself": true, "she": true, "her": true,
+	"hers": true, "herself": true, "it": true, "its": true, "itself": true, "they": true,
+	"them": true, "their": true, "theirs": true, "themselves": true, "what": true,
+	"which": true, "who": true, "whom": true, "whose": true, "where": true, "when": true,
+	"why": true, "how": true,
+}
+
+// splitWords splits text into lowercase runs of letters and digits
+func splitWords(text string) []string {
 	words := strings.FieldsFunc(text, func(c rune) bool {
 		return !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'))
 	})
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// isKeyword reports whether a word is long enough and uncommon enough to match on
+func isKeyword(word string) bool {
+	return len(word) > 2 && !stopWords[word]
+}
 
+// extractKeywords extracts meaningful keywords from text
+func extractKeywords(text string) []string {
 	filtered := make([]string, 0)
-	for _, word := range words {
-		word = strings.ToLower(strings.TrimSpace(word))
-		if len(word) > 2 && !stopWords[word] {
+	for _, word := range splitWords(text) {
+		if isKeyword(word) {
 			filtered = append(filtered, word)
 		}
 	}
@@ -222,40 +315,64 @@ func extractKeywords(text string) []string {
 	return filtered
 }
 
-// BuildSimpleRAGContext creates context from search results
-func BuildSimpleRAGContext(query string, results []SearchResult, maxTokens int) RAGContext {
+// extractBigrams returns each pair of keywords that are adjacent in text, joined by a space.
+// Words separated by a stop word don't form a bigram.
+func extractBigrams(text string) []string {
+	words := splitWords(text)
+	bigrams := make([]string, 0)
+	for i := 0; i+1 < len(words); i++ {
+		if isKeyword(words[i]) && isKeyword(words[i+1]) {
+			bigrams = append(bigrams, words[i]+" "+words[i+1])
+		}
+	}
+	return bigrams
+}
+
+// BuildSimpleRAGContext creates context from search results. Keyword scores are unbounded, so
+// each document's relevance is shown relative to the best result's, from 0 to 1.
+func BuildSimpleRAGContext(query string, results []SearchResult, maxTokens int, opts RAGContextOptions) RAGContext {
 	var contextBuilder strings.Builder
-	contextBuilder.WriteString("# Relevant OpenTDF Documentation\n\n")
+	if !opts.BareContext {
+		contextBuilder.WriteString(ragContextHeading)
+	}
+	var best float32
+	for _, result := range results {
+		best = max(best, result.Score)
+	}
 	
 	tokenCount := 0
 	usedResults := make([]SimilarityResult, 0)
+	sources := newSourceCounter(opts.MaxPerSource)
 	
 	for _, result := range results {
+		if opts.MaxResults > 0 && len(usedResults) >= opts.MaxResults {
+			break
+		}
+		
+		// Skip chunks from sources that already filled their slots so other sources get one,
+		// before a skipped chunk's size can end the context early
+		if !sources.allow(result.Document.FilePath, result.Document.ID) {
+			continue
+		}
+		
 		// Estimate token count (rough approximation: 1 token ≈ 4 characters)
 		docTokens := len(result.Document.Content) / 4
 		if tokenCount + docTokens > maxTokens {
 			break
 		}
 		
-		contextBuilder.WriteString(fmt.Sprintf("## %s\n", result.Document.Title))
-		contextBuilder.WriteString(fmt.Sprintf("**Source:** %s\n", result.Document.URL))
-		contextBuilder.WriteString(fmt.Sprintf("**Relevance:** %.3f\n\n", result.Score))
-		contextBuilder.WriteString(result.Document.Content)
-		contextBuilder.WriteString("\n\n---\n\n")
+		// Convert to SimilarityResult for compatibility
+		doc := Document{
+			ID:       result.Document.ID,
+			Title:    result.Document.Title,
+			Content:  result.Document.Content,
+			URL:      result.Document.URL,
+			FilePath: result.Document.FilePath,
+		}
+		writeContextDocument(&contextBuilder, doc, normalizeRelevance(result.Score, best), opts)
 		
 		tokenCount += docTokens
-		
-		// Convert to SimilarityResult for compatibility
-		usedResults = append(usedResults, SimilarityResult{
-			Document: Document{
-				ID:       result.Document.ID,
-				Title:    result.Document.Title,
-				Content:  result.Document.Content,
-				URL:      result.Document.URL,
-				FilePath: result.Document.FilePath,
-			},
-			Similarity: result.Score,
-		})
+		usedResults = append(usedResults, SimilarityResult{Document: doc, Similarity: result.Score})
 	}
 	
 	return RAGContext{
@@ -0,0 +1,299 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSimpleRAGContextMaxPerSource(t *testing.T) {
+	var results []SearchResult
+	for i := 0; i < 4; i++ {
+		results = append(results, SearchResult{
+			Document: SimpleDocument{ID: fmt.Sprintf("policy-%d", i), Content: "policy text", FilePath: "policy.md"},
+			Score:    1 - float32(i)/10,
+		})
+	}
+	results = append(results, SearchResult{
+		Document: SimpleDocument{ID: "kas", Content: "kas text", FilePath: "kas.md"},
+		Score:    0.5,
+	})
+
+	ragContext := BuildSimpleRAGContext("policy", results, 1000, RAGContextOptions{MaxPerSource: 2})
+	assert.Equal(t, 3, ragContext.NumDocuments)
+	assert.Equal(t, "policy-0", ragContext.Results[0].Document.ID)
+	assert.Equal(t, "policy-1", ragContext.Results[1].Document.ID)
+	assert.Equal(t, "kas", ragContext.Results[2].Document.ID)
+
+	uncapped := BuildSimpleRAGContext("policy", results, 1000, RAGContextOptions{})
+	assert.Equal(t, 5, uncapped.NumDocuments)
+}
+
+func TestBuildSimpleRAGContextMaxResults(t *testing.T) {
+	var results []SearchResult
+	for i := 0; i < 6; i++ {
+		results = append(results, SearchResult{
+			Document: SimpleDocument{ID: fmt.Sprintf("doc-%d", i), Content: "short text", FilePath: fmt.Sprintf("doc-%d.md", i)},
+			Score:    1 - float32(i)/10,
+		})
+	}
+
+	// The budget fits all six
+	assert.Equal(t, 6, BuildSimpleRAGContext("policy", results, 1000, RAGContextOptions{}).NumDocuments)
+	for _, maxResults := range []int{1, 3, 6, 10} {
+		ragContext := BuildSimpleRAGContext("policy", results, 1000, RAGContextOptions{MaxResults: maxResults})
+		assert.Equal(t, min(maxResults, len(results)), ragContext.NumDocuments, "max results %d", maxResults)
+		assert.Equal(t, "doc-0", ragContext.Results[0].Document.ID, "the best matches are kept")
+	}
+
+	// The budget still applies below the cap
+	tight := BuildSimpleRAGContext("policy", results, 5, RAGContextOptions{MaxResults: 3})
+	assert.Equal(t, 2, tight.NumDocuments)
+}
+
+func TestBuildSimpleRAGContextRelevance(t *testing.T) {
+	results := []SearchResult{
+		{Document: SimpleDocument{ID: "kas", Title: "KAS", URL: "https://opentdf.io/kas", Content: "The KAS rewraps keys."}, Score: 8},
+		{Document: SimpleDocument{ID: "policy", Title: "Policy", URL: "https://opentdf.io/policy", Content: "Policy binds attributes."}, Score: 2},
+	}
+
+	// Keyword scores are unbounded, so they are shown relative to the best
+	ragContext := BuildSimpleRAGContext("kas", results, 1000, RAGContextOptions{})
+	assert.Contains(t, ragContext.ContextText, "## KAS\n**Source:** https://opentdf.io/kas\n**Relevance:** 1.000\n\nThe KAS rewraps keys.")
+	assert.Contains(t, ragContext.ContextText, "**Relevance:** 0.250\n")
+	assert.InDelta(t, 8, ragContext.Results[0].Similarity, 0.0001)
+
+	bare := BuildSimpleRAGContext("kas", results, 1000, RAGContextOptions{BareContext: true})
+	assert.Equal(t, "The KAS rewraps keys.\n\nPolicy binds attributes.", bare.ContextText)
+	assert.Equal(t, 2, bare.NumDocuments)
+}
+
+func TestNoContextNoteOnlyWhenRetrievalIsEmpty(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(SimpleDocument{
+		ID:       "kas",
+		Title:    "Key Access Service",
+		Content:  "The key access service rewraps keys for entitled clients.",
+		FilePath: "kas.md",
+		Keywords: []string{"access", "service", "rewraps"},
+	}))
+
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.EnableSimpleRAG(store)
+	engine.SetRAGOptions(RAGContextOptions{NoContextNote: DefaultNoContextNote})
+
+	prompt := func(question string) string {
+		messages := []ChatMessage{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: question}}
+		p, _, err := engine.buildPromptWithRAG(messages, question)
+		require.NoError(t, err)
+		return p
+	}
+
+	grounded := prompt("How does the key access service rewrap keys?")
+	assert.Contains(t, grounded, "rewraps keys")
+	assert.NotContains(t, grounded, DefaultNoContextNote)
+
+	assert.Contains(t, prompt("What is the weather on Mars?"), DefaultNoContextNote)
+
+	// Without the option an empty retrieval leaves the prompt alone
+	engine.SetRAGOptions(RAGContextOptions{})
+	assert.NotContains(t, prompt("What is the weather on Mars?"), DefaultNoContextNote)
+}
+
+func TestRetrievalScoreFromResults(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "kas", Title: "Key Access Service", Content: "The key access service rewraps keys for entitled clients."}))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "policy", Title: "Policy", Content: "Attributes and subject mappings make up policy."}))
+
+	engine := NewSimpleChatEngine("missing.gguf")
+	engine.EnableSimpleRAG(store)
+	engine.SetRAGOptions(RAGContextOptions{MinConfidence: DefaultMinConfidence})
+
+	respond := func(question string) SimpleResponse {
+		messages := []ChatMessage{{Role: "user", Content: question}}
+		_, ragContext, err := engine.buildPromptWithRAG(messages, question)
+		require.NoError(t, err)
+		require.NotNil(t, ragContext)
+		resp := SimpleResponse{Content: "answer"}
+		engine.addRetrieval(&resp, ragContext)
+		return resp
+	}
+
+	question := "How does the key access service rewrap keys?"
+	results, err := store.Search(question, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	grounded := respond(question)
+	assert.True(t, grounded.RAGUsed)
+	assert.Equal(t, results[0].Score, grounded.RetrievalScore)
+	assert.False(t, grounded.LowConfidence)
+	assert.Equal(t, "answer", grounded.Content)
+
+	// Nothing matches, so the answer is flagged
+	ungrounded := respond("What is the weather on Mars?")
+	assert.True(t, ungrounded.RAGUsed)
+	assert.Zero(t, ungrounded.RetrievalScore)
+	assert.True(t, ungrounded.LowConfidence)
+	assert.Equal(t, LowConfidenceWarning+"answer", ungrounded.Content)
+
+	// A zero minimum never warns
+	engine.SetRAGOptions(RAGContextOptions{})
+	assert.False(t, respond("What is the weather on Mars?").LowConfidence)
+
+	// Without RAG there is no score to report
+	plain := NewSimpleChatEngine("missing.gguf")
+	_, ragContext, err := plain.buildPromptWithRAG([]ChatMessage{{Role: "user", Content: question}}, question)
+	require.NoError(t, err)
+	assert.Nil(t, ragContext)
+}
+
+func TestExplainScoreListsMatchedTerms(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	doc := SimpleDocument{
+		ID:      "kas",
+		Title:   "Rotating KAS Keys",
+		Content: "The key access service rewraps keys. Rotate keys in the registry.",
+	}
+	require.NoError(t, store.AddDocument(doc))
+
+	explanation := store.ExplainScore("rotate kas keys quickly", doc)
+	var terms []string
+	for _, term := range explanation.Terms {
+		terms = append(terms, term.Term)
+	}
+	assert.ElementsMatch(t, []string{"rotate", "kas", "keys"}, terms)
+	assert.Equal(t, []string{"quickly"}, explanation.Missing)
+	assert.Zero(t, explanation.PhraseBoost)
+
+	// "keys" appears twice in the document and in the title, so it contributes the most
+	assert.Equal(t, "keys", explanation.Terms[0].Term)
+	assert.Equal(t, 3, explanation.Terms[0].DocumentCount)
+	assert.True(t, explanation.Terms[0].TitleMatch)
+	// With a single document every term has the minimum IDF, ln 2
+	assert.InDelta(t, math.Ln2, explanation.Terms[0].IDF, 0.0001)
+	assert.InDelta(t, 0.25*math.Ln2*1.5*2, explanation.Terms[0].Contribution, 0.0001)
+
+	// The explanation adds up to the score Search ranks by
+	results, err := store.Search("rotate kas keys quickly", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.InDelta(t, results[0].Score, explanation.Score, 0.0001)
+}
+
+func TestKeywordScoreWeightsRareTermsByIDF(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	for i, content := range []string{
+		"Policy attributes define data access.",
+		"Policy subject mappings entitle entities.",
+		"Policy obligations must be fulfilled before data is released.",
+		"Policy key access servers wrap keys.",
+	} {
+		require.NoError(t, store.AddDocument(SimpleDocument{ID: fmt.Sprintf("doc%d", i), Title: fmt.Sprintf("Doc %d", i), Content: content}))
+	}
+
+	explanation := store.ExplainScore("policy obligations", store.documents[2])
+	require.Len(t, explanation.Terms, 2)
+	contributions := map[string]float32{}
+	for _, term := range explanation.Terms {
+		contributions[term.Term] = term.Contribution
+	}
+	assert.Greater(t, contributions["obligations"], contributions["policy"])
+	assert.InDelta(t, math.Log(1+4.0/1), explanation.Terms[0].IDF, 0.0001)
+	assert.InDelta(t, math.Ln2, explanation.Terms[1].IDF, 0.0001)
+
+	// Matching the rare term outranks repeating the common one
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "repeats", Title: "Policy", Content: "Policy, policy and more policy. Obligations too."}))
+	results, err := store.Search("obligations data", 5)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "doc2", results[0].Document.ID)
+
+	// Removing documents updates the frequencies
+	store.RemoveDocuments(DocumentFilter{ID: "repeats"})
+	store.RemoveDocuments(DocumentFilter{ID: "doc0"})
+	assert.InDelta(t, math.Log(1+3.0/1), store.idf("obligations"), 0.0001)
+	assert.InDelta(t, math.Log(1+3.0/3), store.idf("policy"), 0.0001)
+}
+
+func TestLoadIndexRecomputesDocumentFrequencies(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	store := NewSimpleRAGStore(indexPath)
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "a", Content: "rewrap keys"}))
+	require.NoError(t, store.AddDocument(SimpleDocument{ID: "b", Content: "rotate keys"}))
+	require.NoError(t, store.SaveIndex())
+
+	loaded := NewSimpleRAGStore(indexPath)
+	require.NoError(t, loaded.LoadIndex())
+	assert.Equal(t, store.idf("rewrap"), loaded.idf("rewrap"))
+	assert.Greater(t, loaded.idf("rewrap"), loaded.idf("keys"))
+}
+
+func TestBigramMatchOutranksScatteredWords(t *testing.T) {
+	store := NewSimpleRAGStore(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, store.AddDocument(SimpleDocument{
+		ID:      "scattered",
+		Title:   "Entities",
+		Content: "Every subject carries claims. A mapping of values, a key per service, and access rules.",
+	}))
+	require.NoError(t, store.AddDocument(SimpleDocument{
+		ID:      "adjacent",
+		Title:   "Entities",
+		Content: "A subject mapping ties claims to values. The key access service checks rules per carrier.",
+	}))
+	assert.Contains(t, store.documents[1].Bigrams, "subject mapping")
+	assert.Contains(t, store.documents[1].Bigrams, "key access")
+	assert.NotContains(t, store.documents[0].Bigrams, "subject mapping")
+
+	// Both documents contain every query word once; only word order differs
+	results, err := store.Search("how does a subject mapping work with the key access service", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "adjacent", results[0].Document.ID)
+	assert.Greater(t, results[0].Score, results[1].Score)
+
+	explanation := store.ExplainScore("subject mapping", store.documents[1])
+	require.Len(t, explanation.Bigrams, 1)
+	assert.Equal(t, "subject mapping", explanation.Bigrams[0].Bigram)
+	assert.Empty(t, store.ExplainScore("subject mapping", store.documents[0]).Bigrams)
+}
+
+func TestExtractBigramsSkipsStopWords(t *testing.T) {
+	assert.Equal(t, []string{"key access", "access server"}, extractBigrams("the key access server"))
+	// "attribute" and "values" aren't adjacent once "of the" is dropped
+	assert.Empty(t, extractBigrams("attribute of the values"))
+}
+
+func TestLoadIndexAddsMissingBigrams(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(indexPath, []byte(`{"documents":[{"id":"kas","title":"KAS","content":"Rotate the key access server keys."}]}`), 0o600))
+
+	store := NewSimpleRAGStore(indexPath)
+	require.NoError(t, store.LoadIndex())
+	assert.Equal(t, []string{"access server", "key access", "server keys"}, store.documents[0].Bigrams)
+}
+
+func TestSimpleSaveIndexIsByteStable(t *testing.T) {
+	save := func(docs []SimpleDocument) string {
+		path := filepath.Join(t.TempDir(), "index.json")
+		store := NewSimpleRAGStore(path)
+		for _, doc := range docs {
+			require.NoError(t, store.AddDocument(doc))
+		}
+		require.NoError(t, store.SaveIndex())
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	reversed := make([]SimpleDocument, len(openTDFDocs))
+	for i, doc := range openTDFDocs {
+		reversed[len(openTDFDocs)-1-i] = doc
+	}
+	assert.Equal(t, save(openTDFDocs), save(reversed))
+}
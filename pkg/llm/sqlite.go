@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	// Registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+const sqliteMetadataSchema = `
+CREATE TABLE IF NOT EXISTS metadata (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+// sqlExecutor is the part of *sql.DB and *sql.Tx the SQLite stores query through
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqliteDB is a SQLite database opened on first use. Changes are made in a transaction that
+// commit persists and close discards. It isn't safe for concurrent use; the stores built on it
+// hold their own lock.
+type sqliteDB struct {
+	path   string
+	schema string
+	db     *sql.DB
+	tx     *sql.Tx
+}
+
+// open connects to the database, creating it and its schema if needed
+func (d *sqliteDB) open() error {
+	if d.db != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", d.path)
+	if err != nil {
+		return err
+	}
+	// A single connection keeps reads inside the pending transaction consistent with its writes
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(d.schema + sqliteMetadataSchema); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create schema in %s: %w", d.path, err)
+	}
+	d.db = db
+
+	version, err := d.metadata("format_version")
+	if err == nil && version != "" {
+		n, _ := strconv.Atoi(version)
+		err = checkIndexVersion(d.path, n)
+	}
+	if err != nil {
+		d.close()
+		return err
+	}
+	return nil
+}
+
+// addColumn adds column to table, in databases created before the schema had it. The database
+// must be open.
+func (d *sqliteDB) addColumn(table, column, definition string) error {
+	var n int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := d.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		return fmt.Errorf("failed to add %s.%s to %s: %w", table, column, d.path, err)
+	}
+	return nil
+}
+
+// reader returns the pending transaction, if any, so reads see unsaved changes. The database
+// must be open.
+func (d *sqliteDB) reader() sqlExecutor {
+	if d.tx != nil {
+		return d.tx
+	}
+	return d.db
+}
+
+// writer returns the pending transaction, opening the database and starting one if needed
+func (d *sqliteDB) writer() (*sql.Tx, error) {
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	if d.tx == nil {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		d.tx = tx
+	}
+	return d.tx, nil
+}
+
+// commit persists the pending transaction, if any, recording the format it was written in
+func (d *sqliteDB) commit() error {
+	if d.tx == nil {
+		return nil
+	}
+	if err := d.setMetadata("format_version", strconv.Itoa(IndexFormatVersion)); err != nil {
+		return err
+	}
+	err := d.tx.Commit()
+	d.tx = nil
+	return err
+}
+
+// close discards the pending transaction and closes the database
+func (d *sqliteDB) close() error {
+	if d.db == nil {
+		return nil
+	}
+	if d.tx != nil {
+		_ = d.tx.Rollback()
+		d.tx = nil
+	}
+	err := d.db.Close()
+	d.db = nil
+	return err
+}
+
+// metadata returns the value stored under key, or "" if there is none. The database must be open.
+func (d *sqliteDB) metadata(key string) (string, error) {
+	var value string
+	err := d.reader().QueryRow(`SELECT value FROM metadata WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// setMetadata stores value under key in the pending transaction
+func (d *sqliteDB) setMetadata(key, value string) error {
+	tx, err := d.writer()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+// count returns the number of rows in table, or 0 if the database isn't open
+func (d *sqliteDB) count(table string) (int, error) {
+	if d.db == nil {
+		return 0, nil
+	}
+	var n int
+	err := d.reader().QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n)
+	return n, err
+}
+
+// deleteSeqs deletes the rows of table whose seq column is in seqs, in the pending transaction
+func (d *sqliteDB) deleteSeqs(table, seqColumn string, seqs []int64) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+	tx, err := d.writer()
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, len(seqs))
+	args := make([]any, len(seqs))
+	for i, seq := range seqs {
+		placeholders[i] = "?"
+		args[i] = seq
+	}
+	_, err = tx.Exec(`DELETE FROM `+table+` WHERE `+seqColumn+` IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	return err
+}
+
+// filterClause returns a WHERE clause selecting the rows that match filter's ID and source file,
+// leaving its search text to DocumentFilter.matches
+func filterClause(filter DocumentFilter) (string, []any) {
+	var conditions []string
+	var args []any
+	if filter.ID != "" {
+		conditions = append(conditions, "id = ?")
+		args = append(args, filter.ID)
+	}
+	if filter.SourceFile != "" {
+		conditions = append(conditions, "file_path = ?")
+		args = append(args, filter.SourceFile)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// keepTopK adds item to best, which is ordered by descending score and holds at most k items.
+// Items with equal scores keep the order they were added in.
+func keepTopK[T any](best []T, k int, item T, score func(T) float32) []T {
+	if k <= 0 || (len(best) == k && score(item) <= score(best[len(best)-1])) {
+		return best
+	}
+	i := len(best)
+	for i > 0 && score(best[i-1]) < score(item) {
+		i--
+	}
+	if len(best) < k {
+		best = append(best, item)
+	}
+	copy(best[i+1:], best[i:])
+	best[i] = item
+	return best
+}
@@ -0,0 +1,368 @@
+package llm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Each document's distinct keywords go in an FTS5 table keyed by the document's seq, so a search
+// only reads the documents sharing a term with the query, and the fts5vocab table counts the
+// documents each term appears in for IDF weighting.
+const sqliteKeywordStoreSchema = `
+CREATE TABLE IF NOT EXISTS simple_documents (
+	seq       INTEGER PRIMARY KEY AUTOINCREMENT,
+	id        TEXT NOT NULL,
+	title     TEXT NOT NULL,
+	content   TEXT NOT NULL,
+	url       TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	keywords  TEXT NOT NULL,
+	bigrams   TEXT NOT NULL,
+	language  TEXT NOT NULL,
+	boost     REAL NOT NULL DEFAULT 0
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS simple_terms USING fts5(terms);
+CREATE VIRTUAL TABLE IF NOT EXISTS simple_terms_vocab USING fts5vocab(simple_terms, 'row');`
+
+// SQLiteKeywordStore is a KeywordStore kept in a SQLite database with an FTS5 full-text index.
+// It ranks documents the same way as SimpleRAGStore, but only considers those containing at
+// least one query keyword, and persists changes without rewriting the whole index. Changes are
+// made in a transaction that SaveIndex commits.
+type SQLiteKeywordStore struct {
+	mu     sync.Mutex
+	db     sqliteDB
+	logger Logger
+}
+
+// NewSQLiteKeywordStore creates a keyword store backed by the SQLite database at path, which is
+// created when the store is first loaded or saved
+func NewSQLiteKeywordStore(path string) *SQLiteKeywordStore {
+	return &SQLiteKeywordStore{
+		db:     sqliteDB{path: path, schema: sqliteKeywordStoreSchema},
+		logger: NopLogger(),
+	}
+}
+
+// SetLogger routes the store's diagnostic messages to the given logger
+func (s *SQLiteKeywordStore) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logger = logger
+}
+
+// LoadIndex opens the database, creating it if it doesn't exist
+func (s *SQLiteKeywordStore) LoadIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexLoad, err)
+	}
+	s.logger.Printf("Loaded %d documents from SQLite keyword index", s.count())
+	return nil
+}
+
+// open connects to the database. The caller must hold s.mu.
+func (s *SQLiteKeywordStore) open() error {
+	if s.db.db != nil {
+		return nil
+	}
+	if err := s.db.open(); err != nil {
+		return err
+	}
+	// Indexes created before boosts were recorded lack the column
+	if err := s.db.addColumn("simple_documents", "boost", "REAL NOT NULL DEFAULT 0"); err != nil {
+		s.db.close()
+		return err
+	}
+	return nil
+}
+
+// writer returns the pending transaction, starting one if needed. The caller must hold s.mu.
+func (s *SQLiteKeywordStore) writer() (*sql.Tx, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s.db.writer()
+}
+
+// SaveIndex commits the changes made since the last save
+func (s *SQLiteKeywordStore) SaveIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexSave, err)
+	}
+	if err := s.db.commit(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexSave, err)
+	}
+
+	s.logger.Printf("Saved SQLite keyword index with %d documents", s.count())
+	return nil
+}
+
+// Close discards unsaved changes and closes the database
+func (s *SQLiteKeywordStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.close()
+}
+
+// AddDocument adds a document to the store
+func (s *SQLiteKeywordStore) AddDocument(doc SimpleDocument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if doc.Bigrams == nil {
+		doc.Bigrams = documentBigrams(doc)
+	}
+	keywords, err := json.Marshal(doc.Keywords)
+	if err != nil {
+		return err
+	}
+	bigrams, err := json.Marshal(doc.Bigrams)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.writer()
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`INSERT INTO simple_documents (id, title, content, url, file_path, keywords, bigrams, language, boost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.Title, doc.Content, doc.URL, doc.FilePath, string(keywords), string(bigrams), doc.Language, doc.Boost)
+	if err != nil {
+		return err
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	terms := make([]string, 0)
+	for term := range documentTerms(doc) {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	_, err = tx.Exec(`INSERT INTO simple_terms (rowid, terms) VALUES (?, ?)`, seq, strings.Join(terms, " "))
+	return err
+}
+
+// Search finds the documents that best match the query's keywords
+func (s *SQLiteKeywordStore) Search(query string, topK int) ([]SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := parseKeywordQuery(query)
+	if s.db.db == nil || len(q.words) == 0 {
+		return []SearchResult{}, nil
+	}
+	idf, err := s.idf(q.words)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every query term is a keyword, so FTS5 matches the quoted terms literally
+	quoted := make([]string, len(q.words))
+	for i, word := range q.words {
+		quoted[i] = `"` + word + `"`
+	}
+	rows, err := s.db.reader().Query(`SELECT d.id, d.title, d.content, d.url, d.file_path, d.keywords, d.bigrams, d.language, d.boost
+		FROM simple_terms JOIN simple_documents d ON d.seq = simple_terms.rowid
+		WHERE simple_terms MATCH ? ORDER BY d.seq`, strings.Join(quoted, " OR "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		doc, err := scanSimpleDocument(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		if score := scoreKeywords(q, doc, idf).Score; score > 0 {
+			results = keepTopK(results, topK, SearchResult{Document: doc, Score: score}, func(r SearchResult) float32 { return r.Score })
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	return results, nil
+}
+
+// scanSimpleDocument reads a simple_documents row selected as id, title, content, url,
+// file_path, keywords, bigrams, language and boost
+func scanSimpleDocument(scan func(dest ...any) error) (SimpleDocument, error) {
+	var doc SimpleDocument
+	var keywords, bigrams string
+	if err := scan(&doc.ID, &doc.Title, &doc.Content, &doc.URL, &doc.FilePath, &keywords, &bigrams, &doc.Language, &doc.Boost); err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal([]byte(keywords), &doc.Keywords); err != nil {
+		return doc, err
+	}
+	err := json.Unmarshal([]byte(bigrams), &doc.Bigrams)
+	return doc, err
+}
+
+// idf returns the smoothed inverse document frequency of each of terms, looked up from the
+// full-text index. The caller must hold s.mu.
+func (s *SQLiteKeywordStore) idf(terms []string) (func(term string) float32, error) {
+	n, err := s.db.count("simple_documents")
+	if err != nil {
+		return nil, err
+	}
+	docFreq := make(map[string]int)
+	if s.db.db != nil {
+		placeholders := make([]string, len(terms))
+		args := make([]any, len(terms))
+		for i, term := range terms {
+			placeholders[i] = "?"
+			args[i] = term
+		}
+		rows, err := s.db.reader().Query(`SELECT term, doc FROM simple_terms_vocab WHERE term IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var term string
+			var df int
+			if err := rows.Scan(&term, &df); err != nil {
+				return nil, err
+			}
+			docFreq[term] = df
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return func(term string) float32 { return smoothedIDF(docFreq[term], n) }, nil
+}
+
+// ExplainScore reports how doc's keyword score for query is made up
+func (s *SQLiteKeywordStore) ExplainScore(query string, doc SimpleDocument) KeywordExplanation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := parseKeywordQuery(query)
+	idf, err := s.idf(q.words)
+	if err != nil {
+		s.logger.Printf("Warning: failed to read term frequencies from %s: %v", s.db.path, err)
+		idf = func(string) float32 { return smoothedIDF(0, 0) }
+	}
+	return scoreKeywords(q, doc, idf)
+}
+
+// GetDocumentCount returns the number of documents in the store
+func (s *SQLiteKeywordStore) GetDocumentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count()
+}
+
+// count returns the number of stored documents, or 0 if they can't be counted. The caller must
+// hold s.mu.
+func (s *SQLiteKeywordStore) count() int {
+	n, err := s.db.count("simple_documents")
+	if err != nil {
+		s.logger.Printf("Warning: failed to count documents in %s: %v", s.db.path, err)
+	}
+	return n
+}
+
+// documents returns every stored document that matches filter, ignoring its paging, in index
+// order. The caller must hold s.mu.
+func (s *SQLiteKeywordStore) documents(filter DocumentFilter) ([]storedDocument, error) {
+	if s.db.db == nil {
+		return nil, nil
+	}
+	where, args := filterClause(filter)
+	rows, err := s.db.reader().Query(`SELECT seq, id, title, content, file_path, language
+		FROM simple_documents`+where+` ORDER BY seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []storedDocument
+	for rows.Next() {
+		var doc storedDocument
+		if err := rows.Scan(&doc.seq, &doc.ID, &doc.Title, &doc.content, &doc.FilePath, &doc.Language); err != nil {
+			return nil, err
+		}
+		if filter.matches(doc.ID, doc.Title, doc.content, doc.FilePath) {
+			doc.ContentLength = len(doc.content)
+			docs = append(docs, doc)
+		}
+	}
+	return docs, rows.Err()
+}
+
+// ListDocuments returns the stored documents that match the filter, in index order.
+// Simple documents are never chunked, so ChunkIndex is always 0.
+func (s *SQLiteKeywordStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.documents(filter)
+	if err != nil {
+		s.logger.Printf("Warning: failed to list documents in %s: %v", s.db.path, err)
+	}
+	return pageStoredDocuments(filter, stored)
+}
+
+// RemoveDocuments deletes the documents matching the filter's ID and source file and returns how many were removed.
+// Search and paging are ignored, and a filter without an ID or source file removes nothing.
+func (s *SQLiteKeywordStore) RemoveDocuments(filter DocumentFilter) int {
+	if filter.ID == "" && filter.SourceFile == "" {
+		return 0
+	}
+	filter.Search = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.documents(filter)
+	if err == nil {
+		err = s.db.deleteSeqs("simple_documents", "seq", storedSeqs(stored))
+	}
+	if err == nil {
+		err = s.db.deleteSeqs("simple_terms", "rowid", storedSeqs(stored))
+	}
+	if err != nil {
+		s.logger.Printf("Warning: failed to remove documents from %s: %v", s.db.path, err)
+		return 0
+	}
+	return len(stored)
+}
+
+// Clear removes every document
+func (s *SQLiteKeywordStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.writer()
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM simple_documents`)
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM simple_terms`)
+	}
+	if err != nil {
+		s.logger.Printf("Warning: failed to clear %s: %v", s.db.path, err)
+	}
+}
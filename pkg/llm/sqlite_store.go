@@ -0,0 +1,446 @@
+package llm
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+const sqliteStoreSchema = `
+CREATE TABLE IF NOT EXISTS documents (
+	seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+	id              TEXT NOT NULL,
+	title           TEXT NOT NULL,
+	content         TEXT NOT NULL,
+	url             TEXT NOT NULL,
+	file_path       TEXT NOT NULL,
+	chunk_index     INTEGER NOT NULL,
+	total_chunks    INTEGER NOT NULL,
+	language        TEXT NOT NULL,
+	embedding       BLOB NOT NULL,
+	title_embedding BLOB,
+	parent_id       TEXT NOT NULL DEFAULT '',
+	boost           REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS documents_id ON documents (id);`
+
+// SQLiteStore is a Store kept in a SQLite database. Search reads embeddings a row at a time
+// and only loads the content of the best matches, so indexes can grow beyond what fits
+// comfortably in memory. Changes are made in a transaction that SaveIndex commits.
+type SQLiteStore struct {
+	mu           sync.Mutex
+	db           sqliteDB
+	embeddingDim int
+	titleBoost   float32
+	logger       Logger
+}
+
+// NewSQLiteStore creates a store backed by the SQLite database at path, which is created when
+// the store is first loaded or saved
+func NewSQLiteStore(path string) *SQLiteStore {
+	return &SQLiteStore{
+		db:     sqliteDB{path: path, schema: sqliteStoreSchema},
+		logger: NopLogger(),
+	}
+}
+
+// SetLogger routes the store's diagnostic messages to the given logger
+func (s *SQLiteStore) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logger = logger
+}
+
+// SetTitleBoost sets how much title similarity contributes to a document's score.
+// A weight of 0 scores on the chunk body alone; 1 scores on the title alone.
+func (s *SQLiteStore) SetTitleBoost(weight float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.titleBoost = weight
+}
+
+// LoadIndex opens the database, creating it if it doesn't exist
+func (s *SQLiteStore) LoadIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexLoad, err)
+	}
+	s.logger.Printf("Loaded %d documents from SQLite index", s.count())
+	return nil
+}
+
+// open connects to the database and reads the embedding dimension. The caller must hold s.mu.
+func (s *SQLiteStore) open() error {
+	if s.db.db != nil {
+		return nil
+	}
+	if err := s.db.open(); err != nil {
+		return err
+	}
+	// Indexes created before parent IDs and boosts were recorded lack the columns
+	if err := s.db.addColumn("documents", "parent_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		s.db.close()
+		return err
+	}
+	if err := s.db.addColumn("documents", "boost", "REAL NOT NULL DEFAULT 0"); err != nil {
+		s.db.close()
+		return err
+	}
+	dim, err := s.db.metadata("embedding_dim")
+	if err != nil {
+		s.db.close()
+		return err
+	}
+	s.embeddingDim, _ = strconv.Atoi(dim)
+	return nil
+}
+
+// writer returns the pending transaction, starting one if needed. The caller must hold s.mu.
+func (s *SQLiteStore) writer() (*sql.Tx, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s.db.writer()
+}
+
+// SaveIndex commits the changes made since the last save
+func (s *SQLiteStore) SaveIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexSave, err)
+	}
+	if err := s.db.setMetadata("embedding_dim", strconv.Itoa(s.embeddingDim)); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexSave, err)
+	}
+	if err := s.db.commit(); err != nil {
+		return fmt.Errorf("%w: %w", ErrIndexSave, err)
+	}
+
+	s.logger.Printf("Saved SQLite index with %d documents", s.count())
+	return nil
+}
+
+// Close discards unsaved changes and closes the database
+func (s *SQLiteStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.close()
+}
+
+// AddDocument adds a document with its embedding to the store
+func (s *SQLiteStore) AddDocument(doc Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.writer()
+	if err != nil {
+		return err
+	}
+
+	if err := checkDocumentEmbeddings(doc); err != nil {
+		return fmt.Errorf("document %s: %w", doc.ID, err)
+	}
+	if s.embeddingDim == 0 && len(doc.Embedding) > 0 {
+		s.embeddingDim = len(doc.Embedding)
+	}
+	if len(doc.Embedding) != s.embeddingDim && s.embeddingDim > 0 {
+		return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, s.embeddingDim, len(doc.Embedding))
+	}
+
+	_, err = tx.Exec(`INSERT INTO documents
+		(id, title, content, url, file_path, chunk_index, total_chunks, language, embedding, title_embedding, parent_id, boost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.Title, doc.Content, doc.URL, doc.FilePath, doc.ChunkIndex, doc.TotalChunks, doc.Language,
+		encodeEmbedding(doc.Embedding), encodeEmbedding(doc.TitleEmbedding), doc.ParentID, doc.Boost)
+	return err
+}
+
+// Search finds the most similar documents to a query embedding
+func (s *SQLiteStore) Search(queryEmbedding []float32, topK int) ([]SimilarityResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(queryEmbedding) != s.embeddingDim {
+		return nil, fmt.Errorf("query %w: expected %d, got %d", ErrDimensionMismatch, s.embeddingDim, len(queryEmbedding))
+	}
+	if err := checkEmbeddingValues(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("query %w", err)
+	}
+	if s.db.db == nil {
+		return []SimilarityResult{}, nil
+	}
+
+	// Score every row from its embeddings and boost alone, keeping only the topK best
+	rows, err := s.db.reader().Query(`SELECT seq, embedding, title_embedding, boost FROM documents ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	type scored struct {
+		seq        int64
+		similarity float32
+	}
+	var best []scored
+	for rows.Next() {
+		var seq int64
+		var embedding, titleEmbedding []byte
+		var boost float32
+		if err := rows.Scan(&seq, &embedding, &titleEmbedding, &boost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		doc := Document{Embedding: decodeEmbedding(embedding), TitleEmbedding: decodeEmbedding(titleEmbedding), Boost: boost}
+		// Rows written before embeddings were checked could poison the ranking
+		if checkDocumentEmbeddings(doc) != nil {
+			continue
+		}
+		similarity := explainVectorSimilarity(queryEmbedding, doc, s.titleBoost).Similarity
+		best = keepTopK(best, topK, scored{seq: seq, similarity: similarity}, func(r scored) float32 { return r.similarity })
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]SimilarityResult, 0, len(best))
+	for _, match := range best {
+		doc, err := s.document(match.seq)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SimilarityResult{Document: doc, Similarity: match.similarity})
+	}
+	return results, nil
+}
+
+// document reads the full document stored in row seq. The caller must hold s.mu.
+func (s *SQLiteStore) document(seq int64) (Document, error) {
+	var doc Document
+	var embedding, titleEmbedding []byte
+	err := s.db.reader().QueryRow(`SELECT id, title, content, url, file_path, chunk_index, total_chunks, language,
+		embedding, title_embedding, parent_id, boost FROM documents WHERE seq = ?`, seq).Scan(
+		&doc.ID, &doc.Title, &doc.Content, &doc.URL, &doc.FilePath, &doc.ChunkIndex, &doc.TotalChunks, &doc.Language,
+		&embedding, &titleEmbedding, &doc.ParentID, &doc.Boost)
+	doc.Embedding = decodeEmbedding(embedding)
+	doc.TitleEmbedding = decodeEmbedding(titleEmbedding)
+	return doc, err
+}
+
+// GetDocument returns the full document with the given ID. If the ID was added more than
+// once, the latest copy is returned.
+func (s *SQLiteStore) GetDocument(id string) (Document, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db.db == nil {
+		return Document{}, false
+	}
+	var seq int64
+	err := s.db.reader().QueryRow(`SELECT seq FROM documents WHERE id = ? ORDER BY seq DESC LIMIT 1`, id).Scan(&seq)
+	if err == nil {
+		var doc Document
+		if doc, err = s.document(seq); err == nil {
+			return doc, true
+		}
+	}
+	if err != sql.ErrNoRows {
+		s.logger.Printf("Warning: failed to read document %s from %s: %v", id, s.db.path, err)
+	}
+	return Document{}, false
+}
+
+// ExplainSimilarity reports how doc's similarity to queryEmbedding is made up
+func (s *SQLiteStore) ExplainSimilarity(queryEmbedding []float32, doc Document) VectorExplanation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return explainVectorSimilarity(queryEmbedding, doc, s.titleBoost)
+}
+
+// GetDocumentCount returns the number of documents in the store
+func (s *SQLiteStore) GetDocumentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count()
+}
+
+// count returns the number of stored documents, or 0 if they can't be counted. The caller must
+// hold s.mu.
+func (s *SQLiteStore) count() int {
+	n, err := s.db.count("documents")
+	if err != nil {
+		s.logger.Printf("Warning: failed to count documents in %s: %v", s.db.path, err)
+	}
+	return n
+}
+
+// EmbeddingDim returns the size of the embeddings in the store, or 0 if it has none
+func (s *SQLiteStore) EmbeddingDim() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.embeddingDim
+}
+
+// storedDocument is a row of a documents table without its embeddings or keywords
+type storedDocument struct {
+	seq int64
+	DocumentInfo
+	content string
+}
+
+// documents returns every stored document that matches filter, ignoring its paging, in index
+// order. The caller must hold s.mu.
+func (s *SQLiteStore) documents(filter DocumentFilter) ([]storedDocument, error) {
+	if s.db.db == nil {
+		return nil, nil
+	}
+	where, args := filterClause(filter)
+	rows, err := s.db.reader().Query(`SELECT seq, id, title, content, file_path, chunk_index, language
+		FROM documents`+where+` ORDER BY seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []storedDocument
+	for rows.Next() {
+		var doc storedDocument
+		if err := rows.Scan(&doc.seq, &doc.ID, &doc.Title, &doc.content, &doc.FilePath, &doc.ChunkIndex, &doc.Language); err != nil {
+			return nil, err
+		}
+		if filter.matches(doc.ID, doc.Title, doc.content, doc.FilePath) {
+			doc.ContentLength = len(doc.content)
+			docs = append(docs, doc)
+		}
+	}
+	return docs, rows.Err()
+}
+
+// ListDocuments returns the stored documents that match the filter, in index order
+func (s *SQLiteStore) ListDocuments(filter DocumentFilter) []DocumentInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.documents(filter)
+	if err != nil {
+		s.logger.Printf("Warning: failed to list documents in %s: %v", s.db.path, err)
+	}
+	return pageStoredDocuments(filter, stored)
+}
+
+// EachDocument calls fn with every stored document in index order, stopping at the first error
+func (s *SQLiteStore) EachDocument(fn func(Document) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.documents(DocumentFilter{})
+	if err != nil {
+		return err
+	}
+	for _, row := range stored {
+		doc, err := s.document(row.seq)
+		if err != nil {
+			return fmt.Errorf("failed to read document %s: %w", row.ID, err)
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageStoredDocuments applies the filter's paging to stored documents
+func pageStoredDocuments(filter DocumentFilter, stored []storedDocument) []DocumentInfo {
+	docs := make([]DocumentInfo, 0, len(stored))
+	for _, doc := range stored {
+		docs = append(docs, doc.DocumentInfo)
+	}
+	return filter.page(docs)
+}
+
+// RemoveDocuments deletes the documents matching the filter's ID and source file and returns how many were removed.
+// Search and paging are ignored, and a filter without an ID or source file removes nothing.
+func (s *SQLiteStore) RemoveDocuments(filter DocumentFilter) int {
+	if filter.ID == "" && filter.SourceFile == "" {
+		return 0
+	}
+	filter.Search = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.documents(filter)
+	if err == nil {
+		err = s.db.deleteSeqs("documents", "seq", storedSeqs(stored))
+	}
+	if err != nil {
+		s.logger.Printf("Warning: failed to remove documents from %s: %v", s.db.path, err)
+		return 0
+	}
+
+	// An empty store accepts embeddings of any dimension again
+	if s.count() == 0 {
+		s.embeddingDim = 0
+	}
+	return len(stored)
+}
+
+// storedSeqs returns the row numbers of stored documents
+func storedSeqs(stored []storedDocument) []int64 {
+	seqs := make([]int64, len(stored))
+	for i, doc := range stored {
+		seqs[i] = doc.seq
+	}
+	return seqs
+}
+
+// Clear removes every document and resets the embedding dimension so a new model can be used
+func (s *SQLiteStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.writer()
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM documents`)
+	}
+	if err != nil {
+		s.logger.Printf("Warning: failed to clear %s: %v", s.db.path, err)
+		return
+	}
+	s.embeddingDim = 0
+}
+
+// encodeEmbedding packs an embedding as little-endian float32s, or nil if it is empty
+func encodeEmbedding(embedding []float32) []byte {
+	if len(embedding) == 0 {
+		return nil
+	}
+	b := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+// decodeEmbedding unpacks an embedding written by encodeEmbedding
+func decodeEmbedding(b []byte) []float32 {
+	if len(b) == 0 {
+		return nil
+	}
+	embedding := make([]float32, len(b)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return embedding
+}
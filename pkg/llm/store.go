@@ -0,0 +1,83 @@
+package llm
+
+import "fmt"
+
+const (
+	// StoreJSON keeps the whole index in memory and saves it as a JSON file
+	StoreJSON = "json"
+	// StoreSQLite keeps the index in a SQLite database, so search doesn't load every chunk at once
+	StoreSQLite = "sqlite"
+)
+
+// Store holds embedded document chunks and finds the ones most similar to a query embedding.
+// *VectorStore and *SQLiteStore implement it.
+//
+// Changes made by AddDocument, RemoveDocuments and Clear are only persisted by SaveIndex.
+type Store interface {
+	SetLogger(logger Logger)
+	// SetTitleBoost sets how much title similarity contributes to a document's score
+	SetTitleBoost(weight float32)
+	LoadIndex() error
+	SaveIndex() error
+	// Close releases the store's resources, discarding unsaved changes
+	Close() error
+	AddDocument(doc Document) error
+	Search(queryEmbedding []float32, topK int) ([]SimilarityResult, error)
+	ExplainSimilarity(queryEmbedding []float32, doc Document) VectorExplanation
+	GetDocumentCount() int
+	// EmbeddingDim returns the size of the stored embeddings, or 0 if there are none
+	EmbeddingDim() int
+	// GetDocument returns the full document with the given ID, if there is one
+	GetDocument(id string) (Document, bool)
+	ListDocuments(filter DocumentFilter) []DocumentInfo
+	// EachDocument calls fn with every full document, duplicates included, in index order,
+	// stopping at the first error fn returns. fn must not call back into the store.
+	EachDocument(fn func(Document) error) error
+	RemoveDocuments(filter DocumentFilter) int
+	Clear()
+}
+
+// NewStore creates a store of the given kind, StoreJSON or StoreSQLite, backed by the file at
+// path. An empty kind means StoreJSON.
+func NewStore(kind, path string) (Store, error) {
+	switch kind {
+	case StoreJSON, "":
+		return NewVectorStore(path), nil
+	case StoreSQLite:
+		return NewSQLiteStore(path), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected %s or %s", kind, StoreJSON, StoreSQLite)
+	}
+}
+
+// KeywordStore holds documents for keyword retrieval and ranks them against a query's terms.
+// *SimpleRAGStore and *SQLiteKeywordStore implement it.
+//
+// Changes made by AddDocument, RemoveDocuments and Clear are only persisted by SaveIndex.
+type KeywordStore interface {
+	SetLogger(logger Logger)
+	LoadIndex() error
+	SaveIndex() error
+	// Close releases the store's resources, discarding unsaved changes
+	Close() error
+	AddDocument(doc SimpleDocument) error
+	Search(query string, topK int) ([]SearchResult, error)
+	ExplainScore(query string, doc SimpleDocument) KeywordExplanation
+	GetDocumentCount() int
+	ListDocuments(filter DocumentFilter) []DocumentInfo
+	RemoveDocuments(filter DocumentFilter) int
+	Clear()
+}
+
+// NewKeywordStore creates a keyword store of the given kind, StoreJSON or StoreSQLite, backed
+// by the file at path. An empty kind means StoreJSON.
+func NewKeywordStore(kind, path string) (KeywordStore, error) {
+	switch kind {
+	case StoreJSON, "":
+		return NewSimpleRAGStore(path), nil
+	case StoreSQLite:
+		return NewSQLiteKeywordStore(path), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected %s or %s", kind, StoreJSON, StoreSQLite)
+	}
+}
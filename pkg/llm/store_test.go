@@ -0,0 +1,423 @@
+package llm
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ Store = (*VectorStore)(nil)
+	_ Store = (*SQLiteStore)(nil)
+
+	_ KeywordStore = (*SimpleRAGStore)(nil)
+	_ KeywordStore = (*SQLiteKeywordStore)(nil)
+)
+
+// storeBackends creates each kind of Store at a path in dir
+var storeBackends = map[string]func(dir string) Store{
+	StoreJSON:   func(dir string) Store { return NewVectorStore(filepath.Join(dir, "index.json")) },
+	StoreSQLite: func(dir string) Store { return NewSQLiteStore(filepath.Join(dir, "index.db")) },
+}
+
+// injectDocument stores doc without the checks AddDocument makes, as an index written before
+// the checks existed or damaged on disk could hold it
+func injectDocument(t *testing.T, store Store, doc Document) {
+	t.Helper()
+	switch s := store.(type) {
+	case *VectorStore:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.documents = append(s.documents, doc)
+	case *SQLiteStore:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		tx, err := s.writer()
+		require.NoError(t, err)
+		_, err = tx.Exec(`INSERT INTO documents
+			(id, title, content, url, file_path, chunk_index, total_chunks, language, embedding, title_embedding)
+			VALUES (?, '', ?, '', '', 0, 0, '', ?, ?)`,
+			doc.ID, doc.Content, encodeEmbedding(doc.Embedding), encodeEmbedding(doc.TitleEmbedding))
+		require.NoError(t, err)
+	default:
+		t.Fatalf("can't inject documents into %T", store)
+	}
+}
+
+func TestStoreRejectsInvalidEmbeddings(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(-1))
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			store := newStore(t.TempDir())
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+			require.NoError(t, store.AddDocument(Document{ID: "kas", Content: "The KAS rewraps keys.", Embedding: []float32{1, 0}}))
+
+			for name, doc := range map[string]Document{
+				"NaN":       {ID: "nan", Embedding: []float32{nan, 1}},
+				"infinite":  {ID: "inf", Embedding: []float32{0, inf}},
+				"empty":     {ID: "empty"},
+				"NaN title": {ID: "title", Embedding: []float32{0, 1}, TitleEmbedding: []float32{nan, 0}},
+			} {
+				assert.ErrorIs(t, store.AddDocument(doc), ErrInvalidEmbedding, name)
+			}
+			assert.Equal(t, 1, store.GetDocumentCount())
+
+			_, err := store.Search([]float32{nan, 0}, 5)
+			assert.ErrorIs(t, err, ErrInvalidEmbedding, "a bad query is rejected too")
+
+			// Documents that got into the index anyway are skipped rather than ranked
+			injectDocument(t, store, Document{ID: "poisoned", Content: "NaN", Embedding: []float32{nan, 0}})
+			injectDocument(t, store, Document{ID: "policy", Content: "Policy", Embedding: []float32{0.5, 0.5}})
+			results, err := store.Search([]float32{1, 0}, 5)
+			require.NoError(t, err)
+			var ids []string
+			for _, r := range results {
+				ids = append(ids, r.Document.ID)
+			}
+			assert.Equal(t, []string{"kas", "policy"}, ids)
+		})
+	}
+}
+
+func TestStoreConformance(t *testing.T) {
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			testStoreConformance(t, newStore)
+		})
+	}
+}
+
+// testStoreConformance checks the behavior every Store implementation must share
+func testStoreConformance(t *testing.T, newStore func(dir string) Store) {
+	openStore := func(t *testing.T, dir string) Store {
+		t.Helper()
+		store := newStore(dir)
+		require.NoError(t, store.LoadIndex())
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	addDocs := func(t *testing.T, store Store) {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, store.AddDocument(Document{
+				ID:         fmt.Sprintf("kas_chunk_%d", i),
+				Title:      "Key Access Service",
+				Content:    fmt.Sprintf("KAS rewrap step %d", i),
+				FilePath:   "protocol/kas.md",
+				ChunkIndex: i,
+				Embedding:  []float32{1, float32(i), 0},
+			}))
+		}
+		require.NoError(t, store.AddDocument(Document{
+			ID:             "policy_chunk_0",
+			Title:          "Policy",
+			Content:        "Attribute definitions and subject mappings",
+			URL:            "https://opentdf.io/policy",
+			FilePath:       "protocol/policy.md",
+			TotalChunks:    1,
+			Language:       "en",
+			Embedding:      []float32{0, 0, 1},
+			TitleEmbedding: []float32{0, 1, 0},
+		}))
+	}
+
+	t.Run("search ranks by similarity", func(t *testing.T) {
+		store := openStore(t, t.TempDir())
+		addDocs(t, store)
+		assert.Equal(t, 4, store.GetDocumentCount())
+		assert.Equal(t, 3, store.EmbeddingDim())
+
+		results, err := store.Search([]float32{0, 0, 1}, 2)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "policy_chunk_0", results[0].Document.ID)
+		assert.Equal(t, "Attribute definitions and subject mappings", results[0].Document.Content)
+		assert.Equal(t, "https://opentdf.io/policy", results[0].Document.URL)
+		assert.Equal(t, []float32{0, 0, 1}, results[0].Document.Embedding)
+		assert.InDelta(t, 1, results[0].Similarity, 0.0001)
+		assert.GreaterOrEqual(t, results[0].Similarity, results[1].Similarity)
+
+		results, err = store.Search([]float32{1, 0, 0}, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 4, "topK larger than the store returns every document")
+		assert.Equal(t, "kas_chunk_0", results[0].Document.ID)
+	})
+
+	t.Run("dimension mismatch", func(t *testing.T) {
+		store := openStore(t, t.TempDir())
+		addDocs(t, store)
+
+		err := store.AddDocument(Document{ID: "short", Embedding: []float32{1, 0}})
+		require.ErrorIs(t, err, ErrDimensionMismatch)
+		_, err = store.Search([]float32{1, 0}, 1)
+		require.ErrorIs(t, err, ErrDimensionMismatch)
+	})
+
+	t.Run("persists on save", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openStore(t, dir)
+		addDocs(t, store)
+		require.NoError(t, store.SaveIndex())
+		require.NoError(t, store.Close())
+
+		reopened := openStore(t, dir)
+		assert.Equal(t, 4, reopened.GetDocumentCount())
+		assert.Equal(t, 3, reopened.EmbeddingDim())
+		results, err := reopened.Search([]float32{0, 0, 1}, 1)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "policy_chunk_0", results[0].Document.ID)
+		assert.Equal(t, []float32{0, 1, 0}, results[0].Document.TitleEmbedding)
+		assert.Equal(t, "en", results[0].Document.Language)
+	})
+
+	t.Run("list and remove", func(t *testing.T) {
+		store := openStore(t, t.TempDir())
+		addDocs(t, store)
+
+		doc, ok := store.GetDocument("policy_chunk_0")
+		require.True(t, ok)
+		assert.Equal(t, "Attribute definitions and subject mappings", doc.Content)
+		assert.Equal(t, []float32{0, 0, 1}, doc.Embedding)
+		_, ok = store.GetDocument("missing")
+		assert.False(t, ok)
+
+		all := store.ListDocuments(DocumentFilter{})
+		require.Len(t, all, 4)
+		assert.Equal(t, DocumentInfo{ID: "kas_chunk_1", Title: "Key Access Service", FilePath: "protocol/kas.md", ChunkIndex: 1, ContentLength: 17}, all[1])
+		assert.Len(t, store.ListDocuments(DocumentFilter{Search: "SUBJECT MAPPINGS"}), 1)
+		page := store.ListDocuments(DocumentFilter{Offset: 1, Limit: 2})
+		require.Len(t, page, 2)
+		assert.Equal(t, "kas_chunk_1", page[0].ID)
+
+		assert.Zero(t, store.RemoveDocuments(DocumentFilter{Search: "kas"}), "a filter without an ID or source file removes nothing")
+		assert.Equal(t, 3, store.RemoveDocuments(DocumentFilter{SourceFile: "protocol/kas.md"}))
+		assert.Equal(t, 1, store.GetDocumentCount())
+		assert.Equal(t, 3, store.EmbeddingDim())
+
+		assert.Equal(t, 1, store.RemoveDocuments(DocumentFilter{ID: "policy_chunk_0"}))
+		assert.Zero(t, store.EmbeddingDim(), "an empty store accepts any dimension")
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openStore(t, dir)
+		addDocs(t, store)
+		require.NoError(t, store.SaveIndex())
+
+		store.Clear()
+		assert.Zero(t, store.GetDocumentCount())
+		assert.Zero(t, store.EmbeddingDim())
+		require.NoError(t, store.AddDocument(Document{ID: "new-model", Embedding: []float32{1, 0}}))
+		require.NoError(t, store.SaveIndex())
+		require.NoError(t, store.Close())
+
+		reopened := openStore(t, dir)
+		assert.Equal(t, 1, reopened.GetDocumentCount())
+		assert.Equal(t, 2, reopened.EmbeddingDim())
+	})
+
+	t.Run("title boost and explain", func(t *testing.T) {
+		store := openStore(t, t.TempDir())
+		query := []float32{1, 0, 0}
+		require.NoError(t, store.AddDocument(Document{ID: "body-only", Embedding: []float32{1, 1, 0}, TitleEmbedding: []float32{0, 0, 1}}))
+		require.NoError(t, store.AddDocument(Document{ID: "title-match", Embedding: []float32{1, 1, 0}, TitleEmbedding: []float32{1, 0, 0}}))
+
+		store.SetTitleBoost(0.3)
+		results, err := store.Search(query, 2)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "title-match", results[0].Document.ID)
+		assert.Greater(t, results[0].Similarity, results[1].Similarity)
+
+		explanation := store.ExplainSimilarity(query, results[0].Document)
+		assert.Equal(t, results[0].Similarity, explanation.Similarity)
+		assert.InDelta(t, 1, explanation.TitleCosine, 0.0001)
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStore("", filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	assert.IsType(t, &VectorStore{}, store)
+
+	store, err = NewStore(StoreSQLite, filepath.Join(dir, "index.db"))
+	require.NoError(t, err)
+	assert.IsType(t, &SQLiteStore{}, store)
+
+	_, err = NewStore("postgres", filepath.Join(dir, "index"))
+	require.ErrorContains(t, err, `unknown store "postgres"`)
+}
+
+func TestSQLiteStoreDiscardsUnsavedChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	store := NewSQLiteStore(path)
+	require.NoError(t, store.LoadIndex())
+	require.NoError(t, store.AddDocument(Document{ID: "saved", Embedding: []float32{1, 0}}))
+	require.NoError(t, store.SaveIndex())
+	require.NoError(t, store.AddDocument(Document{ID: "unsaved", Embedding: []float32{0, 1}}))
+	assert.Equal(t, 2, store.GetDocumentCount(), "unsaved documents are visible before Close")
+	require.NoError(t, store.Close())
+
+	reopened := NewSQLiteStore(path)
+	require.NoError(t, reopened.LoadIndex())
+	defer reopened.Close()
+	docs := reopened.ListDocuments(DocumentFilter{})
+	require.Len(t, docs, 1)
+	assert.Equal(t, "saved", docs[0].ID)
+}
+
+func TestSQLiteStoreAddsParentIDColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	// The documents table as created before parent IDs were recorded
+	_, err = db.Exec(`CREATE TABLE documents (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT, id TEXT NOT NULL, title TEXT NOT NULL, content TEXT NOT NULL,
+		url TEXT NOT NULL, file_path TEXT NOT NULL, chunk_index INTEGER NOT NULL, total_chunks INTEGER NOT NULL,
+		language TEXT NOT NULL, embedding BLOB NOT NULL, title_embedding BLOB);
+		INSERT INTO documents (id, title, content, url, file_path, chunk_index, total_chunks, language, embedding)
+		VALUES ('kas_chunk_0', 'KAS', 'rewrap', '', 'kas.md', 0, 1, '', x'0000803f');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store := NewSQLiteStore(path)
+	require.NoError(t, store.LoadIndex())
+	defer store.Close()
+	doc, ok := store.GetDocument("kas_chunk_0")
+	require.True(t, ok)
+	assert.Empty(t, doc.ParentID)
+	assert.Equal(t, "kas", parentID(doc))
+
+	require.NoError(t, store.AddDocument(Document{ID: "policy_chunk_0", ParentID: "policy", Embedding: []float32{1}}))
+	doc, ok = store.GetDocument("policy_chunk_0")
+	require.True(t, ok)
+	assert.Equal(t, "policy", doc.ParentID)
+}
+
+func TestSQLiteKeywordStoreAddsBoostColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "simple.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	// The simple_documents table as created before boosts were recorded
+	_, err = db.Exec(`CREATE TABLE simple_documents (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT, id TEXT NOT NULL, title TEXT NOT NULL, content TEXT NOT NULL,
+		url TEXT NOT NULL, file_path TEXT NOT NULL, keywords TEXT NOT NULL, bigrams TEXT NOT NULL, language TEXT NOT NULL);
+		CREATE VIRTUAL TABLE simple_terms USING fts5(terms);
+		INSERT INTO simple_documents (id, title, content, url, file_path, keywords, bigrams, language)
+		VALUES ('kas', 'KAS', 'The KAS rewraps keys', '', 'kas.md', '[]', '[]', '');
+		INSERT INTO simple_terms (rowid, terms) VALUES (1, 'kas keys rewraps');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store := NewSQLiteKeywordStore(path)
+	require.NoError(t, store.LoadIndex())
+	defer store.Close()
+	results, err := store.Search("rewraps", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Zero(t, results[0].Document.Boost)
+	assert.Zero(t, store.ExplainScore("rewraps", results[0].Document).SourceBoost)
+}
+
+// openTDFDocs are representative OpenTDF documents for comparing keyword backends
+var openTDFDocs = []SimpleDocument{
+	{ID: "kas", Title: "Key Access Service", FilePath: "components/kas.md",
+		Content: "The key access service rewraps wrapped keys for clients whose entitlements satisfy the policy. KAS keys can be rotated."},
+	{ID: "policy", Title: "Policy", FilePath: "components/policy.md",
+		Content: "Policy is made of attribute definitions, attribute values and subject mappings. The policy service stores namespaces."},
+	{ID: "subject-mappings", Title: "Subject Mappings", FilePath: "components/policy/subject-mappings.md",
+		Content: "Subject mappings entitle entities to attribute values using subject condition sets evaluated against entity claims."},
+	{ID: "authorization", Title: "Authorization", FilePath: "components/authorization.md",
+		Content: "The authorization service decides access by resolving entitlements from subject mappings and comparing them with attribute values."},
+	{ID: "tdf", Title: "TDF Specification", FilePath: "spec/tdf.md",
+		Content: "A TDF wraps the payload key for one or more key access servers and binds the policy to the ciphertext with a policy binding."},
+	{ID: "obligations", Title: "Obligations", FilePath: "components/policy/obligations.md",
+		Content: "Obligations are conditions a client must fulfill before decrypting, such as watermarking. Obligations travel with the policy."},
+}
+
+func TestKeywordStoreConformance(t *testing.T) {
+	backends := map[string]func(dir string) KeywordStore{
+		StoreJSON:   func(dir string) KeywordStore { return NewSimpleRAGStore(filepath.Join(dir, "simple.json")) },
+		StoreSQLite: func(dir string) KeywordStore { return NewSQLiteKeywordStore(filepath.Join(dir, "simple.db")) },
+	}
+	for kind, newStore := range backends {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			store := newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			for _, doc := range openTDFDocs {
+				require.NoError(t, store.AddDocument(doc))
+			}
+			require.NoError(t, store.SaveIndex())
+			require.NoError(t, store.Close())
+
+			store = newStore(dir)
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+			assert.Equal(t, len(openTDFDocs), store.GetDocumentCount())
+
+			results, err := store.Search("what are obligations", 2)
+			require.NoError(t, err)
+			require.NotEmpty(t, results)
+			assert.Equal(t, "obligations", results[0].Document.ID)
+			assert.NotEmpty(t, results[0].Document.Bigrams)
+			assert.Equal(t, results[0].Score, store.ExplainScore("what are obligations", results[0].Document).Score)
+
+			results, err = store.Search("weather on mars", 5)
+			require.NoError(t, err)
+			assert.Empty(t, results)
+
+			docs := store.ListDocuments(DocumentFilter{Search: "SUBJECT MAPPINGS"})
+			assert.Len(t, docs, 3)
+			assert.Equal(t, 2, store.RemoveDocuments(DocumentFilter{SourceFile: "components/policy/obligations.md"})+store.RemoveDocuments(DocumentFilter{ID: "kas"}))
+			assert.Equal(t, len(openTDFDocs)-2, store.GetDocumentCount())
+			results, err = store.Search("obligations", 5)
+			require.NoError(t, err)
+			assert.Empty(t, results, "removed documents are no longer searched")
+
+			store.Clear()
+			assert.Zero(t, store.GetDocumentCount())
+		})
+	}
+}
+
+func TestSQLiteKeywordStoreMatchesInMemoryRanking(t *testing.T) {
+	memory := NewSimpleRAGStore(filepath.Join(t.TempDir(), "simple.json"))
+	fts := NewSQLiteKeywordStore(filepath.Join(t.TempDir(), "simple.db"))
+	require.NoError(t, fts.LoadIndex())
+	defer fts.Close()
+	for _, doc := range openTDFDocs {
+		require.NoError(t, memory.AddDocument(doc))
+		require.NoError(t, fts.AddDocument(doc))
+	}
+
+	for _, query := range []string{
+		"how does the key access service rewrap keys",
+		"subject mappings",
+		"attribute values entitlements",
+		"policy binding",
+		"what are obligations",
+		"authorization decisions",
+		"rotate KAS keys",
+	} {
+		want, err := memory.Search(query, 3)
+		require.NoError(t, err)
+		got, err := fts.Search(query, 3)
+		require.NoError(t, err)
+
+		require.Len(t, got, len(want), query)
+		for i := range want {
+			assert.Equal(t, want[i].Document.ID, got[i].Document.ID, "%q result %d", query, i)
+			assert.InDelta(t, want[i].Score, got[i].Score, 0.0001, "%q result %d", query, i)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tableSeparatorRegex matches the delimiter row under a markdown table header, e.g. "|---|:-:|"
+var tableSeparatorRegex = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+
+// FlattenTables rewrites each markdown table as one line per row of "header: value" pairs,
+// so each cell stays next to the column it belongs to once the pipes and dashes are gone.
+// For example a row "| STANDARD | Any value matches |" under "| Rule | Meaning |" becomes
+// "Rule: STANDARD; Meaning: Any value matches". Empty cells are left out.
+func FlattenTables(content string) string {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		if !isTableHeader(lines, i) {
+			out = append(out, lines[i])
+			continue
+		}
+
+		headers := splitTableRow(lines[i])
+		i += 2 // skip the header and separator rows
+		for ; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+			out = append(out, flattenTableRow(headers, splitTableRow(lines[i])))
+		}
+		i-- // the loop's increment moves past the last row
+	}
+	return strings.Join(out, "\n")
+}
+
+// isTableHeader reports whether lines[i] is a table header row followed by its separator row
+func isTableHeader(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	separator := strings.TrimSpace(lines[i+1])
+	return strings.Contains(separator, "|") && tableSeparatorRegex.MatchString(separator)
+}
+
+// splitTableRow returns the trimmed cells of a table row. Escaped pipes (\|) stay in their cell.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for j := 0; j < len(line); j++ {
+		switch {
+		case line[j] == '\\' && j+1 < len(line) && line[j+1] == '|':
+			cell.WriteByte('|')
+			j++
+		case line[j] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[j])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// flattenTableRow pairs each cell with its column header
+func flattenTableRow(headers, cells []string) string {
+	var pairs []string
+	for j, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		if j < len(headers) && headers[j] != "" {
+			pairs = append(pairs, headers[j]+": "+cell)
+		} else {
+			pairs = append(pairs, cell)
+		}
+	}
+	return strings.Join(pairs, "; ")
+}
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// transcriptTimeFormat is used for the export time and each message's timestamp
+const transcriptTimeFormat = "2006-01-02 15:04:05 MST"
+
+// WriteTranscript writes the conversation as a Markdown transcript with a header per
+// message naming its role and, when recorded, the time it was sent
+func WriteTranscript(w io.Writer, messages []ChatMessage, exportedAt time.Time) error {
+	var b strings.Builder
+
+	b.WriteString("# OpenTDF LLM Chat Transcript\n\n")
+	fmt.Fprintf(&b, "_Exported %s_\n", exportedAt.Format(transcriptTimeFormat))
+
+	for _, msg := range messages {
+		b.WriteString("\n## ")
+		b.WriteString(roleLabel(msg.Role))
+		if !msg.Time.IsZero() {
+			fmt.Fprintf(&b, " (%s)", msg.Time.Format(transcriptTimeFormat))
+		}
+		b.WriteString("\n\n")
+		b.WriteString(strings.TrimSpace(msg.Content))
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// roleLabel returns the header used for a message role in transcripts
+func roleLabel(role string) string {
+	switch role {
+	case "system":
+		return "System"
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "":
+		return "Unknown"
+	default:
+		return strings.ToUpper(role[:1]) + role[1:]
+	}
+}
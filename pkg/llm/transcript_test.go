@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTranscript(t *testing.T) {
+	asked := time.Date(2025, 3, 4, 10, 15, 0, 0, time.UTC)
+	messages := []ChatMessage{
+		{Role: "system", Content: "You are an OpenTDF expert."},
+		{Role: "user", Content: "Why does decrypt return 403?", Time: asked},
+		{Role: "assistant", Content: "Check the subject mappings for your client.", Time: asked.Add(5 * time.Second)},
+		{Role: "user", Content: "How do I list them?", Time: asked.Add(time.Minute)},
+		{Role: "assistant", Content: "Run `otdfctl policy subject-mappings list`.\n", Time: asked.Add(time.Minute + 3*time.Second)},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteTranscript(&b, messages, asked.Add(time.Hour)))
+	transcript := b.String()
+
+	assert.True(t, strings.HasPrefix(transcript, "# OpenTDF LLM Chat Transcript\n\n_Exported 2025-03-04 11:15:00 UTC_\n"))
+
+	// Each turn appears in order under its role header
+	headers := []string{
+		"## System\n\nYou are an OpenTDF expert.\n",
+		"## User (2025-03-04 10:15:00 UTC)\n\nWhy does decrypt return 403?\n",
+		"## Assistant (2025-03-04 10:15:05 UTC)\n\nCheck the subject mappings for your client.\n",
+		"## User (2025-03-04 10:16:00 UTC)\n\nHow do I list them?\n",
+		"## Assistant (2025-03-04 10:16:03 UTC)\n\nRun `otdfctl policy subject-mappings list`.\n",
+	}
+	last := -1
+	for _, header := range headers {
+		idx := strings.Index(transcript, header)
+		require.NotEqual(t, -1, idx, "missing %q", header)
+		assert.Greater(t, idx, last, "%q out of order", header)
+		last = idx
+	}
+}
+
+func TestRoleLabel(t *testing.T) {
+	assert.Equal(t, "System", roleLabel("system"))
+	assert.Equal(t, "User", roleLabel("user"))
+	assert.Equal(t, "Assistant", roleLabel("assistant"))
+	assert.Equal(t, "Tool", roleLabel("tool"))
+	assert.Equal(t, "Unknown", roleLabel(""))
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// IndexProblem is something wrong with a document in an index, found by ValidateIndex
+type IndexProblem struct {
+	DocumentID string `json:"document_id"`
+	// Position is where the document is in the index, counting from 0, which tells apart
+	// documents with the same or no ID
+	Position int    `json:"position"`
+	Problem  string `json:"problem"`
+}
+
+// ValidateIndex checks that every document in store has a unique ID, non-empty content and an
+// embedding of the store's dimension without NaN or infinite values. Such documents are left by
+// corrupt or partially written indexes and otherwise only show up as failed or skewed searches.
+// Problems are returned in index order; an error means the index couldn't be read.
+func ValidateIndex(store Store) ([]IndexProblem, error) {
+	dim := store.EmbeddingDim()
+	var problems []IndexProblem
+	seen := map[string]int{}
+	position := 0
+	err := store.EachDocument(func(doc Document) error {
+		report := func(format string, args ...any) {
+			problems = append(problems, IndexProblem{DocumentID: doc.ID, Position: position, Problem: fmt.Sprintf(format, args...)})
+		}
+
+		if doc.ID == "" {
+			report("empty ID")
+		} else if first, ok := seen[doc.ID]; ok {
+			report("duplicate ID, first used at position %d", first)
+		} else {
+			seen[doc.ID] = position
+		}
+		if strings.TrimSpace(doc.Content) == "" {
+			report("empty content")
+		}
+
+		// An index that didn't record its dimension is held to its first embedding's
+		if dim == 0 {
+			dim = len(doc.Embedding)
+		}
+		if len(doc.Embedding) == 0 {
+			report("no embedding")
+		} else if problem := checkEmbedding(doc.Embedding, dim); problem != "" {
+			report("embedding %s", problem)
+		}
+		if len(doc.TitleEmbedding) > 0 {
+			if problem := checkEmbedding(doc.TitleEmbedding, dim); problem != "" {
+				report("title embedding %s", problem)
+			}
+		}
+
+		position++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIndexLoad, err)
+	}
+	return problems, nil
+}
+
+// checkEmbedding describes what is wrong with an embedding expected to have dim values, or
+// returns "" if nothing is
+func checkEmbedding(embedding []float32, dim int) string {
+	if len(embedding) != dim {
+		return fmt.Sprintf("has %d dimensions, expected %d", len(embedding), dim)
+	}
+	for i, v := range embedding {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Sprintf("has a NaN or infinite value at index %d", i)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateIndex(t *testing.T) {
+	for kind, newStore := range storeBackends {
+		t.Run(kind, func(t *testing.T) {
+			store := newStore(t.TempDir())
+			require.NoError(t, store.LoadIndex())
+			defer store.Close()
+
+			nan := float32(math.NaN())
+			require.NoError(t, store.AddDocument(Document{ID: "kas", Content: "The KAS rewraps keys.", Embedding: []float32{1, 0}}))
+			// AddDocument rejects NaN and infinite values, so they are written straight to the index
+			injectDocument(t, store, Document{ID: "policy", Content: "Policy binds attributes.", Embedding: []float32{0, nan}})
+			require.NoError(t, store.AddDocument(Document{ID: "kas", Content: "A second copy.", Embedding: []float32{0, 1}}))
+			injectDocument(t, store, Document{ID: "blank", Content: "  ", Embedding: []float32{1, 1}, TitleEmbedding: []float32{float32(math.Inf(1)), 0}})
+
+			problems, err := ValidateIndex(store)
+			require.NoError(t, err)
+			assert.Equal(t, []IndexProblem{
+				{DocumentID: "policy", Position: 1, Problem: "embedding has a NaN or infinite value at index 1"},
+				{DocumentID: "kas", Position: 2, Problem: "duplicate ID, first used at position 0"},
+				{DocumentID: "blank", Position: 3, Problem: "empty content"},
+				{DocumentID: "blank", Position: 3, Problem: "title embedding has a NaN or infinite value at index 0"},
+			}, problems)
+
+			store.Clear()
+			require.NoError(t, store.AddDocument(Document{ID: "kas", Content: "The KAS rewraps keys.", Embedding: []float32{1, 0}}))
+			problems, err = ValidateIndex(store)
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+		})
+	}
+}
+
+func TestValidateIndexFile(t *testing.T) {
+	// A hand-edited index whose documents disagree with its recorded dimension
+	path := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 1, "embedding_dim": 3, "documents": [
+		{"id": "a", "content": "Attributes.", "embedding": [1, 0, 0]},
+		{"id": "b", "content": "Subject mappings.", "embedding": [1, 0]},
+		{"id": "", "content": "Orphan."}
+	]}`), 0o644))
+	store := NewVectorStore(path)
+	require.NoError(t, store.LoadIndex())
+
+	problems, err := ValidateIndex(store)
+	require.NoError(t, err)
+	assert.Equal(t, []IndexProblem{
+		{DocumentID: "b", Position: 1, Problem: "embedding has 2 dimensions, expected 3"},
+		{DocumentID: "", Position: 2, Problem: "empty ID"},
+		{DocumentID: "", Position: 2, Problem: "no embedding"},
+	}, problems)
+}